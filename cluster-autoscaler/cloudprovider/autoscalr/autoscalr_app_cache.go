@@ -0,0 +1,156 @@
+/*
+Copyright 2017 AutoScalr
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscalr
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultAppDefCacheTTL is how long a cached app definition is served after AutoScalr
+// stopped answering.
+const defaultAppDefCacheTTL = 5 * time.Minute
+
+// appDefCache keeps the last known app definition of every node group, so that node
+// group methods don't call the AutoScalr API. It is refreshed once per loop; if
+// AutoScalr can't be reached the previous value is kept.
+type appDefCache struct {
+	mutex   sync.Mutex
+	entries map[string]*appDefCacheEntry
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+type appDefCacheEntry struct {
+	// appDef is nil if AutoScalr answered that the app does not exist.
+	appDef *AppDef
+	// missingErr is the answer AutoScalr gave for a missing app.
+	missingErr error
+	// updated is when AutoScalr last answered for the group.
+	updated time.Time
+	// refreshErr is the last failure to reach AutoScalr since updated.
+	refreshErr error
+}
+
+func newAppDefCache(ttl time.Duration) *appDefCache {
+	return &appDefCache{
+		entries: make(map[string]*appDefCacheEntry),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// get returns a copy of the cache entry of the given group and whether there is one.
+func (c *appDefCache) get(groupId string) (appDefCacheEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, found := c.entries[groupId]
+	if !found {
+		return appDefCacheEntry{}, false
+	}
+	result := *entry
+	if entry.appDef != nil {
+		result.appDef = copyAppDef(entry.appDef)
+	}
+	return result, true
+}
+
+// stale returns true if the entry is served from cache past its TTL because AutoScalr
+// can't be reached.
+func (c *appDefCache) stale(entry appDefCacheEntry) bool {
+	return entry.refreshErr != nil && c.now().Sub(entry.updated) > c.ttl
+}
+
+// set stores the app definition AutoScalr answered with.
+func (c *appDefCache) set(groupId string, appDef *AppDef) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries[groupId] = &appDefCacheEntry{
+		appDef:  copyAppDef(appDef),
+		updated: c.now(),
+	}
+}
+
+// setMissing records that AutoScalr answered that the group has no app.
+func (c *appDefCache) setMissing(groupId string, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries[groupId] = &appDefCacheEntry{
+		missingErr: err,
+		updated:    c.now(),
+	}
+}
+
+// setRefreshError records a failure to reach AutoScalr, keeping the previous value.
+func (c *appDefCache) setRefreshError(groupId string, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, found := c.entries[groupId]
+	if !found {
+		entry = &appDefCacheEntry{}
+		c.entries[groupId] = entry
+	}
+	entry.refreshErr = err
+}
+
+// modify applies a successful write to the cached app definition of the given group, if any.
+func (c *appDefCache) modify(groupId string, apply func(appDef *AppDef)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, found := c.entries[groupId]
+	if !found || entry.appDef == nil {
+		return
+	}
+	apply(entry.appDef)
+	entry.updated = c.now()
+	entry.refreshErr = nil
+}
+
+// invalidate drops the entry of the given group, so that it is read again on next use.
+func (c *appDefCache) invalidate(groupId string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, groupId)
+}
+
+// retain drops the entries of all groups but the given ones.
+func (c *appDefCache) retain(groupIds []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	keep := make(map[string]bool, len(groupIds))
+	for _, groupId := range groupIds {
+		keep[groupId] = true
+	}
+	for groupId := range c.entries {
+		if !keep[groupId] {
+			delete(c.entries, groupId)
+		}
+	}
+}
+
+func copyAppDef(appDef *AppDef) *AppDef {
+	result := *appDef
+	result.InstanceTypes = append([]string{}, appDef.InstanceTypes...)
+	return &result
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2017 AutoScalr
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscalr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws/ec2instances"
+)
+
+// capacityModel converts between node counts and the target capacity of an AutoScalr app,
+// which is expressed in vCPUs and may be fulfilled with any of the app's instance types.
+//
+// A running instance counts for its vCPUs. New nodes are requested in units of the
+// representative instance type, the smallest one of the app, weighted by memory: if the
+// representative type has more memory per vCPU than another type of the app, enough vCPUs
+// are requested for the pending memory to arrive whichever type AutoScalr launches.
+type capacityModel struct {
+	representative *ec2instances.InstanceType
+	// unit is the capacity requested for one node of the representative type.
+	unit int
+	// minMemoryMbPerVCpu is the memory per vCPU of the type of the app with the least of it.
+	minMemoryMbPerVCpu float64
+}
+
+// newCapacityModel builds the capacity model of an app with the given instance types.
+// The instance types are expected to be validated with parseInstanceTypes.
+func newCapacityModel(instanceTypeNames []string) (*capacityModel, error) {
+	types := make([]*ec2instances.InstanceType, 0, len(instanceTypeNames))
+	for _, name := range instanceTypeNames {
+		t, found := ec2instances.InstanceTypes[name]
+		if !found || t.VCPU <= 0 {
+			return nil, fmt.Errorf("unknown instance type %q", name)
+		}
+		types = append(types, t)
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no instance types")
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].VCPU != types[j].VCPU {
+			return types[i].VCPU < types[j].VCPU
+		}
+		return types[i].MemoryMb < types[j].MemoryMb
+	})
+	representative := types[0]
+
+	minMemoryMbPerVCpu := float64(types[0].MemoryMb) / float64(types[0].VCPU)
+	for _, t := range types[1:] {
+		if memoryMbPerVCpu := float64(t.MemoryMb) / float64(t.VCPU); memoryMbPerVCpu < minMemoryMbPerVCpu {
+			minMemoryMbPerVCpu = memoryMbPerVCpu
+		}
+	}
+	unit := int(representative.VCPU)
+	if minMemoryMbPerVCpu > 0 {
+		if memoryUnit := int(math.Ceil(float64(representative.MemoryMb)/minMemoryMbPerVCpu - 1e-9)); memoryUnit > unit {
+			unit = memoryUnit
+		}
+	}
+
+	return &capacityModel{
+		representative:     representative,
+		unit:               unit,
+		minMemoryMbPerVCpu: minMemoryMbPerVCpu,
+	}, nil
+}
+
+// instanceCapacity returns the capacity an instance of the given type counts for. Instances
+// of unknown type count as a requested node.
+func (c *capacityModel) instanceCapacity(instanceTypeName string) int {
+	if t, found := ec2instances.InstanceTypes[instanceTypeName]; found && t.VCPU > 0 {
+		return int(t.VCPU)
+	}
+	return c.unit
+}
+
+// nodeCount returns the number of nodes the given target capacity amounts to, given the
+// instance types of the running instances. Capacity not yet running is counted in nodes
+// of the representative type, capacity above the target in average running instances.
+func (c *capacityModel) nodeCount(targetCapacity int, runningInstanceTypes []string) int {
+	runningCapacity := 0
+	for _, instanceTypeName := range runningInstanceTypes {
+		runningCapacity += c.instanceCapacity(instanceTypeName)
+	}
+	count := len(runningInstanceTypes)
+	if targetCapacity >= runningCapacity {
+		count += ceilDiv(targetCapacity-runningCapacity, c.unit)
+	} else {
+		// Capacity to be removed is counted in instances of average running capacity.
+		count -= (runningCapacity - targetCapacity) * count / runningCapacity
+	}
+	if count < 0 {
+		return 0
+	}
+	return count
+}
+
+// capacityForNodes returns the capacity to request for the given number of new nodes.
+func (c *capacityModel) capacityForNodes(nodes int) int {
+	return nodes * c.unit
+}
+
+// capacityForRequests returns the capacity to request for the given CPU and memory: enough
+// vCPUs for both, whichever instance type of the app AutoScalr launches.
+func (c *capacityModel) capacityForRequests(milliCPU, memoryBytes int64) int {
+	capacity := int(ceilDiv64(milliCPU, 1000))
+	if c.minMemoryMbPerVCpu > 0 {
+		memoryMb := float64(memoryBytes) / (1024 * 1024)
+		if memoryCapacity := int(math.Ceil(memoryMb/c.minMemoryMbPerVCpu - 1e-9)); memoryCapacity > capacity {
+			capacity = memoryCapacity
+		}
+	}
+	return capacity
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+func ceilDiv64(a, b int64) int64 {
+	return (a + b - 1) / b
+}
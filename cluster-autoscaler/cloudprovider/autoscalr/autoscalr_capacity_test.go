@@ -0,0 +1,75 @@
+/*
+Copyright 2017 AutoScalr
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscalr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapacityModelMixedSizes(t *testing.T) {
+	model, err := newCapacityModel([]string{"m4.2xlarge", "m4.large"})
+	assert.NoError(t, err)
+	assert.Equal(t, "m4.large", model.representative.InstanceType)
+	assert.Equal(t, 2, model.unit)
+
+	assert.Equal(t, 8, model.instanceCapacity("m4.2xlarge"))
+	assert.Equal(t, 2, model.instanceCapacity("m4.large"))
+	// Instances without a known type count as a requested node.
+	assert.Equal(t, 2, model.instanceCapacity(""))
+
+	// One m4.2xlarge and one m4.large running, target fully running.
+	assert.Equal(t, 2, model.nodeCount(10, []string{"m4.2xlarge", "m4.large"}))
+	// 6 more vCPUs requested: 3 more m4.large nodes.
+	assert.Equal(t, 5, model.nodeCount(16, []string{"m4.2xlarge", "m4.large"}))
+	// Partial units round up.
+	assert.Equal(t, 3, model.nodeCount(11, []string{"m4.2xlarge", "m4.large"}))
+	// Nothing running yet.
+	assert.Equal(t, 4, model.nodeCount(8, []string{}))
+	// Target below running capacity.
+	assert.Equal(t, 1, model.nodeCount(4, []string{"m4.2xlarge", "m4.large"}))
+	assert.Equal(t, 0, model.nodeCount(0, []string{"m4.2xlarge", "m4.large"}))
+
+	assert.Equal(t, 6, model.capacityForNodes(3))
+
+	// Requests round up to whole vCPUs, memory counts at 4096Mb per vCPU.
+	assert.Equal(t, 5, model.capacityForRequests(4800, 3*1024*1024*1024))
+	assert.Equal(t, 3, model.capacityForRequests(1200, 12*1024*1024*1024))
+}
+
+func TestCapacityModelMemoryWeighted(t *testing.T) {
+	// r4.large has about 4 times the memory per vCPU of c4.large, so a node of it is
+	// requested with enough vCPUs for the memory to arrive on c4.large instances.
+	model, err := newCapacityModel([]string{"c4.large", "r4.large"})
+	assert.NoError(t, err)
+	assert.Equal(t, "c4.large", model.representative.InstanceType)
+	assert.Equal(t, 2, model.unit)
+
+	model, err = newCapacityModel([]string{"r4.large", "c4.2xlarge"})
+	assert.NoError(t, err)
+	assert.Equal(t, "r4.large", model.representative.InstanceType)
+	// 15616Mb at 1920Mb per vCPU of c4.2xlarge.
+	assert.Equal(t, 9, model.unit)
+}
+
+func TestCapacityModelUnknownType(t *testing.T) {
+	_, err := newCapacityModel([]string{"m4.large", "z9.huge"})
+	assert.Error(t, err)
+	_, err = newCapacityModel([]string{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2017 AutoScalr
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscalr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultApiUrl is the base url of the AutoScalr app definition API.
+	DefaultApiUrl = "https://app.autoscalr.com/api"
+	// DefaultClusterStateApiUrl is the base url of the AutoScalr cluster state API.
+	DefaultClusterStateApiUrl = "https://api.autoscalr.com/v1"
+	// DefaultApiTimeout is the timeout applied to every AutoScalr API call.
+	DefaultApiTimeout = 20 * time.Second
+
+	appApiPath          = "/autoScalrApp"
+	clusterStateApiPath = "/k8sClusterState"
+)
+
+// Request types understood by the AutoScalr app definition API.
+const (
+	requestTypeCreate                 = "Create"
+	requestTypeGet                    = "Get"
+	requestTypeUpdate                 = "Update"
+	requestTypeDecreaseTargetCapacity = "DecreaseTargetCapacity"
+	requestTypeDeleteAppNodes         = "DeleteAppNodes"
+	requestTypeDelete                 = "Delete"
+)
+
+// AutoScalrClient talks to the AutoScalr API.
+type AutoScalrClient struct {
+	appUrl          string
+	clusterStateUrl string
+	apiKey          string
+	httpClient      *http.Client
+}
+
+// NewAutoScalrClient builds an AutoScalrClient. apiUrl and clusterStateApiUrl are the base urls
+// of the app definition and cluster state APIs respectively.
+func NewAutoScalrClient(apiUrl, clusterStateApiUrl, apiKey string, timeout time.Duration) *AutoScalrClient {
+	return &AutoScalrClient{
+		appUrl:          strings.TrimSuffix(apiUrl, "/") + appApiPath,
+		clusterStateUrl: strings.TrimSuffix(clusterStateApiUrl, "/") + clusterStateApiPath,
+		apiKey:          apiKey,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// Create creates the given app definition. If overwrite is false an already existing app
+// definition is left untouched.
+func (c *AutoScalrClient) Create(appDef *AppDef, overwrite bool) (*AppDef, error) {
+	req := &AutoScalrRequest{
+		AsrToken:          c.apiKey,
+		RequestType:       requestTypeCreate,
+		OverwriteExisting: overwrite,
+		AsrAppDef:         appDef,
+	}
+	app := new(AppDef)
+	if err := c.post(c.appUrl, req, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// Get returns the app definition of the given autoscaling group.
+func (c *AutoScalrClient) Get(asgName, region string) (*AppDef, error) {
+	req := &AutoScalrRequest{
+		AsrToken:    c.apiKey,
+		RequestType: requestTypeGet,
+		AsrAppDef: &AppDef{
+			AutoScalingGroupName: asgName,
+			AwsRegion:            region,
+		},
+	}
+	app := new(AppDef)
+	if err := c.post(c.appUrl, req, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// Update applies the given update to an existing app definition.
+func (c *AutoScalrClient) Update(update *AppDefUpdate) (*AppDef, error) {
+	req := &AutoScalrUpdateRequest{
+		AsrToken:    c.apiKey,
+		RequestType: requestTypeUpdate,
+		AsrAppDef:   update,
+	}
+	app := new(AppDef)
+	if err := c.post(c.appUrl, req, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// DecreaseTargetCapacity lowers the target capacity of an app definition without terminating
+// any of its instances.
+func (c *AutoScalrClient) DecreaseTargetCapacity(decrease *AppDefTargetDecrease) (*AppDef, error) {
+	req := &AutoScalrTargetDecreaseRequest{
+		AsrToken:    c.apiKey,
+		RequestType: requestTypeDecreaseTargetCapacity,
+		AsrAppDef:   decrease,
+	}
+	app := new(AppDef)
+	if err := c.post(c.appUrl, req, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// DeleteAppNodes terminates the given nodes of an app definition.
+func (c *AutoScalrClient) DeleteAppNodes(nodeDelete *AppDefNodeDelete) (*AppDef, error) {
+	req := &AutoScalrNodeDeleteRequest{
+		AsrToken:    c.apiKey,
+		RequestType: requestTypeDeleteAppNodes,
+		AsrAppDef:   nodeDelete,
+	}
+	app := new(AppDef)
+	if err := c.post(c.appUrl, req, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// Delete deletes the app definition of the given autoscaling group.
+func (c *AutoScalrClient) Delete(asgName, region string) error {
+	req := &AutoScalrRequest{
+		AsrToken:    c.apiKey,
+		RequestType: requestTypeDelete,
+		AsrAppDef: &AppDef{
+			AutoScalingGroupName: asgName,
+			AwsRegion:            region,
+		},
+	}
+	return c.post(c.appUrl, req, nil)
+}
+
+// ClusterState sends a cluster state snapshot to AutoScalr and returns the label updates it requested.
+func (c *AutoScalrClient) ClusterState(state *ClusterStateSnapshot) (*SendClusterStateResponse, error) {
+	state.AsrToken = c.apiKey
+	state.AppType = appTypeK8s
+	resp := new(SendClusterStateResponse)
+	if err := c.post(c.clusterStateUrl, state, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// post sends body as json to url and decodes the response into result, unless result is nil.
+// AutoScalr reports errors with status 200 and an error object, so both are checked. Errors
+// AutoScalr answered with are returned as *AsrApiError.
+func (c *AutoScalrClient) post(url string, body interface{}, result interface{}) error {
+	postBody := new(bytes.Buffer)
+	if err := json.NewEncoder(postBody).Encode(body); err != nil {
+		return fmt.Errorf("failed to encode AutoScalr request: %v", err)
+	}
+	resp, err := c.httpClient.Post(url, "application/json", postBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("AutoScalr API returned: %s", resp.Status)
+	}
+
+	respBuf := new(bytes.Buffer)
+	if _, err := respBuf.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("failed to read AutoScalr response: %v", err)
+	}
+	jsonErr := new(AsrApiErrorResponse)
+	if err := json.Unmarshal(respBuf.Bytes(), jsonErr); err == nil && jsonErr.Error != nil && jsonErr.Error.ErrorMessage != "" {
+		return jsonErr.Error
+	}
+	if result == nil || respBuf.Len() == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBuf.Bytes(), result); err != nil {
+		return fmt.Errorf("failed to decode AutoScalr response: %v", err)
+	}
+	return nil
+}
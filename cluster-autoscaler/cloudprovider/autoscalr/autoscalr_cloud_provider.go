@@ -17,91 +17,78 @@ limitations under the License.
 package autoscalr
 
 import (
-<<<<<<< HEAD
-	"os"
-	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"fmt"
+	"net/url"
+
+	"github.com/golang/glog"
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
-	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
-	"k8s.io/autoscaler/cluster-autoscaler/utils/errors"
-	"k8s.io/kubernetes/plugin/pkg/scheduler/schedulercache"
-	"github.com/golang/glog"
-	"k8s.io/autoscaler/cluster-autoscaler/config/dynamic"
-=======
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
-	apiv1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
+	"k8s.io/autoscaler/cluster-autoscaler/config"
 	"k8s.io/autoscaler/cluster-autoscaler/utils/errors"
-	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
-	"k8s.io/apimachinery/pkg/api/resource"
-	"github.com/golang/glog"
-	//"k8s.io/client-go/rest"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
+	kube_util "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
 	kube_client "k8s.io/client-go/kubernetes"
-	"k8s.io/autoscaler/cluster-autoscaler/config"
-	"net/url"
-	"os"
-	"fmt"
-	"time"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
 )
 
 const (
 	// ProviderName is the cloud provider name for AWS
 	ProviderName = "autoscalr"
->>>>>>> cluster-autoscaler-release-1.2
 )
 
 // autoScalrCloudProvider implements CloudProvider interface.
 type autoScalrCloudProvider struct {
-	autoScalrManager	*AutoScalrManager
-	awsProvider			cloudprovider.CloudProvider
+	autoScalrManager *AutoScalrManager
+	awsProvider      cloudprovider.CloudProvider
+	// listerRegistry holds the node and deployment listers shared with the autoscaler.
+	listerRegistry kube_util.ListerRegistry
+	// kubeClient is built on first use, to label nodes with their pay model.
+	kubeClient kube_client.Interface
+	// clusterState sends the cluster state to AutoScalr, it is started by the first Refresh.
+	clusterState *clusterStateReporter
+	stopCh       chan struct{}
 }
 
-<<<<<<< HEAD
-func BuildAutoScalrCloudProvider(autoScalrManager *AutoScalrManager, discoveryOpts cloudprovider.NodeGroupDiscoveryOptions, resourceLimiter *cloudprovider.ResourceLimiter, awsManager *aws.AwsManager) (*autoScalrCloudProvider, error) {
-	awsProv, err := aws.BuildAwsCloudProvider(awsManager, discoveryOpts, resourceLimiter)
+func BuildAutoScalrCloudProvider(autoScalrManager *AutoScalrManager, resourceLimiter *cloudprovider.ResourceLimiter, awsManager *aws.AwsManager,
+	listerRegistry kube_util.ListerRegistry) (*autoScalrCloudProvider, error) {
+	awsProv, err := aws.BuildAwsCloudProvider(awsManager, resourceLimiter)
 	if err != nil {
 		glog.V(0).Infof("Received error from BuildAwsCloudProvider: %s", err.Error())
 	} else {
-		for _, spec := range discoveryOpts.NodeGroupSpecs {
-			specObj, err := dynamic.SpecFromString(spec, true)
-			if err != nil {
-				glog.V(0).Infof("Received error from SpecFromString: %s", err.Error())
-			} else {
-				// Record ASG in env variable
-				os.Setenv("AUTOSCALING_GROUP_NAME", specObj.Name)
+		configuredGroups := make(map[string]bool)
+		for _, nodeGroup := range awsProv.NodeGroups() {
+			configuredGroups[nodeGroup.Id()] = true
+			if err := autoScalrManager.appDefCreate(nodeGroup.Id()); err != nil {
+				glog.Errorf("Failed to create AutoScalr app for %s: %v", nodeGroup.Id(), err)
+			}
+		}
+		for groupId := range autoScalrManager.groupAppDefs {
+			if !configuredGroups[groupId] {
+				glog.Warningf("autoscalr-group %q does not match any node group", groupId)
 			}
 		}
-=======
-func BuildAutoScalrCloudProvider(autoScalrManager *AutoScalrManager, resourceLimiter *cloudprovider.ResourceLimiter, awsManager *aws.AwsManager) (*autoScalrCloudProvider, error) {
-	awsProv, err := aws.BuildAwsCloudProvider(awsManager, resourceLimiter)
-	if err != nil {
-		glog.V(0).Infof("Received error from BuildAwsCloudProvider: %s", err.Error())
-	} else {
->>>>>>> cluster-autoscaler-release-1.2
-		createAsrAppIfNeeded()
 		provider := &autoScalrCloudProvider{
 			autoScalrManager: autoScalrManager,
 			awsProvider:      awsProv,
+			listerRegistry:   listerRegistry,
+			stopCh:           make(chan struct{}),
 		}
 		return provider, err
 	}
 	return nil, err
 }
 
-func createAsrAppIfNeeded() error {
-	err := appDefCreate()
-	return err
-}
-
-<<<<<<< HEAD
-=======
 // Cleanup stops the go routine that is handling the current view of the ASGs in the form of a cache
 func (asrProvider *autoScalrCloudProvider) Cleanup() error {
+	if asrProvider.stopCh != nil {
+		close(asrProvider.stopCh)
+	}
 	return nil
 }
 
->>>>>>> cluster-autoscaler-release-1.2
 // Name returns name of the cloud provider.
 func (asrProvider *autoScalrCloudProvider) Name() string {
 	return "autoscalr"
@@ -112,7 +99,7 @@ func (asrProvider *autoScalrCloudProvider) NodeGroups() []cloudprovider.NodeGrou
 	awsNGs := asrProvider.awsProvider.NodeGroups()
 	asrNGs := make([]cloudprovider.NodeGroup, 0, len(awsNGs))
 	for _, nodeGrp := range awsNGs {
-		asrNGs = append(asrNGs, BuildAutoScalrNodeGroup(nodeGrp))
+		asrNGs = append(asrNGs, BuildAutoScalrNodeGroup(nodeGrp, asrProvider.autoScalrManager))
 	}
 	return asrNGs
 }
@@ -124,7 +111,7 @@ func (asrProvider *autoScalrCloudProvider) NodeGroupForNode(node *apiv1.Node) (c
 		return awsNg, err
 	} else {
 		// wrap in asrNode
-		return BuildAutoScalrNodeGroup(awsNg), err
+		return BuildAutoScalrNodeGroup(awsNg, asrProvider.autoScalrManager), err
 	}
 }
 
@@ -140,20 +127,9 @@ func (asrProvider *autoScalrCloudProvider) GetAvailableMachineTypes() ([]string,
 
 // NewNodeGroup builds a theoretical node group based on the node definition provided. The node group is not automatically
 // created on the cloud provider side. The node group is not returned by NodeGroups() until it is created.
-<<<<<<< HEAD
-func (asrProvider *autoScalrCloudProvider) NewNodeGroup(machineType string, labels map[string]string, extraResources map[string]resource.Quantity) (cloudprovider.NodeGroup, error) {
-	awsNg, err := asrProvider.awsProvider.NewNodeGroup(machineType, labels, extraResources)
-	if err != nil {
-		return awsNg, err
-	} else {
-		// wrap in asrNode
-		return BuildAutoScalrNodeGroup(awsNg), err
-	}
-=======
 func (asrProvider *autoScalrCloudProvider) NewNodeGroup(machineType string, labels map[string]string, systemLabels map[string]string,
 	extraResources map[string]resource.Quantity) (cloudprovider.NodeGroup, error) {
-	return asrProvider.awsProvider.NewNodeGroup(machineType, labels, systemLabels, extraResources )
->>>>>>> cluster-autoscaler-release-1.2
+	return asrProvider.awsProvider.NewNodeGroup(machineType, labels, systemLabels, extraResources)
 }
 
 // GetResourceLimiter returns struct containing limits (max, min) for resources (cores, memory etc.).
@@ -161,108 +137,96 @@ func (asrProvider *autoScalrCloudProvider) GetResourceLimiter() (*cloudprovider.
 	return asrProvider.awsProvider.GetResourceLimiter()
 }
 
-<<<<<<< HEAD
-// Cleanup stops the go routine that is handling the current view of the ASGs in the form of a cache
-func (asrProvider *autoScalrCloudProvider) Cleanup() error {
-	return asrProvider.awsProvider.Cleanup()
+// SpotLimits returns the spot limits of the app definition of the node group. Scale down keeps
+// the nodes of the node group within them.
+func (asrProvider *autoScalrCloudProvider) SpotLimits(nodeGroup cloudprovider.NodeGroup) (maxSpotPercentTotal, maxSpotPercentOneMarket float64) {
+	appDef := asrProvider.autoScalrManager.appDefForGroup(nodeGroup.Id())
+	return float64(appDef.MaxSpotPercentTotal), float64(appDef.MaxSpotPercentOneMarket)
 }
-=======
-var launchTime = time.Now()
->>>>>>> cluster-autoscaler-release-1.2
 
 // Refresh is called before every main loop and can be used to dynamically update cloud provider state.
 // In particular the list of node groups returned by NodeGroups can change as a result of CloudProvider.Refresh().
 func (asrProvider *autoScalrCloudProvider) Refresh() error {
-<<<<<<< HEAD
-	return asrProvider.awsProvider.Refresh()
-=======
 	err := asrProvider.awsProvider.Refresh()
 	if err != nil {
 		glog.Errorf("Failed to refresh cloud provider config: %v", err)
 		return err
 	}
-	var execTime = time.Now()
-	var elapsedTime = execTime.Sub(launchTime)
-	glog.V(4).Info("Running for ", elapsedTime.Hours(), " hours" )
-	if elapsedTime.Hours() > 24 {
-		glog.V(4).Info("Running over 24 hours, exiting to force restart.")
-		os.Exit(0)
+	awsNodeGroups := asrProvider.awsProvider.NodeGroups()
+	groupIds := make([]string, 0, len(awsNodeGroups))
+	for _, nodeGroup := range awsNodeGroups {
+		groupIds = append(groupIds, nodeGroup.Id())
 	}
+	asrProvider.autoScalrManager.Refresh(groupIds)
 
-	depFlag := os.Getenv("ANAYLZE_DEPLOYMENTS")
-	if depFlag != "false" {
-		err = asrProvider.CollectClusterState()
-	}
-	return err
-}
-
-func (asrProvider *autoScalrCloudProvider) CollectClusterState() error {
-	glog.V(4).Info("Starting CollectClusterState.")
-	kubeClient := asrProvider.createKubeClient()
-	nodeList, err := kubeClient.CoreV1().Nodes().List(metav1.ListOptions{})
-
-	//allNodes, err := allNodeLister.List()
+	nodes, err := asrProvider.listerRegistry.AllNodeLister().List()
 	if err != nil {
 		glog.Errorf("Failed to list all nodes: %v", err)
+		return err
 	}
-	// print out node names
-	for _, aNode := range nodeList.Items {
-		glog.V(4).Info("Node: ", aNode.Name, "Alloc mem: ", aNode.Status.Allocatable.Memory())
-	}
+	asrProvider.autoScalrManager.UpdateNodeInstanceTypes(nodes)
 
-	//podList, err := kubeClient.CoreV1().Pods(apiv1.NamespaceAll).List(metav1.ListOptions{})
-	//if err != nil {
-	//	glog.Errorf("Failed to list all pods: %v", err)
-	//}
-	// print out pods names
-	//for _, aPod := range podList.Items {
-	//	glog.V(4).Info("Pod: ", aPod.Name)
-	//}
-	// not needed now
-
-	servList, err := kubeClient.AppsV1().Deployments(apiv1.NamespaceAll).List(metav1.ListOptions{})
-	if err != nil {
-		glog.Errorf("Failed to list all services: %v", err)
-	}
-	// print out service names
-	for _, aServ := range servList.Items {
-		glog.V(4).Info("Service: ", aServ.Name, " replicas: ", aServ.Status.Replicas)
+	// AutoScalr associates the cluster state with the app of the first node group.
+	clusterAsgName := ""
+	if len(groupIds) > 0 {
+		clusterAsgName = groupIds[0]
 	}
-	state := &AutoScalrClusterState{
-		AsrToken:    os.Getenv("AUTOSCALR_API_KEY"),
-		AwsRegion:    os.Getenv("AWS_REGION"),
-		AutoScalingGroupName:    os.Getenv("AUTOSCALING_GROUP_NAME"),
-		Deployments: servList.Items,
-		Nodes: nodeList.Items,
+	if asrProvider.clusterState != nil {
+		asrProvider.clusterState.setGroupName(clusterAsgName)
+	} else if asrProvider.autoScalrManager.clusterStateInterval > 0 {
+		kubeClient, err := asrProvider.getKubeClient()
+		if err != nil {
+			glog.Errorf("Failed to build Kubernetes client: %v", err)
+			return err
+		}
+		asrProvider.startClusterStateReporter(kubeClient, clusterAsgName)
 	}
-	rc, err := SendClusterState(state, kubeClient)
-	glog.V(4).Info("AutoScalrClusterState returned: ", rc)
-	if err != nil {
-		glog.Errorf("Error in SendClusterState: %v", err)
-	} else {
-		err = fmt.Errorf("CollectClusterState.Completed")
+	return nil
+}
+
+// startClusterStateReporter starts sending the cluster state to AutoScalr in the background.
+func (asrProvider *autoScalrCloudProvider) startClusterStateReporter(kubeClient kube_client.Interface, clusterAsgName string) {
+	if asrProvider.stopCh == nil {
+		asrProvider.stopCh = make(chan struct{})
 	}
-	return err
+	asrProvider.clusterState = newClusterStateReporter(asrProvider.autoScalrManager, asrProvider.listerRegistry.AllNodeLister(),
+		asrProvider.listerRegistry.DeploymentLister(), kubeClient)
+	asrProvider.clusterState.setGroupName(clusterAsgName)
+	glog.V(1).Infof("Sending cluster state to AutoScalr every %v", asrProvider.autoScalrManager.clusterStateInterval)
+	go asrProvider.clusterState.run(asrProvider.stopCh)
 }
 
-func (asrProvider *autoScalrCloudProvider) createKubeClient() kube_client.Interface {
+// getKubeClient returns the Kubernetes client of the provider, building it on first use.
+func (asrProvider *autoScalrCloudProvider) getKubeClient() (kube_client.Interface, error) {
+	if asrProvider.kubeClient != nil {
+		return asrProvider.kubeClient, nil
+	}
 	url, err := url.Parse("")
+	if err != nil {
+		return nil, err
+	}
 	kubeConfig, err := config.GetKubeClientConfig(url)
 	if err != nil {
-		glog.Fatalf("Failed to build Kubernetes client configuration: %v", err)
+		return nil, fmt.Errorf("failed to build Kubernetes client configuration: %v", err)
+	}
+	kubeClient, err := kube_client.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
 	}
-	return kube_client.NewForConfigOrDie(kubeConfig)
->>>>>>> cluster-autoscaler-release-1.2
+	asrProvider.kubeClient = kubeClient
+	return kubeClient, nil
 }
 
 // asrNodeGroup implements NodeGroup interface, defaulting to pass through to awsNodeGroup object
 type asrNodeGroup struct {
-	awsNodeGroup			cloudprovider.NodeGroup
+	awsNodeGroup     cloudprovider.NodeGroup
+	autoScalrManager *AutoScalrManager
 }
 
-func BuildAutoScalrNodeGroup(aNode cloudprovider.NodeGroup) (cloudprovider.NodeGroup) {
+func BuildAutoScalrNodeGroup(aNode cloudprovider.NodeGroup, autoScalrManager *AutoScalrManager) cloudprovider.NodeGroup {
 	asrNG := &asrNodeGroup{
-		awsNodeGroup: aNode,
+		awsNodeGroup:     aNode,
+		autoScalrManager: autoScalrManager,
 	}
 	return asrNG
 }
@@ -277,62 +241,164 @@ func (asrNG *asrNodeGroup) MinSize() int {
 	return asrNG.awsNodeGroup.MinSize()
 }
 
+// TargetSize returns the number of nodes the target capacity of the app amounts to. Running
+// instances count as one node each; capacity not running yet is counted in nodes of the
+// representative instance type.
 func (asrNG *asrNodeGroup) TargetSize() (int, error) {
-	//glog.V(0).Infof("AsrNodeGroup::TargetSize")
-	app, err := appDefRead()
-	tSize := 0
+	app, err := asrNG.autoScalrManager.appDefRead(asrNG.Id())
 	if err != nil {
 		glog.V(0).Infof("Received error from appDefRead: %s", err.Error())
+		return 0, err
 	}
-	if app != nil {
-		numVcpu := numVCpusBaseType()
-		tSize = app.TargetCapacity / numVcpu
+	model, err := asrNG.autoScalrManager.capacityModel(asrNG.Id())
+	if err != nil {
+		return 0, err
+	}
+	instances, err := asrNG.awsNodeGroup.Nodes()
+	if err != nil {
+		return 0, err
 	}
-	glog.V(0).Infof("Returning TargetSize: %d",tSize)
-	return tSize, err
+	tSize := model.nodeCount(app.TargetCapacity, asrNG.autoScalrManager.instanceTypesOf(instances))
+	glog.V(4).Infof("Returning TargetSize: %d (target capacity %d)", tSize, app.TargetCapacity)
+	return tSize, nil
 }
 
+// IncreaseSize raises the target capacity of the app by delta nodes of the representative
+// instance type. Scale-ups for pending pods go through IncreaseSizeForPods.
 func (asrNG *asrNodeGroup) IncreaseSize(delta int) error {
-	glog.V(0).Infof("AsrNodeGroup::IncreaseSize delta: %v",delta)
-	currSize, err := asrNG.TargetSize()
+	glog.V(4).Infof("AsrNodeGroup::IncreaseSize delta: %v", delta)
+	if delta <= 0 {
+		return fmt.Errorf("size increase must be positive")
+	}
+	model, err := asrNG.autoScalrManager.capacityModel(asrNG.Id())
 	if err != nil {
-		glog.V(0).Infof("TargetSize returned error: %v", err.Error())
-	} else {
-		//glog.V(0).Infof("currSize: %v",currSize)
-		numVcpu := numVCpusBaseType()
-		//glog.V(0).Infof("numVcpu: %v",numVcpu)
-		newTarget := (currSize + delta) * numVcpu
-		glog.V(0).Infof("new vCpu target: %v",newTarget)
-		err = appDefUpdate(newTarget)
+		return err
 	}
-	return err
+	return asrNG.increaseTargetCapacity(model.capacityForNodes(delta))
+}
+
+// IncreaseSizeForPods raises the target capacity of the app by the CPU and memory the pods
+// request, plus the reservations and the pods of the template on each of the delta new nodes.
+// delta is the number of nodes of the representative type the pods were estimated to need, so
+// no more capacity than IncreaseSize(delta) is requested.
+func (asrNG *asrNodeGroup) IncreaseSizeForPods(delta int, pods []*apiv1.Pod) error {
+	glog.V(4).Infof("AsrNodeGroup::IncreaseSizeForPods delta: %v, pods: %v", delta, len(pods))
+	if delta <= 0 {
+		return fmt.Errorf("size increase must be positive")
+	}
+	model, err := asrNG.autoScalrManager.capacityModel(asrNG.Id())
+	if err != nil {
+		return err
+	}
+	template, err := asrNG.TemplateNodeInfo()
+	if err != nil {
+		return err
+	}
+	requested := schedulercache.NewNodeInfo(pods...).RequestedResource()
+	perNode := template.RequestedResource()
+	templateNode := template.Node()
+	milliCPU := requested.MilliCPU + int64(delta)*(perNode.MilliCPU+reservedQuantity(templateNode, apiv1.ResourceCPU).MilliValue())
+	memory := requested.Memory + int64(delta)*(perNode.Memory+reservedQuantity(templateNode, apiv1.ResourceMemory).Value())
+
+	capacity := model.capacityForNodes(delta)
+	if podsCapacity := model.capacityForRequests(milliCPU, memory); podsCapacity > 0 && podsCapacity < capacity {
+		capacity = podsCapacity
+	}
+	return asrNG.increaseTargetCapacity(capacity)
+}
+
+// reservedQuantity returns the quantity of the resource the node reserves for the system.
+func reservedQuantity(node *apiv1.Node, name apiv1.ResourceName) resource.Quantity {
+	capacity, found := node.Status.Capacity[name]
+	if !found {
+		return resource.Quantity{}
+	}
+	allocatable, found := node.Status.Allocatable[name]
+	if !found {
+		return resource.Quantity{}
+	}
+	reserved := capacity.DeepCopy()
+	reserved.Sub(allocatable)
+	return reserved
+}
+
+// increaseTargetCapacity raises the target capacity of the app by the given number of vCPUs.
+func (asrNG *asrNodeGroup) increaseTargetCapacity(capacity int) error {
+	app, err := asrNG.autoScalrManager.appDefRead(asrNG.Id())
+	if err != nil {
+		glog.V(0).Infof("Received error from appDefRead: %v", err.Error())
+		return err
+	}
+	newTarget := app.TargetCapacity + capacity
+	glog.V(4).Infof("new vCpu target: %v", newTarget)
+	return asrNG.autoScalrManager.appDefUpdate(asrNG.Id(), newTarget)
 }
 
+// DeleteNodes terminates the given nodes and lowers the target capacity of the app by
+// their capacity.
 func (asrNG *asrNodeGroup) DeleteNodes(nodes []*apiv1.Node) error {
-	//glog.V(0).Infof("AsrNodeGroup::DeleteNodes")
-	numNodesToDelete := len(nodes)
-	glog.V(0).Infof("Deleting %v nodes",numNodesToDelete)
+	glog.V(0).Infof("Deleting %v nodes", len(nodes))
+	model, err := asrNG.autoScalrManager.capacityModel(asrNG.Id())
+	if err != nil {
+		return err
+	}
 
 	nodeIds := make([]string, 0, len(nodes))
+	deltaVcpu := 0
 	for _, node := range nodes {
-		provId := node.Spec.ProviderID
-		instId := InstanceIdFromProviderId(provId)
-		glog.V(0).Infof("Deleting instance id: %v",instId)
-		//glog.V(0).Infof("node.Spec: %v",node.Spec.String())
+		instId := InstanceIdFromProviderId(node.Spec.ProviderID)
+		glog.V(4).Infof("Deleting instance id: %v", instId)
 		nodeIds = append(nodeIds, instId)
+		deltaVcpu += model.instanceCapacity(node.Labels[kubeletapis.LabelInstanceType])
 	}
-	err := appDefDeleteNodes(0, nodeIds)
+	err = asrNG.autoScalrManager.appDefDeleteNodes(asrNG.Id(), deltaVcpu, nodeIds)
 	if err != nil {
 		glog.V(0).Infof("Received error from appDefDeleteNodes: %s", err.Error())
 	}
 	return err
 }
 
+// DecreaseTargetSize lowers the target capacity of the app by delta nodes that were requested
+// but never registered. The capacity of running instances is never given up, so the size can't
+// go below the number of instances of the autoscaling group.
 func (asrNG *asrNodeGroup) DecreaseTargetSize(delta int) error {
-	//glog.V(0).Infof("AsrNodeGroup::DecreaseTargetSize")
-	return nil
-	//return cloudprovider.ErrNotImplemented
-	//return asrNG.awsNodeGroup.DecreaseTargetSize(delta)
+	glog.V(4).Infof("AsrNodeGroup::DecreaseTargetSize delta: %v", delta)
+	if delta >= 0 {
+		return fmt.Errorf("size decrease must be negative")
+	}
+	app, err := asrNG.autoScalrManager.appDefRead(asrNG.Id())
+	if err != nil {
+		glog.V(0).Infof("Received error from appDefRead: %v", err.Error())
+		return err
+	}
+	model, err := asrNG.autoScalrManager.capacityModel(asrNG.Id())
+	if err != nil {
+		return err
+	}
+	instances, err := asrNG.awsNodeGroup.Nodes()
+	if err != nil {
+		return err
+	}
+	instanceTypes := asrNG.autoScalrManager.instanceTypesOf(instances)
+	size := model.nodeCount(app.TargetCapacity, instanceTypes)
+	if size+delta < len(instances) {
+		return fmt.Errorf("attempt to delete existing nodes targetSize:%d delta:%d existingNodes: %d",
+			size, delta, len(instances))
+	}
+
+	runningCapacity := 0
+	for _, instanceType := range instanceTypes {
+		runningCapacity += model.instanceCapacity(instanceType)
+	}
+	deltaVcpu := model.capacityForNodes(-delta)
+	if pending := app.TargetCapacity - runningCapacity; deltaVcpu > pending {
+		deltaVcpu = pending
+	}
+	if deltaVcpu <= 0 {
+		return nil
+	}
+	glog.V(4).Infof("new vCpu target: %v", app.TargetCapacity-deltaVcpu)
+	return asrNG.autoScalrManager.appDefDecreaseTarget(asrNG.Id(), deltaVcpu)
 }
 
 func (asrNG *asrNodeGroup) Id() string {
@@ -345,43 +411,81 @@ func (asrNG *asrNodeGroup) Debug() string {
 }
 
 func (asrNG *asrNodeGroup) Nodes() ([]string, error) {
-<<<<<<< HEAD
-	//glog.V(0).Infof("AsrNodeGroup::Nodes")
-=======
-	glog.V(0).Infof("AsrNodeGroup::Nodes")
->>>>>>> cluster-autoscaler-release-1.2
+	glog.V(4).Infof("AsrNodeGroup::Nodes")
 	return asrNG.awsNodeGroup.Nodes()
 }
 
-func (asrNG *asrNodeGroup) TemplateNodeInfo() (*schedulercache.NodeInfo, error) {
-	//glog.V(0).Infof("AsrNodeGroup::TemplateNodeInfo")
-	return asrNG.awsNodeGroup.TemplateNodeInfo()
+// instanceTypeTemplater is implemented by node groups building templates of any instance type,
+// as aws node groups do.
+type instanceTypeTemplater interface {
+	TemplateNodeInfoForInstanceType(instanceType string) (*schedulercache.NodeInfo, error)
 }
 
-func (asrNG *asrNodeGroup) Exist() bool {
-	//glog.V(0).Infof("AsrNodeGroup::Exist")
-	app, err := appDefRead()
-	exists := false
+// TemplateNodeInfo returns a node template for this node group, built as if its next node were of
+// the representative instance type of the app. Other node groups only get the resources of the
+// representative type, with the same reservations as their own template.
+func (asrNG *asrNodeGroup) TemplateNodeInfo() (*schedulercache.NodeInfo, error) {
+	model, err := asrNG.autoScalrManager.capacityModel(asrNG.Id())
 	if err != nil {
-		glog.V(0).Infof("Received error from appDefRead: %s", err.Error())
+		return nil, err
+	}
+	representative := model.representative
+	if templater, ok := asrNG.awsNodeGroup.(instanceTypeTemplater); ok {
+		return templater.TemplateNodeInfoForInstanceType(representative.InstanceType)
+	}
+
+	awsNodeInfo, err := asrNG.awsNodeGroup.TemplateNodeInfo()
+	if err != nil {
+		return nil, err
+	}
+	node := awsNodeInfo.Node().DeepCopy()
+	capacity := apiv1.ResourceList{}
+	for name, quantity := range node.Status.Capacity {
+		capacity[name] = quantity
+	}
+	capacity[apiv1.ResourceCPU] = *resource.NewQuantity(representative.VCPU, resource.DecimalSI)
+	capacity[apiv1.ResourceMemory] = *resource.NewQuantity(representative.MemoryMb*1024*1024, resource.DecimalSI)
+	capacity[gpu.ResourceNvidiaGPU] = *resource.NewQuantity(representative.GPU, resource.DecimalSI)
+	allocatable := apiv1.ResourceList{}
+	for name, quantity := range capacity {
+		value := quantity.DeepCopy()
+		if templateCapacity, found := node.Status.Capacity[name]; found {
+			if templateAllocatable, found := node.Status.Allocatable[name]; found {
+				// The template reserves the difference for the system.
+				value.Sub(templateCapacity)
+				value.Add(templateAllocatable)
+			}
+		}
+		if value.Sign() < 0 {
+			value = *resource.NewQuantity(0, quantity.Format)
+		}
+		allocatable[name] = value
 	}
-	if app != nil {
-		exists = true
+	node.Status.Capacity = capacity
+	node.Status.Allocatable = allocatable
+	if node.Labels == nil {
+		node.Labels = make(map[string]string)
 	}
-	//glog.V(0).Infof("Returning exists: %v",exists)
-	return exists
-	//return asrNG.awsNodeGroup.Exist()
+	node.Labels[kubeletapis.LabelInstanceType] = representative.InstanceType
+
+	nodeInfo := schedulercache.NewNodeInfo(cloudprovider.BuildKubeProxy(asrNG.Id()))
+	nodeInfo.SetNode(node)
+	return nodeInfo, nil
+}
+
+func (asrNG *asrNodeGroup) Exist() bool {
+	return asrNG.autoScalrManager.appDefExists(asrNG.Id())
 }
 
 func (asrNG *asrNodeGroup) Create() error {
-	glog.V(0).Infof("AsrNodeGroup::Create")
-	return appDefCreate()
+	glog.V(4).Infof("AsrNodeGroup::Create")
+	return asrNG.autoScalrManager.appDefCreate(asrNG.Id())
 	//return asrNG.awsNodeGroup.Create()
 }
 
 func (asrNG *asrNodeGroup) Delete() error {
-	glog.V(0).Infof("AsrNodeGroup::Delete")
-	return appDefDelete()
+	glog.V(4).Infof("AsrNodeGroup::Delete")
+	return asrNG.autoScalrManager.appDefDelete(asrNG.Id())
 	//return asrNG.awsNodeGroup.Delete()
 }
 
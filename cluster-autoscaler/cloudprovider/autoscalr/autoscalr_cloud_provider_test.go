@@ -17,17 +17,22 @@ limitations under the License.
 package autoscalr
 
 import (
-	"testing"
 	"os"
+	"strings"
+	"testing"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-<<<<<<< HEAD
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
-=======
-	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
-	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
->>>>>>> cluster-autoscaler-release-1.2
+	testprovider "k8s.io/autoscaler/cluster-autoscaler/cloudprovider/test"
+	kube_util "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
+	. "k8s.io/autoscaler/cluster-autoscaler/utils/test"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
 )
 
 func SetEnvTestCase1() {
@@ -44,21 +49,13 @@ func SetEnvTestCase1() {
 	os.Setenv("TARGET_CAPACITY_INSTANCES", "2")
 	os.Setenv("TARGET_SPARE_MEMORY_PERCENT", "20")
 }
-<<<<<<< HEAD
-func getDiscoveryOptionsTestCase1() cloudprovider.NodeGroupDiscoveryOptions {
-	return cloudprovider.NodeGroupDiscoveryOptions{
-		NodeGroupSpecs: []string{"1:6:asgName"},
-		NodeGroupAutoDiscoverySpec: "",
-	}
-}
-=======
+
 //func getDiscoveryOptionsTestCase1() cloudprovider.NodeGroupDiscoveryOptions {
 //	return cloudprovider.NodeGroupDiscoveryOptions{
 //		NodeGroupSpecs: []string{"1:6:asgName"},
 //		NodeGroupAutoDiscoverySpec: "",
 //	}
 //}
->>>>>>> cluster-autoscaler-release-1.2
 
 func TestEnvSetCorrectly(t *testing.T) {
 	SetEnvTestCase1()
@@ -67,30 +64,22 @@ func TestEnvSetCorrectly(t *testing.T) {
 
 func TestBuildAutoScalrCloudProvider(t *testing.T) {
 	SetEnvTestCase1()
-<<<<<<< HEAD
-	asrMgr, err := CreateAutoScalrManager(nil)
-	awsMgr, err := aws.CreateAwsManager(nil)
-	assert.NoError(t, err)
-	discOpts := getDiscoveryOptionsTestCase1()
-	resourceLimiter := cloudprovider.NewResourceLimiter(
-		map[string]int64{cloudprovider.ResourceNameCores: 1, cloudprovider.ResourceNameMemory: 10000000},
-		map[string]int64{cloudprovider.ResourceNameCores: 10, cloudprovider.ResourceNameMemory: 100000000})
-	asrCloudProv, err := BuildAutoScalrCloudProvider(asrMgr, discOpts, resourceLimiter, awsMgr)
-=======
 	do := cloudprovider.NodeGroupDiscoveryOptions{}
 	resourceLimiter := cloudprovider.NewResourceLimiter(
 		map[string]int64{cloudprovider.ResourceNameCores: 1, cloudprovider.ResourceNameMemory: 10000000},
 		map[string]int64{cloudprovider.ResourceNameCores: 10, cloudprovider.ResourceNameMemory: 100000000})
 
-	asrMgr, err := CreateAutoScalrManager(nil, do)
+	server := NewFakeAutoScalrServer()
+	defer server.Close()
+	asrMgr, err := createAutoScalrManagerInternal(nil, do, server.Client("myApiKey"))
+	assert.NoError(t, err)
 	awsMgr, err := aws.CreateAwsManager(nil, do)
 	assert.NoError(t, err)
 	//rl := nil
 	//resourceLimiter := cloudprovider.NewResourceLimiter(
 	//	map[string]int64{cloudprovider.ResourceNameCores: 1, cloudprovider.ResourceNameMemory: 10000000},
 	//	map[string]int64{cloudprovider.ResourceNameCores: 10, cloudprovider.ResourceNameMemory: 100000000})
-	asrCloudProv, err := BuildAutoScalrCloudProvider(asrMgr, resourceLimiter, awsMgr)
->>>>>>> cluster-autoscaler-release-1.2
+	asrCloudProv, err := BuildAutoScalrCloudProvider(asrMgr, resourceLimiter, awsMgr, nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, asrCloudProv)
 	assert.Equal(t, asrCloudProv.Name(), "autoscalr")
@@ -100,11 +89,315 @@ func TestBuildAutoScalrCloudProvider(t *testing.T) {
 	assert.Equal(t, ng1.MaxSize(), 6)
 	assert.Equal(t, ng1.MinSize(), 1)
 	assert.Equal(t, ng1.Id(), "asgName")
-<<<<<<< HEAD
-	assert.True(t, ng1.Exist())
-=======
 	//assert.True(t, ng1.Exist())
->>>>>>> cluster-autoscaler-release-1.2
+}
+
+func TestNodeGroupsUseOwnAppDef(t *testing.T) {
+	server := NewFakeAutoScalrServer()
+	defer server.Close()
+	asrMgr, err := createAutoScalrManagerInternal(strings.NewReader(testAppDefConfig), cloudprovider.NodeGroupDiscoveryOptions{}, server.Client("myApiKey"))
+	assert.NoError(t, err)
+
+	awsProv := testprovider.NewTestCloudProvider(nil, nil)
+	awsProv.AddNodeGroup("testASG", 1, 10, 1)
+	awsProv.AddNodeGroup("gpuASG", 0, 4, 0)
+	asrProv := &autoScalrCloudProvider{
+		autoScalrManager: asrMgr,
+		awsProvider:      awsProv,
+	}
+
+	nodeGroups := make(map[string]cloudprovider.NodeGroup)
+	for _, ng := range asrProv.NodeGroups() {
+		assert.False(t, ng.Exist())
+		assert.NoError(t, ng.Create())
+		assert.True(t, ng.Exist())
+		nodeGroups[ng.Id()] = ng
+	}
+	assert.Equal(t, []string{"c3.large", "c3.xlarge"}, server.AppDef("testASG", "us-east-1").InstanceTypes)
+	assert.Equal(t, []string{"p2.xlarge"}, server.AppDef("gpuASG", "us-east-1").InstanceTypes)
+
+	// Sizes are converted with the instance types of each group: p2.xlarge has 4 vCPUs.
+	size, err := nodeGroups["gpuASG"].TargetSize()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, size)
+	assert.NoError(t, nodeGroups["gpuASG"].IncreaseSize(2))
+	assert.Equal(t, 9, server.AppDef("gpuASG", "us-east-1").TargetCapacity)
+	assert.Equal(t, 1, server.AppDef("testASG", "us-east-1").TargetCapacity)
+	size, err = nodeGroups["gpuASG"].TargetSize()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, size)
+
+	assert.NoError(t, nodeGroups["gpuASG"].Delete())
+	assert.False(t, nodeGroups["gpuASG"].Exist())
+	assert.True(t, nodeGroups["testASG"].Exist())
+}
+
+func TestSpotLimits(t *testing.T) {
+	config := `
+[autoscalr]
+aws-region = us-east-1
+instance-types = c3.large
+max-spot-percent-one-market = 20
+
+[autoscalr-group "gpuASG"]
+instance-types = p2.xlarge
+max-spot-percent-total = 0
+`
+	server := NewFakeAutoScalrServer()
+	defer server.Close()
+	asrMgr, err := createAutoScalrManagerInternal(strings.NewReader(config), cloudprovider.NodeGroupDiscoveryOptions{}, server.Client("myApiKey"))
+	assert.NoError(t, err)
+	awsProv := testprovider.NewTestCloudProvider(nil, nil)
+	awsProv.AddNodeGroup("testASG", 1, 10, 1)
+	awsProv.AddNodeGroup("gpuASG", 0, 4, 0)
+	asrProv := &autoScalrCloudProvider{
+		autoScalrManager: asrMgr,
+		awsProvider:      awsProv,
+	}
+
+	// Each node group has the limits of its own app definition; unset limits don't limit spot.
+	total, oneMarket := asrProv.SpotLimits(awsProv.GetNodeGroup("testASG"))
+	assert.Equal(t, float64(100), total)
+	assert.Equal(t, float64(20), oneMarket)
+	total, oneMarket = asrProv.SpotLimits(awsProv.GetNodeGroup("gpuASG"))
+	assert.Equal(t, float64(0), total)
+	assert.Equal(t, float64(20), oneMarket)
+}
+
+func TestNodeGroupWeightedSizing(t *testing.T) {
+	server := NewFakeAutoScalrServer()
+	defer server.Close()
+	config := "[autoscalr]\naws-region = us-east-1\ninstance-types = m4.2xlarge,m4.large\n"
+	asrMgr, err := createAutoScalrManagerInternal(strings.NewReader(config), cloudprovider.NodeGroupDiscoveryOptions{}, server.Client("myApiKey"))
+	assert.NoError(t, err)
+	server.SetAppDef(&AppDef{AutoScalingGroupName: "mixedASG", AwsRegion: "us-east-1", TargetCapacity: 10})
+
+	template := schedulercache.NewNodeInfo()
+	template.SetNode(&apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "template",
+			Labels: map[string]string{kubeletapis.LabelInstanceType: "m4.2xlarge", "team": "a"},
+		},
+		Status: apiv1.NodeStatus{
+			Capacity: apiv1.ResourceList{
+				apiv1.ResourcePods: *resource.NewQuantity(110, resource.DecimalSI),
+			},
+		},
+	})
+	awsProv := testprovider.NewTestAutoprovisioningCloudProvider(nil, nil, nil, nil, nil,
+		map[string]*schedulercache.NodeInfo{"mixedASG": template})
+	awsProv.AddNodeGroup("mixedASG", 0, 10, 2)
+	nodes := []*apiv1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws:///us-east-1a/i-1", Labels: map[string]string{kubeletapis.LabelInstanceType: "m4.2xlarge"}},
+			Spec:       apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/i-1"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws:///us-east-1a/i-2", Labels: map[string]string{kubeletapis.LabelInstanceType: "m4.large"}},
+			Spec:       apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/i-2"},
+		},
+	}
+	for _, node := range nodes {
+		awsProv.AddNode("mixedASG", node)
+	}
+	asrMgr.UpdateNodeInstanceTypes(nodes)
+	ng := BuildAutoScalrNodeGroup(awsProv.GetNodeGroup("mixedASG"), asrMgr)
+
+	// An m4.2xlarge and an m4.large make up the 10 vCPUs.
+	size, err := ng.TargetSize()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, size)
+
+	// New nodes are requested as m4.large, the representative type.
+	assert.NoError(t, ng.IncreaseSize(3))
+	assert.Equal(t, 16, server.AppDef("mixedASG", "us-east-1").TargetCapacity)
+	size, err = ng.TargetSize()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, size)
+
+	// Deleting the m4.2xlarge node releases its 8 vCPUs.
+	assert.NoError(t, ng.DeleteNodes([]*apiv1.Node{&nodes[0]}))
+	assert.Equal(t, 8, server.AppDef("mixedASG", "us-east-1").TargetCapacity)
+	assert.Equal(t, []string{"i-1"}, server.DeletedNodes("mixedASG", "us-east-1"))
+
+	nodeInfo, err := ng.TemplateNodeInfo()
+	assert.NoError(t, err)
+	templateNode := nodeInfo.Node()
+	assert.Equal(t, "m4.large", templateNode.Labels[kubeletapis.LabelInstanceType])
+	assert.Equal(t, "a", templateNode.Labels["team"])
+	assert.Equal(t, int64(2), templateNode.Status.Capacity.Cpu().Value())
+	assert.Equal(t, int64(8192*1024*1024), templateNode.Status.Capacity.Memory().Value())
+	assert.Equal(t, int64(110), templateNode.Status.Allocatable.Pods().Value())
+	// The template of the autoscaling group is left untouched.
+	assert.Equal(t, "m4.2xlarge", template.Node().Labels[kubeletapis.LabelInstanceType])
+}
+
+func TestNodeGroupIncreaseSizeForPods(t *testing.T) {
+	server := NewFakeAutoScalrServer()
+	defer server.Close()
+	config := "[autoscalr]\naws-region = us-east-1\ninstance-types = m4.2xlarge,m4.large\n"
+	asrMgr, err := createAutoScalrManagerInternal(strings.NewReader(config), cloudprovider.NodeGroupDiscoveryOptions{}, server.Client("myApiKey"))
+	assert.NoError(t, err)
+	server.SetAppDef(&AppDef{AutoScalingGroupName: "mixedASG", AwsRegion: "us-east-1", TargetCapacity: 10})
+
+	template := schedulercache.NewNodeInfo()
+	template.SetNode(&apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "template",
+			Labels: map[string]string{kubeletapis.LabelInstanceType: "m4.2xlarge"},
+		},
+	})
+	awsProv := testprovider.NewTestAutoprovisioningCloudProvider(nil, nil, nil, nil, nil,
+		map[string]*schedulercache.NodeInfo{"mixedASG": template})
+	awsProv.AddNodeGroup("mixedASG", 0, 10, 0)
+	ng := BuildAutoScalrNodeGroup(awsProv.GetNodeGroup("mixedASG"), asrMgr)
+	increaser, ok := ng.(interface {
+		IncreaseSizeForPods(delta int, pods []*apiv1.Pod) error
+	})
+	assert.True(t, ok)
+
+	// Each pod needs its own m4.large, but 5 vCPUs are enough for the pods and the kube-proxy
+	// of each node: they may as well be launched as an m4.2xlarge.
+	pods := []*apiv1.Pod{
+		BuildTestPod("p1", 1500, 1024*1024*1024),
+		BuildTestPod("p2", 1500, 1024*1024*1024),
+		BuildTestPod("p3", 1500, 1024*1024*1024),
+	}
+	assert.NoError(t, increaser.IncreaseSizeForPods(3, pods))
+	assert.Equal(t, 15, server.AppDef("mixedASG", "us-east-1").TargetCapacity)
+
+	// Memory is requested at 4096Mb per vCPU.
+	pods = []*apiv1.Pod{
+		BuildTestPod("p4", 500, 6*1024*1024*1024),
+		BuildTestPod("p5", 500, 6*1024*1024*1024),
+	}
+	assert.NoError(t, increaser.IncreaseSizeForPods(2, pods))
+	assert.Equal(t, 18, server.AppDef("mixedASG", "us-east-1").TargetCapacity)
+
+	// No more than the estimated nodes are requested, as when the pods are split between
+	// several node groups.
+	pods = []*apiv1.Pod{
+		BuildTestPod("p6", 1500, 0),
+		BuildTestPod("p7", 1500, 0),
+		BuildTestPod("p8", 1500, 0),
+	}
+	assert.NoError(t, increaser.IncreaseSizeForPods(1, pods))
+	assert.Equal(t, 20, server.AppDef("mixedASG", "us-east-1").TargetCapacity)
+}
+
+func TestNodeGroupTemplateOfRepresentativeType(t *testing.T) {
+	server := NewFakeAutoScalrServer()
+	defer server.Close()
+	config := "[autoscalr]\naws-region = us-east-1\ninstance-types = m4.2xlarge,m4.large\n"
+	asrMgr, err := createAutoScalrManagerInternal(strings.NewReader(config), cloudprovider.NodeGroupDiscoveryOptions{}, server.Client("myApiKey"))
+	assert.NoError(t, err)
+
+	asgs := aws.NewFakeAutoScaling(0)
+	assert.NoError(t, asgs.AddGroup("mixedASG", "m4.2xlarge", "us-east-1a", 0, 10, 0, map[string]string{
+		"k8s.io/cluster-autoscaler/node-template/kube-reserved": "cpu=100m,memory=256Mi",
+	}))
+	awsProv, err := aws.NewFakeAwsCloudProvider(asgs, cloudprovider.NodeGroupDiscoveryOptions{
+		NodeGroupSpecs: []string{"0:10:mixedASG"},
+	}, nil)
+	assert.NoError(t, err)
+	awsNodeGroup := awsProv.NodeGroups()[0]
+	ng := BuildAutoScalrNodeGroup(awsNodeGroup, asrMgr)
+
+	// The template is an m4.large, with the reservations of the autoscaling group.
+	nodeInfo, err := ng.TemplateNodeInfo()
+	assert.NoError(t, err)
+	node := nodeInfo.Node()
+	assert.Equal(t, "m4.large", node.Labels[kubeletapis.LabelInstanceType])
+	assert.Equal(t, int64(2), node.Status.Capacity.Cpu().Value())
+	assert.Equal(t, int64(1900), node.Status.Allocatable.Cpu().MilliValue())
+	assert.Equal(t, int64((8192-256)*1024*1024), node.Status.Allocatable.Memory().Value())
+	assert.Equal(t, int64(20), node.Status.Allocatable.Pods().Value())
+
+	awsNodeInfo, err := awsNodeGroup.TemplateNodeInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "m4.2xlarge", awsNodeInfo.Node().Labels[kubeletapis.LabelInstanceType])
+	assert.Equal(t, int64(58), awsNodeInfo.Node().Status.Allocatable.Pods().Value())
+}
+
+func TestNodeGroupDecreaseTargetSize(t *testing.T) {
+	server := NewFakeAutoScalrServer()
+	defer server.Close()
+	config := "[autoscalr]\naws-region = us-east-1\ninstance-types = m4.2xlarge,m4.large\n"
+	asrMgr, err := createAutoScalrManagerInternal(strings.NewReader(config), cloudprovider.NodeGroupDiscoveryOptions{}, server.Client("myApiKey"))
+	assert.NoError(t, err)
+	server.SetAppDef(&AppDef{AutoScalingGroupName: "mixedASG", AwsRegion: "us-east-1", TargetCapacity: 16})
+
+	awsProv := testprovider.NewTestCloudProvider(nil, nil)
+	awsProv.AddNodeGroup("mixedASG", 0, 10, 2)
+	nodes := []*apiv1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws:///us-east-1a/i-1", Labels: map[string]string{kubeletapis.LabelInstanceType: "m4.2xlarge"}},
+			Spec:       apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/i-1"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws:///us-east-1a/i-2", Labels: map[string]string{kubeletapis.LabelInstanceType: "m4.large"}},
+			Spec:       apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/i-2"},
+		},
+	}
+	for _, node := range nodes {
+		awsProv.AddNode("mixedASG", node)
+	}
+	asrMgr.UpdateNodeInstanceTypes(nodes)
+	ng := BuildAutoScalrNodeGroup(awsProv.GetNodeGroup("mixedASG"), asrMgr)
+
+	// 10 vCPUs running, 6 vCPUs or 3 m4.large nodes never arrived.
+	size, err := ng.TargetSize()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, size)
+
+	assert.Error(t, ng.DecreaseTargetSize(1))
+	assert.Error(t, ng.DecreaseTargetSize(-4))
+	assert.Equal(t, 16, server.AppDef("mixedASG", "us-east-1").TargetCapacity)
+
+	assert.NoError(t, ng.DecreaseTargetSize(-3))
+	assert.Equal(t, 10, server.AppDef("mixedASG", "us-east-1").TargetCapacity)
+	assert.Equal(t, 1, server.Requests(requestTypeDecreaseTargetCapacity))
+	assert.Equal(t, 0, server.Requests(requestTypeDeleteAppNodes))
+	assert.Empty(t, server.DeletedNodes("mixedASG", "us-east-1"))
+	size, err = ng.TargetSize()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, size)
+
+	// Registered instances are never given up.
+	assert.Error(t, ng.DecreaseTargetSize(-1))
+	assert.Equal(t, 10, server.AppDef("mixedASG", "us-east-1").TargetCapacity)
+}
+
+func TestRefreshUsesSharedListers(t *testing.T) {
+	os.Setenv("ANAYLZE_DEPLOYMENTS", "false")
+	defer os.Unsetenv("ANAYLZE_DEPLOYMENTS")
+	server := NewFakeAutoScalrServer()
+	defer server.Close()
+	asrMgr, err := createAutoScalrManagerInternal(strings.NewReader(testAppDefConfig), cloudprovider.NodeGroupDiscoveryOptions{}, server.Client("myApiKey"))
+	assert.NoError(t, err)
+	server.SetAppDef(&AppDef{AutoScalingGroupName: "testASG", AwsRegion: "us-east-1", TargetCapacity: 4})
+
+	awsProv := testprovider.NewTestCloudProvider(nil, nil)
+	awsProv.AddNodeGroup("testASG", 1, 10, 1)
+	node := &apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws:///us-east-1a/i-1", Labels: map[string]string{kubeletapis.LabelInstanceType: "c3.large"}},
+		Spec:       apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/i-1"},
+	}
+	nodeLister := &fakeNodeLister{nodes: []*apiv1.Node{node}}
+	asrProv := &autoScalrCloudProvider{
+		autoScalrManager: asrMgr,
+		awsProvider:      awsProv,
+		listerRegistry:   kube_util.NewListerRegistry(nodeLister, nil, nil, nil, nil, nil, nil),
+	}
+
+	assert.NoError(t, asrProv.Refresh())
+	assert.Equal(t, []string{"c3.large"}, asrMgr.instanceTypesOf([]string{"aws:///us-east-1a/i-1"}))
+
+	// Instance types follow the nodes of the lister, no kube client is needed without cluster state reports.
+	node.Labels[kubeletapis.LabelInstanceType] = "c3.xlarge"
+	assert.NoError(t, asrProv.Refresh())
+	assert.Equal(t, []string{"c3.xlarge"}, asrMgr.instanceTypesOf([]string{"aws:///us-east-1a/i-1"}))
+	assert.Nil(t, asrProv.kubeClient)
 }
 
 // Create a mock for awsProvider that requests will be forwarded to by default
@@ -112,7 +405,7 @@ type CloudProviderMock struct {
 	mock.Mock
 }
 
-func (a *CloudProviderMock) Name() (string) {
+func (a *CloudProviderMock) Name() string {
 	args := a.Called()
 	return args.String(0)
-}
\ No newline at end of file
+}
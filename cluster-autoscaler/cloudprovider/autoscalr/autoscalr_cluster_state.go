@@ -0,0 +1,351 @@
+/*
+Copyright 2017 AutoScalr
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscalr
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apiappsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
+	kube_util "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
+	kube_client "k8s.io/client-go/kubernetes"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+)
+
+const (
+	defaultClusterStateInterval     = time.Minute
+	defaultClusterStateFullInterval = 30 * time.Minute
+)
+
+// ClusterStateSnapshot is the cluster state sent to AutoScalr. A full snapshot lists every node
+// and deployment; a delta only lists those that changed since the snapshot of BaseGeneration,
+// and the keys of those that are gone.
+type ClusterStateSnapshot struct {
+	AsrToken             string `json:"api_key"`
+	AppType              string `json:"app_type"`
+	AwsRegion            string `json:"AwsRegion"`
+	AutoScalingGroupName string `json:"AutoScalingGroupName"`
+	Generation           int64  `json:"Generation"`
+	BaseGeneration       int64  `json:"BaseGeneration,omitempty"`
+	Full                 bool   `json:"Full"`
+
+	Nodes              []NodeState       `json:"Nodes,omitempty"`
+	Deployments        []DeploymentState `json:"Deployments,omitempty"`
+	RemovedNodes       []string          `json:"RemovedNodes,omitempty"`
+	RemovedDeployments []string          `json:"RemovedDeployments,omitempty"`
+}
+
+// NodeState is the part of a node AutoScalr looks at. Nodes are keyed by UID.
+type NodeState struct {
+	Name          string            `json:"Name"`
+	UID           string            `json:"UID"`
+	InstanceId    string            `json:"InstanceId,omitempty"`
+	InstanceType  string            `json:"InstanceType,omitempty"`
+	Zone          string            `json:"Zone,omitempty"`
+	PayModel      string            `json:"PayModel,omitempty"`
+	Ready         bool              `json:"Ready"`
+	Unschedulable bool              `json:"Unschedulable"`
+	Capacity      ResourceState     `json:"Capacity"`
+	Allocatable   ResourceState     `json:"Allocatable"`
+	Labels        map[string]string `json:"Labels,omitempty"`
+}
+
+// DeploymentState is the part of a deployment AutoScalr looks at. Deployments are keyed by
+// namespace/name.
+type DeploymentState struct {
+	Namespace         string            `json:"Namespace"`
+	Name              string            `json:"Name"`
+	Replicas          int32             `json:"Replicas"`
+	ReadyReplicas     int32             `json:"ReadyReplicas"`
+	AvailableReplicas int32             `json:"AvailableReplicas"`
+	PodRequests       ResourceState     `json:"PodRequests"`
+	NodeSelector      map[string]string `json:"NodeSelector,omitempty"`
+}
+
+// ResourceState is an amount of node resources.
+type ResourceState struct {
+	MilliCpu    int64 `json:"MilliCpu"`
+	MemoryBytes int64 `json:"MemoryBytes"`
+	Gpu         int64 `json:"Gpu,omitempty"`
+	Pods        int64 `json:"Pods,omitempty"`
+}
+
+type LabelUpdate struct {
+	InstanceId string `json:"InstanceId"`
+	UID        string `json:"UID"`
+	PayModel   string `json:"PayModel"`
+}
+
+type SendClusterStateResponse struct {
+	LabelUpdates []LabelUpdate `json:"LabelUpdates"`
+	// FullStateRequired is set when AutoScalr can't apply the next delta, e.g. after it lost
+	// the base snapshot.
+	FullStateRequired bool `json:"FullStateRequired"`
+}
+
+func (s *DeploymentState) key() string {
+	return s.Namespace + "/" + s.Name
+}
+
+func buildNodeState(node *apiv1.Node) NodeState {
+	state := NodeState{
+		Name:          node.Name,
+		UID:           string(node.UID),
+		InstanceType:  node.Labels[kubeletapis.LabelInstanceType],
+		Zone:          node.Labels[kubeletapis.LabelZoneFailureDomain],
+		PayModel:      node.Labels[aws.PayModelLabel],
+		Ready:         kube_util.IsNodeReadyAndSchedulable(node),
+		Unschedulable: node.Spec.Unschedulable,
+		Capacity:      buildResourceState(node.Status.Capacity),
+		Allocatable:   buildResourceState(node.Status.Allocatable),
+		Labels:        node.Labels,
+	}
+	if strings.HasPrefix(node.Spec.ProviderID, "aws:///") {
+		state.InstanceId = InstanceIdFromProviderId(node.Spec.ProviderID)
+	}
+	return state
+}
+
+func buildDeploymentState(deployment *apiappsv1.Deployment) DeploymentState {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	podRequests := apiv1.ResourceList{}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			sum := podRequests[name]
+			sum.Add(quantity)
+			podRequests[name] = sum
+		}
+	}
+	return DeploymentState{
+		Namespace:         deployment.Namespace,
+		Name:              deployment.Name,
+		Replicas:          replicas,
+		ReadyReplicas:     deployment.Status.ReadyReplicas,
+		AvailableReplicas: deployment.Status.AvailableReplicas,
+		PodRequests:       buildResourceState(podRequests),
+		NodeSelector:      deployment.Spec.Template.Spec.NodeSelector,
+	}
+}
+
+func buildResourceState(resources apiv1.ResourceList) ResourceState {
+	state := ResourceState{
+		MilliCpu:    resources.Cpu().MilliValue(),
+		MemoryBytes: resources.Memory().Value(),
+		Pods:        resources.Pods().Value(),
+	}
+	if gpuQuantity, found := resources[gpu.ResourceNvidiaGPU]; found {
+		state.Gpu = gpuQuantity.Value()
+	}
+	return state
+}
+
+// clusterStateReporter sends the cluster state to AutoScalr in the background, independently
+// of the autoscaler loop. Nodes and deployments are read from listers. Only changes since the
+// last snapshot AutoScalr accepted are sent, except every fullInterval and after a failure,
+// when the whole state is sent again.
+type clusterStateReporter struct {
+	client           *AutoScalrClient
+	region           string
+	nodeLister       kube_util.NodeLister
+	deploymentLister kube_util.DeploymentLister
+	kubeClient       kube_client.Interface
+	interval         time.Duration
+	fullInterval     time.Duration
+
+	groupMutex sync.Mutex
+	// groupName is the autoscaling group AutoScalr associates the cluster state with.
+	groupName string
+
+	generation int64
+	// The last snapshot accepted by AutoScalr, nil if the next one has to be full.
+	ackedGeneration  int64
+	ackedNodes       map[string]NodeState
+	ackedDeployments map[string]DeploymentState
+	lastFull         time.Time
+}
+
+func newClusterStateReporter(manager *AutoScalrManager, nodeLister kube_util.NodeLister,
+	deploymentLister kube_util.DeploymentLister, kubeClient kube_client.Interface) *clusterStateReporter {
+	return &clusterStateReporter{
+		client:           manager.client,
+		region:           manager.region,
+		nodeLister:       nodeLister,
+		deploymentLister: deploymentLister,
+		kubeClient:       kubeClient,
+		interval:         manager.clusterStateInterval,
+		fullInterval:     manager.clusterStateFullInterval,
+	}
+}
+
+// run reports the cluster state every interval until stopCh is closed.
+func (r *clusterStateReporter) run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := r.report(time.Now()); err != nil {
+			glog.Warningf("Failed to send cluster state to AutoScalr: %v", err)
+		}
+	}, r.interval, stopCh)
+}
+
+func (r *clusterStateReporter) setGroupName(groupName string) {
+	r.groupMutex.Lock()
+	defer r.groupMutex.Unlock()
+	r.groupName = groupName
+}
+
+func (r *clusterStateReporter) getGroupName() string {
+	r.groupMutex.Lock()
+	defer r.groupMutex.Unlock()
+	return r.groupName
+}
+
+// report sends one snapshot of the cluster state and applies the label updates AutoScalr answers with.
+func (r *clusterStateReporter) report(now time.Time) error {
+	nodes, err := r.nodeLister.List()
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %v", err)
+	}
+	deployments, err := r.deploymentLister.List()
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %v", err)
+	}
+	nodeStates := make(map[string]NodeState, len(nodes))
+	for _, node := range nodes {
+		nodeStates[string(node.UID)] = buildNodeState(node)
+	}
+	deploymentStates := make(map[string]DeploymentState, len(deployments))
+	for _, deployment := range deployments {
+		state := buildDeploymentState(deployment)
+		deploymentStates[state.key()] = state
+	}
+
+	r.generation++
+	snapshot := &ClusterStateSnapshot{
+		AwsRegion:            r.region,
+		AutoScalingGroupName: r.getGroupName(),
+		Generation:           r.generation,
+		Full:                 r.ackedNodes == nil || now.Sub(r.lastFull) >= r.fullInterval,
+	}
+	if snapshot.Full {
+		snapshot.Nodes, _ = diffNodeStates(nil, nodeStates)
+		snapshot.Deployments, _ = diffDeploymentStates(nil, deploymentStates)
+	} else {
+		snapshot.BaseGeneration = r.ackedGeneration
+		snapshot.Nodes, snapshot.RemovedNodes = diffNodeStates(r.ackedNodes, nodeStates)
+		snapshot.Deployments, snapshot.RemovedDeployments = diffDeploymentStates(r.ackedDeployments, deploymentStates)
+	}
+	glog.V(4).Infof("Sending cluster state %d to AutoScalr (full: %v, nodes: %d, deployments: %d)",
+		snapshot.Generation, snapshot.Full, len(snapshot.Nodes), len(snapshot.Deployments))
+
+	resp, err := r.client.ClusterState(snapshot)
+	if err != nil {
+		r.ackedNodes, r.ackedDeployments = nil, nil
+		return err
+	}
+	if resp.FullStateRequired {
+		glog.V(1).Infof("AutoScalr requested the full cluster state")
+		r.ackedNodes, r.ackedDeployments = nil, nil
+	} else {
+		r.ackedGeneration = snapshot.Generation
+		r.ackedNodes, r.ackedDeployments = nodeStates, deploymentStates
+		if snapshot.Full {
+			r.lastFull = now
+		}
+	}
+	return ApplyLabels(resp, nodes, r.kubeClient)
+}
+
+// diffNodeStates returns the node states that are new or changed since old, sorted by UID, and
+// the UIDs of the nodes that are gone.
+func diffNodeStates(old, current map[string]NodeState) ([]NodeState, []string) {
+	uids := make([]string, 0, len(current))
+	for uid := range current {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	changed := make([]NodeState, 0)
+	for _, uid := range uids {
+		if oldState, found := old[uid]; !found || !reflect.DeepEqual(oldState, current[uid]) {
+			changed = append(changed, current[uid])
+		}
+	}
+	removed := make([]string, 0)
+	for uid := range old {
+		if _, found := current[uid]; !found {
+			removed = append(removed, uid)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// diffDeploymentStates returns the deployment states that are new or changed since old, sorted
+// by key, and the keys of the deployments that are gone.
+func diffDeploymentStates(old, current map[string]DeploymentState) ([]DeploymentState, []string) {
+	keys := make([]string, 0, len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	changed := make([]DeploymentState, 0)
+	for _, key := range keys {
+		if oldState, found := old[key]; !found || !reflect.DeepEqual(oldState, current[key]) {
+			changed = append(changed, current[key])
+		}
+	}
+	removed := make([]string, 0)
+	for key := range old {
+		if _, found := current[key]; !found {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// ApplyLabels sets the pay model label AutoScalr asked for on the given nodes. Nodes that
+// already have it are not updated.
+func ApplyLabels(scsResp *SendClusterStateResponse, nodes []*apiv1.Node, kubeClient kube_client.Interface) error {
+	for _, labUpd := range scsResp.LabelUpdates {
+		for _, node := range nodes {
+			if string(node.UID) != labUpd.UID || node.Labels[aws.PayModelLabel] == labUpd.PayModel {
+				continue
+			}
+			glog.V(4).Infof("Setting %s label on %s as: %s", aws.PayModelLabel, labUpd.InstanceId, labUpd.PayModel)
+			updated := node.DeepCopy()
+			if updated.Labels == nil {
+				updated.Labels = make(map[string]string)
+			}
+			updated.Labels[aws.PayModelLabel] = labUpd.PayModel
+			if _, err := kubeClient.CoreV1().Nodes().Update(updated); err != nil {
+				return fmt.Errorf("failed to update label of %s: %v", node.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2017 AutoScalr
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscalr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiappsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
+	"k8s.io/client-go/kubernetes/fake"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+)
+
+type fakeNodeLister struct {
+	nodes []*apiv1.Node
+}
+
+func (l *fakeNodeLister) List() ([]*apiv1.Node, error) {
+	return l.nodes, nil
+}
+
+type fakeDeploymentLister struct {
+	deployments []*apiappsv1.Deployment
+}
+
+func (l *fakeDeploymentLister) List() ([]*apiappsv1.Deployment, error) {
+	return l.deployments, nil
+}
+
+func buildClusterStateNode(name string, uid string, cpu string) *apiv1.Node {
+	resources := apiv1.ResourceList{
+		apiv1.ResourceCPU:    resource.MustParse(cpu),
+		apiv1.ResourceMemory: resource.MustParse("4Gi"),
+		apiv1.ResourcePods:   resource.MustParse("110"),
+	}
+	return &apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			UID:  types.UID(uid),
+			Labels: map[string]string{
+				kubeletapis.LabelInstanceType:      "c3.large",
+				kubeletapis.LabelZoneFailureDomain: "us-east-1a",
+			},
+		},
+		Spec: apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/i-" + name},
+		Status: apiv1.NodeStatus{
+			Capacity:    resources,
+			Allocatable: resources,
+			Conditions:  []apiv1.NodeCondition{{Type: apiv1.NodeReady, Status: apiv1.ConditionTrue}},
+		},
+	}
+}
+
+func buildClusterStateDeployment(name string, replicas int32) *apiappsv1.Deployment {
+	container := apiv1.Container{
+		Resources: apiv1.ResourceRequirements{
+			Requests: apiv1.ResourceList{
+				apiv1.ResourceCPU:    resource.MustParse("250m"),
+				apiv1.ResourceMemory: resource.MustParse("512Mi"),
+			},
+		},
+	}
+	return &apiappsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: apiappsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: apiv1.PodTemplateSpec{
+				Spec: apiv1.PodSpec{Containers: []apiv1.Container{container, container}},
+			},
+		},
+		Status: apiappsv1.DeploymentStatus{ReadyReplicas: replicas - 1},
+	}
+}
+
+func TestBuildNodeState(t *testing.T) {
+	node := buildClusterStateNode("n1", "uid1", "2")
+	node.Labels[aws.PayModelLabel] = "spot"
+
+	state := buildNodeState(node)
+	assert.Equal(t, "n1", state.Name)
+	assert.Equal(t, "uid1", state.UID)
+	assert.Equal(t, "i-n1", state.InstanceId)
+	assert.Equal(t, "c3.large", state.InstanceType)
+	assert.Equal(t, "us-east-1a", state.Zone)
+	assert.Equal(t, "spot", state.PayModel)
+	assert.True(t, state.Ready)
+	assert.Equal(t, ResourceState{MilliCpu: 2000, MemoryBytes: 4 * 1024 * 1024 * 1024, Pods: 110}, state.Allocatable)
+}
+
+func TestBuildDeploymentState(t *testing.T) {
+	state := buildDeploymentState(buildClusterStateDeployment("d1", 3))
+	assert.Equal(t, "default/d1", state.key())
+	assert.Equal(t, int32(3), state.Replicas)
+	assert.Equal(t, int32(2), state.ReadyReplicas)
+	assert.Equal(t, ResourceState{MilliCpu: 500, MemoryBytes: 1024 * 1024 * 1024}, state.PodRequests)
+}
+
+func TestClusterStateReporter(t *testing.T) {
+	m, server := newTestAutoScalrManager(t)
+	defer server.Close()
+
+	n1 := buildClusterStateNode("n1", "uid1", "2")
+	n2 := buildClusterStateNode("n2", "uid2", "2")
+	nodeLister := &fakeNodeLister{nodes: []*apiv1.Node{n1, n2}}
+	deploymentLister := &fakeDeploymentLister{deployments: []*apiappsv1.Deployment{
+		buildClusterStateDeployment("d1", 2),
+		buildClusterStateDeployment("d2", 1),
+	}}
+	reporter := newClusterStateReporter(m, nodeLister, deploymentLister, fake.NewSimpleClientset(n1, n2))
+	reporter.setGroupName("testASG")
+	now := time.Now()
+
+	// The first snapshot is full.
+	assert.NoError(t, reporter.report(now))
+	states := server.ClusterStates()
+	assert.Equal(t, 1, len(states))
+	assert.True(t, states[0].Full)
+	assert.Equal(t, "myApiKey", states[0].AsrToken)
+	assert.Equal(t, appTypeK8s, states[0].AppType)
+	assert.Equal(t, "testASG", states[0].AutoScalingGroupName)
+	assert.Equal(t, "us-east-1", states[0].AwsRegion)
+	assert.Equal(t, 2, len(states[0].Nodes))
+	assert.Equal(t, 2, len(states[0].Deployments))
+
+	// Then only changes are sent.
+	n2Resized := buildClusterStateNode("n2", "uid2", "4")
+	nodeLister.nodes = []*apiv1.Node{n1, n2Resized}
+	deploymentLister.deployments = deploymentLister.deployments[1:]
+	assert.NoError(t, reporter.report(now.Add(time.Minute)))
+	states = server.ClusterStates()
+	delta := states[1]
+	assert.False(t, delta.Full)
+	assert.Equal(t, states[0].Generation, delta.BaseGeneration)
+	assert.Equal(t, 1, len(delta.Nodes))
+	assert.Equal(t, "n2", delta.Nodes[0].Name)
+	assert.Empty(t, delta.Deployments)
+	assert.Empty(t, delta.RemovedNodes)
+	assert.Equal(t, []string{"default/d1"}, delta.RemovedDeployments)
+
+	// A failed upload is followed by a full snapshot.
+	server.SetUnavailable(true)
+	assert.Error(t, reporter.report(now.Add(2*time.Minute)))
+	server.SetUnavailable(false)
+	assert.NoError(t, reporter.report(now.Add(3*time.Minute)))
+	states = server.ClusterStates()
+	assert.True(t, states[len(states)-1].Full)
+
+	// So is an answer asking for the full state.
+	server.RequireFullState()
+	assert.NoError(t, reporter.report(now.Add(4*time.Minute)))
+	assert.NoError(t, reporter.report(now.Add(5*time.Minute)))
+	states = server.ClusterStates()
+	assert.False(t, states[len(states)-2].Full)
+	assert.True(t, states[len(states)-1].Full)
+
+	// And the state is sent in full every full interval.
+	assert.NoError(t, reporter.report(now.Add(6*time.Minute)))
+	assert.NoError(t, reporter.report(now.Add(5*time.Minute+defaultClusterStateFullInterval)))
+	states = server.ClusterStates()
+	assert.False(t, states[len(states)-2].Full)
+	assert.True(t, states[len(states)-1].Full)
+	assert.Equal(t, 2, len(states[len(states)-1].Nodes))
+}
+
+func TestClusterStateReporterAppliesLabels(t *testing.T) {
+	m, server := newTestAutoScalrManager(t)
+	defer server.Close()
+
+	n1 := buildClusterStateNode("n1", "uid1", "2")
+	kubeClient := fake.NewSimpleClientset(n1)
+	reporter := newClusterStateReporter(m, &fakeNodeLister{nodes: []*apiv1.Node{n1}}, &fakeDeploymentLister{}, kubeClient)
+	server.SetLabelUpdates([]LabelUpdate{{InstanceId: "i-n1", UID: "uid1", PayModel: "spot"}})
+
+	assert.NoError(t, reporter.report(time.Now()))
+	updated, err := kubeClient.CoreV1().Nodes().Get("n1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "spot", updated.Labels[aws.PayModelLabel])
+	// The lister's copy is left alone.
+	assert.Equal(t, "", n1.Labels[aws.PayModelLabel])
+}
+
+func TestApplyLabels(t *testing.T) {
+	n1 := buildClusterStateNode("n1", "uid1", "2")
+	n2 := buildClusterStateNode("n2", "uid2", "2")
+	n2.Labels[aws.PayModelLabel] = "spot"
+	kubeClient := fake.NewSimpleClientset(n1, n2)
+	resp := &SendClusterStateResponse{LabelUpdates: []LabelUpdate{
+		{InstanceId: "i-n1", UID: "uid1", PayModel: "ondemand"},
+		{InstanceId: "i-n2", UID: "uid2", PayModel: "spot"},
+		{InstanceId: "i-n3", UID: "uid3", PayModel: "spot"},
+	}}
+
+	assert.NoError(t, ApplyLabels(resp, []*apiv1.Node{n1, n2}, kubeClient))
+	// Only n1 needed an update.
+	assert.Equal(t, 1, len(kubeClient.Actions()))
+	updated, err := kubeClient.CoreV1().Nodes().Get("n1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "ondemand", updated.Labels[aws.PayModelLabel])
+}
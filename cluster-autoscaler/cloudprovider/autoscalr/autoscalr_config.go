@@ -0,0 +1,384 @@
+/*
+Copyright 2017 AutoScalr
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscalr
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"gopkg.in/gcfg.v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws/ec2instances"
+)
+
+// Environment variables used as a fallback when the matching cloud-config
+// variable is not set. They are only kept for deployments configured before
+// the cloud-config file was supported.
+const (
+	envApiKey                    = "AUTOSCALR_API_KEY"
+	envAwsRegion                 = "AWS_REGION"
+	envInstanceTypes             = "INSTANCE_TYPES"
+	envMaxSpotPercentTotal       = "MAX_SPOT_PERCENT_TOTAL"
+	envMaxSpotPercentOneMarket   = "MAX_SPOT_PERCENT_ONE_MARKET"
+	envMaxHoursInstanceAge       = "MAX_HOURS_INSTANCE_AGE"
+	envTargetCapacityVCpus       = "TARGET_CAPACITY_VCPUS"
+	envDetailedMonitoringEnabled = "DETAILED_MONITORING_ENABLED"
+	envDisplayName               = "DISPLAY_NAME"
+	envOsFamily                  = "OS_FAMILY"
+
+	// envAutoScalingGroupName is no longer used, node groups come from --nodes.
+	envAutoScalingGroupName = "AUTOSCALING_GROUP_NAME"
+	// envAnalyzeDeployments set to "false" disables cluster state reporting, like
+	// cluster-state-interval = 0.
+	envAnalyzeDeployments = "ANAYLZE_DEPLOYMENTS"
+)
+
+// defaultInstanceSpinUpSeconds is the instance spin up time reported to AutoScalr.
+const defaultInstanceSpinUpSeconds = 180
+
+// autoScalrConfig is the AutoScalr part of the cloud-config file:
+//
+//	[autoscalr]
+//	api-key = <key>
+//	api-url = https://app.autoscalr.com/api
+//	cluster-state-api-url = https://api.autoscalr.com/v1
+//	api-timeout = 20s
+//	app-cache-ttl = 5m
+//	cluster-state-interval = 1m
+//	cluster-state-full-interval = 30m
+//	aws-region = us-east-1
+//	instance-types = m5.large,m5.xlarge
+//	max-spot-percent-total = 80
+//	max-spot-percent-one-market = 20
+//	max-hours-instance-age = 0
+//	target-capacity-vcpus = 4
+//	detailed-monitoring-enabled = false
+//	display-name = my-cluster
+//	os-family = Linux
+//
+//	[autoscalr-group "gpu-asg"]
+//	instance-types = p2.xlarge
+//	max-spot-percent-total = 0
+//
+// The app definition settings of the [autoscalr] section are the defaults of
+// every node group; an [autoscalr-group] section overrides them for the
+// autoscaling group it is named after.
+//
+// Values are kept as strings so that unset variables can fall back to the
+// environment and all values go through the same validation.
+type autoScalrConfig struct {
+	AutoScalr struct {
+		ApiKey                    string `gcfg:"api-key"`
+		ApiUrl                    string `gcfg:"api-url"`
+		ClusterStateApiUrl        string `gcfg:"cluster-state-api-url"`
+		ApiTimeout                string `gcfg:"api-timeout"`
+		AppCacheTTL               string `gcfg:"app-cache-ttl"`
+		ClusterStateInterval      string `gcfg:"cluster-state-interval"`
+		ClusterStateFullInterval  string `gcfg:"cluster-state-full-interval"`
+		AwsRegion                 string `gcfg:"aws-region"`
+		InstanceTypes             string `gcfg:"instance-types"`
+		MaxSpotPercentTotal       string `gcfg:"max-spot-percent-total"`
+		MaxSpotPercentOneMarket   string `gcfg:"max-spot-percent-one-market"`
+		MaxHoursInstanceAge       string `gcfg:"max-hours-instance-age"`
+		TargetCapacityVCpus       string `gcfg:"target-capacity-vcpus"`
+		DetailedMonitoringEnabled string `gcfg:"detailed-monitoring-enabled"`
+		DisplayName               string `gcfg:"display-name"`
+		OsFamily                  string `gcfg:"os-family"`
+	}
+	Group map[string]*autoScalrGroupConfig `gcfg:"autoscalr-group"`
+}
+
+// autoScalrGroupConfig holds the app definition settings of a single node group.
+type autoScalrGroupConfig struct {
+	InstanceTypes             string `gcfg:"instance-types"`
+	MaxSpotPercentTotal       string `gcfg:"max-spot-percent-total"`
+	MaxSpotPercentOneMarket   string `gcfg:"max-spot-percent-one-market"`
+	MaxHoursInstanceAge       string `gcfg:"max-hours-instance-age"`
+	TargetCapacityVCpus       string `gcfg:"target-capacity-vcpus"`
+	DetailedMonitoringEnabled string `gcfg:"detailed-monitoring-enabled"`
+	DisplayName               string `gcfg:"display-name"`
+	OsFamily                  string `gcfg:"os-family"`
+}
+
+// readAutoScalrConfig reads the cloud-config file and fills unset variables from the environment.
+func readAutoScalrConfig(configReader io.Reader) (*autoScalrConfig, error) {
+	cfg := &autoScalrConfig{}
+	if configReader != nil {
+		// The cloud-config file is shared with the aws provider, so sections
+		// and variables unknown to AutoScalr are not an error.
+		if err := gcfg.FatalOnly(gcfg.ReadInto(cfg, configReader)); err != nil {
+			glog.Errorf("Couldn't read config: %v", err)
+			return nil, err
+		}
+	}
+	cfg.applyEnvFallback()
+	return cfg, nil
+}
+
+func (cfg *autoScalrConfig) applyEnvFallback() {
+	c := &cfg.AutoScalr
+	fallbacks := []struct {
+		value *string
+		env   string
+	}{
+		{&c.ApiKey, envApiKey},
+		{&c.AwsRegion, envAwsRegion},
+		{&c.InstanceTypes, envInstanceTypes},
+		{&c.MaxSpotPercentTotal, envMaxSpotPercentTotal},
+		{&c.MaxSpotPercentOneMarket, envMaxSpotPercentOneMarket},
+		{&c.MaxHoursInstanceAge, envMaxHoursInstanceAge},
+		{&c.TargetCapacityVCpus, envTargetCapacityVCpus},
+		{&c.DetailedMonitoringEnabled, envDetailedMonitoringEnabled},
+		{&c.DisplayName, envDisplayName},
+		{&c.OsFamily, envOsFamily},
+	}
+	for _, f := range fallbacks {
+		if *f.value == "" {
+			if v := os.Getenv(f.env); v != "" {
+				glog.V(1).Infof("AutoScalr config: using %s from environment", f.env)
+				*f.value = v
+			}
+		}
+	}
+	if c.ClusterStateInterval == "" && os.Getenv(envAnalyzeDeployments) == "false" {
+		glog.V(1).Infof("AutoScalr config: cluster state reporting disabled by %s", envAnalyzeDeployments)
+		c.ClusterStateInterval = "0"
+	}
+	if os.Getenv(envAutoScalingGroupName) != "" {
+		glog.Warningf("AutoScalr config: %s is ignored, AutoScalr apps are created for the node groups given with --nodes", envAutoScalingGroupName)
+	}
+}
+
+// buildClient builds the AutoScalr API client described by the config.
+func (cfg *autoScalrConfig) buildClient() (*AutoScalrClient, error) {
+	c := cfg.AutoScalr
+	apiUrl := c.ApiUrl
+	if apiUrl == "" {
+		apiUrl = DefaultApiUrl
+	}
+	clusterStateApiUrl := c.ClusterStateApiUrl
+	if clusterStateApiUrl == "" {
+		clusterStateApiUrl = DefaultClusterStateApiUrl
+	}
+	timeout := DefaultApiTimeout
+	if c.ApiTimeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(c.ApiTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid api-timeout %q: %v", c.ApiTimeout, err)
+		}
+	}
+	return NewAutoScalrClient(apiUrl, clusterStateApiUrl, c.ApiKey, timeout), nil
+}
+
+// appDefCacheTTL returns how long app definitions are served from cache while AutoScalr can't be reached.
+func (cfg *autoScalrConfig) appDefCacheTTL() (time.Duration, error) {
+	return parseDurationVar("app-cache-ttl", cfg.AutoScalr.AppCacheTTL, defaultAppDefCacheTTL)
+}
+
+// clusterStateIntervals returns how often the cluster state is sent to AutoScalr, 0 if it
+// isn't, and how often it is sent in full rather than as a delta.
+func (cfg *autoScalrConfig) clusterStateIntervals() (time.Duration, time.Duration, error) {
+	interval, err := parseDurationVar("cluster-state-interval", cfg.AutoScalr.ClusterStateInterval, defaultClusterStateInterval)
+	if err != nil {
+		return 0, 0, err
+	}
+	fullInterval, err := parseDurationVar("cluster-state-full-interval", cfg.AutoScalr.ClusterStateFullInterval, defaultClusterStateFullInterval)
+	if err != nil {
+		return 0, 0, err
+	}
+	return interval, fullInterval, nil
+}
+
+// parseDurationVar parses the value of a non-negative duration variable, returning defaultValue if it is unset.
+func parseDurationVar(name, value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", name, value, err)
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", name, value)
+	}
+	return duration, nil
+}
+
+// defaultGroupConfig returns the node group settings of the [autoscalr] section.
+func (cfg *autoScalrConfig) defaultGroupConfig() *autoScalrGroupConfig {
+	c := cfg.AutoScalr
+	return &autoScalrGroupConfig{
+		InstanceTypes:             c.InstanceTypes,
+		MaxSpotPercentTotal:       c.MaxSpotPercentTotal,
+		MaxSpotPercentOneMarket:   c.MaxSpotPercentOneMarket,
+		MaxHoursInstanceAge:       c.MaxHoursInstanceAge,
+		TargetCapacityVCpus:       c.TargetCapacityVCpus,
+		DetailedMonitoringEnabled: c.DetailedMonitoringEnabled,
+		DisplayName:               c.DisplayName,
+		OsFamily:                  c.OsFamily,
+	}
+}
+
+// mergedWith returns a copy of the defaults with the variables set in override replacing them.
+func (defaults *autoScalrGroupConfig) mergedWith(override *autoScalrGroupConfig) *autoScalrGroupConfig {
+	merged := *defaults
+	pairs := []struct {
+		value    *string
+		override string
+	}{
+		{&merged.InstanceTypes, override.InstanceTypes},
+		{&merged.MaxSpotPercentTotal, override.MaxSpotPercentTotal},
+		{&merged.MaxSpotPercentOneMarket, override.MaxSpotPercentOneMarket},
+		{&merged.MaxHoursInstanceAge, override.MaxHoursInstanceAge},
+		{&merged.TargetCapacityVCpus, override.TargetCapacityVCpus},
+		{&merged.DetailedMonitoringEnabled, override.DetailedMonitoringEnabled},
+		{&merged.DisplayName, override.DisplayName},
+		{&merged.OsFamily, override.OsFamily},
+	}
+	for _, p := range pairs {
+		if p.override != "" {
+			*p.value = p.override
+		}
+	}
+	return &merged
+}
+
+// buildAppDefTemplates validates the config and builds the app definitions used to create
+// AutoScalr apps: the default one, without an autoscaling group name, and one per
+// [autoscalr-group] section keyed by autoscaling group name.
+func (cfg *autoScalrConfig) buildAppDefTemplates() (*AppDef, map[string]*AppDef, error) {
+	if cfg.AutoScalr.AwsRegion == "" {
+		return nil, nil, fmt.Errorf("aws-region is not set")
+	}
+	defaults := cfg.defaultGroupConfig()
+	defaultAppDef, err := buildAppDef(cfg.AutoScalr.AwsRegion, defaults)
+	if err != nil {
+		return nil, nil, err
+	}
+	groupAppDefs := make(map[string]*AppDef)
+	for name, group := range cfg.Group {
+		appDef, err := buildAppDef(cfg.AutoScalr.AwsRegion, defaults.mergedWith(group))
+		if err != nil {
+			return nil, nil, fmt.Errorf("autoscalr-group %q: %v", name, err)
+		}
+		appDef.AutoScalingGroupName = name
+		groupAppDefs[name] = appDef
+	}
+	return defaultAppDef, groupAppDefs, nil
+}
+
+func buildAppDef(region string, c *autoScalrGroupConfig) (*AppDef, error) {
+	instanceTypes, err := parseInstanceTypes(c.InstanceTypes)
+	if err != nil {
+		return nil, err
+	}
+	// Unset spot limits don't limit spot.
+	maxSpotPercentTotal, err := parsePercent("max-spot-percent-total", c.MaxSpotPercentTotal, 100)
+	if err != nil {
+		return nil, err
+	}
+	maxSpotPercentOneMarket, err := parsePercent("max-spot-percent-one-market", c.MaxSpotPercentOneMarket, 100)
+	if err != nil {
+		return nil, err
+	}
+	maxHoursInstanceAge, err := parseNonNegativeInt("max-hours-instance-age", c.MaxHoursInstanceAge)
+	if err != nil {
+		return nil, err
+	}
+	targetCapacity, err := parseNonNegativeInt("target-capacity-vcpus", c.TargetCapacityVCpus)
+	if err != nil {
+		return nil, err
+	}
+	detailedMonitoring := false
+	if c.DetailedMonitoringEnabled != "" {
+		detailedMonitoring, err = strconv.ParseBool(c.DetailedMonitoringEnabled)
+		if err != nil {
+			return nil, fmt.Errorf("invalid detailed-monitoring-enabled %q: expected a boolean", c.DetailedMonitoringEnabled)
+		}
+	}
+
+	return &AppDef{
+		AwsRegion:                 region,
+		AppType:                   appTypeK8s,
+		InstanceTypes:             instanceTypes,
+		ScaleMode:                 "fixed",
+		MaxSpotPercentTotal:       maxSpotPercentTotal,
+		MaxSpotPercentOneMarket:   maxSpotPercentOneMarket,
+		InstanceSpinUpSeconds:     defaultInstanceSpinUpSeconds,
+		DisplayName:               c.DisplayName,
+		DetailedMonitoringEnabled: detailedMonitoring,
+		AutoscalrEnabled:          true,
+		OsFamily:                  c.OsFamily,
+		MaxHoursInstanceAge:       maxHoursInstanceAge,
+		TargetCapacity:            targetCapacity,
+	}, nil
+}
+
+func parseInstanceTypes(value string) ([]string, error) {
+	result := make([]string, 0)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		instanceType, found := ec2instances.InstanceTypes[name]
+		if !found {
+			return nil, fmt.Errorf("unknown instance type %q in instance-types", name)
+		}
+		if instanceType.VCPU <= 0 {
+			return nil, fmt.Errorf("instance type %q in instance-types has no vCPUs", name)
+		}
+		result = append(result, name)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("instance-types is not set")
+	}
+	return result, nil
+}
+
+func parsePercent(name, value string, unset int) (int, error) {
+	if value == "" {
+		return unset, nil
+	}
+	percent, err := parseNonNegativeInt(name, value)
+	if err != nil {
+		return 0, err
+	}
+	if percent > 100 {
+		return 0, fmt.Errorf("invalid %s %q: must be between 0 and 100", name, value)
+	}
+	return percent, nil
+}
+
+func parseNonNegativeInt(name, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	result, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: expected an integer", name, value)
+	}
+	if result < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", name, value)
+	}
+	return result, nil
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2017 AutoScalr
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscalr
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var appDefEnvVars = []string{
+	envApiKey,
+	envAwsRegion,
+	envAutoScalingGroupName,
+	envInstanceTypes,
+	envMaxSpotPercentTotal,
+	envMaxSpotPercentOneMarket,
+	envMaxHoursInstanceAge,
+	envTargetCapacityVCpus,
+	envDetailedMonitoringEnabled,
+	envDisplayName,
+	envOsFamily,
+	envAnalyzeDeployments,
+}
+
+// clearAppDefEnv unsets the fallback environment variables and returns a function restoring them.
+func clearAppDefEnv() func() {
+	saved := make(map[string]string)
+	for _, name := range appDefEnvVars {
+		if value, found := os.LookupEnv(name); found {
+			saved[name] = value
+		}
+		os.Unsetenv(name)
+	}
+	return func() {
+		for _, name := range appDefEnvVars {
+			os.Unsetenv(name)
+		}
+		for name, value := range saved {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+func buildTestAppDefTemplate(config string) (*AppDef, error) {
+	appDef, _, err := buildTestAppDefTemplates(config)
+	return appDef, err
+}
+
+func buildTestAppDefTemplates(config string) (*AppDef, map[string]*AppDef, error) {
+	cfg, err := readAutoScalrConfig(strings.NewReader(config))
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg.buildAppDefTemplates()
+}
+
+func TestBuildAppDefTemplate(t *testing.T) {
+	defer clearAppDefEnv()()
+
+	appDef, err := buildTestAppDefTemplate(`
+[autoscalr]
+aws-region = us-west-2
+instance-types = c3.large, c3.xlarge
+max-spot-percent-total = 80
+max-spot-percent-one-market = 20
+max-hours-instance-age = 48
+target-capacity-vcpus = 4
+detailed-monitoring-enabled = true
+display-name = test cluster
+os-family = Linux
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, &AppDef{
+		AwsRegion:                 "us-west-2",
+		AppType:                   appTypeK8s,
+		InstanceTypes:             []string{"c3.large", "c3.xlarge"},
+		ScaleMode:                 "fixed",
+		MaxSpotPercentTotal:       80,
+		MaxSpotPercentOneMarket:   20,
+		InstanceSpinUpSeconds:     defaultInstanceSpinUpSeconds,
+		DisplayName:               "test cluster",
+		DetailedMonitoringEnabled: true,
+		AutoscalrEnabled:          true,
+		OsFamily:                  "Linux",
+		MaxHoursInstanceAge:       48,
+		TargetCapacity:            4,
+	}, appDef)
+}
+
+func TestBuildAppDefTemplateEnvFallback(t *testing.T) {
+	defer clearAppDefEnv()()
+	os.Setenv(envAwsRegion, "us-east-1")
+	os.Setenv(envInstanceTypes, "m3.large")
+	os.Setenv(envMaxSpotPercentTotal, "90")
+
+	appDef, err := buildTestAppDefTemplate("[autoscalr]\nmax-spot-percent-total = 50\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1", appDef.AwsRegion)
+	assert.Equal(t, []string{"m3.large"}, appDef.InstanceTypes)
+	// The cloud-config takes precedence over the environment.
+	assert.Equal(t, 50, appDef.MaxSpotPercentTotal)
+}
+
+func TestBuildAppDefTemplateInvalid(t *testing.T) {
+	defer clearAppDefEnv()()
+
+	testCases := []struct {
+		name   string
+		config string
+		errMsg string
+	}{
+		{"missing region", "instance-types = c3.large", "aws-region is not set"},
+		{"missing instance types", "aws-region = us-east-1", "instance-types is not set"},
+		{"unknown instance type", "aws-region = us-east-1\ninstance-types = c3.large,z9.huge", `unknown instance type "z9.huge"`},
+		{"non-numeric spot percent", "aws-region = us-east-1\ninstance-types = c3.large\nmax-spot-percent-total = lots", `invalid max-spot-percent-total "lots"`},
+		{"spot percent out of range", "aws-region = us-east-1\ninstance-types = c3.large\nmax-spot-percent-one-market = 150", `invalid max-spot-percent-one-market "150"`},
+		{"negative capacity", "aws-region = us-east-1\ninstance-types = c3.large\ntarget-capacity-vcpus = -2", `invalid target-capacity-vcpus "-2"`},
+		{"non-boolean monitoring", "aws-region = us-east-1\ninstance-types = c3.large\ndetailed-monitoring-enabled = maybe", `invalid detailed-monitoring-enabled "maybe"`},
+	}
+	for _, tc := range testCases {
+		_, err := buildTestAppDefTemplate("[autoscalr]\n" + tc.config + "\n")
+		if assert.Error(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.errMsg, tc.name)
+		}
+	}
+}
+
+func TestBuildAppDefTemplatesPerGroup(t *testing.T) {
+	defer clearAppDefEnv()()
+
+	defaultAppDef, groupAppDefs, err := buildTestAppDefTemplates(`
+[autoscalr]
+aws-region = us-east-1
+instance-types = c3.large
+max-spot-percent-total = 80
+display-name = general
+
+[autoscalr-group "gpu-asg"]
+instance-types = p2.xlarge
+max-spot-percent-total = 0
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c3.large"}, defaultAppDef.InstanceTypes)
+	assert.Equal(t, 1, len(groupAppDefs))
+	gpu := groupAppDefs["gpu-asg"]
+	assert.Equal(t, "gpu-asg", gpu.AutoScalingGroupName)
+	assert.Equal(t, "us-east-1", gpu.AwsRegion)
+	assert.Equal(t, []string{"p2.xlarge"}, gpu.InstanceTypes)
+	assert.Equal(t, 0, gpu.MaxSpotPercentTotal)
+	// Unset spot limits don't limit spot.
+	assert.Equal(t, 100, gpu.MaxSpotPercentOneMarket)
+	assert.Equal(t, "general", gpu.DisplayName)
+
+	_, _, err = buildTestAppDefTemplates(`
+[autoscalr]
+aws-region = us-east-1
+instance-types = c3.large
+
+[autoscalr-group "gpu-asg"]
+instance-types = z9.huge
+`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `autoscalr-group "gpu-asg"`)
+	}
+}
+
+func TestClusterStateIntervals(t *testing.T) {
+	defer clearAppDefEnv()()
+
+	cfg, err := readAutoScalrConfig(strings.NewReader("[autoscalr]\n"))
+	assert.NoError(t, err)
+	interval, fullInterval, err := cfg.clusterStateIntervals()
+	assert.NoError(t, err)
+	assert.Equal(t, defaultClusterStateInterval, interval)
+	assert.Equal(t, defaultClusterStateFullInterval, fullInterval)
+
+	cfg, err = readAutoScalrConfig(strings.NewReader("[autoscalr]\ncluster-state-interval = 30s\ncluster-state-full-interval = 1h\n"))
+	assert.NoError(t, err)
+	interval, fullInterval, err = cfg.clusterStateIntervals()
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, interval)
+	assert.Equal(t, time.Hour, fullInterval)
+
+	cfg, err = readAutoScalrConfig(strings.NewReader("[autoscalr]\ncluster-state-interval = -1m\n"))
+	assert.NoError(t, err)
+	_, _, err = cfg.clusterStateIntervals()
+	assert.Error(t, err)
+
+	// The legacy environment variable disables reporting unless the cloud-config sets an interval.
+	os.Setenv(envAnalyzeDeployments, "false")
+	cfg, err = readAutoScalrConfig(strings.NewReader("[autoscalr]\n"))
+	assert.NoError(t, err)
+	interval, _, err = cfg.clusterStateIntervals()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), interval)
+}
@@ -17,58 +17,89 @@ limitations under the License.
 package autoscalr
 
 import (
-	"io"
-	"bytes"
 	"fmt"
-	"encoding/json"
-	"net/http"
-	"time"
-	"errors"
-	"os"
+	"io"
 	"strings"
-	"strconv"
-<<<<<<< HEAD
-=======
-	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
-	apiappsv1 "k8s.io/api/apps/v1"
-	apiv1 "k8s.io/api/core/v1"
-	kube_client "k8s.io/client-go/kubernetes"
+	"sync"
+	"time"
+
 	"github.com/golang/glog"
->>>>>>> cluster-autoscaler-release-1.2
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
 )
 
+const appTypeK8s = "k8s"
+
 // AutoScalrManager is handles communication and data caching.
 type AutoScalrManager struct {
-	random   string
+	client *AutoScalrClient
+	region string
+	// defaultAppDef is the app definition of node groups without their own config section.
+	defaultAppDef *AppDef
+	// groupAppDefs are the app definitions of [autoscalr-group] sections, keyed by group id.
+	groupAppDefs map[string]*AppDef
+	// appDefs caches the app definitions read from AutoScalr, keyed by group id.
+	appDefs *appDefCache
+	// clusterStateInterval is how often the cluster state is sent to AutoScalr, 0 if it isn't.
+	clusterStateInterval time.Duration
+	// clusterStateFullInterval is how often the cluster state is sent in full rather than as a delta.
+	clusterStateFullInterval time.Duration
+
+	nodesMutex sync.Mutex
+	// nodeInstanceTypes are the instance types of the registered nodes, keyed by provider id.
+	nodeInstanceTypes map[string]string
 }
 
-<<<<<<< HEAD
-func createAutoScalrManagerInternal(configReader io.Reader) (*AutoScalrManager, error) {
-=======
-func createAutoScalrManagerInternal(configReader io.Reader, discoveryOpts cloudprovider.NodeGroupDiscoveryOptions) (*AutoScalrManager, error) {
->>>>>>> cluster-autoscaler-release-1.2
+// createAutoScalrManagerInternal allows for a custom AutoScalrClient to be passed in by tests
+func createAutoScalrManagerInternal(configReader io.Reader, discoveryOpts cloudprovider.NodeGroupDiscoveryOptions, client *AutoScalrClient) (*AutoScalrManager, error) {
+	cfg, err := readAutoScalrConfig(configReader)
+	if err != nil {
+		return nil, err
+	}
+	defaultAppDef, groupAppDefs, err := cfg.buildAppDefTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("invalid AutoScalr configuration: %v", err)
+	}
+	cacheTTL, err := cfg.appDefCacheTTL()
+	if err != nil {
+		return nil, fmt.Errorf("invalid AutoScalr configuration: %v", err)
+	}
+	clusterStateInterval, clusterStateFullInterval, err := cfg.clusterStateIntervals()
+	if err != nil {
+		return nil, fmt.Errorf("invalid AutoScalr configuration: %v", err)
+	}
+	if client == nil {
+		client, err = cfg.buildClient()
+		if err != nil {
+			return nil, fmt.Errorf("invalid AutoScalr configuration: %v", err)
+		}
+	}
 	manager := &AutoScalrManager{
-		random: "Test-jay",
+		client:            client,
+		region:            cfg.AutoScalr.AwsRegion,
+		defaultAppDef:     defaultAppDef,
+		groupAppDefs:      groupAppDefs,
+		appDefs:           newAppDefCache(cacheTTL),
+		nodeInstanceTypes: make(map[string]string),
+
+		clusterStateInterval:     clusterStateInterval,
+		clusterStateFullInterval: clusterStateFullInterval,
 	}
 	return manager, nil
 }
 
-<<<<<<< HEAD
-func CreateAutoScalrManager(configReader io.Reader) (*AutoScalrManager, error) {
-	return createAutoScalrManagerInternal(configReader)
-=======
+// CreateAutoScalrManager constructs AutoScalrManager object. Settings are read from the
+// [autoscalr] section of the cloud-config, falling back to the legacy environment variables
+// for unset values.
 func CreateAutoScalrManager(configReader io.Reader, discoveryOpts cloudprovider.NodeGroupDiscoveryOptions) (*AutoScalrManager, error) {
-	return createAutoScalrManagerInternal(configReader, discoveryOpts)
->>>>>>> cluster-autoscaler-release-1.2
+	return createAutoScalrManagerInternal(configReader, discoveryOpts, nil)
 }
 
 type AppDef struct {
 	AutoScalingGroupName        string   `json:"aws_autoscaling_group_name"`
 	AwsRegion                   string   `json:"aws_region"`
-<<<<<<< HEAD
-=======
-	AppType						string	 `json:"app_type"`
->>>>>>> cluster-autoscaler-release-1.2
+	AppType                     string   `json:"app_type"`
 	InstanceTypes               []string `json:"instance_types"`
 	ScaleMode                   string   `json:"scale_mode"`
 	MaxSpotPercentTotal         int      `json:"max_spot_percent_total"`
@@ -78,398 +109,261 @@ type AppDef struct {
 	TargetSpareMemoryPercent    int      `json:"target_spare_memory_percent"`
 	QueueName                   string   `json:"queue_name"`
 	TargetQueueSize             int      `json:"target_queue_size"`
-<<<<<<< HEAD
-=======
 	InstanceSpinUpSeconds       int      `json:"instance_spin_up_seconds"`
->>>>>>> cluster-autoscaler-release-1.2
 	MaxMinutesToTargetQueueSize int      `json:"max_minutes_to_target_queue_size"`
 	DisplayName                 string   `json:"display_name"`
 	DetailedMonitoringEnabled   bool     `json:"detailed_monitoring_enabled"`
 	AutoscalrEnabled            bool     `json:"autoscalr_enabled"`
 	OsFamily                    string   `json:"os_family"`
 	MaxHoursInstanceAge         int      `json:"max_hours_instance_age"`
-	TargetCapacity		        int      `json:"target_capacity"`
+	TargetCapacity              int      `json:"target_capacity"`
 }
 
 type AppDefUpdate struct {
-	AutoScalingGroupName        string   `json:"aws_autoscaling_group_name"`
-	AwsRegion                   string   `json:"aws_region"`
-	TargetCapacity		        int      `json:"target_capacity"`
-<<<<<<< HEAD
-=======
-	AppType						string	 `json:"app_type"`
->>>>>>> cluster-autoscaler-release-1.2
+	AutoScalingGroupName string `json:"aws_autoscaling_group_name"`
+	AwsRegion            string `json:"aws_region"`
+	TargetCapacity       int    `json:"target_capacity"`
+	AppType              string `json:"app_type"`
+}
+
+type AppDefTargetDecrease struct {
+	AutoScalingGroupName string `json:"aws_autoscaling_group_name"`
+	AwsRegion            string `json:"aws_region"`
+	AppType              string `json:"app_type"`
+	DeltaVCpu            int    `json:"delta_vcpu"`
 }
+
 type AppDefNodeDelete struct {
-	AutoScalingGroupName        string   `json:"aws_autoscaling_group_name"`
-	AwsRegion                   string   `json:"aws_region"`
-	DeltaVCpu			        int      `json:"delta_vcpu"`
-	NodesToDelete               []string `json:"nodes_to_delete"`
+	AutoScalingGroupName string   `json:"aws_autoscaling_group_name"`
+	AwsRegion            string   `json:"aws_region"`
+	DeltaVCpu            int      `json:"delta_vcpu"`
+	NodesToDelete        []string `json:"nodes_to_delete"`
 }
 
 type AutoScalrRequest struct {
-	AsrToken    string  `json:"api_key"`
-	RequestType string  `json:"request_type"`
-<<<<<<< HEAD
-=======
-	OverwriteExisting bool `json:"overwrite_existing"`
->>>>>>> cluster-autoscaler-release-1.2
-	AsrAppDef   *AppDef `json:"autoscalr_app_def"`
+	AsrToken          string  `json:"api_key"`
+	RequestType       string  `json:"request_type"`
+	OverwriteExisting bool    `json:"overwrite_existing"`
+	AsrAppDef         *AppDef `json:"autoscalr_app_def"`
 }
 
 type AutoScalrUpdateRequest struct {
-	AsrToken    string  `json:"api_key"`
-	RequestType string  `json:"request_type"`
+	AsrToken    string        `json:"api_key"`
+	RequestType string        `json:"request_type"`
 	AsrAppDef   *AppDefUpdate `json:"autoscalr_app_def"`
 }
 
-type AutoScalrNodeDeleteRequest struct {
-	AsrToken    string  `json:"api_key"`
-	RequestType string  `json:"request_type"`
-	AsrAppDef   *AppDefNodeDelete `json:"autoscalr_app_def"`
+type AutoScalrTargetDecreaseRequest struct {
+	AsrToken    string                `json:"api_key"`
+	RequestType string                `json:"request_type"`
+	AsrAppDef   *AppDefTargetDecrease `json:"autoscalr_app_def"`
 }
 
-<<<<<<< HEAD
-=======
-type AutoScalrClusterState struct {
-	AsrToken    string  `json:"api_key"`
-	AwsRegion   string  `json:"AwsRegion"`
-	AutoScalingGroupName   string  `json:"AutoScalingGroupName"`
-	AppType				   string	 `json:"app_type"`
-	Deployments []apiappsv1.Deployment `json:"deployments"`
-	Nodes []apiv1.Node `json:"nodes"`
-}
-
-type AsrDeployment struct {
-	Name    string  `json:"Name"`
+type AutoScalrNodeDeleteRequest struct {
+	AsrToken    string            `json:"api_key"`
+	RequestType string            `json:"request_type"`
+	AsrAppDef   *AppDefNodeDelete `json:"autoscalr_app_def"`
 }
 
->>>>>>> cluster-autoscaler-release-1.2
 type AsrApiErrorResponse struct {
-	Error    *AsrApiError  `json:"error"`
+	Error *AsrApiError `json:"error"`
 }
 
 type AsrApiError struct {
-	ErrorMessage    	string  `json:"errorMessage"`
-	Code 	 	string  `json:"code"`
-}
-
-<<<<<<< HEAD
-=======
-type LabelUpdate struct {
-	InstanceId       string `json:"InstanceId"`
-	UID              string   `json:"UID"`
-	PayModel         string      `json:"PayModel"`
-}
-type SendClusterStateResponse struct {
-	LabelUpdates               []LabelUpdate `json:"LabelUpdates"`
+	ErrorMessage string `json:"errorMessage"`
+	Code         string `json:"code"`
 }
 
->>>>>>> cluster-autoscaler-release-1.2
-func numVCpusBaseType() int {
-	instanceTypesStr := os.Getenv("INSTANCE_TYPES")
-	instanceTypesArr := strings.Split(instanceTypesStr, ",")
-	baseType := instanceTypesArr[0]
-	baseTypeStats := InstanceTypes[baseType]
-	return int(baseTypeStats.VCPU)
+func (e *AsrApiError) Error() string {
+	return "Error response: " + e.ErrorMessage
 }
 
-func InstanceIdFromProviderId(id string) (string) {
-	splitted := strings.Split(id[7:], "/")
-	return splitted[1]
+// isAsrApiError returns true if err is an answer of the AutoScalr API, as opposed to
+// a failure to reach it.
+func isAsrApiError(err error) bool {
+	_, ok := err.(*AsrApiError)
+	return ok
 }
 
-<<<<<<< HEAD
-=======
-func SendClusterState(cState *AutoScalrClusterState, kube_client kube_client.Interface) (int, error) {
-	url := "https://api.autoscalr.com/v1/k8sClusterState"
-	client := &http.Client{
-		Timeout: time.Second * 20,
-	}
-	postBody := new(bytes.Buffer)
-	cState.AppType = "k8s"
-	sendClusterResp := new(SendClusterStateResponse)
-
-	json.NewEncoder(postBody).Encode(cState)
-	resp, err := client.Post(url, "application/json", postBody)
-	if resp != nil {
-		defer resp.Body.Close()
-		if resp.StatusCode == 200 {
-			// make 2 copies of response, one for error decoding and one for good response
-			respBuf := new(bytes.Buffer)
-			respBuf.ReadFrom(resp.Body)
-			errBuf := bytes.NewBuffer(respBuf.Bytes())
-			// Check for error response json
-			jsonErr := new(AsrApiErrorResponse)
-			json.NewDecoder(errBuf).Decode(jsonErr)
-			if jsonErr.Error != nil && jsonErr.Error.ErrorMessage != ""  {
-				// error response
-				err = errors.New(fmt.Sprintf("Error response: %s", jsonErr.Error.ErrorMessage))
-			} else {
-				// looks like good response
-				json.NewDecoder(respBuf).Decode(sendClusterResp)
-				return resp.StatusCode, ApplyLabels(sendClusterResp, cState.Nodes, kube_client)
-			}
-			return resp.StatusCode, err
-		} else {
-			err = errors.New(fmt.Sprintf("k8sClusterStateAPI returned: %d", resp.Status))
-			return resp.StatusCode, err
-		}
+// appDefForGroup returns a copy of the app definition of the given node group.
+func (m *AutoScalrManager) appDefForGroup(groupId string) *AppDef {
+	var appDef AppDef
+	if groupAppDef, found := m.groupAppDefs[groupId]; found {
+		appDef = *groupAppDef
 	} else {
-		//log.Println("Error: %s", err.Error())
-		return 500, err
+		appDef = *m.defaultAppDef
 	}
+	appDef.AutoScalingGroupName = groupId
+	appDef.InstanceTypes = append([]string{}, appDef.InstanceTypes...)
+	return &appDef
 }
 
-func ApplyLabels(scsResp *SendClusterStateResponse, nodes []apiv1.Node, kube_client kube_client.Interface) error {
-	// print out node instance ids that need labels
-	for _, labUpd := range scsResp.LabelUpdates {
-		for _, node := range nodes {
-			if string(node.GetUID()) == labUpd.UID {
-				glog.V(4).Info("Setting autoscalr.com/paymodel label on ", labUpd.InstanceId, " as: ", labUpd.PayModel)
-				currLbls := node.GetLabels()
-				currLbls["autoscalr.com/paymodel"] = labUpd.PayModel
-				node.SetLabels(currLbls)
-				if _, err := kube_client.CoreV1().Nodes().Update(&node); err != nil {
-					return errors.New("Failed to update label " + err.Error())
-				}
-			}
-		}
+// capacityModel returns the capacity model of the given node group.
+func (m *AutoScalrManager) capacityModel(groupId string) (*capacityModel, error) {
+	model, err := newCapacityModel(m.appDefForGroup(groupId).InstanceTypes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid instance types of %s: %v", groupId, err)
 	}
-	return nil
+	return model, nil
 }
 
->>>>>>> cluster-autoscaler-release-1.2
-func makeApiCall(asrReq *AutoScalrRequest) (int, *AppDef, error) {
-	url := "https://app.autoscalr.com/api/autoScalrApp"
-	client := &http.Client{
-		Timeout: time.Second * 20,
-	}
-	postBody := new(bytes.Buffer)
-	json.NewEncoder(postBody).Encode(asrReq)
-	app := new(AppDef)
-	resp, err := client.Post(url, "application/json", postBody)
-	if resp != nil {
-		defer resp.Body.Close()
-		if resp.StatusCode == 200 {
-			// make 2 copies of response, one for error decoding and one for good response
-			respBuf := new(bytes.Buffer)
-			respBuf.ReadFrom(resp.Body)
-			errBuf := bytes.NewBuffer(respBuf.Bytes())
-			// Check for error response json
-			jsonErr := new(AsrApiErrorResponse)
-			json.NewDecoder(errBuf).Decode(jsonErr)
-			if jsonErr.Error != nil && jsonErr.Error.ErrorMessage != ""  {
-				// error response
-				err = errors.New(fmt.Sprintf("Error response: %s", jsonErr.Error.ErrorMessage))
-			} else {
-				// looks like good response
-				json.NewDecoder(respBuf).Decode(app)
-			}
-			return resp.StatusCode, app, err
-		} else {
-			err = errors.New(fmt.Sprintf("AutoScalr API returned: %d", resp.Status))
-			return resp.StatusCode, app, err
+// UpdateNodeInstanceTypes records the instance types of the registered nodes.
+func (m *AutoScalrManager) UpdateNodeInstanceTypes(nodes []*apiv1.Node) {
+	nodeInstanceTypes := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		if node.Spec.ProviderID != "" {
+			nodeInstanceTypes[node.Spec.ProviderID] = node.Labels[kubeletapis.LabelInstanceType]
 		}
-	} else {
-		//log.Println("Error: %s", err.Error())
-		return 500, app, err
 	}
+	m.nodesMutex.Lock()
+	defer m.nodesMutex.Unlock()
+	m.nodeInstanceTypes = nodeInstanceTypes
 }
 
-func makeUpdateApiCall(asrReq *AutoScalrUpdateRequest) (int, *AppDef, error) {
-	url := "https://app.autoscalr.com/api/autoScalrApp"
-	client := &http.Client{
-		Timeout: time.Second * 20,
-	}
-	postBody := new(bytes.Buffer)
-	json.NewEncoder(postBody).Encode(asrReq)
-	app := new(AppDef)
-	resp, err := client.Post(url, "application/json", postBody)
-	if resp != nil {
-		defer resp.Body.Close()
-		if resp.StatusCode == 200 {
-			// make 2 copies of response, one for error decoding and one for good response
-			respBuf := new(bytes.Buffer)
-			respBuf.ReadFrom(resp.Body)
-			errBuf := bytes.NewBuffer(respBuf.Bytes())
-			// Check for error response json
-			jsonErr := new(AsrApiErrorResponse)
-			json.NewDecoder(errBuf).Decode(jsonErr)
-			if jsonErr.Error != nil && jsonErr.Error.ErrorMessage != ""  {
-				// error response
-				err = errors.New(fmt.Sprintf("Error response: %s", jsonErr.Error.ErrorMessage))
-			} else {
-				// looks like good response
-				json.NewDecoder(respBuf).Decode(app)
-			}
-			return resp.StatusCode, app, err
-		} else {
-			err = errors.New(fmt.Sprintf("AutoScalr API returned: %d", resp.Status))
-			return resp.StatusCode, app, err
-		}
-	} else {
-		//log.Println("Error: %s", err.Error())
-		return 500, app, err
+// instanceTypesOf returns the instance types of the given instances. The type of an instance
+// without a registered node is empty.
+func (m *AutoScalrManager) instanceTypesOf(providerIds []string) []string {
+	m.nodesMutex.Lock()
+	defer m.nodesMutex.Unlock()
+	result := make([]string, 0, len(providerIds))
+	for _, providerId := range providerIds {
+		result = append(result, m.nodeInstanceTypes[providerId])
 	}
+	return result
 }
 
-func makeDeleteNodesApiCall(asrReq *AutoScalrNodeDeleteRequest) (int, *AppDef, error) {
-	url := "https://app.autoscalr.com/api/autoScalrApp"
-	client := &http.Client{
-		Timeout: time.Second * 20,
-	}
-	postBody := new(bytes.Buffer)
-	json.NewEncoder(postBody).Encode(asrReq)
-	app := new(AppDef)
-	resp, err := client.Post(url, "application/json", postBody)
-	if resp != nil {
-		defer resp.Body.Close()
-		if resp.StatusCode == 200 {
-			// make 2 copies of response, one for error decoding and one for good response
-			respBuf := new(bytes.Buffer)
-			respBuf.ReadFrom(resp.Body)
-			errBuf := bytes.NewBuffer(respBuf.Bytes())
-			// Check for error response json
-			jsonErr := new(AsrApiErrorResponse)
-			json.NewDecoder(errBuf).Decode(jsonErr)
-			if jsonErr.Error != nil && jsonErr.Error.ErrorMessage != ""  {
-				// error response
-				err = errors.New(fmt.Sprintf("Error response: %s", jsonErr.Error.ErrorMessage))
-			} else {
-				// looks like good response
-				json.NewDecoder(respBuf).Decode(app)
-			}
-			return resp.StatusCode, app, err
-		} else {
-			err = errors.New(fmt.Sprintf("AutoScalr API returned: %d", resp.Status))
-			return resp.StatusCode, app, err
+func InstanceIdFromProviderId(id string) string {
+	splitted := strings.Split(id[7:], "/")
+	return splitted[1]
+}
+
+// Refresh reads the app definitions of the given node groups from AutoScalr and forgets
+// all others. Groups AutoScalr can't be read for keep their cached app definition.
+func (m *AutoScalrManager) Refresh(groupIds []string) {
+	m.appDefs.retain(groupIds)
+	for _, groupId := range groupIds {
+		if err := m.fetchAppDef(groupId); err != nil {
+			glog.Warningf("Failed to refresh AutoScalr app of %s, using cached value: %v", groupId, err)
 		}
-	} else {
-		//log.Println("Error: %s", err.Error())
-		return 500, app, err
 	}
 }
 
-func appDefCreate() error {
-	instanceTypesStr := os.Getenv("INSTANCE_TYPES")
-	instanceTypesArr := strings.Split(instanceTypesStr, ",")
-	maxSpotPercTotal, _ := strconv.Atoi(os.Getenv("MAX_SPOT_PERCENT_TOTAL"))
-	maxSpotPercOneMarket, _ := strconv.Atoi(os.Getenv("MAX_SPOT_PERCENT_ONE_MARKET"))
-	maxHoursInstAge, _ := strconv.Atoi(os.Getenv("MAX_HOURS_INSTANCE_AGE"))
-	targVcpuCapacity, _ := strconv.Atoi(os.Getenv("TARGET_CAPACITY_VCPUS"))
-	detailedMonitoring, _ := strconv.ParseBool(os.Getenv("DETAILED_MONITORING_ENABLED"))
-	body := &AutoScalrRequest{
-		AsrToken:    os.Getenv("AUTOSCALR_API_KEY"),
-		RequestType: "Create",
-<<<<<<< HEAD
-		AsrAppDef: &AppDef{
-			AutoScalingGroupName:        os.Getenv("AUTOSCALING_GROUP_NAME"),
-			AwsRegion:                   os.Getenv("AWS_REGION"),
-=======
-		OverwriteExisting: false,
-		AsrAppDef: &AppDef{
-			AutoScalingGroupName:        os.Getenv("AUTOSCALING_GROUP_NAME"),
-			AwsRegion:                   os.Getenv("AWS_REGION"),
-			AppType:					 "k8s",
->>>>>>> cluster-autoscaler-release-1.2
-			InstanceTypes:               instanceTypesArr,
-			ScaleMode:                   "fixed",
-			MaxSpotPercentTotal:         maxSpotPercTotal,
-			MaxSpotPercentOneMarket:     maxSpotPercOneMarket,
-			ClusterName:                 "",
-			TargetSpareCPUPercent:       0,
-			TargetSpareMemoryPercent:    0,
-			QueueName:                   "",
-			TargetQueueSize:             0,
-<<<<<<< HEAD
-=======
-			InstanceSpinUpSeconds:       180,
->>>>>>> cluster-autoscaler-release-1.2
-			MaxMinutesToTargetQueueSize: 0,
-			DisplayName:                 os.Getenv("DISPLAY_NAME"),
-			DetailedMonitoringEnabled:   detailedMonitoring,
-			AutoscalrEnabled:            true,
-			OsFamily:                    os.Getenv("OS_FAMILY"),
-			MaxHoursInstanceAge:         maxHoursInstAge,
-			TargetCapacity:         	 targVcpuCapacity,
-		},
+// fetchAppDef reads the app definition of the given group from AutoScalr into the cache.
+// Only failures to reach AutoScalr are returned.
+func (m *AutoScalrManager) fetchAppDef(groupId string) error {
+	app, err := m.client.Get(groupId, m.region)
+	if err == nil {
+		m.appDefs.set(groupId, app)
+		return nil
 	}
-	respCode, _, err := makeApiCall(body)
-	if respCode > 400 {
-		err = fmt.Errorf("AutoScalr API returned status code: %d", respCode)
+	if isAsrApiError(err) {
+		m.appDefs.setMissing(groupId, err)
+		return nil
 	}
+	m.appDefs.setRefreshError(groupId, err)
 	return err
 }
 
-func appDefRead() (*AppDef, error) {
-	body := &AutoScalrRequest{
-		AsrToken:    os.Getenv("AUTOSCALR_API_KEY"),
-		RequestType: "Get",
-		AsrAppDef: &AppDef{
-			AutoScalingGroupName:        os.Getenv("AUTOSCALING_GROUP_NAME"),
-			AwsRegion:                   os.Getenv("AWS_REGION"),
-		},
+// cachedAppDef returns the cache entry of the given group, reading it from AutoScalr
+// if the group isn't cached yet.
+func (m *AutoScalrManager) cachedAppDef(groupId string) appDefCacheEntry {
+	entry, found := m.appDefs.get(groupId)
+	if !found {
+		m.fetchAppDef(groupId)
+		entry, _ = m.appDefs.get(groupId)
 	}
-	respCode, app, err := makeApiCall(body)
-	if respCode > 400 {
-		err = fmt.Errorf("AutoScalr API returned status code: %d", respCode)
-	}
-	return app, err
+	return entry
 }
 
+func (m *AutoScalrManager) appDefCreate(groupId string) error {
+	_, err := m.client.Create(m.appDefForGroup(groupId), false)
+	// An already existing app is left untouched by Create, so read it again.
+	m.appDefs.invalidate(groupId)
+	return err
+}
 
-func appDefUpdate(target_capacity int) error {
-	body := &AutoScalrUpdateRequest{
-		AsrToken:    os.Getenv("AUTOSCALR_API_KEY"),
-		RequestType: "Update",
-		AsrAppDef: &AppDefUpdate{
-			AutoScalingGroupName:        os.Getenv("AUTOSCALING_GROUP_NAME"),
-			AwsRegion:                   os.Getenv("AWS_REGION"),
-			TargetCapacity:         	 target_capacity,
-<<<<<<< HEAD
-=======
-			AppType:					"k8s",
->>>>>>> cluster-autoscaler-release-1.2
-		},
+// appDefRead returns the cached app definition of the given group. It fails if the app
+// doesn't exist or AutoScalr couldn't be reached for longer than the cache TTL.
+func (m *AutoScalrManager) appDefRead(groupId string) (*AppDef, error) {
+	entry := m.cachedAppDef(groupId)
+	if m.appDefs.stale(entry) {
+		return nil, fmt.Errorf("AutoScalr app of %s is stale, last refresh failed: %v", groupId, entry.refreshErr)
 	}
-	respCode, _, err := makeUpdateApiCall(body)
-	if respCode > 400 {
-		err = fmt.Errorf("AutoScalr API returned status code: %d", respCode)
+	if entry.appDef == nil {
+		if entry.missingErr != nil {
+			return nil, entry.missingErr
+		}
+		return nil, entry.refreshErr
 	}
-	return err
+	return entry.appDef, nil
+}
+
+// appDefExists returns whether the given group has an app, as last answered by AutoScalr.
+// An AutoScalr outage doesn't make a known app disappear.
+func (m *AutoScalrManager) appDefExists(groupId string) bool {
+	return m.cachedAppDef(groupId).appDef != nil
 }
 
-func appDefDeleteNodes(deltaVcpu int, nodesToDel []string) error {
-	body := &AutoScalrNodeDeleteRequest{
-		AsrToken:    os.Getenv("AUTOSCALR_API_KEY"),
-		RequestType: "DeleteAppNodes",
-		AsrAppDef: &AppDefNodeDelete{
-			AutoScalingGroupName:        os.Getenv("AUTOSCALING_GROUP_NAME"),
-			AwsRegion:                   os.Getenv("AWS_REGION"),
-			DeltaVCpu:         	 		1,
-			NodesToDelete: 				nodesToDel,
-		},
+func (m *AutoScalrManager) appDefUpdate(groupId string, targetCapacity int) error {
+	_, err := m.client.Update(&AppDefUpdate{
+		AutoScalingGroupName: groupId,
+		AwsRegion:            m.region,
+		TargetCapacity:       targetCapacity,
+		AppType:              appTypeK8s,
+	})
+	if err != nil {
+		return err
 	}
-	respCode, _, err := makeDeleteNodesApiCall(body)
-	if respCode > 400 {
-		err = fmt.Errorf("AutoScalr API returned status code: %d", respCode)
+	m.appDefs.modify(groupId, func(appDef *AppDef) {
+		appDef.TargetCapacity = targetCapacity
+	})
+	return nil
+}
+
+// appDefDecreaseTarget lowers the target capacity of the given group by deltaVcpu, giving up
+// on capacity that was requested but never arrived.
+func (m *AutoScalrManager) appDefDecreaseTarget(groupId string, deltaVcpu int) error {
+	_, err := m.client.DecreaseTargetCapacity(&AppDefTargetDecrease{
+		AutoScalingGroupName: groupId,
+		AwsRegion:            m.region,
+		AppType:              appTypeK8s,
+		DeltaVCpu:            deltaVcpu,
+	})
+	if err != nil {
+		return err
 	}
-	return err
+	m.appDefs.modify(groupId, func(appDef *AppDef) {
+		appDef.TargetCapacity -= deltaVcpu
+		if appDef.TargetCapacity < 0 {
+			appDef.TargetCapacity = 0
+		}
+	})
+	return nil
 }
 
-func appDefDelete() error {
-	body := &AutoScalrRequest{
-		AsrToken:    os.Getenv("AUTOSCALR_API_KEY"),
-		RequestType: "Delete",
-		AsrAppDef: &AppDef{
-			AutoScalingGroupName:        os.Getenv("AUTOSCALING_GROUP_NAME"),
-			AwsRegion:                   os.Getenv("AWS_REGION"),
-		},
+func (m *AutoScalrManager) appDefDeleteNodes(groupId string, deltaVcpu int, nodesToDel []string) error {
+	_, err := m.client.DeleteAppNodes(&AppDefNodeDelete{
+		AutoScalingGroupName: groupId,
+		AwsRegion:            m.region,
+		DeltaVCpu:            deltaVcpu,
+		NodesToDelete:        nodesToDel,
+	})
+	if err != nil {
+		return err
 	}
-	respCode, _, err := makeApiCall(body)
-	if respCode > 400 {
-		err = fmt.Errorf("AutoScalr API returned status code: %d", respCode)
+	m.appDefs.modify(groupId, func(appDef *AppDef) {
+		appDef.TargetCapacity -= deltaVcpu
+		if appDef.TargetCapacity < 0 {
+			appDef.TargetCapacity = 0
+		}
+	})
+	return nil
+}
+
+func (m *AutoScalrManager) appDefDelete(groupId string) error {
+	if err := m.client.Delete(groupId, m.region); err != nil {
+		return err
 	}
-	return err
+	m.appDefs.setMissing(groupId, fmt.Errorf("AutoScalr app of %s was deleted", groupId))
+	return nil
 }
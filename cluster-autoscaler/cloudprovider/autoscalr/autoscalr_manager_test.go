@@ -17,90 +17,250 @@ limitations under the License.
 package autoscalr
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
+
 	"github.com/stretchr/testify/assert"
-<<<<<<< HEAD
-	"os"
-=======
-	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
-	"os"
 	apiv1 "k8s.io/api/core/v1"
->>>>>>> cluster-autoscaler-release-1.2
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
 )
 
+const testAppDefConfig = `
+[autoscalr]
+aws-region = us-east-1
+instance-types = c3.large,c3.xlarge
+target-capacity-vcpus = 1
+
+[autoscalr-group "gpuASG"]
+instance-types = p2.xlarge
+max-spot-percent-total = 0
+`
+
+func newTestAutoScalrManager(t *testing.T) (*AutoScalrManager, *FakeAutoScalrServer) {
+	server := NewFakeAutoScalrServer()
+	do := cloudprovider.NodeGroupDiscoveryOptions{}
+	m, err := createAutoScalrManagerInternal(strings.NewReader(testAppDefConfig), do, server.Client("myApiKey"))
+	assert.NoError(t, err)
+	return m, server
+}
+
 func TestOne(t *testing.T) {
 	assert.Equal(t, "us-east-1", "us-east-1")
 }
 
 func TestCreateAutoScalrManager(t *testing.T) {
-<<<<<<< HEAD
-	asrMgr, _ := CreateAutoScalrManager(nil)
-=======
 	do := cloudprovider.NodeGroupDiscoveryOptions{}
-	asrMgr, _ := CreateAutoScalrManager(nil, do)
->>>>>>> cluster-autoscaler-release-1.2
+	asrMgr, err := CreateAutoScalrManager(strings.NewReader(testAppDefConfig), do)
+	assert.NoError(t, err)
 	assert.NotNil(t, asrMgr)
+	assert.Equal(t, "us-east-1", asrMgr.region)
+	model, err := asrMgr.capacityModel("testASG")
+	assert.NoError(t, err)
+	assert.Equal(t, "c3.large", model.representative.InstanceType)
+	model, err = asrMgr.capacityModel("gpuASG")
+	assert.NoError(t, err)
+	assert.Equal(t, "p2.xlarge", model.representative.InstanceType)
+
+	_, err = CreateAutoScalrManager(strings.NewReader("[autoscalr]\naws-region = us-east-1\ninstance-types = z9.huge\n"), do)
+	assert.Error(t, err)
+}
+
+func TestCreateAutoScalrManagerFromConfig(t *testing.T) {
+	server := NewFakeAutoScalrServer()
+	defer server.Close()
+
+	cfg := fmt.Sprintf(`
+[global]
+zone = us-east-1a
+
+[autoscalr]
+api-key = configApiKey
+api-url = %s
+cluster-state-api-url = %s
+api-timeout = 5s
+aws-region = us-east-1
+instance-types = c3.large
+`, server.URL, server.URL)
+	do := cloudprovider.NodeGroupDiscoveryOptions{}
+	m, err := CreateAutoScalrManager(strings.NewReader(cfg), do)
+	assert.NoError(t, err)
+	assert.Equal(t, "configApiKey", m.client.apiKey)
+	assert.Equal(t, server.URL+appApiPath, m.client.appUrl)
+	assert.Equal(t, server.URL+clusterStateApiPath, m.client.clusterStateUrl)
+	assert.Equal(t, 5*time.Second, m.client.httpClient.Timeout)
+
+	assert.NoError(t, m.appDefCreate("testASG"))
+	assert.NotNil(t, server.AppDef("testASG", "us-east-1"))
+
+	_, err = CreateAutoScalrManager(strings.NewReader("[autoscalr]\napi-timeout = soon\naws-region = us-east-1\ninstance-types = c3.large\n"), do)
+	assert.Error(t, err)
+	_, err = CreateAutoScalrManager(strings.NewReader("[autoscalr]\napp-cache-ttl = soon\naws-region = us-east-1\ninstance-types = c3.large\n"), do)
+	assert.Error(t, err)
+}
+
+func TestAppDefCreate(t *testing.T) {
+	m, server := newTestAutoScalrManager(t)
+	defer server.Close()
+
+	err := m.appDefCreate("testASG")
+	assert.NoError(t, err)
+
+	app := server.AppDef("testASG", "us-east-1")
+	assert.NotNil(t, app)
+	assert.Equal(t, []string{"c3.large", "c3.xlarge"}, app.InstanceTypes)
+	assert.Equal(t, 1, app.TargetCapacity)
+	assert.Equal(t, appTypeK8s, app.AppType)
+	assert.Nil(t, server.AppDef("gpuASG", "us-east-1"))
 }
 
-func TestAppDefCreate(t *testing.T){
-	os.Setenv("AUTOSCALING_GROUP_NAME", "testASG")
-	os.Setenv("AWS_REGION", "us-east-1")
-	os.Setenv("INSTANCE_TYPES", "c3.large,c3.xlarge")
-	os.Setenv("TARGET_CAPACITY_VCPUS", "1")
-	err := appDefCreate()
+func TestAppDefCreatePerGroup(t *testing.T) {
+	m, server := newTestAutoScalrManager(t)
+	defer server.Close()
+
+	assert.NoError(t, m.appDefCreate("testASG"))
+	assert.NoError(t, m.appDefCreate("gpuASG"))
+
+	general := server.AppDef("testASG", "us-east-1")
+	assert.Equal(t, []string{"c3.large", "c3.xlarge"}, general.InstanceTypes)
+	gpu := server.AppDef("gpuASG", "us-east-1")
+	assert.Equal(t, "gpuASG", gpu.AutoScalingGroupName)
+	assert.Equal(t, []string{"p2.xlarge"}, gpu.InstanceTypes)
+	assert.Equal(t, 0, gpu.MaxSpotPercentTotal)
+	// Unset group variables are inherited from the [autoscalr] section.
+	assert.Equal(t, 1, gpu.TargetCapacity)
+
+	assert.NoError(t, m.appDefUpdate("gpuASG", 8))
+	assert.Equal(t, 8, server.AppDef("gpuASG", "us-east-1").TargetCapacity)
+	assert.Equal(t, 1, server.AppDef("testASG", "us-east-1").TargetCapacity)
+
+	assert.NoError(t, m.appDefDelete("gpuASG"))
+	assert.Nil(t, server.AppDef("gpuASG", "us-east-1"))
+	assert.NotNil(t, server.AppDef("testASG", "us-east-1"))
+}
+
+func TestAppDefRead(t *testing.T) {
+	m, server := newTestAutoScalrManager(t)
+	defer server.Close()
+
+	_, err := m.appDefRead("testASG")
+	assert.Error(t, err)
 
+	server.SetAppDef(&AppDef{AutoScalingGroupName: "testASG", AwsRegion: "us-east-1", TargetCapacity: 4})
+	m.Refresh([]string{"testASG"})
+	appDefTest, err := m.appDefRead("testASG")
 	assert.NoError(t, err)
+	assert.Equal(t, 4, appDefTest.TargetCapacity)
 }
 
-func TestAppDefRead(t *testing.T){
-	os.Setenv("AUTOSCALING_GROUP_NAME", "testASG")
-	os.Setenv("AWS_REGION", "us-east-1")
+func TestAppDefCache(t *testing.T) {
+	m, server := newTestAutoScalrManager(t)
+	defer server.Close()
+	server.SetAppDef(&AppDef{AutoScalingGroupName: "testASG", AwsRegion: "us-east-1", TargetCapacity: 4})
 
-	appDefTest, err := appDefRead()
+	m.Refresh([]string{"testASG", "gpuASG"})
+	assert.Equal(t, 2, server.Requests(requestTypeGet))
+	for i := 0; i < 3; i++ {
+		app, err := m.appDefRead("testASG")
+		assert.NoError(t, err)
+		assert.Equal(t, 4, app.TargetCapacity)
+		assert.True(t, m.appDefExists("testASG"))
+		assert.False(t, m.appDefExists("gpuASG"))
+	}
+	assert.Equal(t, 2, server.Requests(requestTypeGet))
 
-	assert.NoError(t, err, appDefTest)
+	// Writes update the cache without reading the app again.
+	assert.NoError(t, m.appDefUpdate("testASG", 6))
+	app, err := m.appDefRead("testASG")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, app.TargetCapacity)
+	assert.NoError(t, m.appDefDeleteNodes("testASG", 1, []string{"nodeId1"}))
+	app, err = m.appDefRead("testASG")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, app.TargetCapacity)
+	assert.Equal(t, 2, server.Requests(requestTypeGet))
+
+	// Groups that are no longer refreshed are forgotten.
+	m.Refresh([]string{"gpuASG"})
+	_, found := m.appDefs.get("testASG")
+	assert.False(t, found)
 }
 
-func TestAppDefUpdate(t *testing.T){
-	os.Setenv("AUTOSCALING_GROUP_NAME", "testASG")
-	os.Setenv("AWS_REGION", "us-east-1")
-	target_capacity := 2
+func TestAppDefCacheStaleOnError(t *testing.T) {
+	m, server := newTestAutoScalrManager(t)
+	defer server.Close()
+	now := time.Now()
+	m.appDefs.now = func() time.Time { return now }
+	server.SetAppDef(&AppDef{AutoScalingGroupName: "testASG", AwsRegion: "us-east-1", TargetCapacity: 4})
+	m.Refresh([]string{"testASG"})
+
+	server.SetUnavailable(true)
+	now = now.Add(time.Minute)
+	m.Refresh([]string{"testASG"})
+	app, err := m.appDefRead("testASG")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, app.TargetCapacity)
+	assert.True(t, m.appDefExists("testASG"))
+
+	// Past the TTL the cached app is no longer served, but the group doesn't vanish.
+	now = now.Add(defaultAppDefCacheTTL)
+	_, err = m.appDefRead("testASG")
+	assert.Error(t, err)
+	assert.True(t, m.appDefExists("testASG"))
 
-	err := appDefUpdate(target_capacity)
+	server.SetUnavailable(false)
+	m.Refresh([]string{"testASG"})
+	app, err = m.appDefRead("testASG")
 	assert.NoError(t, err)
+	assert.Equal(t, 4, app.TargetCapacity)
 }
 
-func TestAppDefDeleteNodes(t *testing.T){
-	os.Setenv("AUTOSCALING_GROUP_NAME", "testASG")
-	os.Setenv("AWS_REGION", "us-east-1")
-	delta_vcpu := 1
-	nodes2Del := make([]string, 1)
-	nodes2Del[0] = "nodeId1"
+func TestAppDefUpdate(t *testing.T) {
+	m, server := newTestAutoScalrManager(t)
+	defer server.Close()
+	server.SetAppDef(&AppDef{AutoScalingGroupName: "testASG", AwsRegion: "us-east-1", TargetCapacity: 1})
 
-	err := appDefDeleteNodes(delta_vcpu, nodes2Del)
+	err := m.appDefUpdate("testASG", 2)
 	assert.NoError(t, err)
+	assert.Equal(t, 2, server.AppDef("testASG", "us-east-1").TargetCapacity)
 }
 
-func TestAppDefDelete(t *testing.T){
-	os.Setenv("AUTOSCALING_GROUP_NAME", "testASG")
-	os.Setenv("AWS_REGION", "us-east-1")
+func TestAppDefDeleteNodes(t *testing.T) {
+	m, server := newTestAutoScalrManager(t)
+	defer server.Close()
+	server.SetAppDef(&AppDef{AutoScalingGroupName: "testASG", AwsRegion: "us-east-1", TargetCapacity: 2})
 
-	err := appDefDelete()
+	err := m.appDefDeleteNodes("testASG", 1, []string{"nodeId1"})
 	assert.NoError(t, err)
+	assert.Equal(t, []string{"nodeId1"}, server.DeletedNodes("testASG", "us-east-1"))
 }
-<<<<<<< HEAD
-=======
-
-func TestApplyLabels(t *testing.T){
-	scsResp := new(SendClusterStateResponse)
-	lblEntry := new(LabelUpdate)
-	lblEntry.InstanceId = "id1"
-	lblEntry.UID = "uid1"
-	lblEntry.PayModel = "spot"
-	scsResp.LabelUpdates = append(scsResp.LabelUpdates, *lblEntry)
-	nodes := make([]apiv1.Node, 1)
-
-	err := ApplyLabels(scsResp, nodes)
+
+func TestUpdateNodeInstanceTypes(t *testing.T) {
+	m, server := newTestAutoScalrManager(t)
+	defer server.Close()
+
+	m.UpdateNodeInstanceTypes([]*apiv1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{kubeletapis.LabelInstanceType: "c3.large"}},
+			Spec:       apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/i-1"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node2", Labels: map[string]string{}},
+			Spec:       apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/i-2"},
+		},
+	})
+	assert.Equal(t, []string{"c3.large", "", ""}, m.instanceTypesOf([]string{"aws:///us-east-1a/i-1", "aws:///us-east-1a/i-2", "aws:///us-east-1a/i-3"}))
+}
+
+func TestAppDefDelete(t *testing.T) {
+	m, server := newTestAutoScalrManager(t)
+	defer server.Close()
+	server.SetAppDef(&AppDef{AutoScalingGroupName: "testASG", AwsRegion: "us-east-1"})
+
+	err := m.appDefDelete("testASG")
 	assert.NoError(t, err)
+	assert.Nil(t, server.AppDef("testASG", "us-east-1"))
 }
->>>>>>> cluster-autoscaler-release-1.2
@@ -0,0 +1,271 @@
+/*
+Copyright 2017 AutoScalr
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscalr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// FakeAutoScalrServer is an in-process AutoScalr API that keeps app definitions in memory.
+// It serves both the app definition and the cluster state APIs, so a client pointed at
+// its URL can be used to test the provider offline.
+type FakeAutoScalrServer struct {
+	*httptest.Server
+
+	mutex         sync.Mutex
+	apps          map[string]*AppDef
+	deletedNodes  map[string][]string
+	clusterStates []*ClusterStateSnapshot
+	labelUpdates  []LabelUpdate
+	requests      map[string]int
+	unavailable   bool
+	// fullStateRequired makes the next cluster state answer ask for a full snapshot.
+	fullStateRequired bool
+}
+
+// fakeRequest is the common envelope of all app definition requests.
+type fakeRequest struct {
+	AsrToken          string          `json:"api_key"`
+	RequestType       string          `json:"request_type"`
+	OverwriteExisting bool            `json:"overwrite_existing"`
+	AsrAppDef         json.RawMessage `json:"autoscalr_app_def"`
+}
+
+// NewFakeAutoScalrServer starts a FakeAutoScalrServer. It should be closed by the caller.
+func NewFakeAutoScalrServer() *FakeAutoScalrServer {
+	s := &FakeAutoScalrServer{
+		apps:         make(map[string]*AppDef),
+		deletedNodes: make(map[string][]string),
+		requests:     make(map[string]int),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(appApiPath, s.handleApp)
+	mux.HandleFunc(clusterStateApiPath, s.handleClusterState)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Client returns an AutoScalrClient talking to this server.
+func (s *FakeAutoScalrServer) Client(apiKey string) *AutoScalrClient {
+	return NewAutoScalrClient(s.URL, s.URL, apiKey, DefaultApiTimeout)
+}
+
+// AppDef returns a copy of the stored app definition or nil if there is none.
+func (s *FakeAutoScalrServer) AppDef(asgName, region string) *AppDef {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	app, found := s.apps[fakeAppKey(asgName, region)]
+	if !found {
+		return nil
+	}
+	appCopy := *app
+	return &appCopy
+}
+
+// SetAppDef stores the given app definition, replacing any existing one.
+func (s *FakeAutoScalrServer) SetAppDef(app *AppDef) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	appCopy := *app
+	s.apps[fakeAppKey(app.AutoScalingGroupName, app.AwsRegion)] = &appCopy
+}
+
+// DeletedNodes returns the instance ids deleted from the given app definition.
+func (s *FakeAutoScalrServer) DeletedNodes(asgName, region string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.deletedNodes[fakeAppKey(asgName, region)]...)
+}
+
+// SetLabelUpdates sets the label updates returned for every cluster state upload.
+func (s *FakeAutoScalrServer) SetLabelUpdates(updates []LabelUpdate) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.labelUpdates = updates
+}
+
+// ClusterStates returns the cluster state snapshots received so far.
+func (s *FakeAutoScalrServer) ClusterStates() []*ClusterStateSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*ClusterStateSnapshot{}, s.clusterStates...)
+}
+
+// RequireFullState makes the server ask for a full snapshot in its next cluster state answer.
+func (s *FakeAutoScalrServer) RequireFullState() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fullStateRequired = true
+}
+
+// Requests returns how many requests of the given type were received.
+func (s *FakeAutoScalrServer) Requests(requestType string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests[requestType]
+}
+
+// SetUnavailable makes the server answer every request with 503 Service Unavailable.
+func (s *FakeAutoScalrServer) SetUnavailable(unavailable bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.unavailable = unavailable
+}
+
+func fakeAppKey(asgName, region string) string {
+	return region + "/" + asgName
+}
+
+func (s *FakeAutoScalrServer) handleApp(w http.ResponseWriter, r *http.Request) {
+	req := fakeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.unavailable {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	s.requests[req.RequestType]++
+
+	switch req.RequestType {
+	case requestTypeCreate:
+		app := new(AppDef)
+		if err := json.Unmarshal(req.AsrAppDef, app); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		key := fakeAppKey(app.AutoScalingGroupName, app.AwsRegion)
+		if existing, found := s.apps[key]; found && !req.OverwriteExisting {
+			writeFakeResponse(w, existing)
+			return
+		}
+		s.apps[key] = app
+		writeFakeResponse(w, app)
+	case requestTypeGet:
+		app := new(AppDef)
+		if err := json.Unmarshal(req.AsrAppDef, app); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		existing, found := s.apps[fakeAppKey(app.AutoScalingGroupName, app.AwsRegion)]
+		if !found {
+			writeFakeError(w, "NotFound", fmt.Sprintf("App %s not found", app.AutoScalingGroupName))
+			return
+		}
+		writeFakeResponse(w, existing)
+	case requestTypeUpdate:
+		update := new(AppDefUpdate)
+		if err := json.Unmarshal(req.AsrAppDef, update); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		existing, found := s.apps[fakeAppKey(update.AutoScalingGroupName, update.AwsRegion)]
+		if !found {
+			writeFakeError(w, "NotFound", fmt.Sprintf("App %s not found", update.AutoScalingGroupName))
+			return
+		}
+		existing.TargetCapacity = update.TargetCapacity
+		writeFakeResponse(w, existing)
+	case requestTypeDecreaseTargetCapacity:
+		decrease := new(AppDefTargetDecrease)
+		if err := json.Unmarshal(req.AsrAppDef, decrease); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		existing, found := s.apps[fakeAppKey(decrease.AutoScalingGroupName, decrease.AwsRegion)]
+		if !found {
+			writeFakeError(w, "NotFound", fmt.Sprintf("App %s not found", decrease.AutoScalingGroupName))
+			return
+		}
+		existing.TargetCapacity -= decrease.DeltaVCpu
+		if existing.TargetCapacity < 0 {
+			existing.TargetCapacity = 0
+		}
+		writeFakeResponse(w, existing)
+	case requestTypeDeleteAppNodes:
+		nodeDelete := new(AppDefNodeDelete)
+		if err := json.Unmarshal(req.AsrAppDef, nodeDelete); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		key := fakeAppKey(nodeDelete.AutoScalingGroupName, nodeDelete.AwsRegion)
+		existing, found := s.apps[key]
+		if !found {
+			writeFakeError(w, "NotFound", fmt.Sprintf("App %s not found", nodeDelete.AutoScalingGroupName))
+			return
+		}
+		existing.TargetCapacity -= nodeDelete.DeltaVCpu
+		if existing.TargetCapacity < 0 {
+			existing.TargetCapacity = 0
+		}
+		s.deletedNodes[key] = append(s.deletedNodes[key], nodeDelete.NodesToDelete...)
+		writeFakeResponse(w, existing)
+	case requestTypeDelete:
+		app := new(AppDef)
+		if err := json.Unmarshal(req.AsrAppDef, app); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		delete(s.apps, fakeAppKey(app.AutoScalingGroupName, app.AwsRegion))
+		writeFakeResponse(w, app)
+	default:
+		writeFakeError(w, "BadRequest", fmt.Sprintf("Unknown request type %s", req.RequestType))
+	}
+}
+
+func (s *FakeAutoScalrServer) handleClusterState(w http.ResponseWriter, r *http.Request) {
+	state := new(ClusterStateSnapshot)
+	if err := json.NewDecoder(r.Body).Decode(state); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.unavailable {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	s.clusterStates = append(s.clusterStates, state)
+	writeFakeResponse(w, &SendClusterStateResponse{
+		LabelUpdates:      s.labelUpdates,
+		FullStateRequired: s.fullStateRequired,
+	})
+	s.fullStateRequired = false
+}
+
+func writeFakeResponse(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeFakeError(w http.ResponseWriter, code, message string) {
+	writeFakeResponse(w, &AsrApiErrorResponse{
+		Error: &AsrApiError{
+			ErrorMessage: message,
+			Code:         code,
+		},
+	})
+}
@@ -21,9 +21,13 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/glog"
 )
 
+// defaultLaunchTemplateVersion is used for launch templates that don't specify a version.
+const defaultLaunchTemplateVersion = "$Default"
+
 // autoScaling is the interface represents a specific aspect of the auto-scaling service provided by AWS SDK for use in CA
 type autoScaling interface {
 	DescribeAutoScalingGroups(input *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error)
@@ -32,6 +36,21 @@ type autoScaling interface {
 	DescribeTagsPages(input *autoscaling.DescribeTagsInput, fn func(*autoscaling.DescribeTagsOutput, bool) bool) error
 	SetDesiredCapacity(input *autoscaling.SetDesiredCapacityInput) (*autoscaling.SetDesiredCapacityOutput, error)
 	TerminateInstanceInAutoScalingGroup(input *autoscaling.TerminateInstanceInAutoScalingGroupInput) (*autoscaling.TerminateInstanceInAutoScalingGroupOutput, error)
+	DescribeLaunchTemplateVersions(input *ec2.DescribeLaunchTemplateVersionsInput) (*ec2.DescribeLaunchTemplateVersionsOutput, error)
+	CreateAutoScalingGroup(input *autoscaling.CreateAutoScalingGroupInput) (*autoscaling.CreateAutoScalingGroupOutput, error)
+	DeleteAutoScalingGroup(input *autoscaling.DeleteAutoScalingGroupInput) (*autoscaling.DeleteAutoScalingGroupOutput, error)
+}
+
+// autoScalingServices implements autoScaling with the auto-scaling service, and the EC2 service
+// for launch template lookups.
+type autoScalingServices struct {
+	*autoscaling.AutoScaling
+	ec2Service *ec2.EC2
+}
+
+// DescribeLaunchTemplateVersions calls the EC2 service.
+func (s autoScalingServices) DescribeLaunchTemplateVersions(input *ec2.DescribeLaunchTemplateVersionsInput) (*ec2.DescribeLaunchTemplateVersionsOutput, error) {
+	return s.ec2Service.DescribeLaunchTemplateVersions(input)
 }
 
 // autoScalingWrapper provides several utility methods over the auto-scaling service provided by AWS SDK
@@ -56,6 +75,35 @@ func (m autoScalingWrapper) getInstanceTypeByLCName(name string) (string, error)
 	return *launchConfigurations.LaunchConfigurations[0].InstanceType, nil
 }
 
+func (m autoScalingWrapper) getInstanceTypeByLT(spec *autoscaling.LaunchTemplateSpecification) (string, error) {
+	params := &ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateId:   spec.LaunchTemplateId,
+		LaunchTemplateName: spec.LaunchTemplateName,
+		Versions:           []*string{aws.String(defaultLaunchTemplateVersion)},
+	}
+	if spec.Version != nil {
+		params.Versions = []*string{spec.Version}
+	}
+	name := aws.StringValue(spec.LaunchTemplateName)
+	if name == "" {
+		name = aws.StringValue(spec.LaunchTemplateId)
+	}
+	versions, err := m.DescribeLaunchTemplateVersions(params)
+	if err != nil {
+		glog.V(4).Infof("Failed LaunchTemplate info request for %s: %v", name, err)
+		return "", err
+	}
+	if len(versions.LaunchTemplateVersions) < 1 {
+		return "", fmt.Errorf("Unable to get LaunchTemplate version %s for %s", aws.StringValue(params.Versions[0]), name)
+	}
+	data := versions.LaunchTemplateVersions[0].LaunchTemplateData
+	if data == nil || data.InstanceType == nil {
+		return "", fmt.Errorf("LaunchTemplate %s has no instance type", name)
+	}
+
+	return *data.InstanceType, nil
+}
+
 func (m autoScalingWrapper) getAutoscalingGroupByName(name string) (*autoscaling.Group, error) {
 	params := &autoscaling.DescribeAutoScalingGroupsInput{
 		AutoScalingGroupNames: []*string{aws.String(name)},
@@ -0,0 +1,346 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/glog"
+	"k8s.io/autoscaler/cluster-autoscaler/metrics"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	defaultAPIQPS        = 10
+	defaultAPIBurst      = 20
+	defaultAPIMaxRetries = 5
+
+	apiInitialBackoff = 500 * time.Millisecond
+	apiMaxBackoff     = 30 * time.Second
+
+	// describeCacheTTL is how long described groups answer DescribeAutoScalingGroups requests, so
+	// that describing the groups one by one during a loop of the autoscaler takes a single request.
+	describeCacheTTL = 5 * time.Second
+	// maxGroupNamesPerDescribe is the number of group names described by one request.
+	maxGroupNamesPerDescribe = 50
+)
+
+// rateLimitedAutoScaling implements autoScaling on top of another autoScaling. Requests are rate
+// limited by a token bucket, retried with exponential backoff after throttling and transient
+// errors, and recorded in the AWS request metrics.
+//
+// DescribeAutoScalingGroups requests for given groups are batched: every group described recently
+// is described again by the same request, and the result answers the following requests for
+// describeCacheTTL. Requests made while groups are being described wait for that description
+// rather than making their own. Writes to a group make the next request go to AWS.
+type rateLimitedAutoScaling struct {
+	service    autoScaling
+	limiter    flowcontrol.RateLimiter
+	maxRetries int
+	sleep      func(time.Duration)
+	now        func() time.Time
+
+	mutex       sync.Mutex
+	groups      map[string]*autoscaling.Group
+	describedAt time.Time
+	// describing is closed when the groups being described are, nil if none are.
+	describing chan struct{}
+	// generation is increased by writes, so that descriptions started before don't count as recent.
+	generation int
+}
+
+// newRateLimitedAutoScaling builds a rateLimitedAutoScaling, using the defaults for values <= 0.
+func newRateLimitedAutoScaling(service autoScaling, qps float64, burst int, maxRetries int) *rateLimitedAutoScaling {
+	if qps <= 0 {
+		qps = defaultAPIQPS
+	}
+	if burst <= 0 {
+		burst = defaultAPIBurst
+	}
+	if maxRetries <= 0 {
+		maxRetries = defaultAPIMaxRetries
+	}
+	return &rateLimitedAutoScaling{
+		service:    service,
+		limiter:    flowcontrol.NewTokenBucketRateLimiter(float32(qps), burst),
+		maxRetries: maxRetries,
+		sleep:      time.Sleep,
+		now:        time.Now,
+		groups:     make(map[string]*autoscaling.Group),
+	}
+}
+
+// pagesError is returned by paginated requests failing after some pages were handed over.
+// They can't be taken back, so such requests aren't retried.
+type pagesError struct {
+	err error
+}
+
+func (e pagesError) Error() string {
+	return e.err.Error()
+}
+
+func isRetryable(err error) bool {
+	return request.IsErrorThrottle(err) || request.IsErrorRetryable(err)
+}
+
+func requestResult(err error) metrics.AwsRequestResult {
+	switch {
+	case err == nil:
+		return metrics.AwsRequestSuccess
+	case request.IsErrorThrottle(err):
+		return metrics.AwsRequestThrottled
+	default:
+		return metrics.AwsRequestError
+	}
+}
+
+// call makes a request, and makes it again while it fails with a retryable error, up to maxRetries times.
+func (c *rateLimitedAutoScaling) call(operation string, makeRequest func() error) error {
+	backoff := apiInitialBackoff
+	for attempt := 0; ; attempt++ {
+		c.limiter.Accept()
+		start := c.now()
+		err := makeRequest()
+		metrics.RegisterAwsRequest(operation, requestResult(err), c.now().Sub(start))
+		if pagesErr, ok := err.(pagesError); ok {
+			return pagesErr.err
+		}
+		if err == nil || attempt >= c.maxRetries || !isRetryable(err) {
+			return err
+		}
+		glog.V(2).Infof("AWS %s request failed, retrying in %v: %v", operation, backoff, err)
+		c.sleep(backoff)
+		backoff *= 2
+		if backoff > apiMaxBackoff {
+			backoff = apiMaxBackoff
+		}
+	}
+}
+
+func (c *rateLimitedAutoScaling) DescribeAutoScalingGroups(input *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	names := aws.StringValueSlice(input.AutoScalingGroupNames)
+	if len(names) == 0 || input.NextToken != nil {
+		// Describing every group, or a further page, isn't batched.
+		var output *autoscaling.DescribeAutoScalingGroupsOutput
+		err := c.call("DescribeAutoScalingGroups", func() (err error) {
+			output, err = c.service.DescribeAutoScalingGroups(input)
+			return err
+		})
+		return output, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for !c.describedLocked(names) {
+		if c.describing != nil {
+			// Groups are being described, they may include the requested ones.
+			describing := c.describing
+			c.mutex.Unlock()
+			<-describing
+			c.mutex.Lock()
+			continue
+		}
+		if err := c.describeUnlocked(names); err != nil {
+			return nil, err
+		}
+		break
+	}
+	output := &autoscaling.DescribeAutoScalingGroupsOutput{}
+	for _, name := range names {
+		if group, found := c.groups[name]; found {
+			output.AutoScalingGroups = append(output.AutoScalingGroups, group)
+		}
+	}
+	return output, nil
+}
+
+// describedLocked returns true if all the groups were described less than describeCacheTTL ago.
+func (c *rateLimitedAutoScaling) describedLocked(names []string) bool {
+	if c.now().Sub(c.describedAt) >= describeCacheTTL {
+		return false
+	}
+	for _, name := range names {
+		if _, found := c.groups[name]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+// describeUnlocked describes the groups along with every group described before. Groups that
+// no longer exist are forgotten. It is called with the mutex held, and releases it while the
+// requests are made, and retried, so that described groups can still be read.
+func (c *rateLimitedAutoScaling) describeUnlocked(names []string) error {
+	all := make(map[string]bool, len(names)+len(c.groups))
+	for _, name := range names {
+		all[name] = true
+	}
+	for name := range c.groups {
+		all[name] = true
+	}
+	describing := make(chan struct{})
+	c.describing = describing
+	generation := c.generation
+	c.mutex.Unlock()
+
+	groups, err := c.describeGroups(all)
+
+	c.mutex.Lock()
+	c.describing = nil
+	close(describing)
+	if err != nil {
+		return err
+	}
+	c.groups = groups
+	if c.generation == generation {
+		c.describedAt = c.now()
+	}
+	return nil
+}
+
+// describeGroups describes the groups of the given names, in batches of maxGroupNamesPerDescribe.
+func (c *rateLimitedAutoScaling) describeGroups(names map[string]bool) (map[string]*autoscaling.Group, error) {
+	batch := make([]string, 0, maxGroupNamesPerDescribe)
+	groups := make(map[string]*autoscaling.Group, len(names))
+	describe := func() error {
+		input := &autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: aws.StringSlice(batch),
+			MaxRecords:            aws.Int64(maxRecordsReturnedByAPI),
+		}
+		batch = make([]string, 0, maxGroupNamesPerDescribe)
+		return c.DescribeAutoScalingGroupsPages(input, func(output *autoscaling.DescribeAutoScalingGroupsOutput, _ bool) bool {
+			for _, group := range output.AutoScalingGroups {
+				groups[aws.StringValue(group.AutoScalingGroupName)] = group
+			}
+			return true
+		})
+	}
+	for name := range names {
+		batch = append(batch, name)
+		if len(batch) == maxGroupNamesPerDescribe {
+			if err := describe(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := describe(); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// invalidate makes the next DescribeAutoScalingGroups request go to AWS.
+func (c *rateLimitedAutoScaling) invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.describedAt = time.Time{}
+	c.generation++
+}
+
+func (c *rateLimitedAutoScaling) DescribeAutoScalingGroupsPages(input *autoscaling.DescribeAutoScalingGroupsInput, fn func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	return c.call("DescribeAutoScalingGroups", func() error {
+		handedOver := false
+		err := c.service.DescribeAutoScalingGroupsPages(input, func(output *autoscaling.DescribeAutoScalingGroupsOutput, last bool) bool {
+			handedOver = true
+			return fn(output, last)
+		})
+		if err != nil && handedOver {
+			return pagesError{err}
+		}
+		return err
+	})
+}
+
+func (c *rateLimitedAutoScaling) DescribeLaunchConfigurations(input *autoscaling.DescribeLaunchConfigurationsInput) (*autoscaling.DescribeLaunchConfigurationsOutput, error) {
+	var output *autoscaling.DescribeLaunchConfigurationsOutput
+	err := c.call("DescribeLaunchConfigurations", func() (err error) {
+		output, err = c.service.DescribeLaunchConfigurations(input)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedAutoScaling) DescribeTagsPages(input *autoscaling.DescribeTagsInput, fn func(*autoscaling.DescribeTagsOutput, bool) bool) error {
+	return c.call("DescribeTags", func() error {
+		handedOver := false
+		err := c.service.DescribeTagsPages(input, func(output *autoscaling.DescribeTagsOutput, last bool) bool {
+			handedOver = true
+			return fn(output, last)
+		})
+		if err != nil && handedOver {
+			return pagesError{err}
+		}
+		return err
+	})
+}
+
+func (c *rateLimitedAutoScaling) SetDesiredCapacity(input *autoscaling.SetDesiredCapacityInput) (*autoscaling.SetDesiredCapacityOutput, error) {
+	defer c.invalidate()
+	var output *autoscaling.SetDesiredCapacityOutput
+	err := c.call("SetDesiredCapacity", func() (err error) {
+		output, err = c.service.SetDesiredCapacity(input)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedAutoScaling) TerminateInstanceInAutoScalingGroup(input *autoscaling.TerminateInstanceInAutoScalingGroupInput) (*autoscaling.TerminateInstanceInAutoScalingGroupOutput, error) {
+	defer c.invalidate()
+	var output *autoscaling.TerminateInstanceInAutoScalingGroupOutput
+	err := c.call("TerminateInstanceInAutoScalingGroup", func() (err error) {
+		output, err = c.service.TerminateInstanceInAutoScalingGroup(input)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedAutoScaling) CreateAutoScalingGroup(input *autoscaling.CreateAutoScalingGroupInput) (*autoscaling.CreateAutoScalingGroupOutput, error) {
+	defer c.invalidate()
+	var output *autoscaling.CreateAutoScalingGroupOutput
+	err := c.call("CreateAutoScalingGroup", func() (err error) {
+		output, err = c.service.CreateAutoScalingGroup(input)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedAutoScaling) DeleteAutoScalingGroup(input *autoscaling.DeleteAutoScalingGroupInput) (*autoscaling.DeleteAutoScalingGroupOutput, error) {
+	defer c.invalidate()
+	var output *autoscaling.DeleteAutoScalingGroupOutput
+	err := c.call("DeleteAutoScalingGroup", func() (err error) {
+		output, err = c.service.DeleteAutoScalingGroup(input)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedAutoScaling) DescribeLaunchTemplateVersions(input *ec2.DescribeLaunchTemplateVersionsInput) (*ec2.DescribeLaunchTemplateVersionsOutput, error) {
+	var output *ec2.DescribeLaunchTemplateVersionsOutput
+	err := c.call("DescribeLaunchTemplateVersions", func() (err error) {
+		output, err = c.service.DescribeLaunchTemplateVersions(input)
+		return err
+	})
+	return output, err
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// flakyAutoScaling fails SetDesiredCapacity with the given errors before succeeding.
+type flakyAutoScaling struct {
+	*AutoScalingMock
+	errs []error
+}
+
+func (f *flakyAutoScaling) SetDesiredCapacity(input *autoscaling.SetDesiredCapacityInput) (*autoscaling.SetDesiredCapacityOutput, error) {
+	f.Called(input)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return &autoscaling.SetDesiredCapacityOutput{}, nil
+}
+
+func newTestRateLimitedAutoScaling(service autoScaling) (*rateLimitedAutoScaling, *[]time.Duration) {
+	c := newRateLimitedAutoScaling(service, 0, 0, 3)
+	c.limiter = flowcontrol.NewFakeAlwaysRateLimiter()
+	sleeps := []time.Duration{}
+	c.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	return c, &sleeps
+}
+
+func TestRateLimitedAutoScalingRetries(t *testing.T) {
+	input := &autoscaling.SetDesiredCapacityInput{AutoScalingGroupName: aws.String("asg"), DesiredCapacity: aws.Int64(3)}
+	throttled := awserr.New("Throttling", "Rate exceeded", nil)
+
+	service := &flakyAutoScaling{AutoScalingMock: &AutoScalingMock{}, errs: []error{throttled, throttled}}
+	service.On("SetDesiredCapacity", input).Return()
+	c, sleeps := newTestRateLimitedAutoScaling(service)
+	_, err := c.SetDesiredCapacity(input)
+	assert.NoError(t, err)
+	service.AssertNumberOfCalls(t, "SetDesiredCapacity", 3)
+	assert.Equal(t, []time.Duration{apiInitialBackoff, 2 * apiInitialBackoff}, *sleeps)
+
+	// Retries are given up after maxRetries.
+	service = &flakyAutoScaling{AutoScalingMock: &AutoScalingMock{}, errs: []error{throttled, throttled, throttled, throttled, throttled}}
+	service.On("SetDesiredCapacity", input).Return()
+	c, _ = newTestRateLimitedAutoScaling(service)
+	_, err = c.SetDesiredCapacity(input)
+	assert.Equal(t, throttled, err)
+	service.AssertNumberOfCalls(t, "SetDesiredCapacity", 4)
+
+	// Other errors aren't retried.
+	invalid := awserr.New("ValidationError", "New SetDesiredCapacity value 30 is above max value 10", nil)
+	service = &flakyAutoScaling{AutoScalingMock: &AutoScalingMock{}, errs: []error{invalid}}
+	service.On("SetDesiredCapacity", input).Return()
+	c, _ = newTestRateLimitedAutoScaling(service)
+	_, err = c.SetDesiredCapacity(input)
+	assert.Equal(t, invalid, err)
+	service.AssertNumberOfCalls(t, "SetDesiredCapacity", 1)
+}
+
+func TestRateLimitedAutoScalingPagesNotRetriedAfterFirstPage(t *testing.T) {
+	service := &AutoScalingMock{}
+	service.On("DescribeTagsPages", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		fn := args.Get(1).(func(*autoscaling.DescribeTagsOutput, bool) bool)
+		fn(&autoscaling.DescribeTagsOutput{}, false)
+	}).Return(awserr.New("Throttling", "Rate exceeded", nil))
+	c, _ := newTestRateLimitedAutoScaling(service)
+
+	pages := 0
+	err := c.DescribeTagsPages(&autoscaling.DescribeTagsInput{}, func(*autoscaling.DescribeTagsOutput, bool) bool {
+		pages++
+		return true
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, pages)
+	service.AssertNumberOfCalls(t, "DescribeTagsPages", 1)
+}
+
+func TestRateLimitedAutoScalingBatchesDescribes(t *testing.T) {
+	service := &AutoScalingMock{}
+	service.On("DescribeAutoScalingGroupsPages", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(0).(*autoscaling.DescribeAutoScalingGroupsInput)
+		fn := args.Get(1).(func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool)
+		output := &autoscaling.DescribeAutoScalingGroupsOutput{}
+		for _, name := range input.AutoScalingGroupNames {
+			if aws.StringValue(name) != "deleted" {
+				output.AutoScalingGroups = append(output.AutoScalingGroups, &autoscaling.Group{AutoScalingGroupName: name})
+			}
+		}
+		fn(output, true)
+	}).Return(nil)
+	service.On("SetDesiredCapacity", mock.Anything).Return(&autoscaling.SetDesiredCapacityOutput{})
+	c, _ := newTestRateLimitedAutoScaling(service)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	describe := func(name string) []*autoscaling.Group {
+		output, err := c.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: []*string{aws.String(name)},
+			MaxRecords:            aws.Int64(1),
+		})
+		assert.NoError(t, err)
+		return output.AutoScalingGroups
+	}
+
+	assert.Equal(t, 1, len(describe("a")))
+	assert.Equal(t, 1, len(describe("b")))
+	service.AssertNumberOfCalls(t, "DescribeAutoScalingGroupsPages", 2)
+
+	// Both groups were described by the last request.
+	assert.Equal(t, "a", aws.StringValue(describe("a")[0].AutoScalingGroupName))
+	service.AssertNumberOfCalls(t, "DescribeAutoScalingGroupsPages", 2)
+
+	// Writes and time make the next request go to AWS.
+	_, err := c.SetDesiredCapacity(&autoscaling.SetDesiredCapacityInput{AutoScalingGroupName: aws.String("a")})
+	assert.NoError(t, err)
+	describe("b")
+	service.AssertNumberOfCalls(t, "DescribeAutoScalingGroupsPages", 3)
+	now = now.Add(describeCacheTTL)
+	describe("a")
+	service.AssertNumberOfCalls(t, "DescribeAutoScalingGroupsPages", 4)
+
+	// Groups that don't exist are not found.
+	assert.Empty(t, describe("deleted"))
+}
+
+func TestRequestResult(t *testing.T) {
+	assert.Equal(t, "success", string(requestResult(nil)))
+	assert.Equal(t, "throttled", string(requestResult(awserr.New("RequestLimitExceeded", "", nil))))
+	assert.Equal(t, "error", string(requestResult(errors.New("boom"))))
+}
+
+// throttledDescribeAutoScaling throttles the first DescribeAutoScalingGroupsPages request.
+type throttledDescribeAutoScaling struct {
+	*AutoScalingMock
+	throttled bool
+}
+
+func (f *throttledDescribeAutoScaling) DescribeAutoScalingGroupsPages(input *autoscaling.DescribeAutoScalingGroupsInput, fn func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	f.Called(input, fn)
+	if !f.throttled {
+		f.throttled = true
+		return awserr.New("Throttling", "Rate exceeded", nil)
+	}
+	output := &autoscaling.DescribeAutoScalingGroupsOutput{}
+	for _, name := range input.AutoScalingGroupNames {
+		output.AutoScalingGroups = append(output.AutoScalingGroups, &autoscaling.Group{AutoScalingGroupName: name})
+	}
+	fn(output, true)
+	return nil
+}
+
+func TestRateLimitedAutoScalingDescribesNotLockedWhileRetried(t *testing.T) {
+	service := &throttledDescribeAutoScaling{AutoScalingMock: &AutoScalingMock{}}
+	service.On("DescribeAutoScalingGroupsPages", mock.Anything, mock.Anything).Return()
+	c, _ := newTestRateLimitedAutoScaling(service)
+	sleeping := make(chan struct{})
+	wake := make(chan struct{})
+	c.sleep = func(time.Duration) {
+		close(sleeping)
+		<-wake
+	}
+	describe := func(done chan<- int) {
+		output, err := c.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: []*string{aws.String("a")},
+		})
+		assert.NoError(t, err)
+		done <- len(output.AutoScalingGroups)
+	}
+
+	first := make(chan int)
+	go describe(first)
+	<-sleeping
+	// The mutex isn't held during the backoff, and requests for the same groups wait for it.
+	second := make(chan int)
+	go describe(second)
+	c.mutex.Lock()
+	c.mutex.Unlock()
+	close(wake)
+	assert.Equal(t, 1, <-first)
+	assert.Equal(t, 1, <-second)
+	service.AssertNumberOfCalls(t, "DescribeAutoScalingGroupsPages", 2)
+}
@@ -0,0 +1,304 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws/ec2instances"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
+)
+
+const (
+	// autoprovisionedTag marks the ASGs created by node autoprovisioning. Its value is the name
+	// of the base ASG they were cloned from.
+	autoprovisionedTag = "k8s.io/cluster-autoscaler/autoprovisioned"
+
+	labelTagPrefix = nodeTemplateTagPrefix + "label/"
+	taintTagPrefix = nodeTemplateTagPrefix + "taint/"
+
+	defaultAutoprovisionedMaxSize = 100
+
+	// gpuTaintValue taints autoprovisioned GPU nodes, so that only pods requesting GPUs run on them.
+	gpuTaintValue = "present:" + string(apiv1.TaintEffectNoSchedule)
+)
+
+// autoprovisioningConfig is the configuration of node autoprovisioning. New ASGs are clones of
+// the base ASG with another instance type. Autoprovisioning is disabled without a base ASG.
+type autoprovisioningConfig struct {
+	baseAsg string
+	maxSize int
+	// instanceTypes autoprovisioned ASGs may have. All known types if empty.
+	instanceTypes []string
+}
+
+// autoprovisionedTemplate describes an autoprovisioned ASG that isn't created yet.
+type autoprovisionedTemplate struct {
+	instanceType string
+	// tags are the node-template tags of the labels and taints of its nodes, by key.
+	tags map[string]string
+}
+
+func newAutoprovisioningConfig(baseAsg string, maxSize int, instanceTypes []string) (autoprovisioningConfig, error) {
+	for _, name := range instanceTypes {
+		if _, found := ec2instances.InstanceTypes[name]; !found {
+			return autoprovisioningConfig{}, fmt.Errorf("unknown instance type %s", name)
+		}
+	}
+	if maxSize <= 0 {
+		maxSize = defaultAutoprovisionedMaxSize
+	}
+	return autoprovisioningConfig{
+		baseAsg:       baseAsg,
+		maxSize:       maxSize,
+		instanceTypes: instanceTypes,
+	}, nil
+}
+
+// discoverySpec returns the autodiscovery config matching the ASGs autoprovisioned before.
+func (c autoprovisioningConfig) discoverySpec() cloudprovider.ASGAutoDiscoveryConfig {
+	return cloudprovider.ASGAutoDiscoveryConfig{
+		TagKeys:   []string{autoprovisionedTag},
+		TagValues: map[string]string{autoprovisionedTag: c.baseAsg},
+	}
+}
+
+// availableInstanceTypes returns the instance types of the ASGs that can be autoprovisioned.
+func (m *AwsManager) availableInstanceTypes() []string {
+	if m.autoprovisioning.baseAsg == "" {
+		return []string{}
+	}
+	if len(m.autoprovisioning.instanceTypes) > 0 {
+		return append([]string{}, m.autoprovisioning.instanceTypes...)
+	}
+	result := make([]string, 0, len(ec2instances.InstanceTypes))
+	for name := range ec2instances.InstanceTypes {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// newAutoprovisionedAsg builds an ASG of the instance type, whose nodes have the labels. Instance
+// types with GPUs are only used when GPUs are requested, and their nodes are tainted.
+// ErrIllegalConfiguration is returned for instance types not fit for the request.
+func (m *AwsManager) newAutoprovisionedAsg(instanceTypeName string, labels map[string]string, systemLabels map[string]string,
+	extraResources map[string]resource.Quantity) (*Asg, error) {
+	if m.autoprovisioning.baseAsg == "" {
+		return nil, cloudprovider.ErrNotImplemented
+	}
+	t, found := ec2instances.InstanceTypes[instanceTypeName]
+	if !found {
+		return nil, fmt.Errorf("unknown instance type %s", instanceTypeName)
+	}
+
+	var gpus int64
+	for name, quantity := range extraResources {
+		if name != gpu.ResourceNvidiaGPU {
+			return nil, cloudprovider.ErrIllegalConfiguration
+		}
+		gpus = quantity.Value()
+	}
+	if t.GPU < gpus || (gpus == 0 && t.GPU > 0) {
+		return nil, cloudprovider.ErrIllegalConfiguration
+	}
+
+	tags := make(map[string]string)
+	// Labels set from the instance type and zone can't be requested.
+	generic := buildGenericLabels(&asgTemplate{InstanceType: t}, "")
+	for key, value := range cloudprovider.JoinStringMaps(labels, systemLabels) {
+		if _, found := generic[key]; found {
+			continue
+		}
+		tags[labelTagPrefix+key] = value
+	}
+	if gpus > 0 {
+		tags[taintTagPrefix+gpu.ResourceNvidiaGPU] = gpuTaintValue
+	}
+
+	name := autoprovisionedAsgName(m.autoprovisioning.baseAsg, instanceTypeName, tags)
+	for _, asg := range m.getAsgs() {
+		if asg.config.Name == name {
+			// The ASG was already created, and is an option of its own.
+			return nil, cloudprovider.ErrIllegalConfiguration
+		}
+	}
+	return &Asg{
+		awsManager:      m,
+		AwsRef:          AwsRef{Name: name},
+		minSize:         0,
+		maxSize:         m.autoprovisioning.maxSize,
+		autoprovisioned: true,
+		template: &autoprovisionedTemplate{
+			instanceType: instanceTypeName,
+			tags:         tags,
+		},
+	}, nil
+}
+
+// autoprovisionedAsgName returns the name of the ASG cloned from base with the instance type and
+// tags. The same request always gives the same name, so that it is created once.
+func autoprovisionedAsgName(base string, instanceType string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := fnv.New32a()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s=%s\n", key, tags[key])
+	}
+	return fmt.Sprintf("%s-nap-%s-%08x", base, strings.Replace(instanceType, ".", "-", -1), hash.Sum32())
+}
+
+// autoprovisionedTags returns the tags of an ASG cloned from base with the template. The
+// node-template, size and autoprovisioned tags of base are replaced, other tags are kept.
+func autoprovisionedTags(base string, baseTags []*autoscaling.TagDescription, template *autoprovisionedTemplate) []*autoscaling.TagDescription {
+	result := make([]*autoscaling.TagDescription, 0, len(baseTags)+len(template.tags)+1)
+	for _, tag := range baseTags {
+		key := aws.StringValue(tag.Key)
+		if strings.HasPrefix(key, labelTagPrefix) || strings.HasPrefix(key, taintTagPrefix) ||
+			strings.HasPrefix(key, resourcesTagPrefix) || key == minSizeTag || key == maxSizeTag || key == autoprovisionedTag {
+			continue
+		}
+		result = append(result, &autoscaling.TagDescription{
+			Key:               tag.Key,
+			Value:             tag.Value,
+			PropagateAtLaunch: tag.PropagateAtLaunch,
+		})
+	}
+	keys := make([]string, 0, len(template.tags))
+	for key := range template.tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result = append(result, &autoscaling.TagDescription{
+			Key:               aws.String(key),
+			Value:             aws.String(template.tags[key]),
+			PropagateAtLaunch: aws.Bool(false),
+		})
+	}
+	return append(result, &autoscaling.TagDescription{
+		Key:               aws.String(autoprovisionedTag),
+		Value:             aws.String(base),
+		PropagateAtLaunch: aws.Bool(false),
+	})
+}
+
+// getAutoprovisionedAsgTemplate returns the template of an ASG that isn't created yet, in a zone
+// of the base ASG. With an empty zone it is in the first zone of the base ASG, where its first
+// instance will be launched.
+func (m *AwsManager) getAutoprovisionedAsgTemplate(template *autoprovisionedTemplate, zone string) (*asgTemplate, error) {
+	if zone == "" {
+		group, err := m.service.getAutoscalingGroupByName(m.autoprovisioning.baseAsg)
+		if err != nil {
+			return nil, err
+		}
+		if len(group.AvailabilityZones) < 1 {
+			return nil, fmt.Errorf("Unable to get first AvailabilityZone for %s", m.autoprovisioning.baseAsg)
+		}
+		zone = aws.StringValue(group.AvailabilityZones[0])
+	}
+	base, err := m.getAsgTemplate(m.autoprovisioning.baseAsg, zone)
+	if err != nil {
+		return nil, err
+	}
+	return &asgTemplate{
+		InstanceType: ec2instances.InstanceTypes[template.instanceType],
+		Region:       base.Region,
+		Zone:         base.Zone,
+		Tags:         autoprovisionedTags(m.autoprovisioning.baseAsg, base.Tags, template),
+	}, nil
+}
+
+// createAutoprovisionedAsg creates the ASG as a clone of the base ASG. It uses the launch template
+// of the base ASG, with its instance type overridden by a mixed instances policy.
+func (m *AwsManager) createAutoprovisionedAsg(asg *Asg) error {
+	base, err := m.service.getAutoscalingGroupByName(m.autoprovisioning.baseAsg)
+	if err != nil {
+		return err
+	}
+	policy := &autoscaling.MixedInstancesPolicy{
+		LaunchTemplate: &autoscaling.LaunchTemplate{
+			LaunchTemplateSpecification: base.LaunchTemplate,
+			Overrides: []*autoscaling.LaunchTemplateOverrides{
+				{InstanceType: aws.String(asg.template.instanceType)},
+			},
+		},
+	}
+	if base.MixedInstancesPolicy != nil && base.MixedInstancesPolicy.LaunchTemplate != nil {
+		policy.LaunchTemplate.LaunchTemplateSpecification = base.MixedInstancesPolicy.LaunchTemplate.LaunchTemplateSpecification
+		policy.InstancesDistribution = base.MixedInstancesPolicy.InstancesDistribution
+	}
+	if policy.LaunchTemplate.LaunchTemplateSpecification == nil {
+		return fmt.Errorf("cannot clone base ASG %s: it doesn't use a launch template", m.autoprovisioning.baseAsg)
+	}
+
+	tags := []*autoscaling.Tag{}
+	for _, tag := range autoprovisionedTags(m.autoprovisioning.baseAsg, base.Tags, asg.template) {
+		tags = append(tags, &autoscaling.Tag{
+			ResourceId:        aws.String(asg.Name),
+			ResourceType:      aws.String("auto-scaling-group"),
+			Key:               tag.Key,
+			Value:             tag.Value,
+			PropagateAtLaunch: tag.PropagateAtLaunch,
+		})
+	}
+	params := &autoscaling.CreateAutoScalingGroupInput{
+		AutoScalingGroupName: aws.String(asg.Name),
+		MinSize:              aws.Int64(int64(asg.minSize)),
+		MaxSize:              aws.Int64(int64(asg.maxSize)),
+		DesiredCapacity:      aws.Int64(0),
+		AvailabilityZones:    base.AvailabilityZones,
+		VPCZoneIdentifier:    base.VPCZoneIdentifier,
+		MixedInstancesPolicy: policy,
+		Tags:                 tags,
+	}
+	glog.V(0).Infof("Creating asg %s of %s from %s", asg.Name, asg.template.instanceType, m.autoprovisioning.baseAsg)
+	if _, err := m.service.CreateAutoScalingGroup(params); err != nil {
+		return err
+	}
+
+	asg.template = nil
+	m.RegisterAsg(asg)
+	return m.regenerateCache()
+}
+
+// deleteAutoprovisionedAsg deletes the ASG, which must have no instances left.
+func (m *AwsManager) deleteAutoprovisionedAsg(asg *Asg) error {
+	glog.V(0).Infof("Deleting asg %s", asg.Name)
+	params := &autoscaling.DeleteAutoScalingGroupInput{
+		AutoScalingGroupName: aws.String(asg.Name),
+	}
+	if _, err := m.service.DeleteAutoScalingGroup(params); err != nil {
+		return err
+	}
+	if m.UnregisterAsg(asg) {
+		return m.regenerateCache()
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+)
+
+const testAutoprovisioningConfig = `
+[aws-autoprovisioning]
+base-asg = base
+max-size = 20
+instance-type = c4.large
+instance-type = p2.xlarge
+`
+
+func newTestAutoprovisioningProvider(t *testing.T, f *FakeAutoScaling) *awsCloudProvider {
+	m, err := createAWSManagerInternal(strings.NewReader(testAutoprovisioningConfig), cloudprovider.NodeGroupDiscoveryOptions{
+		NodeGroupSpecs: []string{"1:5:base"},
+	}, &autoScalingWrapper{f})
+	assert.NoError(t, err)
+	return testProvider(t, m)
+}
+
+func TestAutoprovisioning(t *testing.T) {
+	f := NewFakeAutoScaling(0)
+	assert.NoError(t, f.AddGroup("base", "m4.large", "us-east-1a", 1, 5, 1, map[string]string{
+		"kubernetes.io/cluster/test": "owned",
+		labelTagPrefix + "team":      "base",
+	}))
+	provider := newTestAutoprovisioningProvider(t, f)
+
+	types, err := provider.GetAvailableMachineTypes()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c4.large", "p2.xlarge"}, types)
+
+	// GPU instance types are only used for pods requesting GPUs.
+	_, err = provider.NewNodeGroup("p2.xlarge", map[string]string{}, map[string]string{}, map[string]resource.Quantity{})
+	assert.Equal(t, cloudprovider.ErrIllegalConfiguration, err)
+	_, err = provider.NewNodeGroup("c4.large", map[string]string{}, map[string]string{},
+		map[string]resource.Quantity{gpu.ResourceNvidiaGPU: *resource.NewQuantity(1, resource.DecimalSI)})
+	assert.Equal(t, cloudprovider.ErrIllegalConfiguration, err)
+	gpuGroup, err := provider.NewNodeGroup("p2.xlarge", map[string]string{}, map[string]string{},
+		map[string]resource.Quantity{gpu.ResourceNvidiaGPU: *resource.NewQuantity(1, resource.DecimalSI)})
+	assert.NoError(t, err)
+	gpuInfo, err := gpuGroup.TemplateNodeInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, []apiv1.Taint{{Key: gpu.ResourceNvidiaGPU, Value: "present", Effect: apiv1.TaintEffectNoSchedule}}, gpuInfo.Node().Spec.Taints)
+
+	// Theoretical groups are cloned from the base ASG with the requested labels.
+	ng, err := provider.NewNodeGroup("c4.large", map[string]string{"team": "a", kubeletapis.LabelInstanceType: "m4.large"}, map[string]string{}, nil)
+	assert.NoError(t, err)
+	assert.False(t, ng.Exist())
+	assert.True(t, ng.Autoprovisioned())
+	assert.True(t, strings.HasPrefix(ng.Id(), "base-nap-c4-large-"))
+	assert.Equal(t, 0, ng.MinSize())
+	assert.Equal(t, 20, ng.MaxSize())
+	size, err := ng.TargetSize()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, size)
+	info, err := ng.TemplateNodeInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", info.Node().Labels["team"])
+	assert.Equal(t, "c4.large", info.Node().Labels[kubeletapis.LabelInstanceType])
+	assert.Equal(t, "us-east-1a", info.Node().Labels[kubeletapis.LabelZoneFailureDomain])
+	zones, err := ng.(*Asg).ScaleUpZones()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"us-east-1a"}, zones)
+	assert.Equal(t, 1, len(provider.NodeGroups()))
+
+	// Once created, they are node groups, and found again after a restart.
+	assert.NoError(t, ng.Create())
+	assert.True(t, ng.Exist())
+	assert.Equal(t, cloudprovider.ErrAlreadyExist, ng.Create())
+	assert.Equal(t, 2, len(provider.NodeGroups()))
+	assert.Equal(t, "owned", f.groups[ng.Id()].tags["kubernetes.io/cluster/test"])
+	assert.Equal(t, "a", f.groups[ng.Id()].tags[labelTagPrefix+"team"])
+	assert.Equal(t, "base", f.groups[ng.Id()].tags[autoprovisionedTag])
+	_, err = provider.NewNodeGroup("c4.large", map[string]string{"team": "a"}, map[string]string{}, nil)
+	assert.Equal(t, cloudprovider.ErrIllegalConfiguration, err)
+
+	restarted := newTestAutoprovisioningProvider(t, f)
+	assert.Equal(t, 2, len(restarted.NodeGroups()))
+	found := restarted.NodeGroups()[1]
+	assert.Equal(t, ng.Id(), found.Id())
+	assert.True(t, found.Autoprovisioned())
+	assert.False(t, restarted.NodeGroups()[0].Autoprovisioned())
+
+	// They can be deleted once empty.
+	assert.NoError(t, ng.IncreaseSize(1))
+	assert.Error(t, ng.Delete())
+	assert.Equal(t, 1, len(f.InstanceIds(ng.Id())))
+	node := &apiv1.Node{Spec: apiv1.NodeSpec{ProviderID: "aws:///us-east-1a/" + f.InstanceIds(ng.Id())[0]}}
+	assert.NoError(t, ng.DeleteNodes([]*apiv1.Node{node}))
+	assert.NoError(t, ng.Delete())
+	assert.Equal(t, 1, len(provider.NodeGroups()))
+	assert.Error(t, provider.NodeGroups()[0].Delete())
+}
@@ -24,6 +24,7 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws/ec2instances"
 	"k8s.io/autoscaler/cluster-autoscaler/utils/errors"
 	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
 )
@@ -90,19 +91,22 @@ func (aws *awsCloudProvider) NodeGroupForNode(node *apiv1.Node) (cloudprovider.N
 
 // Pricing returns pricing model for this cloud provider or error if not available.
 func (aws *awsCloudProvider) Pricing() (cloudprovider.PricingModel, errors.AutoscalerError) {
-	return nil, cloudprovider.ErrNotImplemented
+	if aws.awsManager.priceModel == nil {
+		return nil, cloudprovider.ErrNotImplemented
+	}
+	return aws.awsManager.priceModel, nil
 }
 
 // GetAvailableMachineTypes get all machine types that can be requested from the cloud provider.
 func (aws *awsCloudProvider) GetAvailableMachineTypes() ([]string, error) {
-	return []string{}, nil
+	return aws.awsManager.availableInstanceTypes(), nil
 }
 
 // NewNodeGroup builds a theoretical node group based on the node definition provided. The node group is not automatically
 // created on the cloud provider side. The node group is not returned by NodeGroups() until it is created.
 func (aws *awsCloudProvider) NewNodeGroup(machineType string, labels map[string]string, systemLabels map[string]string,
 	extraResources map[string]resource.Quantity) (cloudprovider.NodeGroup, error) {
-	return nil, cloudprovider.ErrNotImplemented
+	return aws.awsManager.newAutoprovisionedAsg(machineType, labels, systemLabels, extraResources)
 }
 
 // GetResourceLimiter returns struct containing limits (max, min) for resources (cores, memory etc.).
@@ -143,6 +147,11 @@ type Asg struct {
 
 	minSize int
 	maxSize int
+
+	// autoprovisioned is true for ASGs created, or to be created, by node autoprovisioning.
+	autoprovisioned bool
+	// template describes autoprovisioned ASGs until they are created.
+	template *autoprovisionedTemplate
 }
 
 // MaxSize returns maximum size of the node group.
@@ -158,6 +167,9 @@ func (asg *Asg) MinSize() int {
 // TargetSize returns the current TARGET size of the node group. It is possible that the
 // number is different from the number of nodes registered in Kubernetes.
 func (asg *Asg) TargetSize() (int, error) {
+	if !asg.Exist() {
+		return 0, nil
+	}
 	size, err := asg.awsManager.GetAsgSize(asg)
 	return int(size), err
 }
@@ -165,23 +177,29 @@ func (asg *Asg) TargetSize() (int, error) {
 // Exist checks if the node group really exists on the cloud provider side. Allows to tell the
 // theoretical node group from the real one.
 func (asg *Asg) Exist() bool {
-	return true
+	return asg.template == nil
 }
 
 // Create creates the node group on the cloud provider side.
 func (asg *Asg) Create() error {
-	return cloudprovider.ErrAlreadyExist
+	if asg.Exist() {
+		return cloudprovider.ErrAlreadyExist
+	}
+	return asg.awsManager.createAutoprovisionedAsg(asg)
 }
 
 // Autoprovisioned returns true if the node group is autoprovisioned.
 func (asg *Asg) Autoprovisioned() bool {
-	return false
+	return asg.autoprovisioned
 }
 
 // Delete deletes the node group on the cloud provider side.
 // This will be executed only for autoprovisioned node groups, once their size drops to 0.
 func (asg *Asg) Delete() error {
-	return cloudprovider.ErrNotImplemented
+	if !asg.autoprovisioned {
+		return fmt.Errorf("asg %s is not autoprovisioned", asg.Id())
+	}
+	return asg.awsManager.deleteAutoprovisionedAsg(asg)
 }
 
 // IncreaseSize increases Asg size
@@ -288,12 +306,56 @@ func (asg *Asg) Nodes() ([]string, error) {
 	return asg.awsManager.GetAsgNodes(asg)
 }
 
-// TemplateNodeInfo returns a node template for this node group.
+// Zones returns all availability zones of the asg.
+func (asg *Asg) Zones() ([]string, error) {
+	zones, _, err := asg.awsManager.getAsgZones(asg)
+	return zones, err
+}
+
+// ScaleUpZones returns the availability zones AWS may place the next node of the asg in.
+func (asg *Asg) ScaleUpZones() ([]string, error) {
+	_, zones, err := asg.awsManager.getAsgZones(asg)
+	return zones, err
+}
+
+// TemplateNodeInfo returns a node template for this node group, in the zone its next node will
+// be placed in.
 func (asg *Asg) TemplateNodeInfo() (*schedulercache.NodeInfo, error) {
-	template, err := asg.awsManager.getAsgTemplate(asg.Name)
+	return asg.TemplateNodeInfoForZone("")
+}
+
+// TemplateNodeInfoForZone returns a node template for this node group in the availability zone.
+// An empty zone is the zone its next node will be placed in.
+func (asg *Asg) TemplateNodeInfoForZone(zone string) (*schedulercache.NodeInfo, error) {
+	return asg.templateNodeInfo(zone, nil)
+}
+
+// TemplateNodeInfoForInstanceType returns a node template for this node group as if its next node
+// were of the given instance type, rather than the one of its launch configuration or template.
+// Reservations, max pods and labels follow the instance type.
+func (asg *Asg) TemplateNodeInfoForInstanceType(instanceTypeName string) (*schedulercache.NodeInfo, error) {
+	instanceType, found := ec2instances.InstanceTypes[instanceTypeName]
+	if !found {
+		return nil, fmt.Errorf("Unknown instance type %s", instanceTypeName)
+	}
+	return asg.templateNodeInfo("", instanceType)
+}
+
+// templateNodeInfo builds the node template in the zone, of the instance type if not nil.
+func (asg *Asg) templateNodeInfo(zone string, instanceType *ec2instances.InstanceType) (*schedulercache.NodeInfo, error) {
+	var template *asgTemplate
+	var err error
+	if asg.Exist() {
+		template, err = asg.awsManager.getAsgTemplate(asg.Name, zone)
+	} else {
+		template, err = asg.awsManager.getAutoprovisionedAsgTemplate(asg.template, zone)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if instanceType != nil {
+		template.InstanceType = instanceType
+	}
 
 	node, err := asg.awsManager.buildNodeFromTemplate(asg, template)
 	if err != nil {
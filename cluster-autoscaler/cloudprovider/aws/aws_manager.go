@@ -14,26 +14,31 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-//go:generate go run ec2_instance_types/gen.go
+//go:generate go run ec2_instance_types/gen.go -offers=$EC2_OFFERS
 
 package aws
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/glog"
 	"gopkg.in/gcfg.v1"
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws/ec2instances"
 	"k8s.io/autoscaler/cluster-autoscaler/config/dynamic"
 	"k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
 	provider_aws "k8s.io/kubernetes/pkg/cloudprovider/providers/aws"
@@ -45,6 +50,20 @@ const (
 	operationPollInterval   = 100 * time.Millisecond
 	maxRecordsReturnedByAPI = 100
 	refreshInterval         = 1 * time.Minute
+
+	// defaultMaxPods is the pod limit of template nodes whose instance type has unknown ENI limits.
+	defaultMaxPods = 110
+
+	nodeTemplateTagPrefix = "k8s.io/cluster-autoscaler/node-template/"
+	kubeReservedTag       = nodeTemplateTagPrefix + "kube-reserved"
+	systemReservedTag     = nodeTemplateTagPrefix + "system-reserved"
+	maxPodsTag            = nodeTemplateTagPrefix + "max-pods"
+	resourcesTagPrefix    = nodeTemplateTagPrefix + "resources/"
+
+	// minSizeTag and maxSizeTag override the size limits of autodiscovered ASGs, within the
+	// limits of the ASG itself.
+	minSizeTag = "k8s.io/cluster-autoscaler/min-size"
+	maxSizeTag = "k8s.io/cluster-autoscaler/max-size"
 )
 
 type asgInformation struct {
@@ -59,10 +78,62 @@ type AwsManager struct {
 	lastRefresh           time.Time
 	asgAutoDiscoverySpecs []cloudprovider.ASGAutoDiscoveryConfig
 	explicitlyConfigured  map[AwsRef]bool
+	priceModel            *AwsPriceModel
+	nodeTemplate          nodeTemplateReservations
+	autoprovisioning      autoprovisioningConfig
+}
+
+// managerConfig holds the sections of the cloud-config specific to the autoscaler:
+//
+//	[aws-pricing]
+//	spot-price-file = /etc/cluster-autoscaler/spot-prices.json
+//
+//	[aws-node-template]
+//	kube-reserved = cpu=100m,memory=256Mi
+//	system-reserved = cpu=100m,memory=128Mi
+//	max-pods = 58
+//
+//	[aws-api]
+//	qps = 10
+//	burst = 20
+//	max-retries = 5
+//
+//	[aws-autoprovisioning]
+//	base-asg = k8s-worker-base
+//	max-size = 100
+//	instance-type = m5.large
+//	instance-type = c5.xlarge
+type managerConfig struct {
+	AwsPricing struct {
+		SpotPriceFile string `gcfg:"spot-price-file"`
+	} `gcfg:"aws-pricing"`
+	AwsNodeTemplate struct {
+		KubeReserved   string `gcfg:"kube-reserved"`
+		SystemReserved string `gcfg:"system-reserved"`
+		MaxPods        int64  `gcfg:"max-pods"`
+	} `gcfg:"aws-node-template"`
+	AwsAPI struct {
+		Qps        float64 `gcfg:"qps"`
+		Burst      int     `gcfg:"burst"`
+		MaxRetries int     `gcfg:"max-retries"`
+	} `gcfg:"aws-api"`
+	AwsAutoprovisioning struct {
+		BaseAsg       string   `gcfg:"base-asg"`
+		MaxSize       int      `gcfg:"max-size"`
+		InstanceTypes []string `gcfg:"instance-type"`
+	} `gcfg:"aws-autoprovisioning"`
+}
+
+// nodeTemplateReservations are the resources reserved by the kubelet on template nodes, and their pod limit.
+type nodeTemplateReservations struct {
+	kubeReserved   apiv1.ResourceList
+	systemReserved apiv1.ResourceList
+	// maxPods replaces the pod limit computed from the ENI limits of the instance type when > 0.
+	maxPods int64
 }
 
 type asgTemplate struct {
-	InstanceType *instanceType
+	InstanceType *ec2instances.InstanceType
 	Region       string
 	Zone         string
 	Tags         []*autoscaling.TagDescription
@@ -74,17 +145,56 @@ func createAWSManagerInternal(
 	discoveryOpts cloudprovider.NodeGroupDiscoveryOptions,
 	service *autoScalingWrapper,
 ) (*AwsManager, error) {
+	var managerCfg managerConfig
 	if configReader != nil {
+		configBytes, err := ioutil.ReadAll(configReader)
+		if err != nil {
+			glog.Errorf("Couldn't read config: %v", err)
+			return nil, err
+		}
 		var cfg provider_aws.CloudConfig
-		if err := gcfg.ReadInto(&cfg, configReader); err != nil {
+		// Other providers wrapping aws (e.g. autoscalr) keep their own sections
+		// in the same file, so only fatal errors are reported.
+		if err := gcfg.FatalOnly(gcfg.ReadInto(&cfg, bytes.NewReader(configBytes))); err != nil {
+			glog.Errorf("Couldn't read config: %v", err)
+			return nil, err
+		}
+		if err := gcfg.FatalOnly(gcfg.ReadInto(&managerCfg, bytes.NewReader(configBytes))); err != nil {
 			glog.Errorf("Couldn't read config: %v", err)
 			return nil, err
 		}
 	}
 
+	var spotPrices SpotPriceSource
+	if managerCfg.AwsPricing.SpotPriceFile != "" {
+		var err error
+		spotPrices, err = NewFileSpotPriceSource(managerCfg.AwsPricing.SpotPriceFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spot prices: %v", err)
+		}
+	}
+
+	kubeReserved, err := parseReservedResources(managerCfg.AwsNodeTemplate.KubeReserved)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kube-reserved: %v", err)
+	}
+	systemReserved, err := parseReservedResources(managerCfg.AwsNodeTemplate.SystemReserved)
+	if err != nil {
+		return nil, fmt.Errorf("invalid system-reserved: %v", err)
+	}
+	autoprovisioning, err := newAutoprovisioningConfig(managerCfg.AwsAutoprovisioning.BaseAsg,
+		managerCfg.AwsAutoprovisioning.MaxSize, managerCfg.AwsAutoprovisioning.InstanceTypes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid autoprovisioning config: %v", err)
+	}
+
 	if service == nil {
+		// Requests are retried, with backoff, by rateLimitedAutoScaling only.
+		sess := session.New(&aws.Config{MaxRetries: aws.Int(0)})
 		service = &autoScalingWrapper{
-			autoscaling.New(session.New()),
+			newRateLimitedAutoScaling(
+				autoScalingServices{autoscaling.New(sess), ec2.New(sess)},
+				managerCfg.AwsAPI.Qps, managerCfg.AwsAPI.Burst, managerCfg.AwsAPI.MaxRetries),
 		}
 	}
 
@@ -97,12 +207,23 @@ func createAWSManagerInternal(
 	if err != nil {
 		return nil, err
 	}
+	if autoprovisioning.baseAsg != "" {
+		// ASGs autoprovisioned before are node groups too.
+		specs = append(specs, autoprovisioning.discoverySpec())
+	}
 
 	manager := &AwsManager{
 		service:               *service,
 		asgCache:              cache,
 		asgAutoDiscoverySpecs: specs,
 		explicitlyConfigured:  make(map[AwsRef]bool),
+		priceModel:            NewAwsPriceModel(spotPrices),
+		nodeTemplate: nodeTemplateReservations{
+			kubeReserved:   kubeReserved,
+			systemReserved: systemReserved,
+			maxPods:        managerCfg.AwsNodeTemplate.MaxPods,
+		},
+		autoprovisioning: autoprovisioning,
 	}
 
 	if err := manager.fetchExplicitAsgs(discoveryOpts.NodeGroupSpecs); err != nil {
@@ -169,6 +290,10 @@ func (m *AwsManager) fetchAutoAsgs() error {
 			return fmt.Errorf("cannot autodiscover ASGs: %s", err)
 		}
 		for _, g := range groups {
+			if !spec.Matches(tagsByKey(g.Tags)) {
+				glog.V(4).Infof("ASG %s doesn't match the tags of %v", aws.StringValue(g.AutoScalingGroupName), spec)
+				continue
+			}
 			asg, err := m.buildAsgFromAWS(g)
 			if err != nil {
 				return err
@@ -205,24 +330,57 @@ func (m *AwsManager) fetchAutoAsgs() error {
 }
 
 func (m *AwsManager) buildAsgFromAWS(g *autoscaling.Group) (*Asg, error) {
+	name := aws.StringValue(g.AutoScalingGroupName)
+	minSize, maxSize := int(aws.Int64Value(g.MinSize)), int(aws.Int64Value(g.MaxSize))
+	tags := tagsByKey(g.Tags)
 	spec := dynamic.NodeGroupSpec{
-		Name:               aws.StringValue(g.AutoScalingGroupName),
-		MinSize:            int(aws.Int64Value(g.MinSize)),
-		MaxSize:            int(aws.Int64Value(g.MaxSize)),
+		Name:               name,
+		MinSize:            sizeFromTag(name, tags, minSizeTag, minSize, minSize, maxSize),
+		MaxSize:            sizeFromTag(name, tags, maxSizeTag, maxSize, minSize, maxSize),
 		SupportScaleToZero: scaleToZeroSupported,
 	}
 	if verr := spec.Validate(); verr != nil {
 		return nil, fmt.Errorf("failed to create node group spec: %v", verr)
 	}
+	_, autoprovisioned := tags[autoprovisionedTag]
 	asg := &Asg{
-		awsManager: m,
-		AwsRef:     AwsRef{Name: spec.Name},
-		minSize:    spec.MinSize,
-		maxSize:    spec.MaxSize,
+		awsManager:      m,
+		AwsRef:          AwsRef{Name: spec.Name},
+		minSize:         spec.MinSize,
+		maxSize:         spec.MaxSize,
+		autoprovisioned: autoprovisioned,
 	}
 	return asg, nil
 }
 
+// tagsByKey returns the values of tags by key.
+func tagsByKey(tags []*autoscaling.TagDescription) map[string]string {
+	result := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		result[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return result
+}
+
+// sizeFromTag returns the size given by the tag of an ASG, or defaultSize if it is missing,
+// invalid or outside the [minSize, maxSize] limits of the ASG.
+func sizeFromTag(name string, tags map[string]string, key string, defaultSize, minSize, maxSize int) int {
+	value, found := tags[key]
+	if !found {
+		return defaultSize
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		glog.Warningf("Ignoring invalid %s tag %q of ASG %s: %v", key, value, name, err)
+		return defaultSize
+	}
+	if size < minSize || size > maxSize {
+		glog.Warningf("Ignoring %s tag %d of ASG %s, outside of the ASG limits %d-%d", key, size, name, minSize, maxSize)
+		return defaultSize
+	}
+	return size
+}
+
 // Refresh is called before every main loop and can be used to dynamically update cloud provider state.
 // In particular the list of node groups returned by NodeGroups can change as a result of CloudProvider.Refresh().
 func (m *AwsManager) Refresh() error {
@@ -358,36 +516,139 @@ func (m *AwsManager) GetAsgNodes(asg *Asg) ([]string, error) {
 	return result, nil
 }
 
-func (m *AwsManager) getAsgTemplate(name string) (*asgTemplate, error) {
+// getAsgTemplate returns the template of new nodes of the ASG in the zone. With an empty zone
+// they are in the zone AWS will place the next instance of the ASG in.
+func (m *AwsManager) getAsgTemplate(name string, zone string) (*asgTemplate, error) {
 	asg, err := m.service.getAutoscalingGroupByName(name)
 	if err != nil {
 		return nil, err
 	}
 
-	instanceTypeName, err := m.service.getInstanceTypeByLCName(*asg.LaunchConfigurationName)
+	instanceTypeName, err := m.getAsgInstanceType(asg)
 	if err != nil {
 		return nil, err
 	}
+	instanceType, found := ec2instances.InstanceTypes[instanceTypeName]
+	if !found {
+		return nil, fmt.Errorf("Unknown instance type %s of %s", instanceTypeName, name)
+	}
 
 	if len(asg.AvailabilityZones) < 1 {
 		return nil, fmt.Errorf("Unable to get first AvailabilityZone for %s", name)
 	}
 
-	az := *asg.AvailabilityZones[0]
-	region := az[0 : len(az)-1]
-
-	if len(asg.AvailabilityZones) > 1 {
-		glog.Warningf("Found multiple availability zones, using %s\n", az)
+	if zone == "" {
+		zone = scaleUpZones(asg)[0]
+	} else if !hasZone(asg, zone) {
+		return nil, fmt.Errorf("%s is not an AvailabilityZone of %s", zone, name)
 	}
 
 	return &asgTemplate{
-		InstanceType: InstanceTypes[instanceTypeName],
-		Region:       region,
-		Zone:         az,
+		InstanceType: instanceType,
+		Region:       zone[0 : len(zone)-1],
+		Zone:         zone,
 		Tags:         asg.Tags,
 	}, nil
 }
 
+// getAsgZones returns all availability zones of the ASG, and those its next instance may be
+// placed in. An autoprovisioned ASG that isn't created yet has the zones of the base ASG.
+func (m *AwsManager) getAsgZones(asg *Asg) ([]string, []string, error) {
+	name := asg.Name
+	if !asg.Exist() {
+		name = m.autoprovisioning.baseAsg
+	}
+	group, err := m.service.getAutoscalingGroupByName(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	zones := aws.StringValueSlice(group.AvailabilityZones)
+	if !asg.Exist() {
+		return zones, zones, nil
+	}
+	return zones, scaleUpZones(group), nil
+}
+
+// scaleUpZones returns the availability zones AWS may launch the next instance of the ASG in.
+// AWS keeps ASGs balanced across their zones, so these are the zones with the fewest instances
+// not being terminated.
+func scaleUpZones(group *autoscaling.Group) []string {
+	counts := make(map[string]int)
+	for _, instance := range group.Instances {
+		if strings.HasPrefix(aws.StringValue(instance.LifecycleState), "Terminating") {
+			continue
+		}
+		counts[aws.StringValue(instance.AvailabilityZone)]++
+	}
+	result := []string{}
+	fewest := -1
+	for _, zone := range aws.StringValueSlice(group.AvailabilityZones) {
+		count := counts[zone]
+		if fewest < 0 || count < fewest {
+			result = []string{}
+			fewest = count
+		}
+		if count == fewest {
+			result = append(result, zone)
+		}
+	}
+	return result
+}
+
+func hasZone(group *autoscaling.Group, zone string) bool {
+	for _, candidate := range group.AvailabilityZones {
+		if aws.StringValue(candidate) == zone {
+			return true
+		}
+	}
+	return false
+}
+
+// getAsgInstanceType returns the instance type new nodes of the ASG are built from. It comes from
+// the launch configuration or the launch template of the ASG. For a mixed instances policy the
+// smallest known override is used, so that a node built from it never overstates the capacity
+// of the nodes the ASG may actually launch.
+func (m *AwsManager) getAsgInstanceType(asg *autoscaling.Group) (string, error) {
+	name := aws.StringValue(asg.AutoScalingGroupName)
+	if asg.LaunchConfigurationName != nil {
+		return m.service.getInstanceTypeByLCName(*asg.LaunchConfigurationName)
+	}
+	if asg.LaunchTemplate != nil {
+		return m.service.getInstanceTypeByLT(asg.LaunchTemplate)
+	}
+	if asg.MixedInstancesPolicy != nil && asg.MixedInstancesPolicy.LaunchTemplate != nil {
+		policy := asg.MixedInstancesPolicy.LaunchTemplate
+		if smallest := smallestInstanceType(policy.Overrides); smallest != "" {
+			return smallest, nil
+		}
+		if policy.LaunchTemplateSpecification != nil {
+			return m.service.getInstanceTypeByLT(policy.LaunchTemplateSpecification)
+		}
+	}
+	return "", fmt.Errorf("Unable to get instance type of %s: no launch configuration or launch template", name)
+}
+
+// smallestInstanceType returns the known override type with the fewest vcpus, then the least
+// memory, or an empty string if no override type is known.
+func smallestInstanceType(overrides []*autoscaling.LaunchTemplateOverrides) string {
+	var smallest *ec2instances.InstanceType
+	for _, override := range overrides {
+		candidate, found := ec2instances.InstanceTypes[aws.StringValue(override.InstanceType)]
+		if !found {
+			glog.V(4).Infof("Ignoring unknown override instance type %s", aws.StringValue(override.InstanceType))
+			continue
+		}
+		if smallest == nil || candidate.VCPU < smallest.VCPU ||
+			(candidate.VCPU == smallest.VCPU && candidate.MemoryMb < smallest.MemoryMb) {
+			smallest = candidate
+		}
+	}
+	if smallest == nil {
+		return ""
+	}
+	return smallest.InstanceType
+}
+
 func (m *AwsManager) buildNodeFromTemplate(asg *Asg, template *asgTemplate) (*apiv1.Node, error) {
 	node := apiv1.Node{}
 	nodeName := fmt.Sprintf("%s-asg-%d", asg.Name, rand.Int63())
@@ -398,18 +659,33 @@ func (m *AwsManager) buildNodeFromTemplate(asg *Asg, template *asgTemplate) (*ap
 		Labels:   map[string]string{},
 	}
 
+	reservations, err := m.nodeTemplate.withAsgTags(template.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node template of %s: %v", asg.Name, err)
+	}
+	maxPods := reservations.maxPods
+	if maxPods <= 0 {
+		maxPods = maxPodsFromENILimits(template.InstanceType)
+	}
+
 	node.Status = apiv1.NodeStatus{
 		Capacity: apiv1.ResourceList{},
 	}
 
-	// TODO: get a real value.
-	node.Status.Capacity[apiv1.ResourcePods] = *resource.NewQuantity(110, resource.DecimalSI)
+	node.Status.Capacity[apiv1.ResourcePods] = *resource.NewQuantity(maxPods, resource.DecimalSI)
 	node.Status.Capacity[apiv1.ResourceCPU] = *resource.NewQuantity(template.InstanceType.VCPU, resource.DecimalSI)
 	node.Status.Capacity[gpu.ResourceNvidiaGPU] = *resource.NewQuantity(template.InstanceType.GPU, resource.DecimalSI)
 	node.Status.Capacity[apiv1.ResourceMemory] = *resource.NewQuantity(template.InstanceType.MemoryMb*1024*1024, resource.DecimalSI)
 
-	// TODO: use proper allocatable!!
-	node.Status.Allocatable = node.Status.Capacity
+	resources, err := extractResourcesFromAsg(template.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node template of %s: %v", asg.Name, err)
+	}
+	for name, quantity := range resources {
+		node.Status.Capacity[name] = quantity
+	}
+
+	node.Status.Allocatable = buildAllocatable(node.Status.Capacity, reservations.kubeReserved, reservations.systemReserved)
 
 	// NodeLabels
 	node.Labels = cloudprovider.JoinStringMaps(node.Labels, extractLabelsFromAsg(template.Tags))
@@ -422,10 +698,83 @@ func (m *AwsManager) buildNodeFromTemplate(asg *Asg, template *asgTemplate) (*ap
 	return &node, nil
 }
 
+// maxPodsFromENILimits returns the number of pods the aws vpc cni can give an address to on the
+// instance type: every interface but the primary address of each, plus two host network pods.
+func maxPodsFromENILimits(t *ec2instances.InstanceType) int64 {
+	if t.MaxENI <= 0 || t.IPsPerENI <= 0 {
+		return defaultMaxPods
+	}
+	return t.MaxENI*(t.IPsPerENI-1) + 2
+}
+
+// buildAllocatable returns capacity less the reserved resources, never below zero.
+func buildAllocatable(capacity apiv1.ResourceList, reserved ...apiv1.ResourceList) apiv1.ResourceList {
+	allocatable := apiv1.ResourceList{}
+	for name, quantity := range capacity {
+		value := quantity.DeepCopy()
+		for _, r := range reserved {
+			if q, found := r[name]; found {
+				value.Sub(q)
+			}
+		}
+		if value.Sign() < 0 {
+			value = *resource.NewQuantity(0, quantity.Format)
+		}
+		allocatable[name] = value
+	}
+	return allocatable
+}
+
+// parseReservedResources parses a list of resources in the format of the kubelet
+// --kube-reserved flag, e.g. "cpu=100m,memory=256Mi".
+func parseReservedResources(value string) (apiv1.ResourceList, error) {
+	result := apiv1.ResourceList{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%q isn't a resource=quantity pair", pair)
+		}
+		quantity, err := resource.ParseQuantity(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity of %s: %v", parts[0], err)
+		}
+		result[apiv1.ResourceName(parts[0])] = quantity
+	}
+	return result, nil
+}
+
+// withAsgTags returns the reservations overridden by the node-template tags of an ASG.
+// A reserved tag replaces the whole list of the cloud-config.
+func (r nodeTemplateReservations) withAsgTags(tags []*autoscaling.TagDescription) (nodeTemplateReservations, error) {
+	for _, tag := range tags {
+		var err error
+		switch aws.StringValue(tag.Key) {
+		case kubeReservedTag:
+			r.kubeReserved, err = parseReservedResources(aws.StringValue(tag.Value))
+		case systemReservedTag:
+			r.systemReserved, err = parseReservedResources(aws.StringValue(tag.Value))
+		case maxPodsTag:
+			r.maxPods, err = strconv.ParseInt(aws.StringValue(tag.Value), 10, 64)
+		default:
+			continue
+		}
+		if err != nil {
+			return r, fmt.Errorf("invalid tag %s: %v", aws.StringValue(tag.Key), err)
+		}
+	}
+	return r, nil
+}
+
 func buildGenericLabels(template *asgTemplate, nodeName string) map[string]string {
 	result := make(map[string]string)
-	// TODO: extract it somehow
 	result[kubeletapis.LabelArch] = cloudprovider.DefaultArch
+	if template.InstanceType.Architecture != "" {
+		result[kubeletapis.LabelArch] = template.InstanceType.Architecture
+	}
 	result[kubeletapis.LabelOS] = cloudprovider.DefaultOS
 
 	result[kubeletapis.LabelInstanceType] = template.InstanceType.InstanceType
@@ -454,6 +803,31 @@ func extractLabelsFromAsg(tags []*autoscaling.TagDescription) map[string]string
 	return result
 }
 
+// extractResourcesFromAsg returns the resources declared by the
+// k8s.io/cluster-autoscaler/node-template/resources/<name> tags of the ASG, e.g. ephemeral-storage,
+// hugepages-2Mi or extended resources of device plugins such as vpc.amazonaws.com/pod-eni.
+func extractResourcesFromAsg(tags []*autoscaling.TagDescription) (apiv1.ResourceList, error) {
+	result := apiv1.ResourceList{}
+
+	for _, tag := range tags {
+		k := aws.StringValue(tag.Key)
+		if !strings.HasPrefix(k, resourcesTagPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(k, resourcesTagPrefix)
+		if name == "" {
+			return nil, fmt.Errorf("tag %s has no resource name", k)
+		}
+		quantity, err := resource.ParseQuantity(aws.StringValue(tag.Value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity of tag %s: %v", k, err)
+		}
+		result[apiv1.ResourceName(name)] = quantity
+	}
+
+	return result, nil
+}
+
 func extractTaintsFromAsg(tags []*autoscaling.TagDescription) []apiv1.Taint {
 	taints := make([]apiv1.Taint, 0)
 
@@ -25,15 +25,17 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/stretchr/testify/assert"
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws/ec2instances"
 	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
 )
 
 func TestBuildGenericLabels(t *testing.T) {
 	labels := buildGenericLabels(&asgTemplate{
-		InstanceType: &instanceType{
+		InstanceType: &ec2instances.InstanceType{
 			InstanceType: "c4.large",
 			VCPU:         2,
 			MemoryMb:     3840,
@@ -45,6 +47,88 @@ func TestBuildGenericLabels(t *testing.T) {
 	assert.Equal(t, "c4.large", labels[kubeletapis.LabelInstanceType])
 	assert.Equal(t, cloudprovider.DefaultArch, labels[kubeletapis.LabelArch])
 	assert.Equal(t, cloudprovider.DefaultOS, labels[kubeletapis.LabelOS])
+
+	labels = buildGenericLabels(&asgTemplate{
+		InstanceType: &ec2instances.InstanceType{InstanceType: "a1.large", Architecture: "arm64"},
+	}, "sillyname")
+	assert.Equal(t, "arm64", labels[kubeletapis.LabelArch])
+}
+
+func TestMaxPodsFromENILimits(t *testing.T) {
+	assert.Equal(t, int64(29), maxPodsFromENILimits(ec2instances.InstanceTypes["m5.large"]))
+	assert.Equal(t, int64(4), maxPodsFromENILimits(ec2instances.InstanceTypes["t2.micro"]))
+	assert.Equal(t, int64(defaultMaxPods), maxPodsFromENILimits(&ec2instances.InstanceType{InstanceType: "z9.huge"}))
+}
+
+func TestParseReservedResources(t *testing.T) {
+	reserved, err := parseReservedResources("cpu=100m, memory=256Mi")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), reserved.Cpu().MilliValue())
+	assert.Equal(t, int64(256*1024*1024), reserved.Memory().Value())
+
+	reserved, err = parseReservedResources("")
+	assert.NoError(t, err)
+	assert.Empty(t, reserved)
+
+	_, err = parseReservedResources("cpu")
+	assert.Error(t, err)
+	_, err = parseReservedResources("cpu=lots")
+	assert.Error(t, err)
+}
+
+func TestBuildNodeFromTemplate(t *testing.T) {
+	m := newTestAwsManagerWithService(&AutoScalingMock{})
+	kubeReserved, err := parseReservedResources("cpu=100m,memory=256Mi")
+	assert.NoError(t, err)
+	m.nodeTemplate = nodeTemplateReservations{kubeReserved: kubeReserved}
+	asg := &Asg{awsManager: m, minSize: 0, maxSize: 10, AwsRef: AwsRef{Name: "test-asg"}}
+	template := &asgTemplate{
+		InstanceType: ec2instances.InstanceTypes["m4.large"],
+		Region:       "us-east-1",
+		Zone:         "us-east-1a",
+	}
+
+	node, err := m.buildNodeFromTemplate(asg, template)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), node.Status.Capacity.Pods().Value())
+	assert.Equal(t, int64(20), node.Status.Allocatable.Pods().Value())
+	assert.Equal(t, int64(2000), node.Status.Capacity.Cpu().MilliValue())
+	assert.Equal(t, int64(1900), node.Status.Allocatable.Cpu().MilliValue())
+	assert.Equal(t, int64((8192-256)*1024*1024), node.Status.Allocatable.Memory().Value())
+	assert.Equal(t, "amd64", node.Labels[kubeletapis.LabelArch])
+
+	// Tags of the ASG take precedence over the cloud-config.
+	template.Tags = []*autoscaling.TagDescription{
+		{Key: aws.String(kubeReservedTag), Value: aws.String("cpu=500m")},
+		{Key: aws.String(systemReservedTag), Value: aws.String("memory=1Gi")},
+		{Key: aws.String(maxPodsTag), Value: aws.String("110")},
+	}
+	node, err = m.buildNodeFromTemplate(asg, template)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(110), node.Status.Allocatable.Pods().Value())
+	assert.Equal(t, int64(1500), node.Status.Allocatable.Cpu().MilliValue())
+	assert.Equal(t, int64(7*1024*1024*1024), node.Status.Allocatable.Memory().Value())
+
+	// Resources declared by tags are part of the capacity, less what is reserved.
+	template.Tags = []*autoscaling.TagDescription{
+		{Key: aws.String(resourcesTagPrefix + "ephemeral-storage"), Value: aws.String("100Gi")},
+		{Key: aws.String(resourcesTagPrefix + "vpc.amazonaws.com/pod-eni"), Value: aws.String("9")},
+		{Key: aws.String(kubeReservedTag), Value: aws.String("ephemeral-storage=1Gi")},
+	}
+	node, err = m.buildNodeFromTemplate(asg, template)
+	assert.NoError(t, err)
+	capacityStorage := node.Status.Capacity[apiv1.ResourceEphemeralStorage]
+	assert.Equal(t, int64(100*1024*1024*1024), capacityStorage.Value())
+	allocatableStorage := node.Status.Allocatable[apiv1.ResourceEphemeralStorage]
+	assert.Equal(t, int64(99*1024*1024*1024), allocatableStorage.Value())
+	podEni := node.Status.Allocatable["vpc.amazonaws.com/pod-eni"]
+	assert.Equal(t, int64(9), podEni.Value())
+
+	template.Tags = []*autoscaling.TagDescription{
+		{Key: aws.String(maxPodsTag), Value: aws.String("many")},
+	}
+	_, err = m.buildNodeFromTemplate(asg, template)
+	assert.Error(t, err)
 }
 
 func TestExtractLabelsFromAsg(t *testing.T) {
@@ -90,6 +174,39 @@ func TestExtractTaintsFromAsg(t *testing.T) {
 	assert.Equal(t, makeTaintSet(expectedTaints), makeTaintSet(taints))
 }
 
+func TestExtractResourcesFromAsg(t *testing.T) {
+	tags := []*autoscaling.TagDescription{
+		{
+			Key:   aws.String("k8s.io/cluster-autoscaler/node-template/resources/ephemeral-storage"),
+			Value: aws.String("100Gi"),
+		},
+		{
+			Key:   aws.String("k8s.io/cluster-autoscaler/node-template/resources/vpc.amazonaws.com/pod-eni"),
+			Value: aws.String("9"),
+		},
+		{
+			Key:   aws.String("k8s.io/cluster-autoscaler/node-template/label/foo"),
+			Value: aws.String("bar"),
+		},
+	}
+
+	resources, err := extractResourcesFromAsg(tags)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(resources))
+	storage := resources[apiv1.ResourceEphemeralStorage]
+	assert.Equal(t, int64(100*1024*1024*1024), storage.Value())
+	podEni := resources["vpc.amazonaws.com/pod-eni"]
+	assert.Equal(t, int64(9), podEni.Value())
+
+	_, err = extractResourcesFromAsg([]*autoscaling.TagDescription{
+		{
+			Key:   aws.String("k8s.io/cluster-autoscaler/node-template/resources/hugepages-2Mi"),
+			Value: aws.String("lots"),
+		},
+	})
+	assert.Error(t, err)
+}
+
 func makeTaintSet(taints []apiv1.Taint) map[apiv1.Taint]bool {
 	set := make(map[apiv1.Taint]bool)
 	for _, taint := range taints {
@@ -120,6 +237,34 @@ func TestBuildAsg(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestCreateAWSManagerSharedConfig(t *testing.T) {
+	do := cloudprovider.NodeGroupDiscoveryOptions{}
+	cfg := `
+[global]
+Zone = us-east-1a
+
+[autoscalr]
+aws-region = us-east-1
+`
+	m, err := createAWSManagerInternal(strings.NewReader(cfg), do, &testService)
+	assert.NoError(t, err)
+	assert.NotNil(t, m.priceModel)
+
+	_, err = createAWSManagerInternal(strings.NewReader("[global"), do, &testService)
+	assert.Error(t, err)
+
+	_, err = createAWSManagerInternal(strings.NewReader(cfg+"\n[aws-pricing]\nspot-price-file = /nonexistent/spot-prices.json\n"), do, &testService)
+	assert.Error(t, err)
+
+	m, err = createAWSManagerInternal(strings.NewReader(cfg+"\n[aws-node-template]\nkube-reserved = cpu=100m\nmax-pods = 58\n"), do, &testService)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), m.nodeTemplate.kubeReserved.Cpu().MilliValue())
+	assert.Equal(t, int64(58), m.nodeTemplate.maxPods)
+
+	_, err = createAWSManagerInternal(strings.NewReader(cfg+"\n[aws-node-template]\nsystem-reserved = memory\n"), do, &testService)
+	assert.Error(t, err)
+}
+
 func validateAsg(t *testing.T, asg *Asg, name string, minSize int, maxSize int) {
 	assert.Equal(t, name, asg.Name)
 	assert.Equal(t, minSize, asg.minSize)
@@ -210,6 +355,10 @@ func TestFetchAutoAsgs(t *testing.T) {
 				AutoScalingGroupName: aws.String(groupname),
 				MinSize:              aws.Int64(int64(min)),
 				MaxSize:              aws.Int64(int64(max)),
+				Tags: []*autoscaling.TagDescription{
+					{Key: aws.String(tags[0]), Value: aws.String("")},
+					{Key: aws.String(tags[1]), Value: aws.String("")},
+				},
 			}}}, false)
 	}).Return(nil).Twice()
 
@@ -244,3 +393,222 @@ func TestFetchAutoAsgs(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Empty(t, m.asgCache.get())
 }
+
+// mockDiscoverableAsgs mocks the auto-scaling service to serve the given groups, and their tags,
+// to autodiscovery until the next call.
+func mockDiscoverableAsgs(s *AutoScalingMock, groups ...*autoscaling.Group) {
+	s.ExpectedCalls = nil
+	s.On("DescribeTagsPages", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(0).(*autoscaling.DescribeTagsInput)
+		fn := args.Get(1).(func(*autoscaling.DescribeTagsOutput, bool) bool)
+		tags := []*autoscaling.TagDescription{}
+		for _, filter := range input.Filters {
+			for _, g := range groups {
+				for _, tag := range g.Tags {
+					if aws.StringValue(tag.Key) == aws.StringValue(filter.Values[0]) {
+						tags = append(tags, &autoscaling.TagDescription{ResourceId: g.AutoScalingGroupName, Key: tag.Key, Value: tag.Value})
+					}
+				}
+			}
+		}
+		fn(&autoscaling.DescribeTagsOutput{Tags: tags}, false)
+	}).Return(nil)
+	s.On("DescribeAutoScalingGroupsPages", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(0).(*autoscaling.DescribeAutoScalingGroupsInput)
+		fn := args.Get(1).(func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool)
+		found := []*autoscaling.Group{}
+		for _, name := range aws.StringValueSlice(input.AutoScalingGroupNames) {
+			for _, g := range groups {
+				if aws.StringValue(g.AutoScalingGroupName) == name {
+					found = append(found, g)
+				}
+			}
+		}
+		fn(&autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: found}, false)
+	}).Return(nil)
+}
+
+func testTaggedGroup(name string, min, max int64, tags map[string]string) *autoscaling.Group {
+	g := &autoscaling.Group{
+		AutoScalingGroupName: aws.String(name),
+		MinSize:              aws.Int64(min),
+		MaxSize:              aws.Int64(max),
+	}
+	for k, v := range tags {
+		g.Tags = append(g.Tags, &autoscaling.TagDescription{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return g
+}
+
+func TestFetchAutoAsgsWithTagFilters(t *testing.T) {
+	s := &AutoScalingMock{}
+	prod := testTaggedGroup("prod", 0, 10, map[string]string{"enabled": "", "env": "prod", minSizeTag: "2", maxSizeTag: "5"})
+	dev := testTaggedGroup("dev", 0, 10, map[string]string{"enabled": "", "env": "dev"})
+	legacy := testTaggedGroup("legacy", 0, 10, map[string]string{"enabled": "", "env": "prod", "legacy": "true"})
+	mockDiscoverableAsgs(s, prod, dev, legacy)
+
+	do := cloudprovider.NodeGroupDiscoveryOptions{
+		NodeGroupAutoDiscoverySpecs: []string{"asg:tag=enabled,env=prod,!legacy"},
+	}
+	m, err := createAWSManagerInternal(nil, do, &autoScalingWrapper{s})
+	assert.NoError(t, err)
+
+	asgs := m.asgCache.get()
+	assert.Equal(t, 1, len(asgs))
+	validateAsg(t, asgs[0].config, "prod", 2, 5)
+
+	// Newly tagged ASGs are found by the next refresh, size tags out of the ASG limits are ignored.
+	canary := testTaggedGroup("canary", 1, 3, map[string]string{"enabled": "", "env": "prod", maxSizeTag: "20"})
+	mockDiscoverableAsgs(s, prod, dev, legacy, canary)
+	assert.NoError(t, m.forceRefresh())
+	asgs = m.asgCache.get()
+	assert.Equal(t, 2, len(asgs))
+	validateAsg(t, asgs[1].config, "canary", 1, 3)
+}
+
+func mockDescribeAsg(s *AutoScalingMock, asg *autoscaling.Group) {
+	s.On("DescribeAutoScalingGroups", &autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{asg.AutoScalingGroupName},
+		MaxRecords:            aws.Int64(1),
+	}).Return(&autoscaling.DescribeAutoScalingGroupsOutput{
+		AutoScalingGroups: []*autoscaling.Group{asg},
+	})
+}
+
+func mockDescribeLaunchTemplate(s *AutoScalingMock, name string, version string, instanceType string) {
+	s.On("DescribeLaunchTemplateVersions", &ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateName: aws.String(name),
+		Versions:           []*string{aws.String(version)},
+	}).Return(&ec2.DescribeLaunchTemplateVersionsOutput{
+		LaunchTemplateVersions: []*ec2.LaunchTemplateVersion{
+			{LaunchTemplateData: &ec2.ResponseLaunchTemplateData{InstanceType: aws.String(instanceType)}},
+		},
+	})
+}
+
+func TestGetAsgTemplate(t *testing.T) {
+	s := &AutoScalingMock{}
+	m := newTestAwsManagerWithService(s)
+	zones := []*string{aws.String("us-east-1a")}
+
+	mockDescribeAsg(s, &autoscaling.Group{
+		AutoScalingGroupName:    aws.String("lc"),
+		AvailabilityZones:       zones,
+		LaunchConfigurationName: aws.String("lc-config"),
+	})
+	s.On("DescribeLaunchConfigurations", &autoscaling.DescribeLaunchConfigurationsInput{
+		LaunchConfigurationNames: []*string{aws.String("lc-config")},
+		MaxRecords:               aws.Int64(1),
+	}).Return(&autoscaling.DescribeLaunchConfigurationsOutput{
+		LaunchConfigurations: []*autoscaling.LaunchConfiguration{{InstanceType: aws.String("m4.xlarge")}},
+	})
+	template, err := m.getAsgTemplate("lc", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "m4.xlarge", template.InstanceType.InstanceType)
+	assert.Equal(t, "us-east-1", template.Region)
+	assert.Equal(t, "us-east-1a", template.Zone)
+
+	// Nodes of multi-AZ ASGs are in the zone AWS launches the next instance in, or the one asked for.
+	mockDescribeAsg(s, &autoscaling.Group{
+		AutoScalingGroupName:    aws.String("multi-az"),
+		AvailabilityZones:       aws.StringSlice([]string{"us-east-1a", "us-east-1b"}),
+		LaunchConfigurationName: aws.String("lc-config"),
+		Instances: []*autoscaling.Instance{
+			{InstanceId: aws.String("i-1"), AvailabilityZone: aws.String("us-east-1a"), LifecycleState: aws.String("InService")},
+		},
+	})
+	template, err = m.getAsgTemplate("multi-az", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1b", template.Zone)
+	template, err = m.getAsgTemplate("multi-az", "us-east-1a")
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1a", template.Zone)
+	_, err = m.getAsgTemplate("multi-az", "us-east-1c")
+	assert.Error(t, err)
+
+	mockDescribeAsg(s, &autoscaling.Group{
+		AutoScalingGroupName: aws.String("lt"),
+		AvailabilityZones:    zones,
+		LaunchTemplate:       &autoscaling.LaunchTemplateSpecification{LaunchTemplateName: aws.String("lt-template")},
+	})
+	mockDescribeLaunchTemplate(s, "lt-template", defaultLaunchTemplateVersion, "m4.large")
+	template, err = m.getAsgTemplate("lt", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "m4.large", template.InstanceType.InstanceType)
+
+	mockDescribeAsg(s, &autoscaling.Group{
+		AutoScalingGroupName: aws.String("mixed"),
+		AvailabilityZones:    zones,
+		MixedInstancesPolicy: &autoscaling.MixedInstancesPolicy{
+			LaunchTemplate: &autoscaling.LaunchTemplate{
+				LaunchTemplateSpecification: &autoscaling.LaunchTemplateSpecification{
+					LaunchTemplateName: aws.String("mixed-template"),
+					Version:            aws.String("3"),
+				},
+				Overrides: []*autoscaling.LaunchTemplateOverrides{
+					{InstanceType: aws.String("m4.xlarge")},
+					{InstanceType: aws.String("m4.large")},
+					{InstanceType: aws.String("c4.large")},
+					{InstanceType: aws.String("z9.tiny")},
+				},
+			},
+		},
+	})
+	template, err = m.getAsgTemplate("mixed", "")
+	assert.NoError(t, err)
+	// c4.large has as many vcpus as m4.large but less memory.
+	assert.Equal(t, "c4.large", template.InstanceType.InstanceType)
+
+	mockDescribeAsg(s, &autoscaling.Group{
+		AutoScalingGroupName: aws.String("mixed-no-overrides"),
+		AvailabilityZones:    zones,
+		MixedInstancesPolicy: &autoscaling.MixedInstancesPolicy{
+			LaunchTemplate: &autoscaling.LaunchTemplate{
+				LaunchTemplateSpecification: &autoscaling.LaunchTemplateSpecification{
+					LaunchTemplateName: aws.String("mixed-template"),
+					Version:            aws.String("3"),
+				},
+			},
+		},
+	})
+	mockDescribeLaunchTemplate(s, "mixed-template", "3", "c4.large")
+	template, err = m.getAsgTemplate("mixed-no-overrides", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "c4.large", template.InstanceType.InstanceType)
+
+	mockDescribeAsg(s, &autoscaling.Group{
+		AutoScalingGroupName: aws.String("none"),
+		AvailabilityZones:    zones,
+	})
+	_, err = m.getAsgTemplate("none", "")
+	assert.Error(t, err)
+
+	mockDescribeAsg(s, &autoscaling.Group{
+		AutoScalingGroupName: aws.String("unknown"),
+		AvailabilityZones:    zones,
+		LaunchTemplate:       &autoscaling.LaunchTemplateSpecification{LaunchTemplateName: aws.String("unknown-template")},
+	})
+	mockDescribeLaunchTemplate(s, "unknown-template", defaultLaunchTemplateVersion, "z9.tiny")
+	_, err = m.getAsgTemplate("unknown", "")
+	assert.Error(t, err)
+}
+
+func TestScaleUpZones(t *testing.T) {
+	instance := func(zone string, state string) *autoscaling.Instance {
+		return &autoscaling.Instance{AvailabilityZone: aws.String(zone), LifecycleState: aws.String(state)}
+	}
+	group := &autoscaling.Group{
+		AvailabilityZones: aws.StringSlice([]string{"us-east-1a", "us-east-1b", "us-east-1c"}),
+	}
+	assert.Equal(t, []string{"us-east-1a", "us-east-1b", "us-east-1c"}, scaleUpZones(group))
+
+	group.Instances = []*autoscaling.Instance{
+		instance("us-east-1a", "InService"),
+		instance("us-east-1b", "Pending"),
+		instance("us-east-1c", "Terminating:Wait"),
+	}
+	assert.Equal(t, []string{"us-east-1c"}, scaleUpZones(group))
+
+	group.Instances = append(group.Instances, instance("us-east-1c", "InService"), instance("us-east-1a", "InService"))
+	assert.Equal(t, []string{"us-east-1b", "us-east-1c"}, scaleUpZones(group))
+}
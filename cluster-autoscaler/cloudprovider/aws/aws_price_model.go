@@ -0,0 +1,212 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//go:generate go run ec2_prices/gen.go
+
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+)
+
+const (
+	// PayModelLabel is the node label telling whether a node runs on a spot or an on-demand instance.
+	PayModelLabel = "autoscalr.com/paymodel"
+	// PayModelSpot is the value of PayModelLabel on spot nodes.
+	PayModelSpot = "spot"
+)
+
+// IsSpotNode returns true if the node runs on a spot instance, according to its PayModelLabel.
+func IsSpotNode(node *apiv1.Node) bool {
+	return strings.EqualFold(node.Labels[PayModelLabel], PayModelSpot)
+}
+
+// Hourly on-demand rates of general purpose instances (m5 in us-east-1), used to price pods
+// and instance types missing from InstancePrices.
+const (
+	cpuPricePerHour         = 0.0316
+	memoryPricePerHourPerGb = 0.0042
+	gpuPricePerHour         = 0.90
+
+	bytesPerGb = 1024 * 1024 * 1024
+)
+
+// SpotPriceSource provides current spot prices.
+type SpotPriceSource interface {
+	// SpotPrice returns the hourly spot price of the instance type in the given availability
+	// zone of the region, and false if it isn't known.
+	SpotPrice(instanceType, region, zone string) (float64, bool, error)
+}
+
+// AwsPriceModel implements PricingModel interface for AWS. On-demand prices come from
+// InstancePrices, spot prices from a SpotPriceSource. All prices are in USD.
+type AwsPriceModel struct {
+	spotPrices SpotPriceSource
+}
+
+// NewAwsPriceModel builds an AwsPriceModel. spotPrices may be nil, in which case spot nodes
+// are priced as on-demand nodes.
+func NewAwsPriceModel(spotPrices SpotPriceSource) *AwsPriceModel {
+	return &AwsPriceModel{
+		spotPrices: spotPrices,
+	}
+}
+
+// NodePrice returns a price of running the given node for a given period of time.
+func (model *AwsPriceModel) NodePrice(node *apiv1.Node, startTime time.Time, endTime time.Time) (float64, error) {
+	hours := getHours(startTime, endTime)
+	instanceType := node.Labels[kubeletapis.LabelInstanceType]
+	region, zone := nodeRegion(node), node.Labels[kubeletapis.LabelZoneFailureDomain]
+
+	hourlyPrice, found := InstancePrices[region][instanceType]
+	if !found {
+		glog.V(4).Infof("No on-demand price of %s in %q, pricing %s by its resources", instanceType, region, node.Name)
+		hourlyPrice = getBasePrice(node.Status.Capacity) + getAdditionalPrice(node.Status.Capacity)
+	}
+	if model.spotPrices != nil && IsSpotNode(node) {
+		spotPrice, found, err := model.spotPrices.SpotPrice(instanceType, region, zone)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get spot price of %s: %v", instanceType, err)
+		}
+		if found {
+			hourlyPrice = spotPrice
+		} else {
+			glog.V(4).Infof("No spot price of %s in %q, using the on-demand price for %s", instanceType, zone, node.Name)
+		}
+	}
+	return hourlyPrice * hours, nil
+}
+
+// PodPrice returns a theoretical minimum price of running a pod for a given
+// period of time on a perfectly matching machine.
+func (model *AwsPriceModel) PodPrice(pod *apiv1.Pod, startTime time.Time, endTime time.Time) (float64, error) {
+	hours := getHours(startTime, endTime)
+	price := 0.0
+	for _, container := range pod.Spec.Containers {
+		price += getBasePrice(container.Resources.Requests) * hours
+		price += getAdditionalPrice(container.Resources.Requests) * hours
+	}
+	return price, nil
+}
+
+// nodeRegion returns the region of the node, taken from its zone if it has no region label.
+func nodeRegion(node *apiv1.Node) string {
+	if region := node.Labels[kubeletapis.LabelZoneRegion]; region != "" {
+		return region
+	}
+	if zone := node.Labels[kubeletapis.LabelZoneFailureDomain]; len(zone) > 1 {
+		return zone[:len(zone)-1]
+	}
+	return ""
+}
+
+func getHours(startTime time.Time, endTime time.Time) float64 {
+	minutes := math.Ceil(float64(endTime.Sub(startTime)) / float64(time.Minute))
+	return minutes / 60.0
+}
+
+// getBasePrice returns the hourly price of the cpu and memory in resources.
+func getBasePrice(resources apiv1.ResourceList) float64 {
+	price := 0.0
+	if cpu, found := resources[apiv1.ResourceCPU]; found {
+		price += float64(cpu.MilliValue()) / 1000.0 * cpuPricePerHour
+	}
+	if mem, found := resources[apiv1.ResourceMemory]; found {
+		price += float64(mem.Value()) / bytesPerGb * memoryPricePerHourPerGb
+	}
+	return price
+}
+
+// getAdditionalPrice returns the hourly price of the GPUs in resources.
+func getAdditionalPrice(resources apiv1.ResourceList) float64 {
+	if gpus, found := resources[gpu.ResourceNvidiaGPU]; found {
+		return float64(gpus.Value()) * gpuPricePerHour
+	}
+	return 0.0
+}
+
+// fileSpotPriceSource reads spot prices from a json file mapping availability zones, or whole
+// regions, to the hourly prices of instance types:
+//
+//	{"us-east-1a": {"m4.large": 0.031}, "us-east-1": {"c4.large": 0.029}}
+//
+// The file is read again whenever it changes, so it can be kept up to date by another process.
+type fileSpotPriceSource struct {
+	path string
+
+	mutex   sync.Mutex
+	modTime time.Time
+	prices  map[string]map[string]float64
+}
+
+// NewFileSpotPriceSource returns a SpotPriceSource reading prices from the given file.
+func NewFileSpotPriceSource(path string) (SpotPriceSource, error) {
+	source := &fileSpotPriceSource{path: path}
+	if err := source.reload(); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// SpotPrice implements SpotPriceSource. Prices of the zone take precedence over prices of the region.
+func (s *fileSpotPriceSource) SpotPrice(instanceType, region, zone string) (float64, bool, error) {
+	if err := s.reload(); err != nil {
+		glog.Warningf("Failed to reload spot prices, using the previous ones: %v", err)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, location := range []string{zone, region} {
+		if price, found := s.prices[location][instanceType]; found && location != "" {
+			return price, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func (s *fileSpotPriceSource) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.prices != nil && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	content, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	prices := make(map[string]map[string]float64)
+	if err := json.Unmarshal(content, &prices); err != nil {
+		return fmt.Errorf("invalid spot price file %s: %v", s.path, err)
+	}
+	s.prices = prices
+	s.modTime = info.ModTime()
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
+	. "k8s.io/autoscaler/cluster-autoscaler/utils/test"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+)
+
+type fakeSpotPriceSource map[string]float64
+
+func (s fakeSpotPriceSource) SpotPrice(instanceType, region, zone string) (float64, bool, error) {
+	price, found := s[zone+"/"+instanceType]
+	return price, found, nil
+}
+
+func buildPricedNode(instanceType, zone, payModel string, millicpu, mem int64) *apiv1.Node {
+	node := &apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "n-" + instanceType,
+			Labels: map[string]string{
+				kubeletapis.LabelInstanceType:      instanceType,
+				kubeletapis.LabelZoneFailureDomain: zone,
+			},
+		},
+		Status: apiv1.NodeStatus{
+			Capacity: apiv1.ResourceList{
+				apiv1.ResourceCPU:    *resource.NewMilliQuantity(millicpu, resource.DecimalSI),
+				apiv1.ResourceMemory: *resource.NewQuantity(mem, resource.DecimalSI),
+			},
+		},
+	}
+	if payModel != "" {
+		node.Labels[PayModelLabel] = payModel
+	}
+	return node
+}
+
+func TestNodePrice(t *testing.T) {
+	model := NewAwsPriceModel(fakeSpotPriceSource{"us-east-1a/m4.large": 0.03})
+	now := time.Now()
+	then := now.Add(time.Hour)
+
+	price := func(node *apiv1.Node, endTime time.Time) float64 {
+		p, err := model.NodePrice(node, now, endTime)
+		assert.NoError(t, err)
+		return p
+	}
+
+	// On-demand prices depend on the region, taken from the zone here.
+	assert.InDelta(t, 0.1, price(buildPricedNode("m4.large", "us-east-1a", "", 2000, 8*bytesPerGb), then), 1e-9)
+	assert.InDelta(t, 0.111, price(buildPricedNode("m4.large", "eu-west-1b", "ondemand", 2000, 8*bytesPerGb), then), 1e-9)
+	assert.InDelta(t, 0.2, price(buildPricedNode("m4.large", "us-east-1a", "", 2000, 8*bytesPerGb), now.Add(2*time.Hour)), 1e-9)
+
+	// Spot nodes get the spot price of their zone, or the on-demand price if it is unknown.
+	assert.InDelta(t, 0.03, price(buildPricedNode("m4.large", "us-east-1a", "spot", 2000, 8*bytesPerGb), then), 1e-9)
+	assert.InDelta(t, 0.1, price(buildPricedNode("m4.large", "us-east-1b", "spot", 2000, 8*bytesPerGb), then), 1e-9)
+
+	// Unknown instance types are priced by their resources.
+	unknown := buildPricedNode("x9.large", "us-east-1a", "", 2000, 8*bytesPerGb)
+	assert.InDelta(t, 2*cpuPricePerHour+8*memoryPricePerHourPerGb, price(unknown, then), 1e-9)
+	unknown.Status.Capacity[gpu.ResourceNvidiaGPU] = *resource.NewQuantity(1, resource.DecimalSI)
+	assert.InDelta(t, 2*cpuPricePerHour+8*memoryPricePerHourPerGb+gpuPricePerHour, price(unknown, then), 1e-9)
+
+	// A bigger instance costs more than a smaller one.
+	assert.True(t, price(buildPricedNode("m4.xlarge", "us-east-1a", "", 4000, 16*bytesPerGb), then) >
+		price(buildPricedNode("m4.large", "us-east-1a", "", 2000, 8*bytesPerGb), then))
+}
+
+func TestPodPrice(t *testing.T) {
+	model := NewAwsPriceModel(nil)
+	now := time.Now()
+	pod := BuildTestPod("p1", 500, bytesPerGb)
+
+	price, err := model.PodPrice(pod, now, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.5*cpuPricePerHour+memoryPricePerHourPerGb, price, 1e-9)
+
+	// A pod is cheaper than the node it fits on.
+	nodePrice, err := model.NodePrice(buildPricedNode("m5.large", "us-east-1a", "", 2000, 8*bytesPerGb), now, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, price < nodePrice)
+}
+
+func TestFileSpotPriceSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spot-prices")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spot-prices.json")
+
+	_, err = NewFileSpotPriceSource(path)
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"us-east-1a": {"m4.large": 0.03}, "us-east-1": {"m4.large": 0.04}}`), 0644))
+	source, err := NewFileSpotPriceSource(path)
+	assert.NoError(t, err)
+
+	price, found, err := source.SpotPrice("m4.large", "us-east-1", "us-east-1a")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 0.03, price)
+	price, found, err = source.SpotPrice("m4.large", "us-east-1", "us-east-1b")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 0.04, price)
+	_, found, err = source.SpotPrice("c4.large", "us-east-1", "us-east-1a")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	// The file is read again when it changes.
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"us-east-1a": {"m4.large": 0.05}}`), 0644))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+	price, found, err = source.SpotPrice("m4.large", "us-east-1", "us-east-1a")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 0.05, price)
+
+	// An invalid file keeps the previous prices.
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{`), 0644))
+	later = later.Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+	price, found, err = source.SpotPrice("m4.large", "us-east-1", "us-east-1a")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 0.05, price)
+}
@@ -19,26 +19,34 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
-	"html/template"
+	"fmt"
+	"go/format"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
 
-	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/golang/glog"
 )
 
+var (
+	offersFlag = flag.String("offers", "", "Comma separated list of AWS offer files of EC2 to read the instance types from, "+
+		"such as https://pricing.us-east-1.amazonaws.com/offers/v1.0/aws/AmazonEC2/current/us-east-1/index.json")
+	outputFlag = flag.String("output", "ec2instances/ec2_instance_types.go", "File to write the catalog to")
+)
+
 type response struct {
 	Products map[string]product `json:"products"`
 }
 
 type product struct {
-	Attributes productAttributes `json:"attributes"`
+	ProductFamily string            `json:"productFamily"`
+	Attributes    productAttributes `json:"attributes"`
 }
 
 type productAttributes struct {
@@ -46,17 +54,149 @@ type productAttributes struct {
 	VCPU         string `json:"vcpu"`
 	Memory       string `json:"memory"`
 	GPU          string `json:"gpu"`
+	// PhysicalProcessor is e.g. "Intel Xeon Family" or "AWS Graviton Processor".
+	PhysicalProcessor  string `json:"physicalProcessor"`
+	NetworkPerformance string `json:"networkPerformance"`
+	Storage            string `json:"storage"`
 }
 
 type instanceType struct {
-	InstanceType string
-	VCPU         int64
-	Memory       int64
-	GPU          int64
+	InstanceType       string
+	VCPU               int64
+	Memory             int64
+	GPU                int64
+	GPUModel           string
+	Architecture       string
+	MaxENI             int64
+	IPsPerENI          int64
+	NetworkPerformance string
+	Storage            string
+}
+
+type eniLimit struct {
+	MaxENI    int64
+	IPsPerENI int64
+}
+
+// eniLimits are the network interface limits of instance types, which aren't part of the price
+// list. They come from https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/using-eni.html#AvailableIpPerENI
+var eniLimits = map[string]eniLimit{
+	"c1.medium":    {2, 6},
+	"c1.xlarge":    {4, 15},
+	"c3.large":     {3, 10},
+	"c3.xlarge":    {4, 15},
+	"c3.2xlarge":   {4, 15},
+	"c3.4xlarge":   {8, 30},
+	"c3.8xlarge":   {8, 30},
+	"c4.large":     {3, 10},
+	"c4.xlarge":    {4, 15},
+	"c4.2xlarge":   {4, 15},
+	"c4.4xlarge":   {8, 30},
+	"c4.8xlarge":   {8, 30},
+	"c5.large":     {3, 10},
+	"c5.xlarge":    {4, 15},
+	"c5.2xlarge":   {4, 15},
+	"c5.4xlarge":   {8, 30},
+	"c5.9xlarge":   {8, 30},
+	"c5.18xlarge":  {15, 50},
+	"cc2.8xlarge":  {8, 30},
+	"cg1.4xlarge":  {8, 30},
+	"cr1.8xlarge":  {8, 30},
+	"d2.xlarge":    {4, 15},
+	"d2.2xlarge":   {4, 15},
+	"d2.4xlarge":   {8, 30},
+	"d2.8xlarge":   {8, 30},
+	"f1.2xlarge":   {4, 15},
+	"f1.16xlarge":  {8, 50},
+	"g2.2xlarge":   {4, 15},
+	"g2.8xlarge":   {8, 30},
+	"g3.4xlarge":   {8, 30},
+	"g3.8xlarge":   {8, 30},
+	"g3.16xlarge":  {15, 50},
+	"h1.2xlarge":   {4, 15},
+	"h1.4xlarge":   {8, 30},
+	"h1.8xlarge":   {8, 30},
+	"h1.16xlarge":  {15, 50},
+	"hs1.8xlarge":  {8, 30},
+	"i2.xlarge":    {4, 15},
+	"i2.2xlarge":   {4, 15},
+	"i2.4xlarge":   {8, 30},
+	"i2.8xlarge":   {8, 30},
+	"i3.large":     {3, 10},
+	"i3.xlarge":    {4, 15},
+	"i3.2xlarge":   {4, 15},
+	"i3.4xlarge":   {8, 30},
+	"i3.8xlarge":   {8, 30},
+	"i3.16xlarge":  {15, 50},
+	"m1.small":     {2, 4},
+	"m1.medium":    {2, 6},
+	"m1.large":     {3, 10},
+	"m1.xlarge":    {4, 15},
+	"m2.xlarge":    {4, 15},
+	"m2.2xlarge":   {4, 30},
+	"m2.4xlarge":   {8, 30},
+	"m3.medium":    {2, 6},
+	"m3.large":     {3, 10},
+	"m3.xlarge":    {4, 15},
+	"m3.2xlarge":   {4, 30},
+	"m4.large":     {2, 10},
+	"m4.xlarge":    {4, 15},
+	"m4.2xlarge":   {4, 15},
+	"m4.4xlarge":   {8, 30},
+	"m4.10xlarge":  {8, 30},
+	"m4.16xlarge":  {8, 30},
+	"m5.large":     {3, 10},
+	"m5.xlarge":    {4, 15},
+	"m5.2xlarge":   {4, 15},
+	"m5.4xlarge":   {8, 30},
+	"m5.12xlarge":  {8, 30},
+	"m5.24xlarge":  {15, 50},
+	"p2.xlarge":    {4, 15},
+	"p2.8xlarge":   {8, 30},
+	"p2.16xlarge":  {8, 30},
+	"p3.2xlarge":   {4, 15},
+	"p3.8xlarge":   {8, 30},
+	"p3.16xlarge":  {8, 30},
+	"r3.large":     {3, 10},
+	"r3.xlarge":    {4, 15},
+	"r3.2xlarge":   {4, 15},
+	"r3.4xlarge":   {8, 30},
+	"r3.8xlarge":   {8, 30},
+	"r4.large":     {3, 10},
+	"r4.xlarge":    {4, 15},
+	"r4.2xlarge":   {4, 15},
+	"r4.4xlarge":   {8, 30},
+	"r4.8xlarge":   {8, 30},
+	"r4.16xlarge":  {15, 50},
+	"t1.micro":     {2, 2},
+	"t2.nano":      {2, 2},
+	"t2.micro":     {2, 2},
+	"t2.small":     {3, 4},
+	"t2.medium":    {3, 6},
+	"t2.large":     {3, 12},
+	"t2.xlarge":    {3, 15},
+	"t2.2xlarge":   {3, 15},
+	"x1.16xlarge":  {8, 30},
+	"x1.32xlarge":  {8, 30},
+	"x1e.xlarge":   {3, 10},
+	"x1e.2xlarge":  {4, 15},
+	"x1e.4xlarge":  {4, 15},
+	"x1e.8xlarge":  {4, 15},
+	"x1e.16xlarge": {8, 30},
+	"x1e.32xlarge": {8, 30},
+}
+
+// gpuModels are the GPU models of instance families, which aren't part of the price list.
+var gpuModels = map[string]string{
+	"cg1": "NVIDIA Tesla M2050",
+	"g2":  "NVIDIA GRID K520",
+	"g3":  "NVIDIA Tesla M60",
+	"p2":  "NVIDIA Tesla K80",
+	"p3":  "NVIDIA Tesla V100",
 }
 
 var packageTemplate = template.Must(template.New("").Parse(`/*
-Copyright 2017 The Kubernetes Authors.
+Copyright 2018 The Kubernetes Authors.
 
 Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
@@ -73,23 +213,22 @@ limitations under the License.
 
 // This file was generated by go generate; DO NOT EDIT
 
-package aws
-
-type instanceType struct {
-	InstanceType string
-	VCPU         int64
-	MemoryMb     int64
-	GPU          int64
-}
+package ec2instances
 
 // InstanceTypes is a map of ec2 resources
-var InstanceTypes = map[string]*instanceType{
+var InstanceTypes = map[string]*InstanceType{
 {{- range .InstanceTypes }}
 	"{{ .InstanceType }}": {
-		InstanceType: "{{ .InstanceType }}",
-		VCPU:         {{ .VCPU }},
-		MemoryMb:     {{ .Memory }},
-		GPU:          {{ .GPU }},
+		InstanceType:       "{{ .InstanceType }}",
+		VCPU:               {{ .VCPU }},
+		MemoryMb:           {{ .Memory }},
+		GPU:                {{ .GPU }},
+		GPUModel:           "{{ .GPUModel }}",
+		Architecture:       "{{ .Architecture }}",
+		MaxENI:             {{ .MaxENI }},
+		IPsPerENI:          {{ .IPsPerENI }},
+		NetworkPerformance: "{{ .NetworkPerformance }}",
+		Storage:            "{{ .Storage }}",
 	},
 {{- end }}
 }
@@ -99,72 +238,92 @@ func main() {
 	flag.Parse()
 	defer glog.Flush()
 
-	instanceTypes := make(map[string]*instanceType)
-
-	resolver := endpoints.DefaultResolver()
-	partitions := resolver.(endpoints.EnumPartitions).Partitions()
-
-	for _, p := range partitions {
-		for _, r := range p.Regions() {
-			url := "https://pricing.us-east-1.amazonaws.com/offers/v1.0/aws/AmazonEC2/current/" + r.ID() + "/index.json"
-			glog.V(1).Infof("fetching %s\n", url)
-			res, err := http.Get(url)
-			if err != nil {
-				glog.Warningf("Error fetching %s skipping...\n", url)
-				continue
-			}
-
-			defer res.Body.Close()
-
-			body, err := ioutil.ReadAll(res.Body)
-			if err != nil {
-				glog.Warningf("Error parsing %s skipping...\n", url)
-				continue
-			}
-
-			var unmarshalled = response{}
-			err = json.Unmarshal(body, &unmarshalled)
-			if err != nil {
-				glog.Warningf("Error unmarshaling %s skipping...\n", url)
-				continue
-			}
-
-			for _, product := range unmarshalled.Products {
-				attr := product.Attributes
-				if attr.InstanceType != "" {
-					instanceTypes[attr.InstanceType] = &instanceType{
-						InstanceType: attr.InstanceType,
-					}
-					if attr.Memory != "" && attr.Memory != "NA" {
-						instanceTypes[attr.InstanceType].Memory = parseMemory(attr.Memory)
-					}
-					if attr.VCPU != "" {
-						instanceTypes[attr.InstanceType].VCPU = parseCPU(attr.VCPU)
-					}
-					if attr.GPU != "" {
-						instanceTypes[attr.InstanceType].GPU = parseCPU(attr.GPU)
-					}
-				}
-			}
-		}
+	if *offersFlag == "" {
+		glog.Error("No offer file given, set -offers")
+		flag.Usage()
+		os.Exit(2)
 	}
 
-	f, err := os.Create("ec2_instance_types.go")
-	if err != nil {
-		glog.Fatal(err)
+	instanceTypes := make(map[string]*instanceType)
+	for _, file := range strings.Split(*offersFlag, ",") {
+		glog.V(1).Infof("reading %s\n", file)
+		if err := readOffers(file, instanceTypes); err != nil {
+			glog.Fatalf("Error reading %s: %v", file, err)
+		}
 	}
 
-	defer f.Close()
-
-	err = packageTemplate.Execute(f, struct {
+	var buf bytes.Buffer
+	err := packageTemplate.Execute(&buf, struct {
 		InstanceTypes map[string]*instanceType
 	}{
 		InstanceTypes: instanceTypes,
 	})
+	if err != nil {
+		glog.Fatal(err)
+	}
 
+	source, err := format.Source(buf.Bytes())
 	if err != nil {
 		glog.Fatal(err)
 	}
+	if err := ioutil.WriteFile(*outputFlag, source, 0644); err != nil {
+		glog.Fatal(err)
+	}
+}
+
+// readOffers adds the instance types of the offer file to instanceTypes. Only instances are
+// read, dedicated hosts and instance types without memory are skipped. An instance type
+// described differently by several products is an error.
+func readOffers(file string, instanceTypes map[string]*instanceType) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var unmarshalled = response{}
+	if err := json.NewDecoder(f).Decode(&unmarshalled); err != nil {
+		return err
+	}
+
+	for _, product := range unmarshalled.Products {
+		attr := product.Attributes
+		if product.ProductFamily != "Compute Instance" || attr.InstanceType == "" {
+			continue
+		}
+		t := &instanceType{
+			InstanceType:       attr.InstanceType,
+			Architecture:       parseArchitecture(attr.PhysicalProcessor),
+			NetworkPerformance: attr.NetworkPerformance,
+			Storage:            attr.Storage,
+		}
+		if limit, found := eniLimits[attr.InstanceType]; found {
+			t.MaxENI = limit.MaxENI
+			t.IPsPerENI = limit.IPsPerENI
+		}
+		if attr.Memory != "" && attr.Memory != "NA" {
+			t.Memory = parseMemory(attr.Memory)
+		}
+		if attr.VCPU != "" {
+			t.VCPU = parseCPU(attr.VCPU)
+		}
+		if attr.GPU != "" {
+			t.GPU = parseCPU(attr.GPU)
+		}
+		if t.GPU > 0 {
+			t.GPUModel = gpuModels[strings.Split(attr.InstanceType, ".")[0]]
+		}
+		if t.Memory <= 0 || t.VCPU <= 0 {
+			glog.Warningf("Skipping %s without memory or vcpus", attr.InstanceType)
+			continue
+		}
+
+		if existing, found := instanceTypes[attr.InstanceType]; found && *existing != *t {
+			return fmt.Errorf("conflicting products of %s: %+v and %+v", attr.InstanceType, *existing, *t)
+		}
+		instanceTypes[attr.InstanceType] = t
+	}
+	return nil
 }
 
 func parseMemory(memory string) int64 {
@@ -189,3 +348,10 @@ func parseCPU(cpu string) int64 {
 	}
 	return i
 }
+
+func parseArchitecture(processor string) string {
+	if strings.Contains(processor, "Graviton") {
+		return "arm64"
+	}
+	return "amd64"
+}
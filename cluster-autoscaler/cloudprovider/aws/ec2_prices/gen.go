@@ -0,0 +1,181 @@
+// +build ignore
+
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"go/format"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/golang/glog"
+)
+
+type response struct {
+	Products map[string]product           `json:"products"`
+	Terms    map[string]map[string]offers `json:"terms"`
+}
+
+type product struct {
+	Sku        string            `json:"sku"`
+	Attributes productAttributes `json:"attributes"`
+}
+
+type productAttributes struct {
+	InstanceType    string `json:"instanceType"`
+	OperatingSystem string `json:"operatingSystem"`
+	Tenancy         string `json:"tenancy"`
+	PreInstalledSw  string `json:"preInstalledSw"`
+	CapacityStatus  string `json:"capacitystatus"`
+}
+
+// offers are the offer terms of a sku, keyed by offer term code.
+type offers map[string]struct {
+	PriceDimensions map[string]struct {
+		PricePerUnit map[string]string `json:"pricePerUnit"`
+	} `json:"priceDimensions"`
+}
+
+var packageTemplate = template.Must(template.New("").Parse(`/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// This file was generated by go generate; DO NOT EDIT
+
+package aws
+
+// InstancePrices are the hourly on-demand prices in USD of Linux instances with shared tenancy,
+// keyed by region and instance type.
+var InstancePrices = map[string]map[string]float64{
+{{- range $region, $prices := .InstancePrices }}
+	"{{ $region }}": {
+{{- range $instanceType, $price := $prices }}
+		"{{ $instanceType }}": {{ $price }},
+{{- end }}
+	},
+{{- end }}
+}
+`))
+
+func main() {
+	regionsFlag := flag.String("regions", "", "Comma separated regions to fetch prices of, all regions if empty")
+	flag.Parse()
+	defer glog.Flush()
+
+	wanted := make(map[string]bool)
+	for _, region := range strings.Split(*regionsFlag, ",") {
+		if region != "" {
+			wanted[region] = true
+		}
+	}
+
+	instancePrices := make(map[string]map[string]float64)
+
+	resolver := endpoints.DefaultResolver()
+	partitions := resolver.(endpoints.EnumPartitions).Partitions()
+
+	for _, p := range partitions {
+		for _, r := range p.Regions() {
+			if len(wanted) > 0 && !wanted[r.ID()] {
+				continue
+			}
+			url := "https://pricing.us-east-1.amazonaws.com/offers/v1.0/aws/AmazonEC2/current/" + r.ID() + "/index.json"
+			glog.V(1).Infof("fetching %s\n", url)
+			prices, err := fetchPrices(url)
+			if err != nil {
+				glog.Warningf("Error fetching %s skipping...: %v\n", url, err)
+				continue
+			}
+			if len(prices) > 0 {
+				instancePrices[r.ID()] = prices
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	err := packageTemplate.Execute(&buf, struct {
+		InstancePrices map[string]map[string]float64
+	}{
+		InstancePrices: instancePrices,
+	})
+	if err != nil {
+		glog.Fatal(err)
+	}
+
+	// Map values are not aligned by the template.
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		glog.Fatal(err)
+	}
+	if err := ioutil.WriteFile("ec2_prices.go", source, 0644); err != nil {
+		glog.Fatal(err)
+	}
+}
+
+// fetchPrices returns the on-demand prices of Linux instances with shared tenancy in the
+// offer file at url, keyed by instance type.
+func fetchPrices(url string) (map[string]float64, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var unmarshalled = response{}
+	if err := json.NewDecoder(res.Body).Decode(&unmarshalled); err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]float64)
+	for _, product := range unmarshalled.Products {
+		attr := product.Attributes
+		if attr.InstanceType == "" || attr.OperatingSystem != "Linux" || attr.Tenancy != "Shared" ||
+			attr.PreInstalledSw != "NA" || (attr.CapacityStatus != "" && attr.CapacityStatus != "Used") {
+			continue
+		}
+		for _, offer := range unmarshalled.Terms["OnDemand"][product.Sku] {
+			for _, dimension := range offer.PriceDimensions {
+				price, err := strconv.ParseFloat(dimension.PricePerUnit["USD"], 64)
+				if err != nil || price == 0 {
+					continue
+				}
+				prices[attr.InstanceType] = price
+			}
+		}
+	}
+	return prices, nil
+}
@@ -0,0 +1,1283 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// This file was generated by go generate; DO NOT EDIT
+
+package ec2instances
+
+// InstanceTypes is a map of ec2 resources
+var InstanceTypes = map[string]*InstanceType{
+	"c1.medium": {
+		InstanceType:       "c1.medium",
+		VCPU:               2,
+		MemoryMb:           1740,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             2,
+		IPsPerENI:          6,
+		NetworkPerformance: "Moderate",
+		Storage:            "1 x 350",
+	},
+	"c1.xlarge": {
+		InstanceType:       "c1.xlarge",
+		VCPU:               8,
+		MemoryMb:           7168,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "4 x 420",
+	},
+	"c3.2xlarge": {
+		InstanceType:       "c3.2xlarge",
+		VCPU:               8,
+		MemoryMb:           15360,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "2 x 80 SSD",
+	},
+	"c3.4xlarge": {
+		InstanceType:       "c3.4xlarge",
+		VCPU:               16,
+		MemoryMb:           30720,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "High",
+		Storage:            "2 x 160 SSD",
+	},
+	"c3.8xlarge": {
+		InstanceType:       "c3.8xlarge",
+		VCPU:               32,
+		MemoryMb:           61440,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "2 x 320 SSD",
+	},
+	"c3.large": {
+		InstanceType:       "c3.large",
+		VCPU:               2,
+		MemoryMb:           3840,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          10,
+		NetworkPerformance: "Moderate",
+		Storage:            "2 x 16 SSD",
+	},
+	"c3.xlarge": {
+		InstanceType:       "c3.xlarge",
+		VCPU:               4,
+		MemoryMb:           7680,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Moderate",
+		Storage:            "2 x 40 SSD",
+	},
+	"c4.2xlarge": {
+		InstanceType:       "c4.2xlarge",
+		VCPU:               8,
+		MemoryMb:           15360,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "EBS only",
+	},
+	"c4.4xlarge": {
+		InstanceType:       "c4.4xlarge",
+		VCPU:               16,
+		MemoryMb:           30720,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "High",
+		Storage:            "EBS only",
+	},
+	"c4.8xlarge": {
+		InstanceType:       "c4.8xlarge",
+		VCPU:               36,
+		MemoryMb:           61440,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"c4.large": {
+		InstanceType:       "c4.large",
+		VCPU:               2,
+		MemoryMb:           3840,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          10,
+		NetworkPerformance: "Moderate",
+		Storage:            "EBS only",
+	},
+	"c4.xlarge": {
+		InstanceType:       "c4.xlarge",
+		VCPU:               4,
+		MemoryMb:           7680,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "EBS only",
+	},
+	"c5.18xlarge": {
+		InstanceType:       "c5.18xlarge",
+		VCPU:               72,
+		MemoryMb:           147456,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             15,
+		IPsPerENI:          50,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "EBS only",
+	},
+	"c5.2xlarge": {
+		InstanceType:       "c5.2xlarge",
+		VCPU:               8,
+		MemoryMb:           16384,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"c5.4xlarge": {
+		InstanceType:       "c5.4xlarge",
+		VCPU:               16,
+		MemoryMb:           32768,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"c5.9xlarge": {
+		InstanceType:       "c5.9xlarge",
+		VCPU:               36,
+		MemoryMb:           73728,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"c5.large": {
+		InstanceType:       "c5.large",
+		VCPU:               2,
+		MemoryMb:           4096,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          10,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"c5.xlarge": {
+		InstanceType:       "c5.xlarge",
+		VCPU:               4,
+		MemoryMb:           8192,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"cc1.4xlarge": {
+		InstanceType:       "cc1.4xlarge",
+		VCPU:               16,
+		MemoryMb:           23552,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             0,
+		IPsPerENI:          0,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "2 x 840",
+	},
+	"cc2.8xlarge": {
+		InstanceType:       "cc2.8xlarge",
+		VCPU:               32,
+		MemoryMb:           61952,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "4 x 840",
+	},
+	"cg1.4xlarge": {
+		InstanceType:       "cg1.4xlarge",
+		VCPU:               16,
+		MemoryMb:           23040,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "2 x 840",
+	},
+	"cr1.8xlarge": {
+		InstanceType:       "cr1.8xlarge",
+		VCPU:               32,
+		MemoryMb:           249856,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "2 x 120 SSD",
+	},
+	"d2.2xlarge": {
+		InstanceType:       "d2.2xlarge",
+		VCPU:               8,
+		MemoryMb:           62464,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "6 x 2000 HDD",
+	},
+	"d2.4xlarge": {
+		InstanceType:       "d2.4xlarge",
+		VCPU:               16,
+		MemoryMb:           124928,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "High",
+		Storage:            "12 x 2000 HDD",
+	},
+	"d2.8xlarge": {
+		InstanceType:       "d2.8xlarge",
+		VCPU:               36,
+		MemoryMb:           249856,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "24 x 2000 HDD",
+	},
+	"d2.xlarge": {
+		InstanceType:       "d2.xlarge",
+		VCPU:               4,
+		MemoryMb:           31232,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Moderate",
+		Storage:            "3 x 2000 HDD",
+	},
+	"f1.16xlarge": {
+		InstanceType:       "f1.16xlarge",
+		VCPU:               64,
+		MemoryMb:           999424,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          50,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "4 x 940 NVMe SSD",
+	},
+	"f1.2xlarge": {
+		InstanceType:       "f1.2xlarge",
+		VCPU:               8,
+		MemoryMb:           124928,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "1 x 470 NVMe SSD",
+	},
+	"g2.2xlarge": {
+		InstanceType:       "g2.2xlarge",
+		VCPU:               8,
+		MemoryMb:           15360,
+		GPU:                1,
+		GPUModel:           "NVIDIA GRID K520",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "1 x 60 SSD",
+	},
+	"g2.8xlarge": {
+		InstanceType:       "g2.8xlarge",
+		VCPU:               32,
+		MemoryMb:           61440,
+		GPU:                4,
+		GPUModel:           "NVIDIA GRID K520",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "2 x 120 SSD",
+	},
+	"g3.16xlarge": {
+		InstanceType:       "g3.16xlarge",
+		VCPU:               64,
+		MemoryMb:           499712,
+		GPU:                4,
+		GPUModel:           "NVIDIA Tesla M60",
+		Architecture:       "amd64",
+		MaxENI:             15,
+		IPsPerENI:          50,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "EBS only",
+	},
+	"g3.4xlarge": {
+		InstanceType:       "g3.4xlarge",
+		VCPU:               16,
+		MemoryMb:           124928,
+		GPU:                1,
+		GPUModel:           "NVIDIA Tesla M60",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"g3.8xlarge": {
+		InstanceType:       "g3.8xlarge",
+		VCPU:               32,
+		MemoryMb:           249856,
+		GPU:                2,
+		GPUModel:           "NVIDIA Tesla M60",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"h1.16xlarge": {
+		InstanceType:       "h1.16xlarge",
+		VCPU:               64,
+		MemoryMb:           262144,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             15,
+		IPsPerENI:          50,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "8 x 2000 HDD",
+	},
+	"h1.2xlarge": {
+		InstanceType:       "h1.2xlarge",
+		VCPU:               8,
+		MemoryMb:           32768,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "1 x 2000 HDD",
+	},
+	"h1.4xlarge": {
+		InstanceType:       "h1.4xlarge",
+		VCPU:               16,
+		MemoryMb:           65536,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "2 x 2000 HDD",
+	},
+	"h1.8xlarge": {
+		InstanceType:       "h1.8xlarge",
+		VCPU:               32,
+		MemoryMb:           131072,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "4 x 2000 HDD",
+	},
+	"hi1.4xlarge": {
+		InstanceType:       "hi1.4xlarge",
+		VCPU:               16,
+		MemoryMb:           61952,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             0,
+		IPsPerENI:          0,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "2 x 1024 SSD",
+	},
+	"hs1.8xlarge": {
+		InstanceType:       "hs1.8xlarge",
+		VCPU:               17,
+		MemoryMb:           119808,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "24 x 2000",
+	},
+	"i2.2xlarge": {
+		InstanceType:       "i2.2xlarge",
+		VCPU:               8,
+		MemoryMb:           62464,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "2 x 800 SSD",
+	},
+	"i2.4xlarge": {
+		InstanceType:       "i2.4xlarge",
+		VCPU:               16,
+		MemoryMb:           124928,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "High",
+		Storage:            "4 x 800 SSD",
+	},
+	"i2.8xlarge": {
+		InstanceType:       "i2.8xlarge",
+		VCPU:               32,
+		MemoryMb:           249856,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "8 x 800 SSD",
+	},
+	"i2.xlarge": {
+		InstanceType:       "i2.xlarge",
+		VCPU:               4,
+		MemoryMb:           31232,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Moderate",
+		Storage:            "1 x 800 SSD",
+	},
+	"i3.16xlarge": {
+		InstanceType:       "i3.16xlarge",
+		VCPU:               64,
+		MemoryMb:           499712,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             15,
+		IPsPerENI:          50,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "8 x 1900 NVMe SSD",
+	},
+	"i3.2xlarge": {
+		InstanceType:       "i3.2xlarge",
+		VCPU:               8,
+		MemoryMb:           62464,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "1 x 1900 NVMe SSD",
+	},
+	"i3.4xlarge": {
+		InstanceType:       "i3.4xlarge",
+		VCPU:               16,
+		MemoryMb:           124928,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "2 x 1900 NVMe SSD",
+	},
+	"i3.8xlarge": {
+		InstanceType:       "i3.8xlarge",
+		VCPU:               32,
+		MemoryMb:           249856,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "4 x 1900 NVMe SSD",
+	},
+	"i3.large": {
+		InstanceType:       "i3.large",
+		VCPU:               2,
+		MemoryMb:           15616,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          10,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "1 x 475 NVMe SSD",
+	},
+	"i3.xlarge": {
+		InstanceType:       "i3.xlarge",
+		VCPU:               4,
+		MemoryMb:           31232,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "1 x 950 NVMe SSD",
+	},
+	"m1.large": {
+		InstanceType:       "m1.large",
+		VCPU:               2,
+		MemoryMb:           7680,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          10,
+		NetworkPerformance: "Moderate",
+		Storage:            "2 x 420",
+	},
+	"m1.medium": {
+		InstanceType:       "m1.medium",
+		VCPU:               1,
+		MemoryMb:           3840,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             2,
+		IPsPerENI:          6,
+		NetworkPerformance: "Moderate",
+		Storage:            "1 x 410",
+	},
+	"m1.small": {
+		InstanceType:       "m1.small",
+		VCPU:               1,
+		MemoryMb:           1740,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             2,
+		IPsPerENI:          4,
+		NetworkPerformance: "Low",
+		Storage:            "1 x 160",
+	},
+	"m1.xlarge": {
+		InstanceType:       "m1.xlarge",
+		VCPU:               4,
+		MemoryMb:           15360,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "4 x 420",
+	},
+	"m2.2xlarge": {
+		InstanceType:       "m2.2xlarge",
+		VCPU:               4,
+		MemoryMb:           35020,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          30,
+		NetworkPerformance: "Moderate",
+		Storage:            "1 x 850",
+	},
+	"m2.4xlarge": {
+		InstanceType:       "m2.4xlarge",
+		VCPU:               8,
+		MemoryMb:           70041,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "High",
+		Storage:            "2 x 840",
+	},
+	"m2.xlarge": {
+		InstanceType:       "m2.xlarge",
+		VCPU:               2,
+		MemoryMb:           17510,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Moderate",
+		Storage:            "1 x 420",
+	},
+	"m3.2xlarge": {
+		InstanceType:       "m3.2xlarge",
+		VCPU:               8,
+		MemoryMb:           30720,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          30,
+		NetworkPerformance: "High",
+		Storage:            "2 x 80 SSD",
+	},
+	"m3.large": {
+		InstanceType:       "m3.large",
+		VCPU:               2,
+		MemoryMb:           7680,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          10,
+		NetworkPerformance: "Moderate",
+		Storage:            "1 x 32 SSD",
+	},
+	"m3.medium": {
+		InstanceType:       "m3.medium",
+		VCPU:               1,
+		MemoryMb:           3840,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             2,
+		IPsPerENI:          6,
+		NetworkPerformance: "Moderate",
+		Storage:            "1 x 4 SSD",
+	},
+	"m3.xlarge": {
+		InstanceType:       "m3.xlarge",
+		VCPU:               4,
+		MemoryMb:           15360,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "2 x 40 SSD",
+	},
+	"m4.10xlarge": {
+		InstanceType:       "m4.10xlarge",
+		VCPU:               40,
+		MemoryMb:           163840,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"m4.16xlarge": {
+		InstanceType:       "m4.16xlarge",
+		VCPU:               64,
+		MemoryMb:           262144,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "EBS only",
+	},
+	"m4.2xlarge": {
+		InstanceType:       "m4.2xlarge",
+		VCPU:               8,
+		MemoryMb:           32768,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "EBS only",
+	},
+	"m4.4xlarge": {
+		InstanceType:       "m4.4xlarge",
+		VCPU:               16,
+		MemoryMb:           65536,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "High",
+		Storage:            "EBS only",
+	},
+	"m4.large": {
+		InstanceType:       "m4.large",
+		VCPU:               2,
+		MemoryMb:           8192,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             2,
+		IPsPerENI:          10,
+		NetworkPerformance: "Moderate",
+		Storage:            "EBS only",
+	},
+	"m4.xlarge": {
+		InstanceType:       "m4.xlarge",
+		VCPU:               4,
+		MemoryMb:           16384,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "EBS only",
+	},
+	"m5.12xlarge": {
+		InstanceType:       "m5.12xlarge",
+		VCPU:               48,
+		MemoryMb:           196608,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"m5.24xlarge": {
+		InstanceType:       "m5.24xlarge",
+		VCPU:               96,
+		MemoryMb:           393216,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             15,
+		IPsPerENI:          50,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "EBS only",
+	},
+	"m5.2xlarge": {
+		InstanceType:       "m5.2xlarge",
+		VCPU:               8,
+		MemoryMb:           32768,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"m5.4xlarge": {
+		InstanceType:       "m5.4xlarge",
+		VCPU:               16,
+		MemoryMb:           65536,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"m5.large": {
+		InstanceType:       "m5.large",
+		VCPU:               2,
+		MemoryMb:           8192,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          10,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"m5.xlarge": {
+		InstanceType:       "m5.xlarge",
+		VCPU:               4,
+		MemoryMb:           16384,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"p2.16xlarge": {
+		InstanceType:       "p2.16xlarge",
+		VCPU:               64,
+		MemoryMb:           786432,
+		GPU:                16,
+		GPUModel:           "NVIDIA Tesla K80",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "EBS only",
+	},
+	"p2.8xlarge": {
+		InstanceType:       "p2.8xlarge",
+		VCPU:               32,
+		MemoryMb:           499712,
+		GPU:                8,
+		GPUModel:           "NVIDIA Tesla K80",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"p2.xlarge": {
+		InstanceType:       "p2.xlarge",
+		VCPU:               4,
+		MemoryMb:           62464,
+		GPU:                1,
+		GPUModel:           "NVIDIA Tesla K80",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "EBS only",
+	},
+	"p3.16xlarge": {
+		InstanceType:       "p3.16xlarge",
+		VCPU:               64,
+		MemoryMb:           499712,
+		GPU:                8,
+		GPUModel:           "NVIDIA Tesla V100",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "EBS only",
+	},
+	"p3.2xlarge": {
+		InstanceType:       "p3.2xlarge",
+		VCPU:               8,
+		MemoryMb:           62464,
+		GPU:                1,
+		GPUModel:           "NVIDIA Tesla V100",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"p3.8xlarge": {
+		InstanceType:       "p3.8xlarge",
+		VCPU:               32,
+		MemoryMb:           249856,
+		GPU:                4,
+		GPUModel:           "NVIDIA Tesla V100",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"r3.2xlarge": {
+		InstanceType:       "r3.2xlarge",
+		VCPU:               8,
+		MemoryMb:           62464,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "High",
+		Storage:            "1 x 160 SSD",
+	},
+	"r3.4xlarge": {
+		InstanceType:       "r3.4xlarge",
+		VCPU:               16,
+		MemoryMb:           124928,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "High",
+		Storage:            "1 x 320 SSD",
+	},
+	"r3.8xlarge": {
+		InstanceType:       "r3.8xlarge",
+		VCPU:               32,
+		MemoryMb:           249856,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "2 x 320 SSD",
+	},
+	"r3.large": {
+		InstanceType:       "r3.large",
+		VCPU:               2,
+		MemoryMb:           15616,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          10,
+		NetworkPerformance: "Moderate",
+		Storage:            "1 x 32 SSD",
+	},
+	"r3.xlarge": {
+		InstanceType:       "r3.xlarge",
+		VCPU:               4,
+		MemoryMb:           31232,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Moderate",
+		Storage:            "1 x 80 SSD",
+	},
+	"r4.16xlarge": {
+		InstanceType:       "r4.16xlarge",
+		VCPU:               64,
+		MemoryMb:           499712,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             15,
+		IPsPerENI:          50,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "EBS only",
+	},
+	"r4.2xlarge": {
+		InstanceType:       "r4.2xlarge",
+		VCPU:               8,
+		MemoryMb:           62464,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"r4.4xlarge": {
+		InstanceType:       "r4.4xlarge",
+		VCPU:               16,
+		MemoryMb:           124928,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"r4.8xlarge": {
+		InstanceType:       "r4.8xlarge",
+		VCPU:               32,
+		MemoryMb:           249856,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"r4.large": {
+		InstanceType:       "r4.large",
+		VCPU:               2,
+		MemoryMb:           15616,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          10,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"r4.xlarge": {
+		InstanceType:       "r4.xlarge",
+		VCPU:               4,
+		MemoryMb:           31232,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "EBS only",
+	},
+	"t1.micro": {
+		InstanceType:       "t1.micro",
+		VCPU:               1,
+		MemoryMb:           627,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             2,
+		IPsPerENI:          2,
+		NetworkPerformance: "Very Low",
+		Storage:            "EBS only",
+	},
+	"t2.2xlarge": {
+		InstanceType:       "t2.2xlarge",
+		VCPU:               8,
+		MemoryMb:           32768,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          15,
+		NetworkPerformance: "Moderate",
+		Storage:            "EBS only",
+	},
+	"t2.large": {
+		InstanceType:       "t2.large",
+		VCPU:               2,
+		MemoryMb:           8192,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          12,
+		NetworkPerformance: "Low to Moderate",
+		Storage:            "EBS only",
+	},
+	"t2.medium": {
+		InstanceType:       "t2.medium",
+		VCPU:               2,
+		MemoryMb:           4096,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          6,
+		NetworkPerformance: "Low to Moderate",
+		Storage:            "EBS only",
+	},
+	"t2.micro": {
+		InstanceType:       "t2.micro",
+		VCPU:               1,
+		MemoryMb:           1024,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             2,
+		IPsPerENI:          2,
+		NetworkPerformance: "Low to Moderate",
+		Storage:            "EBS only",
+	},
+	"t2.nano": {
+		InstanceType:       "t2.nano",
+		VCPU:               1,
+		MemoryMb:           512,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             2,
+		IPsPerENI:          2,
+		NetworkPerformance: "Low",
+		Storage:            "EBS only",
+	},
+	"t2.small": {
+		InstanceType:       "t2.small",
+		VCPU:               1,
+		MemoryMb:           2048,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          4,
+		NetworkPerformance: "Low to Moderate",
+		Storage:            "EBS only",
+	},
+	"t2.xlarge": {
+		InstanceType:       "t2.xlarge",
+		VCPU:               4,
+		MemoryMb:           16384,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          15,
+		NetworkPerformance: "Moderate",
+		Storage:            "EBS only",
+	},
+	"x1.16xlarge": {
+		InstanceType:       "x1.16xlarge",
+		VCPU:               64,
+		MemoryMb:           999424,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "1 x 1920 SSD",
+	},
+	"x1.32xlarge": {
+		InstanceType:       "x1.32xlarge",
+		VCPU:               128,
+		MemoryMb:           1998848,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "2 x 1920 SSD",
+	},
+	"x1e.16xlarge": {
+		InstanceType:       "x1e.16xlarge",
+		VCPU:               64,
+		MemoryMb:           1998848,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "10 Gigabit",
+		Storage:            "1 x 1920 SSD",
+	},
+	"x1e.2xlarge": {
+		InstanceType:       "x1e.2xlarge",
+		VCPU:               8,
+		MemoryMb:           249856,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "1 x 240 SSD",
+	},
+	"x1e.32xlarge": {
+		InstanceType:       "x1e.32xlarge",
+		VCPU:               128,
+		MemoryMb:           3997696,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             8,
+		IPsPerENI:          30,
+		NetworkPerformance: "25 Gigabit",
+		Storage:            "2 x 1920 SSD",
+	},
+	"x1e.4xlarge": {
+		InstanceType:       "x1e.4xlarge",
+		VCPU:               16,
+		MemoryMb:           499712,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "1 x 480 SSD",
+	},
+	"x1e.8xlarge": {
+		InstanceType:       "x1e.8xlarge",
+		VCPU:               32,
+		MemoryMb:           999424,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             4,
+		IPsPerENI:          15,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "1 x 960 SSD",
+	},
+	"x1e.xlarge": {
+		InstanceType:       "x1e.xlarge",
+		VCPU:               4,
+		MemoryMb:           124928,
+		GPU:                0,
+		GPUModel:           "",
+		Architecture:       "amd64",
+		MaxENI:             3,
+		IPsPerENI:          10,
+		NetworkPerformance: "Up to 10 Gigabit",
+		Storage:            "1 x 120 SSD",
+	},
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ec2instances is the catalog of ec2 instance types, generated from the AWS price list
+// by ../ec2_instance_types/gen.go.
+package ec2instances
+
+// InstanceType describes the resources of an ec2 instance type.
+type InstanceType struct {
+	InstanceType string
+	VCPU         int64
+	MemoryMb     int64
+	GPU          int64
+	// GPUModel is the model of the GPUs of the instance type, empty if unknown or without GPUs.
+	GPUModel string
+	// Architecture is the value of the kubernetes.io/arch label of the instance type.
+	Architecture string
+	// MaxENI is the number of network interfaces the instance type can attach, 0 if unknown.
+	MaxENI int64
+	// IPsPerENI is the number of IPv4 addresses per network interface, 0 if unknown.
+	IPsPerENI int64
+	// NetworkPerformance is the network performance of the instance type as advertised by AWS,
+	// e.g. "Up to 10 Gigabit".
+	NetworkPerformance string
+	// Storage is the instance storage of the instance type, e.g. "2 x 800 SSD" or "EBS only".
+	Storage string
+}
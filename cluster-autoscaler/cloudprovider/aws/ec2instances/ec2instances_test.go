@@ -0,0 +1,100 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ec2instances
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// validateInstanceTypes checks that every entry of the catalog describes a single instance
+// type, of a family and size, with resources.
+func validateInstanceTypes(instanceTypes map[string]*InstanceType) error {
+	seen := make(map[string]string)
+	for name, t := range instanceTypes {
+		if t.InstanceType != name {
+			return fmt.Errorf("%s: entry of %s", name, t.InstanceType)
+		}
+		if previous, found := seen[strings.ToLower(name)]; found {
+			return fmt.Errorf("%s: duplicate of %s", name, previous)
+		}
+		seen[strings.ToLower(name)] = name
+		if len(strings.Split(name, ".")) != 2 {
+			return fmt.Errorf("%s: not an instance type of a family and size", name)
+		}
+		if t.VCPU <= 0 || t.MemoryMb <= 0 {
+			return fmt.Errorf("%s: %d vcpus and %d MB of memory", name, t.VCPU, t.MemoryMb)
+		}
+		if t.GPU < 0 || (t.GPU == 0 && t.GPUModel != "") {
+			return fmt.Errorf("%s: %d GPUs of model %q", name, t.GPU, t.GPUModel)
+		}
+		if t.Architecture != "amd64" && t.Architecture != "arm64" {
+			return fmt.Errorf("%s: unknown architecture %q", name, t.Architecture)
+		}
+		if (t.MaxENI > 0) != (t.IPsPerENI > 0) {
+			return fmt.Errorf("%s: %d ENIs of %d IPs", name, t.MaxENI, t.IPsPerENI)
+		}
+	}
+	return nil
+}
+
+func TestInstanceTypes(t *testing.T) {
+	assert.NoError(t, validateInstanceTypes(InstanceTypes))
+
+	m4 := InstanceTypes["m4.large"]
+	assert.Equal(t, int64(2), m4.VCPU)
+	assert.Equal(t, int64(8192), m4.MemoryMb)
+	assert.Equal(t, int64(0), m4.GPU)
+	assert.Equal(t, "amd64", m4.Architecture)
+	assert.Equal(t, "EBS only", m4.Storage)
+
+	p2 := InstanceTypes["p2.xlarge"]
+	assert.Equal(t, int64(1), p2.GPU)
+	assert.Equal(t, "NVIDIA Tesla K80", p2.GPUModel)
+}
+
+func TestValidateInstanceTypes(t *testing.T) {
+	valid := func() *InstanceType {
+		return &InstanceType{InstanceType: "c4.large", VCPU: 2, MemoryMb: 3840, Architecture: "amd64", MaxENI: 3, IPsPerENI: 10}
+	}
+	assert.NoError(t, validateInstanceTypes(map[string]*InstanceType{"c4.large": valid()}))
+
+	zeroMemory := valid()
+	zeroMemory.MemoryMb = 0
+	assert.Error(t, validateInstanceTypes(map[string]*InstanceType{"c4.large": zeroMemory}))
+
+	// Dedicated hosts of a whole family once made it into the catalog.
+	family := valid()
+	family.InstanceType = "c4"
+	assert.Error(t, validateInstanceTypes(map[string]*InstanceType{"c4": family}))
+
+	assert.Error(t, validateInstanceTypes(map[string]*InstanceType{"c4.large": valid(), "c4.xlarge": valid()}))
+	upper := valid()
+	upper.InstanceType = "C4.large"
+	assert.Error(t, validateInstanceTypes(map[string]*InstanceType{"c4.large": valid(), "C4.large": upper}))
+
+	gpuModel := valid()
+	gpuModel.GPUModel = "NVIDIA Tesla K80"
+	assert.Error(t, validateInstanceTypes(map[string]*InstanceType{"c4.large": gpuModel}))
+
+	eni := valid()
+	eni.IPsPerENI = 0
+	assert.Error(t, validateInstanceTypes(map[string]*InstanceType{"c4.large": eni}))
+}
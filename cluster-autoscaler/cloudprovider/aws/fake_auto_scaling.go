@@ -0,0 +1,534 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws/ec2instances"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
+	kube_client "k8s.io/client-go/kubernetes"
+)
+
+// FakeAutoScaling is an in-memory auto-scaling service behaving like real ASGs: instances are
+// launched some time after the desired capacity grows, and terminating an instance shrinks its
+// group. It keeps its own clock, moved with Advance, so tests control when instances come up.
+// Given a kube client, it registers a Node for every running instance and removes it on
+// termination, so that the autoscaler can be run against it end to end.
+type FakeAutoScaling struct {
+	mutex sync.Mutex
+	now   time.Time
+	// launchLatency is the time between a desired capacity increase and the instances running.
+	launchLatency time.Duration
+	groups        map[string]*fakeGroup
+	nextInstance  int
+	failures      map[string][]error
+	kubeClient    kube_client.Interface
+}
+
+type fakeGroup struct {
+	name         string
+	instanceType string
+	zone         string
+	minSize      int64
+	maxSize      int64
+	desired      int64
+	tags         map[string]string
+	instances    []*fakeInstance
+	// launches are the times pending instances will be running at.
+	launches []time.Time
+	// capacity is the number of instances AWS can run in the group, -1 if unlimited.
+	capacity int
+	// mixedInstancesPolicy is that of groups created with one. Other groups use a launch template
+	// named after them.
+	mixedInstancesPolicy *autoscaling.MixedInstancesPolicy
+}
+
+type fakeInstance struct {
+	id       string
+	nodeName string
+}
+
+// NewFakeAutoScaling builds an empty FakeAutoScaling whose instances run launchLatency after
+// they are asked for.
+func NewFakeAutoScaling(launchLatency time.Duration) *FakeAutoScaling {
+	return &FakeAutoScaling{
+		now:           time.Now(),
+		launchLatency: launchLatency,
+		groups:        make(map[string]*fakeGroup),
+		failures:      make(map[string][]error),
+	}
+}
+
+// AddGroup adds an ASG of the instance type in the zone, with desired instances already running.
+func (f *FakeAutoScaling) AddGroup(name string, instanceType string, zone string, minSize, maxSize, desired int64, tags map[string]string) error {
+	if _, found := ec2instances.InstanceTypes[instanceType]; !found {
+		return fmt.Errorf("unknown instance type %s", instanceType)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	g := &fakeGroup{
+		name:         name,
+		instanceType: instanceType,
+		zone:         zone,
+		minSize:      minSize,
+		maxSize:      maxSize,
+		tags:         tags,
+		capacity:     -1,
+	}
+	f.groups[name] = g
+	return f.setDesiredLocked(g, desired, 0)
+}
+
+// RegisterNodes makes the fake register a Node in the client for every running instance, and
+// delete it when the instance is terminated. Instances already running are registered too.
+func (f *FakeAutoScaling) RegisterNodes(kubeClient kube_client.Interface) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.kubeClient = kubeClient
+	for _, g := range f.groups {
+		for _, instance := range g.instances {
+			if err := f.registerNodeLocked(g, instance); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetCapacity limits the number of instances that can run in the group, as when AWS is out of
+// capacity for the instance type. Instances beyond it stay pending. -1 removes the limit.
+func (f *FakeAutoScaling) SetCapacity(group string, capacity int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if g, found := f.groups[group]; found {
+		g.capacity = capacity
+	}
+}
+
+// FailNext makes the next call of the operation, e.g. "SetDesiredCapacity", fail with err.
+func (f *FakeAutoScaling) FailNext(operation string, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.failures[operation] = append(f.failures[operation], err)
+}
+
+// Now returns the time of the fake.
+func (f *FakeAutoScaling) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+// Advance moves the time of the fake forward, running the instances launched in the meantime.
+func (f *FakeAutoScaling) Advance(d time.Duration) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = f.now.Add(d)
+	for _, name := range f.groupNamesLocked() {
+		if err := f.launchDueLocked(f.groups[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InstanceIds returns the ids of the instances running in the group.
+func (f *FakeAutoScaling) InstanceIds(group string) []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	ids := []string{}
+	if g, found := f.groups[group]; found {
+		for _, instance := range g.instances {
+			ids = append(ids, instance.id)
+		}
+	}
+	return ids
+}
+
+// DesiredCapacity returns the desired capacity of the group.
+func (f *FakeAutoScaling) DesiredCapacity(group string) int64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if g, found := f.groups[group]; found {
+		return g.desired
+	}
+	return 0
+}
+
+func (f *FakeAutoScaling) failureLocked(operation string) error {
+	if errs := f.failures[operation]; len(errs) > 0 {
+		f.failures[operation] = errs[1:]
+		return errs[0]
+	}
+	return nil
+}
+
+func (f *FakeAutoScaling) groupNamesLocked() []string {
+	names := make([]string, 0, len(f.groups))
+	for name := range f.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setDesiredLocked launches instances after latency, or drops pending launches then the newest
+// instances, to reach the desired capacity.
+func (f *FakeAutoScaling) setDesiredLocked(g *fakeGroup, desired int64, latency time.Duration) error {
+	g.desired = desired
+	for int64(len(g.instances)+len(g.launches)) < desired {
+		g.launches = append(g.launches, f.now.Add(latency))
+	}
+	for int64(len(g.instances)+len(g.launches)) > desired && len(g.launches) > 0 {
+		g.launches = g.launches[:len(g.launches)-1]
+	}
+	for int64(len(g.instances)) > desired {
+		if err := f.terminateLocked(g, len(g.instances)-1); err != nil {
+			return err
+		}
+	}
+	return f.launchDueLocked(g)
+}
+
+// launchDueLocked runs the pending instances of the group due by now, within its capacity.
+func (f *FakeAutoScaling) launchDueLocked(g *fakeGroup) error {
+	pending := []time.Time{}
+	for _, at := range g.launches {
+		if at.After(f.now) || (g.capacity >= 0 && len(g.instances) >= g.capacity) {
+			pending = append(pending, at)
+			continue
+		}
+		if err := f.runInstanceLocked(g); err != nil {
+			return err
+		}
+	}
+	g.launches = pending
+	return nil
+}
+
+func (f *FakeAutoScaling) runInstanceLocked(g *fakeGroup) error {
+	f.nextInstance++
+	instance := &fakeInstance{
+		id:       fmt.Sprintf("i-%017x", f.nextInstance),
+		nodeName: fmt.Sprintf("ip-10-0-%d-%d.ec2.internal", f.nextInstance/256, f.nextInstance%256),
+	}
+	g.instances = append(g.instances, instance)
+	glog.V(4).Infof("Fake instance %s running in %s", instance.id, g.name)
+	if f.kubeClient != nil {
+		return f.registerNodeLocked(g, instance)
+	}
+	return nil
+}
+
+func (f *FakeAutoScaling) terminateLocked(g *fakeGroup, i int) error {
+	instance := g.instances[i]
+	g.instances = append(g.instances[:i], g.instances[i+1:]...)
+	glog.V(4).Infof("Fake instance %s terminated in %s", instance.id, g.name)
+	if f.kubeClient != nil {
+		return f.kubeClient.CoreV1().Nodes().Delete(instance.nodeName, &metav1.DeleteOptions{})
+	}
+	return nil
+}
+
+func (f *FakeAutoScaling) registerNodeLocked(g *fakeGroup, instance *fakeInstance) error {
+	instanceType := ec2instances.InstanceTypes[g.instanceType]
+	now := metav1.Time{Time: f.now}
+	node := &apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              instance.nodeName,
+			SelfLink:          fmt.Sprintf("/api/v1/nodes/%s", instance.nodeName),
+			CreationTimestamp: now,
+			Labels: buildGenericLabels(&asgTemplate{
+				InstanceType: instanceType,
+				Region:       g.zone[:len(g.zone)-1],
+				Zone:         g.zone,
+			}, instance.nodeName),
+		},
+		Spec: apiv1.NodeSpec{
+			ProviderID: fmt.Sprintf("aws:///%s/%s", g.zone, instance.id),
+		},
+		Status: apiv1.NodeStatus{
+			Capacity: apiv1.ResourceList{
+				apiv1.ResourcePods:    *resource.NewQuantity(maxPodsFromENILimits(instanceType), resource.DecimalSI),
+				apiv1.ResourceCPU:     *resource.NewQuantity(instanceType.VCPU, resource.DecimalSI),
+				gpu.ResourceNvidiaGPU: *resource.NewQuantity(instanceType.GPU, resource.DecimalSI),
+				apiv1.ResourceMemory:  *resource.NewQuantity(instanceType.MemoryMb*1024*1024, resource.DecimalSI),
+			},
+			Conditions: cloudprovider.BuildReadyConditions(),
+		},
+	}
+	node.Status.Allocatable = node.Status.Capacity
+	for i := range node.Status.Conditions {
+		node.Status.Conditions[i].LastTransitionTime = now
+	}
+	_, err := f.kubeClient.CoreV1().Nodes().Create(node)
+	return err
+}
+
+func (f *FakeAutoScaling) describeGroupLocked(g *fakeGroup) *autoscaling.Group {
+	group := &autoscaling.Group{
+		AutoScalingGroupName: aws.String(g.name),
+		AvailabilityZones:    []*string{aws.String(g.zone)},
+		MinSize:              aws.Int64(g.minSize),
+		MaxSize:              aws.Int64(g.maxSize),
+		DesiredCapacity:      aws.Int64(g.desired),
+	}
+	if g.mixedInstancesPolicy != nil {
+		group.MixedInstancesPolicy = g.mixedInstancesPolicy
+	} else {
+		group.LaunchTemplate = &autoscaling.LaunchTemplateSpecification{LaunchTemplateName: aws.String(g.name)}
+	}
+	for _, instance := range g.instances {
+		group.Instances = append(group.Instances, &autoscaling.Instance{
+			InstanceId:       aws.String(instance.id),
+			AvailabilityZone: aws.String(g.zone),
+			LifecycleState:   aws.String(autoscaling.LifecycleStateInService),
+		})
+	}
+	for key, value := range g.tags {
+		group.Tags = append(group.Tags, &autoscaling.TagDescription{
+			ResourceId:   aws.String(g.name),
+			ResourceType: aws.String("auto-scaling-group"),
+			Key:          aws.String(key),
+			Value:        aws.String(value),
+		})
+	}
+	return group
+}
+
+func (f *FakeAutoScaling) describeGroupsLocked(names []*string) []*autoscaling.Group {
+	groups := []*autoscaling.Group{}
+	if len(names) == 0 {
+		names = aws.StringSlice(f.groupNamesLocked())
+	}
+	for _, name := range names {
+		if g, found := f.groups[aws.StringValue(name)]; found {
+			groups = append(groups, f.describeGroupLocked(g))
+		}
+	}
+	return groups
+}
+
+// DescribeAutoScalingGroups implements autoScaling.
+func (f *FakeAutoScaling) DescribeAutoScalingGroups(input *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.failureLocked("DescribeAutoScalingGroups"); err != nil {
+		return nil, err
+	}
+	return &autoscaling.DescribeAutoScalingGroupsOutput{
+		AutoScalingGroups: f.describeGroupsLocked(input.AutoScalingGroupNames),
+	}, nil
+}
+
+// DescribeAutoScalingGroupsPages implements autoScaling. All groups are returned in one page.
+func (f *FakeAutoScaling) DescribeAutoScalingGroupsPages(input *autoscaling.DescribeAutoScalingGroupsInput, fn func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	output, err := f.DescribeAutoScalingGroups(input)
+	if err != nil {
+		return err
+	}
+	fn(output, true)
+	return nil
+}
+
+// DescribeLaunchConfigurations implements autoScaling. The fake groups use launch templates.
+func (f *FakeAutoScaling) DescribeLaunchConfigurations(input *autoscaling.DescribeLaunchConfigurationsInput) (*autoscaling.DescribeLaunchConfigurationsOutput, error) {
+	return &autoscaling.DescribeLaunchConfigurationsOutput{}, nil
+}
+
+// DescribeTagsPages implements autoScaling. Like AWS, it returns the tags matching any of the key filters.
+func (f *FakeAutoScaling) DescribeTagsPages(input *autoscaling.DescribeTagsInput, fn func(*autoscaling.DescribeTagsOutput, bool) bool) error {
+	f.mutex.Lock()
+	if err := f.failureLocked("DescribeTags"); err != nil {
+		f.mutex.Unlock()
+		return err
+	}
+	keys := make(map[string]bool)
+	for _, filter := range input.Filters {
+		if aws.StringValue(filter.Name) == "key" {
+			for _, value := range filter.Values {
+				keys[aws.StringValue(value)] = true
+			}
+		}
+	}
+	output := &autoscaling.DescribeTagsOutput{}
+	for _, group := range f.describeGroupsLocked(nil) {
+		for _, tag := range group.Tags {
+			if keys[aws.StringValue(tag.Key)] {
+				output.Tags = append(output.Tags, tag)
+			}
+		}
+	}
+	f.mutex.Unlock()
+	fn(output, true)
+	return nil
+}
+
+// SetDesiredCapacity implements autoScaling.
+func (f *FakeAutoScaling) SetDesiredCapacity(input *autoscaling.SetDesiredCapacityInput) (*autoscaling.SetDesiredCapacityOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.failureLocked("SetDesiredCapacity"); err != nil {
+		return nil, err
+	}
+	g, found := f.groups[aws.StringValue(input.AutoScalingGroupName)]
+	if !found {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", aws.StringValue(input.AutoScalingGroupName)), nil)
+	}
+	desired := aws.Int64Value(input.DesiredCapacity)
+	if desired < g.minSize || desired > g.maxSize {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("New SetDesiredCapacity value %d is outside of the limits %d-%d", desired, g.minSize, g.maxSize), nil)
+	}
+	if err := f.setDesiredLocked(g, desired, f.launchLatency); err != nil {
+		return nil, err
+	}
+	return &autoscaling.SetDesiredCapacityOutput{}, nil
+}
+
+// TerminateInstanceInAutoScalingGroup implements autoScaling.
+func (f *FakeAutoScaling) TerminateInstanceInAutoScalingGroup(input *autoscaling.TerminateInstanceInAutoScalingGroupInput) (*autoscaling.TerminateInstanceInAutoScalingGroupOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.failureLocked("TerminateInstanceInAutoScalingGroup"); err != nil {
+		return nil, err
+	}
+	id := aws.StringValue(input.InstanceId)
+	for _, name := range f.groupNamesLocked() {
+		g := f.groups[name]
+		for i, instance := range g.instances {
+			if instance.id != id {
+				continue
+			}
+			if aws.BoolValue(input.ShouldDecrementDesiredCapacity) {
+				if g.desired <= g.minSize {
+					return nil, awserr.New("ValidationError", fmt.Sprintf("Currently, desired capacity of %s is equal to its min size", g.name), nil)
+				}
+				g.desired--
+			} else {
+				g.launches = append(g.launches, f.now.Add(f.launchLatency))
+			}
+			if err := f.terminateLocked(g, i); err != nil {
+				return nil, err
+			}
+			return &autoscaling.TerminateInstanceInAutoScalingGroupOutput{
+				Activity: &autoscaling.Activity{
+					Description: aws.String(fmt.Sprintf("Terminating EC2 instance: %s", id)),
+				},
+			}, nil
+		}
+	}
+	return nil, awserr.New("ValidationError", fmt.Sprintf("Instance Id not found - %s", id), nil)
+}
+
+// DescribeLaunchTemplateVersions implements autoScaling. Every group created by AddGroup has a
+// launch template named after it, with the instance type of the group.
+func (f *FakeAutoScaling) DescribeLaunchTemplateVersions(input *ec2.DescribeLaunchTemplateVersionsInput) (*ec2.DescribeLaunchTemplateVersionsOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.failureLocked("DescribeLaunchTemplateVersions"); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeLaunchTemplateVersionsOutput{}
+	if g, found := f.groups[aws.StringValue(input.LaunchTemplateName)]; found && g.mixedInstancesPolicy == nil {
+		output.LaunchTemplateVersions = append(output.LaunchTemplateVersions, &ec2.LaunchTemplateVersion{
+			LaunchTemplateName: aws.String(g.name),
+			LaunchTemplateData: &ec2.ResponseLaunchTemplateData{InstanceType: aws.String(g.instanceType)},
+		})
+	}
+	return output, nil
+}
+
+// CreateAutoScalingGroup implements autoScaling. Groups must have a mixed instances policy
+// overriding the instance type of its launch template, like autoprovisioned groups.
+func (f *FakeAutoScaling) CreateAutoScalingGroup(input *autoscaling.CreateAutoScalingGroupInput) (*autoscaling.CreateAutoScalingGroupOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.failureLocked("CreateAutoScalingGroup"); err != nil {
+		return nil, err
+	}
+	name := aws.StringValue(input.AutoScalingGroupName)
+	if _, found := f.groups[name]; found {
+		return nil, awserr.New("AlreadyExists", fmt.Sprintf("AutoScalingGroup by this name already exists - %s", name), nil)
+	}
+	policy := input.MixedInstancesPolicy
+	if policy == nil || policy.LaunchTemplate == nil || len(policy.LaunchTemplate.Overrides) == 0 {
+		return nil, awserr.New("ValidationError", "The fake only creates groups overriding the instance type of a launch template", nil)
+	}
+	if len(input.AvailabilityZones) == 0 {
+		return nil, awserr.New("ValidationError", "At least one Availability Zone or VPC Subnet is required", nil)
+	}
+	g := &fakeGroup{
+		name:                 name,
+		instanceType:         aws.StringValue(policy.LaunchTemplate.Overrides[0].InstanceType),
+		zone:                 aws.StringValue(input.AvailabilityZones[0]),
+		minSize:              aws.Int64Value(input.MinSize),
+		maxSize:              aws.Int64Value(input.MaxSize),
+		tags:                 make(map[string]string),
+		capacity:             -1,
+		mixedInstancesPolicy: policy,
+	}
+	for _, tag := range input.Tags {
+		g.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	f.groups[name] = g
+	if err := f.setDesiredLocked(g, aws.Int64Value(input.DesiredCapacity), f.launchLatency); err != nil {
+		return nil, err
+	}
+	return &autoscaling.CreateAutoScalingGroupOutput{}, nil
+}
+
+// DeleteAutoScalingGroup implements autoScaling. Like AWS without ForceDelete, groups with
+// instances can't be deleted.
+func (f *FakeAutoScaling) DeleteAutoScalingGroup(input *autoscaling.DeleteAutoScalingGroupInput) (*autoscaling.DeleteAutoScalingGroupOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.failureLocked("DeleteAutoScalingGroup"); err != nil {
+		return nil, err
+	}
+	name := aws.StringValue(input.AutoScalingGroupName)
+	g, found := f.groups[name]
+	if !found {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", name), nil)
+	}
+	if len(g.instances) > 0 || len(g.launches) > 0 {
+		return nil, awserr.New("ResourceInUse", "You cannot delete an AutoScalingGroup while there are instances or pending Spot instance request(s) still in the group.", nil)
+	}
+	delete(f.groups, name)
+	return &autoscaling.DeleteAutoScalingGroupOutput{}, nil
+}
+
+// NewFakeAwsCloudProvider builds an aws cloud provider on top of the fake.
+func NewFakeAwsCloudProvider(fake *FakeAutoScaling, discoveryOpts cloudprovider.NodeGroupDiscoveryOptions, resourceLimiter *cloudprovider.ResourceLimiter) (cloudprovider.CloudProvider, error) {
+	manager, err := createAWSManagerInternal(nil, discoveryOpts, &autoScalingWrapper{fake})
+	if err != nil {
+		return nil, err
+	}
+	return BuildAwsCloudProvider(manager, resourceLimiter)
+}
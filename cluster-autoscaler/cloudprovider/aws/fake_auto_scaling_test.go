@@ -0,0 +1,79 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+)
+
+func TestFakeAutoScaling(t *testing.T) {
+	f := NewFakeAutoScaling(time.Minute)
+	assert.NoError(t, f.AddGroup("ng1", "m4.large", "us-east-1a", 1, 3, 1, nil))
+	kubeClient := fake.NewSimpleClientset()
+	assert.NoError(t, f.RegisterNodes(kubeClient))
+
+	nodes, err := kubeClient.CoreV1().Nodes().List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(nodes.Items))
+	node := nodes.Items[0]
+	assert.Equal(t, "aws:///us-east-1a/"+f.InstanceIds("ng1")[0], node.Spec.ProviderID)
+	assert.Equal(t, "m4.large", node.Labels[kubeletapis.LabelInstanceType])
+	assert.Equal(t, int64(20), node.Status.Allocatable.Pods().Value())
+
+	// Instances come up after the launch latency.
+	_, err = f.SetDesiredCapacity(&autoscaling.SetDesiredCapacityInput{AutoScalingGroupName: aws.String("ng1"), DesiredCapacity: aws.Int64(3)})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(f.InstanceIds("ng1")))
+	f.SetCapacity("ng1", 2)
+	assert.NoError(t, f.Advance(time.Minute))
+	assert.Equal(t, 2, len(f.InstanceIds("ng1")))
+	nodes, err = kubeClient.CoreV1().Nodes().List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(nodes.Items))
+	groups, err := f.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{AutoScalingGroupNames: aws.StringSlice([]string{"ng1"})})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), aws.Int64Value(groups.AutoScalingGroups[0].DesiredCapacity))
+	assert.Equal(t, 2, len(groups.AutoScalingGroups[0].Instances))
+
+	// Terminations shrink the group and remove the node.
+	_, err = f.TerminateInstanceInAutoScalingGroup(&autoscaling.TerminateInstanceInAutoScalingGroupInput{
+		InstanceId:                     aws.String(f.InstanceIds("ng1")[1]),
+		ShouldDecrementDesiredCapacity: aws.Bool(true),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), f.DesiredCapacity("ng1"))
+	nodes, err = kubeClient.CoreV1().Nodes().List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(nodes.Items))
+
+	// Limits and injected failures are reported like AWS does.
+	_, err = f.SetDesiredCapacity(&autoscaling.SetDesiredCapacityInput{AutoScalingGroupName: aws.String("ng1"), DesiredCapacity: aws.Int64(4)})
+	assert.Error(t, err)
+	throttled := awserr.New("Throttling", "Rate exceeded", nil)
+	f.FailNext("SetDesiredCapacity", throttled)
+	_, err = f.SetDesiredCapacity(&autoscaling.SetDesiredCapacityInput{AutoScalingGroupName: aws.String("ng1"), DesiredCapacity: aws.Int64(1)})
+	assert.Equal(t, throttled, err)
+}
@@ -17,19 +17,18 @@ limitations under the License.
 package builder
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 	"os"
 
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
-	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
-<<<<<<< HEAD
-	//"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/gce"
-=======
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/autoscalr"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/azure"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/gce"
->>>>>>> cluster-autoscaler-release-1.2
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/kubemark"
+	kube_util "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
 	"k8s.io/client-go/informers"
 	kubeclient "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -37,12 +36,12 @@ import (
 	kubemarkcontroller "k8s.io/kubernetes/pkg/kubemark"
 
 	"github.com/golang/glog"
-	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/autoscalr"
 )
 
 // AvailableCloudProviders supported by the cloud provider builder.
 var AvailableCloudProviders = []string{
 	aws.ProviderName,
+	autoscalr.ProviderName,
 	azure.ProviderName,
 	gce.ProviderNameGCE,
 	gce.ProviderNameGKE,
@@ -60,87 +59,25 @@ type CloudProviderBuilder struct {
 	clusterName             string
 	autoprovisioningEnabled bool
 	regional                bool
+	// listerRegistry holds the listers shared with the autoscaler, for providers watching the cluster.
+	listerRegistry kube_util.ListerRegistry
 }
 
 // NewCloudProviderBuilder builds a new builder from static settings
-func NewCloudProviderBuilder(cloudProviderFlag, cloudConfig, clusterName string, autoprovisioningEnabled, regional bool) CloudProviderBuilder {
+func NewCloudProviderBuilder(cloudProviderFlag, cloudConfig, clusterName string, autoprovisioningEnabled, regional bool,
+	listerRegistry kube_util.ListerRegistry) CloudProviderBuilder {
 	return CloudProviderBuilder{
 		cloudProviderFlag:       cloudProviderFlag,
 		cloudConfig:             cloudConfig,
 		clusterName:             clusterName,
 		autoprovisioningEnabled: autoprovisioningEnabled,
 		regional:                regional,
+		listerRegistry:          listerRegistry,
 	}
 }
 
 // Build a cloud provider from static settings contained in the builder and dynamic settings passed via args
 func (b CloudProviderBuilder) Build(discoveryOpts cloudprovider.NodeGroupDiscoveryOptions, resourceLimiter *cloudprovider.ResourceLimiter) cloudprovider.CloudProvider {
-<<<<<<< HEAD
-	var err error
-	var cloudProvider cloudprovider.CloudProvider
-
-	nodeGroupsFlag := discoveryOpts.NodeGroupSpecs
-
-	//if b.cloudProviderFlag == "gce" || b.cloudProviderFlag == "gke" {
-	//	// GCE Manager
-	//	var gceManager gce.GceManager
-	//	var gceError error
-	//	mode := gce.ModeGCE
-	//	if b.cloudProviderFlag == "gke" {
-	//		if b.autoprovisioningEnabled {
-	//			mode = gce.ModeGKENAP
-	//		} else {
-	//			mode = gce.ModeGKE
-	//		}
-	//	}
-	//
-	//	if b.cloudConfig != "" {
-	//		config, fileErr := os.Open(b.cloudConfig)
-	//		if fileErr != nil {
-	//			glog.Fatalf("Couldn't open cloud provider configuration %s: %#v", b.cloudConfig, err)
-	//		}
-	//		defer config.Close()
-	//		gceManager, gceError = gce.CreateGceManager(config, mode, b.clusterName)
-	//	} else {
-	//		gceManager, gceError = gce.CreateGceManager(nil, mode, b.clusterName)
-	//	}
-	//	if gceError != nil {
-	//		glog.Fatalf("Failed to create GCE Manager: %v", gceError)
-	//	}
-	//	cloudProvider, err = gce.BuildGceCloudProvider(gceManager, nodeGroupsFlag, resourceLimiter)
-	//	if err != nil {
-	//		glog.Fatalf("Failed to create GCE cloud provider: %v", err)
-	//	}
-	//}
-
-	if b.cloudProviderFlag == "autoscalr" {
-		glog.V(0).Infof("provider autoscalr in pass through to aws mode")
-		var asrManager *autoscalr.AutoScalrManager
-		var awsManager *aws.AwsManager
-		var asrError error
-		var awsError error
-		if b.cloudConfig != "" {
-			config, fileErr := os.Open(b.cloudConfig)
-			if fileErr != nil {
-				glog.Fatalf("Couldn't open cloud provider configuration %s: %#v", b.cloudConfig, err)
-			}
-			defer config.Close()
-			asrManager, asrError = autoscalr.CreateAutoScalrManager(config)
-			awsManager, awsError = aws.CreateAwsManager(config)
-		} else {
-			asrManager, asrError = autoscalr.CreateAutoScalrManager(nil)
-			awsManager, awsError = aws.CreateAwsManager(nil)
-		}
-		if asrError != nil {
-			glog.Fatalf("Failed to create AutoScalr Manager: %v", err)
-		}
-		if awsError != nil {
-			glog.Fatalf("Failed to create AWS Manager: %v", err)
-		}
-		cloudProvider, err = autoscalr.BuildAutoScalrCloudProvider(asrManager, discoveryOpts, resourceLimiter, awsManager)
-		if err != nil {
-			glog.Fatalf("Failed to create AutoScalr cloud provider: %v", err)
-=======
 	glog.V(1).Infof("Building %s cloud provider.", b.cloudProviderFlag)
 	switch b.cloudProviderFlag {
 	case gce.ProviderNameGCE:
@@ -179,7 +116,6 @@ func (b CloudProviderBuilder) buildGCE(do cloudprovider.NodeGroupDiscoveryOption
 		config, err = os.Open(b.cloudConfig)
 		if err != nil {
 			glog.Fatalf("Couldn't open cloud provider configuration %s: %#v", b.cloudConfig, err)
->>>>>>> cluster-autoscaler-release-1.2
 		}
 		defer config.Close()
 	}
@@ -220,26 +156,32 @@ func (b CloudProviderBuilder) buildAWS(do cloudprovider.NodeGroupDiscoveryOption
 }
 
 func (b CloudProviderBuilder) buildAutoScalr(do cloudprovider.NodeGroupDiscoveryOptions, rl *cloudprovider.ResourceLimiter) cloudprovider.CloudProvider {
-	var config io.ReadCloser
+	// The cloud-config is read by both the AutoScalr and the aws manager.
+	var configBytes []byte
 	if b.cloudConfig != "" {
 		var err error
-		config, err = os.Open(b.cloudConfig)
+		configBytes, err = ioutil.ReadFile(b.cloudConfig)
 		if err != nil {
 			glog.Fatalf("Couldn't open cloud provider configuration %s: %#v", b.cloudConfig, err)
 		}
-		defer config.Close()
+	}
+	newConfigReader := func() io.Reader {
+		if configBytes == nil {
+			return nil
+		}
+		return bytes.NewReader(configBytes)
 	}
 
-	asrManager, asrError := autoscalr.CreateAutoScalrManager(config, do)
+	asrManager, asrError := autoscalr.CreateAutoScalrManager(newConfigReader(), do)
 	if asrError != nil {
 		glog.Fatalf("Failed to create AutoScalr Manager: %v", asrError)
 	}
-	awsManager, awsError := aws.CreateAwsManager(config, do)
+	awsManager, awsError := aws.CreateAwsManager(newConfigReader(), do)
 	if awsError != nil {
 		glog.Fatalf("Failed to create AWS Manager: %v", awsError)
 	}
 
-	provider, err := autoscalr.BuildAutoScalrCloudProvider(asrManager, rl, awsManager)
+	provider, err := autoscalr.BuildAutoScalrCloudProvider(asrManager, rl, awsManager, b.listerRegistry)
 	if err != nil {
 		glog.Fatalf("Failed to create AutoScalr cloud provider: %v", err)
 	}
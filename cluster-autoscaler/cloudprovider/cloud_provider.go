@@ -142,6 +142,18 @@ type NodeGroup interface {
 	Autoprovisioned() bool
 }
 
+// ZonalNodeGroup is implemented by node groups spanning several zones, whose new nodes are
+// placed in a zone chosen by the cloud provider. Implementation optional.
+type ZonalNodeGroup interface {
+	NodeGroup
+
+	// Zones returns all zones of the node group.
+	Zones() ([]string, error)
+
+	// ScaleUpZones returns the zones the next node added to the node group may be placed in.
+	ScaleUpZones() ([]string, error)
+}
+
 // PricingModel contains information about the node price and how it changes in time.
 type PricingModel interface {
 	// NodePrice returns a price of running the given node for a given period of time.
@@ -172,8 +172,34 @@ type ASGAutoDiscoveryConfig struct {
 	// TagKeys to match on.
 	// Any ASG with all of the provided tag keys will be autoscaled.
 	TagKeys []string
+	// TagValues the tags of TagKeys must have, by key. Keys without a value match any value.
+	TagValues map[string]string
+	// ExcludedTags of ASGs that are not autoscaled even if they match TagKeys, by key.
+	// An empty value excludes any value of the key.
+	ExcludedTags map[string]string
 }
 
+// Matches returns true if ASG tags given by key match the config.
+func (c ASGAutoDiscoveryConfig) Matches(tags map[string]string) bool {
+	for _, key := range c.TagKeys {
+		value, found := tags[key]
+		if !found {
+			return false
+		}
+		if want, found := c.TagValues[key]; found && want != value {
+			return false
+		}
+	}
+	for key, excluded := range c.ExcludedTags {
+		if value, found := tags[key]; found && (excluded == "" || excluded == value) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseASGAutoDiscoverySpec parses specs of the form asg:tag=<tag>,<tag> where every tag is
+// one of key, key=value (the ASG must have the tag), !key or !key=value (the ASG must not).
 func parseASGAutoDiscoverySpec(spec string) (ASGAutoDiscoveryConfig, error) {
 	cfg := ASGAutoDiscoveryConfig{}
 
@@ -186,7 +212,7 @@ func parseASGAutoDiscoverySpec(spec string) (ASGAutoDiscoveryConfig, error) {
 		return cfg, fmt.Errorf("Unsupported discoverer specified: %s", discoverer)
 	}
 	param := tokens[1]
-	kv := strings.Split(param, "=")
+	kv := strings.SplitN(param, "=", 2)
 	if len(kv) != 2 {
 		return cfg, fmt.Errorf("invalid key=value pair %s", kv)
 	}
@@ -197,7 +223,32 @@ func parseASGAutoDiscoverySpec(spec string) (ASGAutoDiscoveryConfig, error) {
 	if v == "" {
 		return cfg, errors.New("tag value not supplied")
 	}
-	cfg.TagKeys = strings.Split(v, ",")
+	for _, tag := range strings.Split(v, ",") {
+		excluded := strings.HasPrefix(tag, "!")
+		tagKV := strings.SplitN(strings.TrimPrefix(tag, "!"), "=", 2)
+		key := tagKV[0]
+		if key == "" {
+			return cfg, fmt.Errorf("Invalid ASG tag for auto discovery specified: %q has no key", tag)
+		}
+		switch {
+		case excluded:
+			if cfg.ExcludedTags == nil {
+				cfg.ExcludedTags = make(map[string]string)
+			}
+			cfg.ExcludedTags[key] = ""
+			if len(tagKV) == 2 {
+				cfg.ExcludedTags[key] = tagKV[1]
+			}
+		case len(tagKV) == 2:
+			if cfg.TagValues == nil {
+				cfg.TagValues = make(map[string]string)
+			}
+			cfg.TagValues[key] = tagKV[1]
+			cfg.TagKeys = append(cfg.TagKeys, key)
+		default:
+			cfg.TagKeys = append(cfg.TagKeys, key)
+		}
+	}
 	if len(cfg.TagKeys) == 0 {
 		return cfg, fmt.Errorf("Invalid ASG tag for auto discovery specified: ASG tag must not be empty")
 	}
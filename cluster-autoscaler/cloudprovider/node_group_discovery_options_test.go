@@ -145,6 +145,27 @@ func TestParseASGAutoDiscoverySpecs(t *testing.T) {
 				{TagKeys: []string{"cooltag", "anothertag"}},
 			},
 		},
+		{
+			name:  "TagValuesAndExclusions",
+			specs: []string{"asg:tag=tag,env=prod,!legacy,!team=infra"},
+			want: []ASGAutoDiscoveryConfig{
+				{
+					TagKeys:      []string{"tag", "env"},
+					TagValues:    map[string]string{"env": "prod"},
+					ExcludedTags: map[string]string{"legacy": "", "team": "infra"},
+				},
+			},
+		},
+		{
+			name:    "OnlyExclusions",
+			specs:   []string{"asg:tag=!legacy"},
+			wantErr: true,
+		},
+		{
+			name:    "TagMissingKey",
+			specs:   []string{"asg:tag=tag,=prod"},
+			wantErr: true,
+		},
 		{
 			name:    "MissingASGType",
 			specs:   []string{"tag=tag,anothertag"},
@@ -185,3 +206,14 @@ func TestParseASGAutoDiscoverySpecs(t *testing.T) {
 		})
 	}
 }
+
+func TestASGAutoDiscoveryConfigMatches(t *testing.T) {
+	cfg, err := parseASGAutoDiscoverySpec("asg:tag=tag,env=prod,!legacy,!team=infra")
+	assert.NoError(t, err)
+
+	assert.True(t, cfg.Matches(map[string]string{"tag": "", "env": "prod", "team": "web"}))
+	assert.False(t, cfg.Matches(map[string]string{"tag": "", "env": "dev"}))
+	assert.False(t, cfg.Matches(map[string]string{"env": "prod"}))
+	assert.False(t, cfg.Matches(map[string]string{"tag": "", "env": "prod", "legacy": "true"}))
+	assert.False(t, cfg.Matches(map[string]string{"tag": "", "env": "prod", "team": "infra"}))
+}
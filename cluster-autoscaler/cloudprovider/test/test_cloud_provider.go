@@ -183,6 +183,15 @@ func (tcp *TestCloudProvider) AddAutoprovisionedNodeGroup(id string, min int, ma
 	}
 }
 
+// SetNodeGroupZones sets the zones of the node group, and the zones its next node may be placed in.
+func (tcp *TestCloudProvider) SetNodeGroupZones(id string, zones []string, scaleUpZones []string) {
+	tcp.Lock()
+	defer tcp.Unlock()
+
+	tcp.groups[id].(*TestNodeGroup).zones = zones
+	tcp.groups[id].(*TestNodeGroup).scaleUpZones = scaleUpZones
+}
+
 // AddNode adds the given node to the group.
 func (tcp *TestCloudProvider) AddNode(nodeGroupId string, node *apiv1.Node) {
 	tcp.Lock()
@@ -222,6 +231,8 @@ type TestNodeGroup struct {
 	exist           bool
 	autoprovisioned bool
 	machineType     string
+	zones           []string
+	scaleUpZones    []string
 }
 
 // MaxSize returns maximum size of the node group.
@@ -355,6 +366,16 @@ func (tng *TestNodeGroup) Autoprovisioned() bool {
 	return tng.autoprovisioned
 }
 
+// Zones returns the zones of the node group.
+func (tng *TestNodeGroup) Zones() ([]string, error) {
+	return tng.zones, nil
+}
+
+// ScaleUpZones returns the zones the next node of the node group may be placed in.
+func (tng *TestNodeGroup) ScaleUpZones() ([]string, error) {
+	return tng.scaleUpZones, nil
+}
+
 // TemplateNodeInfo returns a node template for this node group.
 func (tng *TestNodeGroup) TemplateNodeInfo() (*schedulercache.NodeInfo, error) {
 	if tng.cloudProvider.machineTemplates == nil {
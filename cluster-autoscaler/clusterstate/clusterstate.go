@@ -260,6 +260,27 @@ func (csr *ClusterStateRegistry) RegisterFailedScaleUp(nodeGroupName string, rea
 	csr.backoffNodeGroup(nodeGroupName, time.Now())
 }
 
+// RegisterTargetSizeDecrease should be called after the target size of a node group was decreased
+// by delta (a negative number) to give up on nodes that failed to register. Scale-up requests of the
+// group are dropped, as they won't be fulfilled, and the group is no longer reported with an
+// incorrect size until the next update.
+func (csr *ClusterStateRegistry) RegisterTargetSizeDecrease(nodeGroupName string, delta int, currentTime time.Time) {
+	csr.Lock()
+	defer csr.Unlock()
+
+	newSur := make([]*ScaleUpRequest, 0, len(csr.scaleUpRequests))
+	for _, sur := range csr.scaleUpRequests {
+		if sur.NodeGroupName != nodeGroupName {
+			newSur = append(newSur, sur)
+		}
+	}
+	csr.scaleUpRequests = newSur
+	delete(csr.incorrectNodeGroupSizes, nodeGroupName)
+	glog.V(1).Infof("Target size of node group %v decreased by %d at %v", nodeGroupName, -delta, currentTime)
+	csr.logRecorder.Eventf(apiv1.EventTypeNormal, "FixNodeGroupSize",
+		"Decreased target size of node group %s by %d, nodes failed to register", nodeGroupName, -delta)
+}
+
 // UpdateNodes updates the state of the nodes in the ClusterStateRegistry and recalculates the stats
 func (csr *ClusterStateRegistry) UpdateNodes(nodes []*apiv1.Node, currentTime time.Time) error {
 	csr.updateNodeGroupMetrics()
@@ -599,7 +620,7 @@ func (csr *ClusterStateRegistry) updateUnregisteredNodes(unregisteredNodes []Unr
 	csr.unregisteredNodes = result
 }
 
-//GetUnregisteredNodes returns a list of all unregistered nodes.
+// GetUnregisteredNodes returns a list of all unregistered nodes.
 func (csr *ClusterStateRegistry) GetUnregisteredNodes() []UnregisteredNode {
 	csr.Lock()
 	defer csr.Unlock()
@@ -451,6 +451,42 @@ func TestIncorrectSize(t *testing.T) {
 	assert.Equal(t, now.Add(-3*time.Minute), incorrect.FirstObserved)
 }
 
+func TestRegisterTargetSizeDecrease(t *testing.T) {
+	ng1_1 := BuildTestNode("ng1-1", 1000, 1000)
+	provider := testprovider.NewTestCloudProvider(nil, nil)
+	provider.AddNodeGroup("ng1", 1, 10, 5)
+	provider.AddNode("ng1", ng1_1)
+	provider.AddNodeGroup("ng2", 1, 10, 3)
+	assert.NotNil(t, provider)
+	fakeClient := &fake.Clientset{}
+	fakeLogRecorder, _ := utils.NewStatusMapRecorder(fakeClient, "kube-system", kube_record.NewFakeRecorder(5), false)
+	clusterstate := NewClusterStateRegistry(provider, ClusterStateRegistryConfig{
+		MaxTotalUnreadyPercentage: 10,
+		OkTotalUnreadyCount:       1,
+	}, fakeLogRecorder)
+	now := time.Now()
+	clusterstate.UpdateNodes([]*apiv1.Node{ng1_1}, now.Add(-5*time.Minute))
+	assert.NotNil(t, clusterstate.GetIncorrectNodeGroupSize("ng1"))
+
+	clusterstate.RegisterScaleUp(&ScaleUpRequest{
+		NodeGroupName:   "ng1",
+		Increase:        1,
+		Time:            now.Add(-time.Minute),
+		ExpectedAddTime: now.Add(5 * time.Minute),
+	})
+	clusterstate.RegisterScaleUp(&ScaleUpRequest{
+		NodeGroupName:   "ng2",
+		Increase:        3,
+		Time:            now.Add(-time.Minute),
+		ExpectedAddTime: now.Add(5 * time.Minute),
+	})
+
+	clusterstate.RegisterTargetSizeDecrease("ng1", -4, now)
+	assert.Nil(t, clusterstate.GetIncorrectNodeGroupSize("ng1"))
+	assert.Equal(t, 1, len(clusterstate.scaleUpRequests))
+	assert.Equal(t, "ng2", clusterstate.scaleUpRequests[0].NodeGroupName)
+}
+
 func TestUnregisteredNodes(t *testing.T) {
 	ng1_1 := BuildTestNode("ng1-1", 1000, 1000)
 	ng1_1.Spec.ProviderID = "ng1-1"
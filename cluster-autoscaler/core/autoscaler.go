@@ -45,6 +45,9 @@ type Autoscaler interface {
 	CloudProvider() cloudprovider.CloudProvider
 	// ExitCleanUp is a clean-up performed just before process termination.
 	ExitCleanUp()
+	// IsDeleteInProgress tells whether nodes are being deleted in the background, so the
+	// process shouldn't be restarted.
+	IsDeleteInProgress() bool
 }
 
 // NewAutoscaler creates an autoscaler of an appropriate type according to the parameters
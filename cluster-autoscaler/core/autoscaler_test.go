@@ -66,7 +66,7 @@ func TestNewAutoscalerStatic(t *testing.T) {
 		},
 	}
 	predicateChecker := simulator.NewTestPredicateChecker()
-	listerRegistry := kube_util.NewListerRegistry(nil, nil, nil, nil, nil, nil)
+	listerRegistry := kube_util.NewListerRegistry(nil, nil, nil, nil, nil, nil, nil)
 	a, _ := NewAutoscaler(opts, predicateChecker, fakeClient, kubeEventRecorder, listerRegistry)
 	assert.IsType(t, &StaticAutoscaler{}, a)
 }
@@ -103,7 +103,7 @@ func TestNewAutoscalerDynamic(t *testing.T) {
 		},
 	}
 	predicateChecker := simulator.NewTestPredicateChecker()
-	listerRegistry := kube_util.NewListerRegistry(nil, nil, nil, nil, nil, nil)
+	listerRegistry := kube_util.NewListerRegistry(nil, nil, nil, nil, nil, nil, nil)
 	a, _ := NewAutoscaler(opts, predicateChecker, fakeClient, kubeEventRecorder, listerRegistry)
 	assert.IsType(t, &DynamicAutoscaler{}, a)
 }
@@ -81,6 +81,10 @@ type AutoscalingOptions struct {
 	EstimatorName string
 	// ExpanderName sets the type of node group expander to be used in scale up
 	ExpanderName string
+	// ExternalExpanderUrl is the url of the service choosing node groups for the external expander
+	ExternalExpanderUrl string
+	// ExternalExpanderTimeout is the timeout of a call to the external expander
+	ExternalExpanderTimeout time.Duration
 	// MaxGracefulTerminationSec is maximum number of seconds scale down waits for pods to terminate before
 	// removing the node from cloud provider.
 	MaxGracefulTerminationSec int
@@ -134,6 +138,23 @@ type AutoscalingOptions struct {
 	ExpendablePodsPriorityCutoff int
 	// Regional tells whether the cluster is regional.
 	Regional bool
+	// SpotInterruptionHandlingEnabled tells whether nodes that received a spot interruption notice
+	// are replaced and drained ahead of the interruption.
+	SpotInterruptionHandlingEnabled bool
+	// SpotInterruptionTaint is the taint key that marks a node with a spot interruption notice.
+	SpotInterruptionTaint string
+	// SpotInterruptionCondition is the node condition that marks a node with a spot interruption notice.
+	SpotInterruptionCondition string
+	// ScaleDownPayModelPolicy tells how scale down picks between spot and on-demand nodes.
+	ScaleDownPayModelPolicy string
+	// MaxSpotPercentTotal is the maximum percentage of the cores of a node group on spot nodes that scale down may leave.
+	// Negative to use the limit of the cloud provider.
+	MaxSpotPercentTotal float64
+	// MaxSpotPercentOneMarket is the maximum percentage of the cores of a node group in a single spot market that scale down may leave.
+	// Negative to use the limit of the cloud provider.
+	MaxSpotPercentOneMarket float64
+	// NodeGroupHeadroom are the specs of the spare capacity kept in node groups, as accepted by ParseHeadroomSpec.
+	NodeGroupHeadroom []string
 }
 
 // NewAutoscalingContext returns an autoscaling context from all the necessary parameters passed via arguments
@@ -141,15 +162,20 @@ func NewAutoscalingContext(options AutoscalingOptions, predicateChecker *simulat
 	kubeClient kube_client.Interface, kubeEventRecorder kube_record.EventRecorder,
 	logEventRecorder *utils.LogEventRecorder, listerRegistry kube_util.ListerRegistry) (*AutoscalingContext, errors.AutoscalerError) {
 
-	cloudProviderBuilder := builder.NewCloudProviderBuilder(options.CloudProviderName, options.CloudConfig, options.ClusterName, options.NodeAutoprovisioningEnabled, options.Regional)
+	cloudProviderBuilder := builder.NewCloudProviderBuilder(options.CloudProviderName, options.CloudConfig, options.ClusterName,
+		options.NodeAutoprovisioningEnabled, options.Regional, listerRegistry)
 	cloudProvider := cloudProviderBuilder.Build(cloudprovider.NodeGroupDiscoveryOptions{
 		NodeGroupSpecs:              options.NodeGroups,
 		NodeGroupAutoDiscoverySpecs: options.NodeGroupAutoDiscovery},
 		cloudprovider.NewResourceLimiter(
 			map[string]int64{cloudprovider.ResourceNameCores: int64(options.MinCoresTotal), cloudprovider.ResourceNameMemory: options.MinMemoryTotal},
 			map[string]int64{cloudprovider.ResourceNameCores: options.MaxCoresTotal, cloudprovider.ResourceNameMemory: options.MaxMemoryTotal}))
+	if err := validatePayModelPolicy(options.ScaleDownPayModelPolicy); err != nil {
+		return nil, errors.ToAutoscalerError(errors.InternalError, err)
+	}
 	expanderStrategy, err := factory.ExpanderStrategyFromString(options.ExpanderName,
-		cloudProvider, listerRegistry.AllNodeLister())
+		cloudProvider, kubeClient, options.ConfigNamespace, kubeEventRecorder, listerRegistry.AllNodeLister(),
+		options.ExternalExpanderUrl, options.ExternalExpanderTimeout)
 	if err != nil {
 		return nil, err
 	}
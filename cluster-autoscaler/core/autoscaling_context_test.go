@@ -44,7 +44,7 @@ func TestNewAutoscalingContext(t *testing.T) {
 		},
 		simulator.NewTestPredicateChecker(),
 		fakeClient, fakeRecorder,
-		fakeLogRecorder, kube_util.NewListerRegistry(nil, nil, nil, nil, nil, nil))
+		fakeLogRecorder, kube_util.NewListerRegistry(nil, nil, nil, nil, nil, nil, nil))
 	assert.NoError(t, err)
 	assert.NotNil(t, autoscalingContext)
 }
@@ -62,6 +62,11 @@ func (a *DynamicAutoscaler) ExitCleanUp() {
 	a.autoscaler.ExitCleanUp()
 }
 
+// IsDeleteInProgress tells whether the current autoscaler is deleting nodes.
+func (a *DynamicAutoscaler) IsDeleteInProgress() bool {
+	return a.autoscaler.IsDeleteInProgress()
+}
+
 // RunOnce represents a single iteration of a dynamic autoscaler inside the CA's control-loop
 func (a *DynamicAutoscaler) RunOnce(currentTime time.Time) errors.AutoscalerError {
 	reconfigureStart := time.Now()
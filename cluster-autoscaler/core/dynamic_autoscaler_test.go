@@ -17,6 +17,7 @@ limitations under the License.
 package core
 
 import (
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
 	"k8s.io/autoscaler/cluster-autoscaler/config/dynamic"
@@ -47,6 +48,11 @@ func (m *AutoscalerMock) ExitCleanUp() {
 	m.Called()
 }
 
+func (m *AutoscalerMock) IsDeleteInProgress() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
 type ConfigFetcherMock struct {
 	mock.Mock
 }
@@ -116,3 +122,17 @@ func TestRunOnceWhenUpdated(t *testing.T) {
 	configFetcher.AssertExpectations(t)
 	builder.AssertExpectations(t)
 }
+
+func TestIsDeleteInProgress(t *testing.T) {
+	autoscaler := &AutoscalerMock{}
+	autoscaler.On("IsDeleteInProgress").Return(true).Once()
+
+	configFetcher := &ConfigFetcherMock{}
+	builder := &AutoscalerBuilderMock{}
+	builder.On("Build").Return(autoscaler).Once()
+
+	a, _ := NewDynamicAutoscaler(builder, configFetcher)
+	assert.True(t, a.IsDeleteInProgress())
+
+	autoscaler.AssertExpectations(t)
+}
@@ -0,0 +1,334 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/clusterstate/api"
+	"k8s.io/autoscaler/cluster-autoscaler/simulator"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/drain"
+	scheduler_util "k8s.io/autoscaler/cluster-autoscaler/utils/scheduler"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// HeadroomNodeGroupAnnotation is set on the virtual pods keeping headroom in a node group,
+	// to the id of the node group.
+	HeadroomNodeGroupAnnotation = "cluster-autoscaler.kubernetes.io/headroom-node-group"
+
+	// ClusterAutoscalerHeadroom is the status condition telling whether the headroom of a node
+	// group fits on its nodes.
+	ClusterAutoscalerHeadroom api.ClusterAutoscalerConditionType = "Headroom"
+	// ClusterAutoscalerHeadroomAvailable status means that all of the headroom fits on the nodes.
+	ClusterAutoscalerHeadroomAvailable api.ClusterAutoscalerConditionStatus = "Available"
+	// ClusterAutoscalerHeadroomMissing status means that some of the headroom doesn't fit on the nodes.
+	ClusterAutoscalerHeadroomMissing api.ClusterAutoscalerConditionStatus = "Missing"
+)
+
+// HeadroomSpec is the spare capacity kept in a node group.
+type HeadroomSpec struct {
+	NodeGroup string
+	// Pods is the number of spare virtual pods requesting CPU and Memory each. If it is 0, CPU
+	// and Memory are the total spare capacity.
+	Pods   int
+	CPU    resource.Quantity
+	Memory resource.Quantity
+}
+
+// ParseHeadroomSpec parses a headroom spec of the form <node group>:cpu=<quantity>,memory=<quantity>
+// for spare capacity, or <node group>:pods=<count>,cpu=<quantity>,memory=<quantity> for spare
+// pods of the given shape. Either of cpu and memory may be left out.
+func ParseHeadroomSpec(value string) (HeadroomSpec, error) {
+	var spec HeadroomSpec
+	separator := strings.LastIndex(value, ":")
+	if separator <= 0 {
+		return spec, fmt.Errorf("headroom %q should be <node group>:<resources>", value)
+	}
+	spec.NodeGroup = value[:separator]
+	for _, field := range strings.Split(value[separator+1:], ",") {
+		keyValue := strings.SplitN(field, "=", 2)
+		if len(keyValue) != 2 {
+			return spec, fmt.Errorf("headroom %q: %q should be <key>=<value>", value, field)
+		}
+		var err error
+		switch keyValue[0] {
+		case "pods":
+			spec.Pods, err = strconv.Atoi(keyValue[1])
+			if err == nil && spec.Pods <= 0 {
+				err = fmt.Errorf("should be positive")
+			}
+		case "cpu":
+			spec.CPU, err = resource.ParseQuantity(keyValue[1])
+		case "memory":
+			spec.Memory, err = resource.ParseQuantity(keyValue[1])
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return spec, fmt.Errorf("headroom %q: invalid %s: %v", value, keyValue[0], err)
+		}
+	}
+	if spec.CPU.Sign() <= 0 && spec.Memory.Sign() <= 0 {
+		return spec, fmt.Errorf("headroom %q: no cpu or memory", value)
+	}
+	return spec, nil
+}
+
+func (spec HeadroomSpec) String() string {
+	if spec.Pods > 0 {
+		return fmt.Sprintf("%d pods of cpu=%s memory=%s", spec.Pods, spec.CPU.String(), spec.Memory.String())
+	}
+	return fmt.Sprintf("cpu=%s memory=%s", spec.CPU.String(), spec.Memory.String())
+}
+
+// headroomNodeGroup returns the node group whose headroom the pod is keeping, if it's a virtual pod.
+func headroomNodeGroup(pod *apiv1.Pod) (string, bool) {
+	nodeGroup, found := pod.Annotations[HeadroomNodeGroupAnnotation]
+	return nodeGroup, found
+}
+
+// isHeadroomPod tells whether the pod is a virtual pod keeping headroom, which doesn't exist in
+// the cluster.
+func isHeadroomPod(pod *apiv1.Pod) bool {
+	_, found := headroomNodeGroup(pod)
+	return found
+}
+
+// buildHeadroomPods returns the virtual pods keeping the headroom of spec. Spare capacity is split
+// into pods fitting on nodes like nodeInfo. The pods tolerate all taints, and are safe to evict so
+// that scale down may move them to other nodes.
+func buildHeadroomPods(spec HeadroomSpec, nodeInfo *schedulercache.NodeInfo) ([]*apiv1.Pod, error) {
+	count := spec.Pods
+	cpu := spec.CPU.MilliValue()
+	memory := spec.Memory.Value()
+	if count == 0 {
+		allocatable := nodeInfo.Node().Status.Allocatable
+		requested := nodeInfo.RequestedResource()
+		allocatableCPU := allocatable[apiv1.ResourceCPU]
+		allocatableMemory := allocatable[apiv1.ResourceMemory]
+		freeCPU := allocatableCPU.MilliValue() - requested.MilliCPU
+		freeMemory := allocatableMemory.Value() - requested.Memory
+		if freeCPU <= 0 || freeMemory <= 0 {
+			return nil, fmt.Errorf("no free resources on template node %s", nodeInfo.Node().Name)
+		}
+		count = 1
+		if c := int(divideRoundingUp(cpu, freeCPU)); c > count {
+			count = c
+		}
+		if c := int(divideRoundingUp(memory, freeMemory)); c > count {
+			count = c
+		}
+		cpu = divideRoundingUp(cpu, int64(count))
+		memory = divideRoundingUp(memory, int64(count))
+	}
+
+	requests := apiv1.ResourceList{}
+	if cpu > 0 {
+		requests[apiv1.ResourceCPU] = *resource.NewMilliQuantity(cpu, resource.DecimalSI)
+	}
+	if memory > 0 {
+		requests[apiv1.ResourceMemory] = *resource.NewQuantity(memory, resource.BinarySI)
+	}
+	pods := make([]*apiv1.Pod, 0, count)
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("headroom-%s-%d", spec.NodeGroup, i)
+		pods = append(pods, &apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				UID:  types.UID(name),
+				Annotations: map[string]string{
+					HeadroomNodeGroupAnnotation: spec.NodeGroup,
+					drain.PodSafeToEvictKey:     "true",
+				},
+			},
+			Spec: apiv1.PodSpec{
+				Containers: []apiv1.Container{
+					{
+						Name:      "headroom",
+						Resources: apiv1.ResourceRequirements{Requests: requests},
+					},
+				},
+				Tolerations: []apiv1.Toleration{{Operator: apiv1.TolerationOpExists}},
+			},
+		})
+	}
+	return pods, nil
+}
+
+func divideRoundingUp(a, b int64) int64 {
+	return (a + b - 1) / b
+}
+
+// headroomResult is the headroom of the node groups in a loop.
+type headroomResult struct {
+	// placed are the virtual pods fitting on the nodes of their node group, with NodeName set.
+	placed []*apiv1.Pod
+	// unplaced are the virtual pods that don't fit on the nodes of their node group.
+	unplaced []*apiv1.Pod
+	// conditions tell whether the headroom of each node group fits, by node group id.
+	conditions map[string]api.ClusterAutoscalerCondition
+}
+
+// computeHeadroom places the virtual pods keeping the headroom of each node group on its ready
+// nodes, most utilized first, so that the least utilized nodes remain scale-down candidates.
+// Placed pods can only be moved to other nodes of their node group.
+func computeHeadroom(specs []HeadroomSpec, cloudProvider cloudprovider.CloudProvider,
+	predicateChecker *simulator.PredicateChecker, readyNodes []*apiv1.Node, scheduledPods []*apiv1.Pod,
+	nodeInfos map[string]*schedulercache.NodeInfo) *headroomResult {
+
+	result := &headroomResult{
+		conditions: make(map[string]api.ClusterAutoscalerCondition),
+	}
+	nodesByGroup := make(map[string][]*apiv1.Node)
+	for _, node := range readyNodes {
+		nodeGroup, err := cloudProvider.NodeGroupForNode(node)
+		if err != nil || nodeGroup == nil || reflect.ValueOf(nodeGroup).IsNil() {
+			continue
+		}
+		nodesByGroup[nodeGroup.Id()] = append(nodesByGroup[nodeGroup.Id()], node)
+	}
+
+	for _, spec := range specs {
+		nodeInfo, found := nodeInfos[spec.NodeGroup]
+		if !found {
+			glog.Warningf("No node info for %s, not keeping its headroom", spec.NodeGroup)
+			continue
+		}
+		pods, err := buildHeadroomPods(spec, nodeInfo)
+		if err != nil {
+			glog.Warningf("Failed to build headroom of %s: %v", spec.NodeGroup, err)
+			continue
+		}
+
+		nodes := nodesByGroup[spec.NodeGroup]
+		nodeNameToNodeInfo := scheduler_util.CreateNodeNameToInfoMap(scheduledPods, nodes)
+		utilization := make(map[string]float64, len(nodes))
+		for _, node := range nodes {
+			utilization[node.Name], _ = simulator.CalculateUtilization(node, nodeNameToNodeInfo[node.Name])
+		}
+		sort.SliceStable(nodes, func(i, j int) bool { return utilization[nodes[i].Name] > utilization[nodes[j].Name] })
+		affinity := nodeGroupAffinity(nodes)
+
+		placedCount := 0
+	podsloop:
+		for _, pod := range pods {
+			for _, node := range nodes {
+				hostInfo := nodeNameToNodeInfo[node.Name]
+				if err := predicateChecker.CheckPredicates(pod, nil, hostInfo, simulator.ReturnSimpleError); err != nil {
+					continue
+				}
+				placedPod := pod.DeepCopy()
+				placedPod.Spec.NodeName = node.Name
+				placedPod.Spec.Affinity = affinity
+				hostInfo.AddPod(placedPod)
+				result.placed = append(result.placed, placedPod)
+				placedCount++
+				continue podsloop
+			}
+			result.unplaced = append(result.unplaced, pod)
+		}
+
+		condition := api.ClusterAutoscalerCondition{
+			Type:    ClusterAutoscalerHeadroom,
+			Status:  ClusterAutoscalerHeadroomAvailable,
+			Message: fmt.Sprintf("%s, %d/%d virtual pods fit", spec, placedCount, len(pods)),
+		}
+		if placedCount < len(pods) {
+			condition.Status = ClusterAutoscalerHeadroomMissing
+		}
+		result.conditions[spec.NodeGroup] = condition
+		glog.V(2).Infof("Headroom of %s: %s", spec.NodeGroup, condition.Message)
+	}
+	return result
+}
+
+// nodeGroupAffinity returns a node affinity to the given nodes of a node group, by hostname, so that
+// scale down doesn't move virtual pods to other node groups.
+func nodeGroupAffinity(nodes []*apiv1.Node) *apiv1.Affinity {
+	hostnames := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		hostname, found := node.Labels[kubeletapis.LabelHostname]
+		if !found {
+			glog.Warningf("Node %s has no %s label, virtual pods may be moved to other node groups", node.Name, kubeletapis.LabelHostname)
+			return nil
+		}
+		hostnames = append(hostnames, hostname)
+	}
+	return &apiv1.Affinity{
+		NodeAffinity: &apiv1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &apiv1.NodeSelector{
+				NodeSelectorTerms: []apiv1.NodeSelectorTerm{{
+					MatchExpressions: []apiv1.NodeSelectorRequirement{{
+						Key:      kubeletapis.LabelHostname,
+						Operator: apiv1.NodeSelectorOpIn,
+						Values:   hostnames,
+					}},
+				}},
+			},
+		},
+	}
+}
+
+// filterOutHeadroomPods returns the pods that aren't virtual pods keeping headroom.
+func filterOutHeadroomPods(pods []*apiv1.Pod) []*apiv1.Pod {
+	result := make([]*apiv1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if !isHeadroomPod(pod) {
+			result = append(result, pod)
+		}
+	}
+	return result
+}
+
+// updateHeadroomConditions replaces the headroom conditions of the previous loop, keeping their
+// transition time if their status didn't change.
+func updateHeadroomConditions(previous, current map[string]api.ClusterAutoscalerCondition,
+	now time.Time) map[string]api.ClusterAutoscalerCondition {
+	for nodeGroup, condition := range current {
+		condition.LastProbeTime = metav1.Time{Time: now}
+		condition.LastTransitionTime = metav1.Time{Time: now}
+		if old, found := previous[nodeGroup]; found && old.Status == condition.Status {
+			condition.LastTransitionTime = old.LastTransitionTime
+		}
+		current[nodeGroup] = condition
+	}
+	return current
+}
+
+// addHeadroomConditions adds the headroom conditions to the statuses of their node groups.
+func addHeadroomConditions(status *api.ClusterAutoscalerStatus, conditions map[string]api.ClusterAutoscalerCondition) {
+	for i := range status.NodeGroupStatuses {
+		if condition, found := conditions[status.NodeGroupStatuses[i].ProviderID]; found {
+			status.NodeGroupStatuses[i].Conditions = append(status.NodeGroupStatuses[i].Conditions, condition)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	testprovider "k8s.io/autoscaler/cluster-autoscaler/cloudprovider/test"
+	"k8s.io/autoscaler/cluster-autoscaler/clusterstate/api"
+	"k8s.io/autoscaler/cluster-autoscaler/simulator"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/drain"
+	. "k8s.io/autoscaler/cluster-autoscaler/utils/test"
+
+	apiv1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHeadroomSpec(t *testing.T) {
+	spec, err := ParseHeadroomSpec("ng1:cpu=4,memory=8Gi")
+	assert.NoError(t, err)
+	assert.Equal(t, "ng1", spec.NodeGroup)
+	assert.Equal(t, 0, spec.Pods)
+	assert.Equal(t, int64(4000), spec.CPU.MilliValue())
+	assert.Equal(t, int64(8<<30), spec.Memory.Value())
+
+	spec, err = ParseHeadroomSpec("arn:aws:autoscaling:us-east-1:asg/ng2:pods=3,cpu=500m")
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:autoscaling:us-east-1:asg/ng2", spec.NodeGroup)
+	assert.Equal(t, 3, spec.Pods)
+	assert.Equal(t, int64(500), spec.CPU.MilliValue())
+	assert.Equal(t, "3 pods of cpu=500m memory=0", spec.String())
+
+	for _, value := range []string{"cpu=4", ":cpu=4", "ng1:cpu", "ng1:cpu=x", "ng1:gpu=1",
+		"ng1:pods=0,cpu=1", "ng1:pods=2"} {
+		_, err = ParseHeadroomSpec(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestBuildHeadroomPods(t *testing.T) {
+	nodeInfo := schedulercache.NewNodeInfo(BuildTestPod("kube-proxy", 500, 0))
+	nodeInfo.SetNode(BuildTestNode("ng1-template", 2000, 2000))
+
+	spec, _ := ParseHeadroomSpec("ng1:pods=2,cpu=300m,memory=100")
+	pods, err := buildHeadroomPods(spec, nodeInfo)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(pods))
+	assert.Equal(t, "headroom-ng1-1", pods[1].Name)
+	group, found := headroomNodeGroup(pods[1])
+	assert.True(t, found)
+	assert.Equal(t, "ng1", group)
+	assert.Equal(t, "true", pods[1].Annotations[drain.PodSafeToEvictKey])
+	cpu := pods[1].Spec.Containers[0].Resources.Requests[apiv1.ResourceCPU]
+	assert.Equal(t, int64(300), cpu.MilliValue())
+
+	// 4 cores don't fit on the 1.5 free cores of a node, so they are split into 3 pods.
+	spec, _ = ParseHeadroomSpec("ng1:cpu=4,memory=1000")
+	pods, err = buildHeadroomPods(spec, nodeInfo)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(pods))
+	cpu = pods[0].Spec.Containers[0].Resources.Requests[apiv1.ResourceCPU]
+	memory := pods[0].Spec.Containers[0].Resources.Requests[apiv1.ResourceMemory]
+	assert.Equal(t, int64(1334), cpu.MilliValue())
+	assert.Equal(t, int64(334), memory.Value())
+
+	assert.False(t, isHeadroomPod(BuildTestPod("p1", 100, 0)))
+}
+
+func TestComputeHeadroom(t *testing.T) {
+	n1 := BuildTestNode("n1", 2000, 2000)
+	n2 := BuildTestNode("n2", 2000, 2000)
+	n3 := BuildTestNode("n3", 2000, 2000)
+	p1 := BuildTestPod("p1", 1500, 0)
+	p1.Spec.NodeName = "n1"
+
+	provider := testprovider.NewTestCloudProvider(nil, nil)
+	provider.AddNodeGroup("ng1", 1, 10, 2)
+	provider.AddNode("ng1", n1)
+	provider.AddNode("ng1", n2)
+	provider.AddNodeGroup("ng2", 1, 10, 1)
+	provider.AddNode("ng2", n3)
+	nodeInfos := make(map[string]*schedulercache.NodeInfo)
+	for _, id := range []string{"ng1", "ng2"} {
+		nodeInfos[id] = schedulercache.NewNodeInfo()
+		nodeInfos[id].SetNode(BuildTestNode(id+"-template", 2000, 2000))
+	}
+
+	// The most utilized node is filled first, the other node group isn't used.
+	spec, _ := ParseHeadroomSpec("ng1:pods=6,cpu=500m")
+	headroom := computeHeadroom([]HeadroomSpec{spec}, provider, simulator.NewTestPredicateChecker(),
+		[]*apiv1.Node{n1, n2, n3}, []*apiv1.Pod{p1}, nodeInfos)
+	assert.Equal(t, 5, len(headroom.placed))
+	assert.Equal(t, "n1", headroom.placed[0].Spec.NodeName)
+	assert.Equal(t, "n2", headroom.placed[1].Spec.NodeName)
+	assert.Equal(t, 1, len(headroom.unplaced))
+	assert.Equal(t, "", headroom.unplaced[0].Spec.NodeName)
+	condition := headroom.conditions["ng1"]
+	assert.Equal(t, ClusterAutoscalerHeadroomMissing, condition.Status)
+	assert.Equal(t, "6 pods of cpu=500m memory=0, 5/6 virtual pods fit", condition.Message)
+
+	// Transition times are kept while the status doesn't change.
+	now := time.Now()
+	previous := map[string]api.ClusterAutoscalerCondition{
+		"ng1": {Status: ClusterAutoscalerHeadroomMissing, LastTransitionTime: metav1.Time{Time: now.Add(-time.Hour)}},
+	}
+	conditions := updateHeadroomConditions(previous, headroom.conditions, now)
+	assert.Equal(t, now.Add(-time.Hour), conditions["ng1"].LastTransitionTime.Time)
+	assert.Equal(t, now, conditions["ng1"].LastProbeTime.Time)
+
+	status := &api.ClusterAutoscalerStatus{NodeGroupStatuses: []api.NodeGroupStatus{{ProviderID: "ng1"}, {ProviderID: "ng2"}}}
+	addHeadroomConditions(status, conditions)
+	assert.Equal(t, 1, len(status.NodeGroupStatuses[0].Conditions))
+	assert.Equal(t, 0, len(status.NodeGroupStatuses[1].Conditions))
+}
+
+func TestHeadroomScaleDown(t *testing.T) {
+	n1 := BuildTestNode("n1", 2000, 2000)
+	n2 := BuildTestNode("n2", 2000, 2000)
+	n3 := BuildTestNode("n3", 2000, 2000)
+	for _, node := range []*apiv1.Node{n1, n2, n3} {
+		node.Labels[kubeletapis.LabelHostname] = node.Name
+	}
+	p1 := BuildTestPod("p1", 200, 0)
+	p1.Spec.NodeName = "n2"
+	p1.OwnerReferences = GenerateOwnerReferences("rs", "ReplicaSet", "extensions/v1beta1", "")
+
+	provider := testprovider.NewTestCloudProvider(nil, nil)
+	provider.AddNodeGroup("ng1", 1, 10, 2)
+	provider.AddNode("ng1", n1)
+	provider.AddNode("ng1", n2)
+	provider.AddNodeGroup("ng2", 1, 10, 1)
+	provider.AddNode("ng2", n3)
+	nodeInfos := map[string]*schedulercache.NodeInfo{"ng1": schedulercache.NewNodeInfo()}
+	nodeInfos["ng1"].SetNode(BuildTestNode("ng1-template", 2000, 2000))
+
+	spec, _ := ParseHeadroomSpec("ng1:pods=1,cpu=800m")
+	predicateChecker := simulator.NewTestPredicateChecker()
+	headroom := computeHeadroom([]HeadroomSpec{spec}, provider, predicateChecker,
+		[]*apiv1.Node{n1, n2, n3}, []*apiv1.Pod{p1}, nodeInfos)
+	assert.Equal(t, 1, len(headroom.placed))
+	assert.Equal(t, "n2", headroom.placed[0].Spec.NodeName)
+
+	// The node hosting the virtual pod can be removed, it is moved to the other node of its node group.
+	allNodes := []*apiv1.Node{n1, n2, n3}
+	pods := append([]*apiv1.Pod{p1}, headroom.placed...)
+	toRemove, _, _, err := simulator.FindNodesToRemove([]*apiv1.Node{n2}, allNodes, pods, nil,
+		predicateChecker, len(allNodes), true, map[string]string{}, simulator.NewUsageTracker(), time.Now(), []*policyv1.PodDisruptionBudget{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(toRemove))
+	assert.Equal(t, 2, len(toRemove[0].PodsToReschedule))
+	assert.Equal(t, []*apiv1.Pod{p1}, filterOutHeadroomPods(toRemove[0].PodsToReschedule))
+
+	// It isn't moved to other node groups, so the node stays once the headroom doesn't fit elsewhere.
+	p2 := BuildTestPod("p2", 1500, 0)
+	p2.Spec.NodeName = "n1"
+	pods = append(pods, p2)
+	toRemove, unremovable, _, err := simulator.FindNodesToRemove([]*apiv1.Node{n2}, allNodes, pods, nil,
+		predicateChecker, len(allNodes), true, map[string]string{}, simulator.NewUsageTracker(), time.Now(), []*policyv1.PodDisruptionBudget{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(toRemove))
+	assert.Equal(t, []*apiv1.Node{n2}, unremovable)
+}
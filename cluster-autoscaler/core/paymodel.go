@@ -0,0 +1,233 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// NoPayModelPolicy makes scale down ignore pay models.
+	NoPayModelPolicy = ""
+	// OnDemandFirstPayModelPolicy makes scale down remove on-demand nodes before spot nodes,
+	// as long as spot limits are respected.
+	OnDemandFirstPayModelPolicy = "ondemand-first"
+)
+
+// AvailablePayModelPolicies lists the pay model policies of scale down.
+var AvailablePayModelPolicies = []string{OnDemandFirstPayModelPolicy}
+
+// spotLimiter is implemented by cloud providers with their own spot limits, like AutoScalr.
+type spotLimiter interface {
+	// SpotLimits returns the maximum percentages of the cores of the node group on spot nodes
+	// and in a single spot market.
+	SpotLimits(nodeGroup cloudprovider.NodeGroup) (maxSpotPercentTotal, maxSpotPercentOneMarket float64)
+}
+
+// spotLimits returns the spot limits of scale down in the given node group, nil for nodes without
+// one. Limits that aren't set in the options (negative) come from the cloud provider, or are 100%
+// if it has none.
+func spotLimits(context *AutoscalingContext, nodeGroup cloudprovider.NodeGroup) (maxSpotPercentTotal, maxSpotPercentOneMarket float64) {
+	maxSpotPercentTotal, maxSpotPercentOneMarket = 100, 100
+	if limiter, ok := context.CloudProvider.(spotLimiter); ok && nodeGroup != nil {
+		maxSpotPercentTotal, maxSpotPercentOneMarket = limiter.SpotLimits(nodeGroup)
+	}
+	if context.MaxSpotPercentTotal >= 0 {
+		maxSpotPercentTotal = context.MaxSpotPercentTotal
+	}
+	if context.MaxSpotPercentOneMarket >= 0 {
+		maxSpotPercentOneMarket = context.MaxSpotPercentOneMarket
+	}
+	return maxSpotPercentTotal, maxSpotPercentOneMarket
+}
+
+func validatePayModelPolicy(policy string) error {
+	if policy == NoPayModelPolicy || policy == OnDemandFirstPayModelPolicy {
+		return nil
+	}
+	return fmt.Errorf("unknown scale down pay model policy %q, available: [%s]",
+		policy, strings.Join(AvailablePayModelPolicies, ","))
+}
+
+// payModelRank orders nodes for removal: on-demand nodes first, then nodes without pay model,
+// then spot nodes.
+func payModelRank(node *apiv1.Node) int {
+	payModel, found := node.Labels[aws.PayModelLabel]
+	switch {
+	case !found || payModel == "":
+		return 1
+	case aws.IsSpotNode(node):
+		return 2
+	default:
+		return 0
+	}
+}
+
+// spotMarket returns the spot market of a spot node, its instance type in its zone.
+func spotMarket(node *apiv1.Node) string {
+	return node.Labels[kubeletapis.LabelInstanceType] + "/" + node.Labels[kubeletapis.LabelZoneFailureDomain]
+}
+
+// sortByPayModel returns the given nodes in the order they should be removed in,
+// keeping the original order within each pay model.
+func sortByPayModel(nodes []*apiv1.Node) []*apiv1.Node {
+	result := make([]*apiv1.Node, len(nodes))
+	copy(result, nodes)
+	sort.SliceStable(result, func(i, j int) bool {
+		return payModelRank(result[i]) < payModelRank(result[j])
+	})
+	return result
+}
+
+// payModelFleet is the split of the cores of a node group between pay models and spot markets,
+// with the spot limits of the node group.
+type payModelFleet struct {
+	totalCores              int64
+	spotCores               int64
+	marketCores             map[string]int64
+	maxSpotPercentTotal     float64
+	maxSpotPercentOneMarket float64
+}
+
+func (f *payModelFleet) add(node *apiv1.Node, sign int64) {
+	cores, _, err := getNodeCoresAndMemory(node)
+	if err != nil {
+		glog.Warningf("Error getting node resources: %v", err)
+		return
+	}
+	f.totalCores += sign * cores
+	if aws.IsSpotNode(node) {
+		f.spotCores += sign * cores
+		f.marketCores[spotMarket(node)] += sign * cores
+	}
+}
+
+func (f *payModelFleet) spotPercent() float64 {
+	if f.totalCores <= 0 {
+		return 0
+	}
+	return 100 * float64(f.spotCores) / float64(f.totalCores)
+}
+
+func (f *payModelFleet) maxMarketPercent() float64 {
+	if f.totalCores <= 0 {
+		return 0
+	}
+	var max int64
+	for _, cores := range f.marketCores {
+		if cores > max {
+			max = cores
+		}
+	}
+	return 100 * float64(max) / float64(f.totalCores)
+}
+
+// canRemove returns true if removing the node keeps the spot percentages within the limits,
+// or at least doesn't take them further above.
+func (f *payModelFleet) canRemove(node *apiv1.Node) bool {
+	spotBefore, marketBefore := f.spotPercent(), f.maxMarketPercent()
+	f.add(node, -1)
+	spotAfter, marketAfter := f.spotPercent(), f.maxMarketPercent()
+	f.add(node, 1)
+
+	if spotAfter > f.maxSpotPercentTotal && spotAfter > spotBefore {
+		glog.V(4).Infof("Skipping %s - spot capacity of its node group would rise to %.1f%%", node.Name, spotAfter)
+		return false
+	}
+	if marketAfter > f.maxSpotPercentOneMarket && marketAfter > marketBefore {
+		glog.V(4).Infof("Skipping %s - capacity of its node group in a single spot market would rise to %.1f%%", node.Name, marketAfter)
+		return false
+	}
+	return true
+}
+
+// payModelFleets holds the fleet of each node group: spot limits apply to the nodes of each node
+// group, as AutoScalr keeps them for each autoscaling group. Nodes without a node group make up
+// one more fleet.
+type payModelFleets struct {
+	context *AutoscalingContext
+	fleets  map[string]*payModelFleet
+}
+
+func newPayModelFleets(context *AutoscalingContext, nodes []*apiv1.Node) *payModelFleets {
+	fleets := &payModelFleets{
+		context: context,
+		fleets:  make(map[string]*payModelFleet),
+	}
+	for _, node := range nodes {
+		fleets.fleetOf(node).add(node, 1)
+	}
+	return fleets
+}
+
+// fleetOf returns the fleet of the node group of the node.
+func (f *payModelFleets) fleetOf(node *apiv1.Node) *payModelFleet {
+	id := ""
+	nodeGroup, err := f.context.CloudProvider.NodeGroupForNode(node)
+	if err != nil {
+		glog.Warningf("Error getting node group for %s: %v", node.Name, err)
+	}
+	if err != nil || nodeGroup == nil || reflect.ValueOf(nodeGroup).IsNil() {
+		nodeGroup = nil
+	} else {
+		id = nodeGroup.Id()
+	}
+	fleet, found := f.fleets[id]
+	if !found {
+		fleet = &payModelFleet{marketCores: make(map[string]int64)}
+		fleet.maxSpotPercentTotal, fleet.maxSpotPercentOneMarket = spotLimits(f.context, nodeGroup)
+		f.fleets[id] = fleet
+	}
+	return fleet
+}
+
+// filterByPayModel orders scale down candidates by pay model and drops the ones whose removal
+// would break the spot limits of their node group on its own.
+func filterByPayModel(context *AutoscalingContext, candidates []*apiv1.Node, allNodes []*apiv1.Node) []*apiv1.Node {
+	fleets := newPayModelFleets(context, allNodes)
+	result := make([]*apiv1.Node, 0, len(candidates))
+	for _, node := range sortByPayModel(candidates) {
+		if fleets.fleetOf(node).canRemove(node) {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// limitByPayModel returns the nodes that can be removed together without breaking the spot limits
+// of their node groups. Nodes are checked in order, as if the previous ones were removed.
+func limitByPayModel(context *AutoscalingContext, nodes []*apiv1.Node, allNodes []*apiv1.Node) []*apiv1.Node {
+	fleets := newPayModelFleets(context, allNodes)
+	result := make([]*apiv1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if fleet := fleets.fleetOf(node); fleet.canRemove(node) {
+			fleet.add(node, -1)
+			result = append(result, node)
+		}
+	}
+	return result
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
+	testprovider "k8s.io/autoscaler/cluster-autoscaler/cloudprovider/test"
+	. "k8s.io/autoscaler/cluster-autoscaler/utils/test"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildPayModelNode(name string, millicpu int64, payModel, instanceType, zone string) *apiv1.Node {
+	node := BuildTestNode(name, millicpu, 1000)
+	if payModel != "" {
+		node.Labels[aws.PayModelLabel] = payModel
+	}
+	node.Labels[kubeletapis.LabelInstanceType] = instanceType
+	node.Labels[kubeletapis.LabelZoneFailureDomain] = zone
+	return node
+}
+
+func nodeNames(nodes []*apiv1.Node) []string {
+	result := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, node.Name)
+	}
+	return result
+}
+
+func TestSortByPayModel(t *testing.T) {
+	s1 := buildPayModelNode("s1", 2000, "spot", "m4.large", "us-east-1a")
+	o1 := buildPayModelNode("o1", 2000, "ondemand", "m4.large", "us-east-1a")
+	n1 := buildPayModelNode("n1", 2000, "", "m4.large", "us-east-1a")
+	s2 := buildPayModelNode("s2", 2000, "Spot", "m4.large", "us-east-1b")
+	o2 := buildPayModelNode("o2", 2000, "ondemand", "m4.large", "us-east-1b")
+
+	assert.Equal(t, []string{"o1", "o2", "n1", "s1", "s2"}, nodeNames(sortByPayModel([]*apiv1.Node{s1, o1, n1, s2, o2})))
+}
+
+// spotLimitedProvider limits spot in node group "limited" to 0%, 20% in a single market.
+type spotLimitedProvider struct {
+	*testprovider.TestCloudProvider
+}
+
+func (p *spotLimitedProvider) SpotLimits(nodeGroup cloudprovider.NodeGroup) (float64, float64) {
+	if nodeGroup.Id() == "limited" {
+		return 0, 20
+	}
+	return 100, 100
+}
+
+func TestSpotLimits(t *testing.T) {
+	provider := testprovider.NewTestCloudProvider(nil, nil)
+	provider.AddNodeGroup("limited", 1, 10, 1)
+	context := &AutoscalingContext{
+		AutoscalingOptions: AutoscalingOptions{MaxSpotPercentTotal: -1, MaxSpotPercentOneMarket: -1},
+		CloudProvider:      provider,
+	}
+	limited := provider.GetNodeGroup("limited")
+	total, oneMarket := spotLimits(context, limited)
+	assert.Equal(t, float64(100), total)
+	assert.Equal(t, float64(100), oneMarket)
+
+	// Limits of the cloud provider are used unless they are set.
+	context.CloudProvider = &spotLimitedProvider{provider}
+	total, oneMarket = spotLimits(context, limited)
+	assert.Equal(t, float64(0), total)
+	assert.Equal(t, float64(20), oneMarket)
+	total, oneMarket = spotLimits(context, nil)
+	assert.Equal(t, float64(100), total)
+	assert.Equal(t, float64(100), oneMarket)
+
+	context.MaxSpotPercentOneMarket = 50
+	total, oneMarket = spotLimits(context, limited)
+	assert.Equal(t, float64(0), total)
+	assert.Equal(t, float64(50), oneMarket)
+}
+
+func payModelContext(maxSpotPercentTotal, maxSpotPercentOneMarket float64) *AutoscalingContext {
+	return &AutoscalingContext{
+		AutoscalingOptions: AutoscalingOptions{
+			MaxSpotPercentTotal:     maxSpotPercentTotal,
+			MaxSpotPercentOneMarket: maxSpotPercentOneMarket,
+		},
+		CloudProvider: testprovider.NewTestCloudProvider(nil, nil),
+	}
+}
+
+func TestValidatePayModelPolicy(t *testing.T) {
+	assert.NoError(t, validatePayModelPolicy(NoPayModelPolicy))
+	assert.NoError(t, validatePayModelPolicy(OnDemandFirstPayModelPolicy))
+	assert.Error(t, validatePayModelPolicy("spot-first"))
+}
+
+func TestFilterByPayModelSpotTotal(t *testing.T) {
+	// 4 spot cores out of 8.
+	s1 := buildPayModelNode("s1", 2000, "spot", "m4.large", "us-east-1a")
+	s2 := buildPayModelNode("s2", 2000, "spot", "m4.large", "us-east-1b")
+	o1 := buildPayModelNode("o1", 2000, "ondemand", "m4.large", "us-east-1a")
+	o2 := buildPayModelNode("o2", 2000, "ondemand", "m4.large", "us-east-1b")
+	allNodes := []*apiv1.Node{s1, s2, o1, o2}
+
+	// Removing an on-demand node takes spot to 67%.
+	assert.Equal(t, []string{"o1", "o2", "s1", "s2"}, nodeNames(filterByPayModel(payModelContext(70, 100), allNodes, allNodes)))
+	assert.Equal(t, []string{"s1", "s2"}, nodeNames(filterByPayModel(payModelContext(60, 100), allNodes, allNodes)))
+
+	// Already above the limit: removing spot nodes is still allowed.
+	assert.Equal(t, []string{"s1", "s2"}, nodeNames(filterByPayModel(payModelContext(40, 100), allNodes, allNodes)))
+
+	// Removed together, the second on-demand node would take spot to 100%.
+	assert.Equal(t, []string{"o1", "s1", "s2"}, nodeNames(limitByPayModel(payModelContext(70, 100), []*apiv1.Node{o1, o2, s1, s2}, allNodes)))
+}
+
+func TestFilterByPayModelSpotMarket(t *testing.T) {
+	// Markets: m4.large/us-east-1a has 4 cores, m4.large/us-east-1b 2 cores, of 10.
+	s1 := buildPayModelNode("s1", 2000, "spot", "m4.large", "us-east-1a")
+	s2 := buildPayModelNode("s2", 2000, "spot", "m4.large", "us-east-1a")
+	s3 := buildPayModelNode("s3", 2000, "spot", "m4.large", "us-east-1b")
+	o1 := buildPayModelNode("o1", 2000, "ondemand", "m4.large", "us-east-1a")
+	o2 := buildPayModelNode("o2", 2000, "ondemand", "m4.large", "us-east-1a")
+	allNodes := []*apiv1.Node{s1, s2, s3, o1, o2}
+
+	// Removing an on-demand node or s3 takes us-east-1a to 50%, removing s1 or s2 keeps it at 25%.
+	assert.Equal(t, []string{"s1", "s2"}, nodeNames(filterByPayModel(payModelContext(100, 45), allNodes, allNodes)))
+	assert.Equal(t, []string{"o1", "o2", "s1", "s2", "s3"}, nodeNames(filterByPayModel(payModelContext(100, 50), allNodes, allNodes)))
+}
+
+func TestFilterByPayModelPerNodeGroup(t *testing.T) {
+	s1 := buildPayModelNode("s1", 2000, "spot", "m4.large", "us-east-1a")
+	s2 := buildPayModelNode("s2", 2000, "spot", "m4.large", "us-east-1b")
+	o1 := buildPayModelNode("o1", 2000, "ondemand", "m4.large", "us-east-1a")
+	o2 := buildPayModelNode("o2", 2000, "ondemand", "m4.large", "us-east-1b")
+	l1 := buildPayModelNode("l1", 2000, "ondemand", "p2.xlarge", "us-east-1a")
+	l2 := buildPayModelNode("l2", 2000, "ondemand", "p2.xlarge", "us-east-1a")
+	provider := testprovider.NewTestCloudProvider(nil, nil)
+	provider.AddNodeGroup("unlimited", 1, 10, 4)
+	provider.AddNodeGroup("limited", 1, 10, 2)
+	for _, node := range []*apiv1.Node{s1, s2, o1, o2} {
+		provider.AddNode("unlimited", node)
+	}
+	provider.AddNode("limited", l1)
+	provider.AddNode("limited", l2)
+	context := &AutoscalingContext{
+		AutoscalingOptions: AutoscalingOptions{MaxSpotPercentTotal: -1, MaxSpotPercentOneMarket: -1},
+		CloudProvider:      &spotLimitedProvider{provider},
+	}
+	allNodes := []*apiv1.Node{s1, s2, o1, o2, l1, l2}
+
+	// The 0% limit of the other node group doesn't keep on-demand nodes of the unlimited one.
+	assert.Equal(t, []string{"o1", "o2", "l1", "l2", "s1", "s2"}, nodeNames(filterByPayModel(context, allNodes, allNodes)))
+	assert.Equal(t, []string{"o1", "o2", "l1"}, nodeNames(limitByPayModel(context, []*apiv1.Node{o1, o2, l1}, allNodes)))
+
+	// With a spot node in the limited node group, its on-demand nodes have to stay.
+	ls := buildPayModelNode("ls", 2000, "spot", "p2.xlarge", "us-east-1a")
+	provider.AddNode("limited", ls)
+	allNodes = append(allNodes, ls)
+	assert.Equal(t, []string{"o1", "o2", "s1", "s2", "ls"}, nodeNames(filterByPayModel(context, allNodes, allNodes)))
+}
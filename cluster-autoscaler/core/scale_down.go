@@ -444,6 +444,9 @@ func (sd *ScaleDown) TryToScaleDown(allNodes []*apiv1.Node, pods []*apiv1.Pod, p
 			candidates = append(candidates, node)
 		}
 	}
+	if sd.context.ScaleDownPayModelPolicy == OnDemandFirstPayModelPolicy {
+		candidates = filterByPayModel(sd.context, candidates, nodesWithoutMaster)
+	}
 	if len(candidates) == 0 {
 		glog.V(1).Infof("No candidates for scale down")
 		return ScaleDownNoUnneeded, nil
@@ -453,6 +456,9 @@ func (sd *ScaleDown) TryToScaleDown(allNodes []*apiv1.Node, pods []*apiv1.Pod, p
 	// try to delete not-so-empty nodes, possibly killing some pods and allowing them
 	// to recreate on other nodes.
 	emptyNodes := getEmptyNodes(candidates, pods, sd.context.MaxEmptyBulkDelete, coresLeft, memoryLeft, sd.context.CloudProvider)
+	if sd.context.ScaleDownPayModelPolicy == OnDemandFirstPayModelPolicy {
+		emptyNodes = limitByPayModel(sd.context, emptyNodes, nodesWithoutMaster)
+	}
 	if len(emptyNodes) > 0 {
 		nodeDeletionStart := time.Now()
 		confirmation := make(chan errors.AutoscalerError, len(emptyNodes))
@@ -503,7 +509,8 @@ func (sd *ScaleDown) TryToScaleDown(allNodes []*apiv1.Node, pods []*apiv1.Pod, p
 	go func() {
 		// Finishing the delete process once this goroutine is over.
 		defer sd.nodeDeleteStatus.SetDeleteInProgress(false)
-		err := deleteNode(sd.context, toRemove.Node, toRemove.PodsToReschedule)
+		// Virtual pods keeping headroom were only moved in the simulation, there is nothing to evict.
+		err := deleteNode(sd.context, toRemove.Node, filterOutHeadroomPods(toRemove.PodsToReschedule))
 		if err != nil {
 			glog.Errorf("Failed to delete %s: %v", toRemove.Node.Name, err)
 			return
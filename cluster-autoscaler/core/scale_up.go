@@ -21,7 +21,6 @@ import (
 	"time"
 
 	apiv1 "k8s.io/api/core/v1"
-	extensionsv1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
 	"k8s.io/autoscaler/cluster-autoscaler/clusterstate"
@@ -40,10 +39,12 @@ import (
 )
 
 // ScaleUp tries to scale the cluster up. Return true if it found a way to increase the size,
-// false if it didn't and error if an error occurred. Assumes that all nodes in the cluster are
-// ready and in sync with instance groups.
+// false if it didn't and error if an error occurred. When only some node groups of the plan failed
+// to be scaled up, returns true along with the error. Assumes that all nodes in the cluster are
+// ready and in sync with instance groups. nodeInfos are the templates of the node groups, as
+// returned by GetNodeInfosForGroups.
 func ScaleUp(context *AutoscalingContext, unschedulablePods []*apiv1.Pod, nodes []*apiv1.Node,
-	daemonSets []*extensionsv1.DaemonSet) (bool, errors.AutoscalerError) {
+	nodeInfos map[string]*schedulercache.NodeInfo) (bool, errors.AutoscalerError) {
 	// From now on we only care about unschedulable pods that were marked after the newest
 	// node became available for the scheduler.
 	if len(unschedulablePods) == 0 {
@@ -59,12 +60,6 @@ func ScaleUp(context *AutoscalingContext, unschedulablePods []*apiv1.Pod, nodes
 		glogx.V(1).UpTo(loggingQuota).Infof("Pod %s/%s is unschedulable", pod.Namespace, pod.Name)
 	}
 	glogx.V(1).Over(loggingQuota).Infof("%v other pods are also unschedulable", -loggingQuota.Left())
-	nodeInfos, err := GetNodeInfosForGroups(nodes, context.CloudProvider, context.ClientSet,
-		daemonSets, context.PredicateChecker)
-	if err != nil {
-		return false, err.AddPrefix("failed to build node infos for node groups: ")
-	}
-
 	nodeGroups := context.CloudProvider.NodeGroups()
 
 	resourceLimiter, errCP := context.CloudProvider.GetResourceLimiter()
@@ -91,111 +86,43 @@ func ScaleUp(context *AutoscalingContext, unschedulablePods []*apiv1.Pod, nodes
 	}
 	glog.V(4).Infof("Upcoming %d nodes", len(upcomingNodes))
 
-	podsPassingPredicates := make(map[string][]*apiv1.Pod)
-	podsRemainUnschedulable := make(map[*apiv1.Pod]bool)
-	expansionOptions := make([]expander.Option, 0)
-
 	if context.AutoscalingOptions.NodeAutoprovisioningEnabled {
 		nodeGroups, nodeInfos = addAutoprovisionedCandidates(context, nodeGroups, nodeInfos, unschedulablePods)
 	}
 
-	for _, nodeGroup := range nodeGroups {
-		// Autoprovisioned node groups without nodes are created later so skip check for them.
-		if nodeGroup.Exist() && !context.ClusterStateRegistry.IsNodeGroupSafeToScaleUp(nodeGroup.Id(), now) {
-			glog.Warningf("Node group %s is not ready for scaleup", nodeGroup.Id())
-			continue
-		}
-
-		currentTargetSize, err := nodeGroup.TargetSize()
-		if err != nil {
-			glog.Errorf("Failed to get node group size: %v", err)
-			continue
-		}
-		if currentTargetSize >= nodeGroup.MaxSize() {
-			// skip this node group.
-			glog.V(4).Infof("Skipping node group %s - max size reached", nodeGroup.Id())
-			continue
-		}
-
-		nodeInfo, found := nodeInfos[nodeGroup.Id()]
-		if !found {
-			glog.Errorf("No node info for: %s", nodeGroup.Id())
-			continue
-		}
-
-		nodeCPU, nodeMemory, err := getNodeInfoCoresAndMemory(nodeInfo)
-		if err != nil {
-			glog.Errorf("Failed to get node resources: %v", err)
-		}
-		if nodeCPU > (resourceLimiter.GetMax(cloudprovider.ResourceNameCores) - coresTotal) {
-			// skip this node group
-			glog.V(4).Infof("Skipping node group %s - not enough cores limit left", nodeGroup.Id())
-			continue
-		}
-		if nodeMemory > (resourceLimiter.GetMax(cloudprovider.ResourceNameMemory) - memoryTotal) {
-			// skip this node group
-			glog.V(4).Infof("Skipping node group %s - not enough memory limit left", nodeGroup.Id())
-			continue
-		}
-
-		option := expander.Option{
-			NodeGroup: nodeGroup,
-			Pods:      make([]*apiv1.Pod, 0),
-		}
-
-		for _, pod := range unschedulablePods {
-			err = context.PredicateChecker.CheckPredicates(pod, nil, nodeInfo, simulator.ReturnVerboseError)
-			if err == nil {
-				option.Pods = append(option.Pods, pod)
-				podsRemainUnschedulable[pod] = false
-			} else {
-				glog.V(2).Infof("Scale-up predicate failed: %v", err)
-				if _, exists := podsRemainUnschedulable[pod]; !exists {
-					podsRemainUnschedulable[pod] = true
-				}
+	// Node groups are picked one after another, each for the pods the previous ones don't help,
+	// so that pods needing different kinds of nodes (e.g. with and without GPUs) don't wait for
+	// another loop. The plan is only executed once complete.
+	var steps []scaleUpStep
+	var podsRemainUnschedulable map[*apiv1.Pod]bool
+	remainingPods := unschedulablePods
+	scaledUpGroups := make(map[string]bool)
+	newNodesTotal := 0
+	// At most one autoprovisioned node group is created per loop.
+	createdNodeGroup := false
+	for len(remainingPods) > 0 {
+		candidateGroups := make([]cloudprovider.NodeGroup, 0, len(nodeGroups))
+		for _, nodeGroup := range nodeGroups {
+			if scaledUpGroups[nodeGroup.Id()] || (createdNodeGroup && !nodeGroup.Exist()) {
+				continue
 			}
+			candidateGroups = append(candidateGroups, nodeGroup)
 		}
-		passingPods := make([]*apiv1.Pod, len(option.Pods))
-		copy(passingPods, option.Pods)
-		podsPassingPredicates[nodeGroup.Id()] = passingPods
-
-		if len(option.Pods) > 0 {
-			if context.EstimatorName == estimator.BinpackingEstimatorName {
-				binpackingEstimator := estimator.NewBinpackingNodeEstimator(context.PredicateChecker)
-				option.NodeCount = binpackingEstimator.Estimate(option.Pods, nodeInfo, upcomingNodes)
-			} else if context.EstimatorName == estimator.BasicEstimatorName {
-				basicEstimator := estimator.NewBasicNodeEstimator()
-				for _, pod := range option.Pods {
-					basicEstimator.Add(pod)
-				}
-				option.NodeCount, option.Debug = basicEstimator.Estimate(nodeInfo.Node(), upcomingNodes)
-			} else {
-				glog.Fatalf("Unrecognized estimator: %s", context.EstimatorName)
-			}
-			if option.NodeCount > 0 {
-				expansionOptions = append(expansionOptions, option)
-			} else {
-				glog.V(2).Infof("No need for any nodes in %s", nodeGroup.Id())
-			}
-		} else {
-			glog.V(4).Infof("No pod can fit to %s", nodeGroup.Id())
+		expansionOptions, optionNodeInfos, podsPassingPredicates, remainUnschedulable := computeExpansionOptions(context, candidateGroups,
+			nodeInfos, remainingPods, upcomingNodes, resourceLimiter, coresTotal, memoryTotal, now)
+		if podsRemainUnschedulable == nil {
+			podsRemainUnschedulable = remainUnschedulable
 		}
-	}
-
-	if len(expansionOptions) == 0 {
-		glog.V(1).Info("No expansion options")
-		for pod, unschedulable := range podsRemainUnschedulable {
-			if unschedulable {
-				context.Recorder.Event(pod, apiv1.EventTypeNormal, "NotTriggerScaleUp",
-					"pod didn't trigger scale-up (it wouldn't fit if a new node is added)")
-			}
+		if len(expansionOptions) == 0 {
+			glog.V(1).Info("No expansion options")
+			break
 		}
-		return false, nil
-	}
 
-	// Pick some expansion option.
-	bestOption := context.ExpanderStrategy.BestOption(expansionOptions, nodeInfos)
-	if bestOption != nil && bestOption.NodeCount > 0 {
+		// Pick some expansion option.
+		bestOption := context.ExpanderStrategy.BestOption(expansionOptions, optionNodeInfos)
+		if bestOption == nil || bestOption.NodeCount <= 0 {
+			break
+		}
 		glog.V(1).Infof("Best option to resize: %s", bestOption.NodeGroup.Id())
 		if len(bestOption.Debug) > 0 {
 			glog.V(1).Info(bestOption.Debug)
@@ -204,55 +131,44 @@ func ScaleUp(context *AutoscalingContext, unschedulablePods []*apiv1.Pod, nodes
 
 		newNodes := bestOption.NodeCount
 
-		if context.MaxNodesTotal > 0 && len(nodes)+newNodes > context.MaxNodesTotal {
+		if context.MaxNodesTotal > 0 && len(nodes)+newNodesTotal+newNodes > context.MaxNodesTotal {
 			glog.V(1).Infof("Capping size to max cluster total size (%d)", context.MaxNodesTotal)
-			newNodes = context.MaxNodesTotal - len(nodes)
+			newNodes = context.MaxNodesTotal - len(nodes) - newNodesTotal
 			if newNodes < 1 {
+				if len(steps) > 0 {
+					break
+				}
 				return false, errors.NewAutoscalerError(
 					errors.TransientError,
 					"max node total count already reached")
 			}
 		}
-		if context.AutoscalingOptions.NodeAutoprovisioningEnabled {
-			if !bestOption.NodeGroup.Exist() {
-				// Node group id may change when we create node group and we need to update
-				// our data structures
-				oldId := bestOption.NodeGroup.Id()
-				err := bestOption.NodeGroup.Create()
-				if err != nil {
-					context.LogRecorder.Eventf(apiv1.EventTypeWarning, "FailedToCreateNodeGroup",
-						"NodeAutoprovisioning: attempt to create node group %v failed: %v", oldId, err)
-					// TODO(maciekpytel): add some metric here after figuring out failure scenarios
-					return false, errors.ToAutoscalerError(errors.CloudProviderError, err)
-				}
-				newId := bestOption.NodeGroup.Id()
-				if newId != oldId {
-					glog.V(2).Infof("Created node group %s based on template node group %s, will use new node group in scale-up", newId, oldId)
-					podsPassingPredicates[newId] = podsPassingPredicates[oldId]
-					delete(podsPassingPredicates, oldId)
-					nodeInfos[newId] = nodeInfos[oldId]
-					delete(nodeInfos, oldId)
-				}
-				context.LogRecorder.Eventf(apiv1.EventTypeNormal, "CreatedNodeGroup",
-					"NodeAutoprovisioning: created new node group %v", newId)
-				metrics.RegisterNodeGroupCreation()
-
-			}
+		// Autoprovisioned node groups are only created when the plan is executed.
+		var createGroup cloudprovider.NodeGroup
+		if context.AutoscalingOptions.NodeAutoprovisioningEnabled && !bestOption.NodeGroup.Exist() {
+			createGroup = bestOption.NodeGroup
+			createdNodeGroup = true
 		}
 
-		nodeInfo, found := nodeInfos[bestOption.NodeGroup.Id()]
+		nodeInfo, found := optionNodeInfos[bestOption.NodeGroup.Id()]
 		if !found {
 			// This should never happen, as we already should have retrieved
 			// nodeInfo for any considered nodegroup.
 			glog.Errorf("No node info for: %s", bestOption.NodeGroup.Id())
+			if len(steps) > 0 {
+				break
+			}
 			return false, errors.NewAutoscalerError(
 				errors.CloudProviderError,
 				"No node info for best expansion option!")
 		}
 
 		// apply upper limits for CPU and memory
-		newNodes, err = applyMaxClusterCoresMemoryLimits(newNodes, coresTotal, memoryTotal, resourceLimiter.GetMax(cloudprovider.ResourceNameCores), resourceLimiter.GetMax(cloudprovider.ResourceNameMemory), nodeInfo)
+		newNodes, err := applyMaxClusterCoresMemoryLimits(newNodes, coresTotal, memoryTotal, resourceLimiter.GetMax(cloudprovider.ResourceNameCores), resourceLimiter.GetMax(cloudprovider.ResourceNameMemory), nodeInfo)
 		if err != nil {
+			if len(steps) > 0 {
+				break
+			}
 			return false, err
 		}
 
@@ -260,6 +176,10 @@ func ScaleUp(context *AutoscalingContext, unschedulablePods []*apiv1.Pod, nodes
 		if context.BalanceSimilarNodeGroups {
 			similarNodeGroups, typedErr := nodegroupset.FindSimilarNodeGroups(bestOption.NodeGroup, context.CloudProvider, nodeInfos)
 			if typedErr != nil {
+				if len(steps) > 0 {
+					glog.Errorf("Failed to find matching node groups: %v", typedErr)
+					break
+				}
 				return false, typedErr.AddPrefix("Failed to find matching node groups: ")
 			}
 			similarNodeGroups = filterNodeGroupsByPods(similarNodeGroups, bestOption.Pods, podsPassingPredicates)
@@ -287,32 +207,260 @@ func ScaleUp(context *AutoscalingContext, unschedulablePods []*apiv1.Pod, nodes
 		scaleUpInfos, typedErr := nodegroupset.BalanceScaleUpBetweenGroups(
 			targetNodeGroups, newNodes)
 		if typedErr != nil {
+			if len(steps) > 0 {
+				glog.Errorf("Failed to balance scale-up between node groups: %v", typedErr)
+				break
+			}
 			return false, typedErr
 		}
-		glog.V(1).Infof("Final scale-up plan: %v", scaleUpInfos)
-		for _, info := range scaleUpInfos {
-			typedErr := executeScaleUp(context, info)
-			if typedErr != nil {
-				return false, typedErr
+		steps = append(steps, scaleUpStep{pods: bestOption.Pods, infos: scaleUpInfos, createGroup: createGroup})
+
+		// Account for the new nodes when looking for node groups for the remaining pods.
+		for _, ng := range targetNodeGroups {
+			scaledUpGroups[ng.Id()] = true
+		}
+		newNodesTotal += newNodes
+		if nodeCPU, nodeMemory, err := getNodeInfoCoresAndMemory(nodeInfo); err == nil {
+			coresTotal += int64(newNodes) * nodeCPU
+			memoryTotal += int64(newNodes) * nodeMemory
+		}
+		for i := 0; i < newNodes; i++ {
+			upcomingNodes = append(upcomingNodes, nodeInfo)
+		}
+		remainingPods = podsNotIn(remainingPods, bestOption.Pods)
+		if newNodes < bestOption.NodeCount {
+			glog.V(1).Infof("Cluster limits reached, not looking for node groups for %d remaining pods", len(remainingPods))
+			break
+		}
+	}
+
+	if len(steps) == 0 {
+		for pod, unschedulable := range podsRemainUnschedulable {
+			if unschedulable {
+				context.Recorder.Event(pod, apiv1.EventTypeNormal, "NotTriggerScaleUp",
+					"pod didn't trigger scale-up (it wouldn't fit if a new node is added)")
 			}
 		}
+		return false, nil
+	}
 
-		for _, pod := range bestOption.Pods {
+	var plan []nodegroupset.ScaleUpInfo
+	for _, step := range steps {
+		plan = append(plan, step.infos...)
+	}
+	glog.V(1).Infof("Final scale-up plan: %v", plan)
+	// A failed step doesn't undo the earlier ones, nor prevent the following ones.
+	errs := make([]errors.AutoscalerError, 0)
+	scaledUp := false
+	for _, step := range steps {
+		if step.createGroup != nil {
+			if typedErr := createNodeGroup(context, step.createGroup); typedErr != nil {
+				errs = append(errs, typedErr)
+				continue
+			}
+		}
+		scaledUpInfos := make([]nodegroupset.ScaleUpInfo, 0, len(step.infos))
+		for _, info := range step.infos {
+			if typedErr := executeScaleUp(context, info, step.pods); typedErr != nil {
+				errs = append(errs, typedErr)
+				continue
+			}
+			scaledUpInfos = append(scaledUpInfos, info)
+		}
+		if len(scaledUpInfos) == 0 {
+			continue
+		}
+		scaledUp = true
+		for _, pod := range step.pods {
+			if isHeadroomPod(pod) {
+				continue
+			}
 			context.Recorder.Eventf(pod, apiv1.EventTypeNormal, "TriggeredScaleUp",
-				"pod triggered scale-up: %v", scaleUpInfos)
+				"pod triggered scale-up: %v", scaledUpInfos)
 		}
+	}
 
+	if scaledUp {
 		context.ClusterStateRegistry.Recalculate()
-		return true, nil
 	}
-	for pod, unschedulable := range podsRemainUnschedulable {
-		if unschedulable {
-			context.Recorder.Event(pod, apiv1.EventTypeNormal, "NotTriggerScaleUp",
-				"pod didn't trigger scale-up (it wouldn't fit if a new node is added)")
+	if len(errs) == 1 {
+		return scaledUp, errs[0]
+	}
+	if len(errs) > 0 {
+		return scaledUp, errors.NewAutoscalerError(errors.CloudProviderError,
+			"failed to execute scale-up plan, due to following errors: %v", errs)
+	}
+	return scaledUp, nil
+}
+
+// scaleUpStep is the scale-up of the node groups chosen for some of the pods.
+type scaleUpStep struct {
+	pods  []*apiv1.Pod
+	infos []nodegroupset.ScaleUpInfo
+	// createGroup is the autoprovisioned node group to create before the scale-up, if any.
+	createGroup cloudprovider.NodeGroup
+}
+
+// computeExpansionOptions returns the options of expanding each of the node groups for the pods,
+// along with the node infos the options were estimated with, the pods passing predicates on each
+// node group, and whether each pod fits none of them. nodeInfos is not modified.
+func computeExpansionOptions(context *AutoscalingContext, nodeGroups []cloudprovider.NodeGroup,
+	nodeInfos map[string]*schedulercache.NodeInfo, unschedulablePods []*apiv1.Pod, upcomingNodes []*schedulercache.NodeInfo,
+	resourceLimiter *cloudprovider.ResourceLimiter, coresTotal, memoryTotal int64,
+	now time.Time) ([]expander.Option, map[string]*schedulercache.NodeInfo, map[string][]*apiv1.Pod, map[*apiv1.Pod]bool) {
+	optionNodeInfos := make(map[string]*schedulercache.NodeInfo, len(nodeInfos))
+	for id, nodeInfo := range nodeInfos {
+		optionNodeInfos[id] = nodeInfo
+	}
+	podsPassingPredicates := make(map[string][]*apiv1.Pod)
+	podsRemainUnschedulable := make(map[*apiv1.Pod]bool)
+	expansionOptions := make([]expander.Option, 0)
+
+	for _, nodeGroup := range nodeGroups {
+		// Autoprovisioned node groups without nodes are created later so skip check for them.
+		if nodeGroup.Exist() && !context.ClusterStateRegistry.IsNodeGroupSafeToScaleUp(nodeGroup.Id(), now) {
+			glog.Warningf("Node group %s is not ready for scaleup", nodeGroup.Id())
+			continue
+		}
+
+		currentTargetSize, err := nodeGroup.TargetSize()
+		if err != nil {
+			glog.Errorf("Failed to get node group size: %v", err)
+			continue
+		}
+		if currentTargetSize >= nodeGroup.MaxSize() {
+			// skip this node group.
+			glog.V(4).Infof("Skipping node group %s - max size reached", nodeGroup.Id())
+			continue
+		}
+
+		nodeInfo, found := nodeInfos[nodeGroup.Id()]
+		if !found {
+			glog.Errorf("No node info for: %s", nodeGroup.Id())
+			continue
+		}
+
+		nodeCPU, nodeMemory, err := getNodeInfoCoresAndMemory(nodeInfo)
+		if err != nil {
+			glog.Errorf("Failed to get node resources: %v", err)
+		}
+		if nodeCPU > (resourceLimiter.GetMax(cloudprovider.ResourceNameCores) - coresTotal) {
+			// skip this node group
+			glog.V(4).Infof("Skipping node group %s - not enough cores limit left", nodeGroup.Id())
+			continue
+		}
+		if nodeMemory > (resourceLimiter.GetMax(cloudprovider.ResourceNameMemory) - memoryTotal) {
+			// skip this node group
+			glog.V(4).Infof("Skipping node group %s - not enough memory limit left", nodeGroup.Id())
+			continue
+		}
+
+		option := expander.Option{
+			NodeGroup: nodeGroup,
+			Pods:      make([]*apiv1.Pod, 0),
+		}
+
+		// New nodes of node groups spread across zones are placed by the cloud provider. A pod
+		// only fits if it fits in one of the zones the next node may be placed in.
+		zoneInfos, scaleUpZones := zoneNodeInfos(nodeGroup, nodeInfo)
+		candidates := []*schedulercache.NodeInfo{nodeInfo}
+		if zoneInfos != nil {
+			candidates = make([]*schedulercache.NodeInfo, 0, len(scaleUpZones))
+			for _, zone := range scaleUpZones {
+				candidates = append(candidates, zoneInfos[zone])
+			}
+		}
+		candidatePods := make([][]*apiv1.Pod, len(candidates))
+		for _, pod := range unschedulablePods {
+			if headroomGroup, isHeadroom := headroomNodeGroup(pod); isHeadroom {
+				// Headroom is only kept in its own node group.
+				if headroomGroup != nodeGroup.Id() {
+					continue
+				}
+				for i, candidate := range candidates {
+					if context.PredicateChecker.CheckPredicates(pod, nil, candidate, simulator.ReturnSimpleError) == nil {
+						candidatePods[i] = append(candidatePods[i], pod)
+					}
+				}
+				continue
+			}
+			fits := false
+			for i, candidate := range candidates {
+				err = context.PredicateChecker.CheckPredicates(pod, nil, candidate, simulator.ReturnVerboseError)
+				if err == nil {
+					candidatePods[i] = append(candidatePods[i], pod)
+					fits = true
+				} else {
+					glog.V(2).Infof("Scale-up predicate failed: %v", err)
+				}
+			}
+			if fits {
+				podsRemainUnschedulable[pod] = false
+			} else if _, exists := podsRemainUnschedulable[pod]; !exists {
+				podsRemainUnschedulable[pod] = true
+			}
+		}
+		best := 0
+		for i := range candidates {
+			if len(candidatePods[i]) > len(candidatePods[best]) {
+				best = i
+			}
+		}
+		if zoneInfos != nil && candidates[best] != nodeInfo {
+			nodeInfo = candidates[best]
+			optionNodeInfos[nodeGroup.Id()] = nodeInfo
+		}
+		option.Pods = append(option.Pods, candidatePods[best]...)
+		zoneConstrained := zoneInfos != nil && !podsFitAllZones(context.PredicateChecker, option.Pods, zoneInfos)
+
+		passingPods := make([]*apiv1.Pod, len(option.Pods))
+		copy(passingPods, option.Pods)
+		podsPassingPredicates[nodeGroup.Id()] = passingPods
+
+		if len(option.Pods) > 0 {
+			if context.EstimatorName == estimator.BinpackingEstimatorName {
+				binpackingEstimator := estimator.NewBinpackingNodeEstimator(context.PredicateChecker)
+				option.NodeCount = binpackingEstimator.Estimate(option.Pods, nodeInfo, upcomingNodes)
+			} else if context.EstimatorName == estimator.BasicEstimatorName {
+				basicEstimator := estimator.NewBasicNodeEstimator()
+				for _, pod := range option.Pods {
+					basicEstimator.Add(pod)
+				}
+				option.NodeCount, option.Debug = basicEstimator.Estimate(nodeInfo.Node(), upcomingNodes)
+			} else {
+				glog.Fatalf("Unrecognized estimator: %s", context.EstimatorName)
+			}
+			if zoneConstrained && option.NodeCount > 1 {
+				// Further nodes are placed in other zones, where the pods don't fit.
+				glog.V(2).Infof("Capping scale-up of %s to 1 node, pods are constrained to a zone", nodeGroup.Id())
+				option.NodeCount = 1
+			}
+			if option.NodeCount > 0 {
+				expansionOptions = append(expansionOptions, option)
+			} else {
+				glog.V(2).Infof("No need for any nodes in %s", nodeGroup.Id())
+			}
+		} else {
+			glog.V(4).Infof("No pod can fit to %s", nodeGroup.Id())
 		}
 	}
 
-	return false, nil
+	return expansionOptions, optionNodeInfos, podsPassingPredicates, podsRemainUnschedulable
+}
+
+// podsNotIn returns the pods that are not in excluded.
+func podsNotIn(pods []*apiv1.Pod, excluded []*apiv1.Pod) []*apiv1.Pod {
+	excludedSet := make(map[*apiv1.Pod]bool, len(excluded))
+	for _, pod := range excluded {
+		excludedSet[pod] = true
+	}
+	result := make([]*apiv1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if !excludedSet[pod] {
+			result = append(result, pod)
+		}
+	}
+	return result
 }
 
 func filterNodeGroupsByPods(groups []cloudprovider.NodeGroup, podsRequiredToFit []*apiv1.Pod,
@@ -340,10 +488,43 @@ groupsloop:
 	return result
 }
 
-func executeScaleUp(context *AutoscalingContext, info nodegroupset.ScaleUpInfo) errors.AutoscalerError {
+// createNodeGroup creates an autoprovisioned node group. Its id may change in the process.
+func createNodeGroup(context *AutoscalingContext, nodeGroup cloudprovider.NodeGroup) errors.AutoscalerError {
+	oldId := nodeGroup.Id()
+	if err := nodeGroup.Create(); err != nil {
+		context.LogRecorder.Eventf(apiv1.EventTypeWarning, "FailedToCreateNodeGroup",
+			"NodeAutoprovisioning: attempt to create node group %v failed: %v", oldId, err)
+		// TODO(maciekpytel): add some metric here after figuring out failure scenarios
+		return errors.ToAutoscalerError(errors.CloudProviderError, err)
+	}
+	newId := nodeGroup.Id()
+	if newId != oldId {
+		glog.V(2).Infof("Created node group %s based on template node group %s, will use new node group in scale-up", newId, oldId)
+	}
+	context.LogRecorder.Eventf(apiv1.EventTypeNormal, "CreatedNodeGroup",
+		"NodeAutoprovisioning: created new node group %v", newId)
+	metrics.RegisterNodeGroupCreation()
+	return nil
+}
+
+// podCapacityIncreaser is implemented by node groups sizing a scale-up from the requests of the
+// pods it is for, like AutoScalr apps requesting capacity rather than nodes.
+type podCapacityIncreaser interface {
+	IncreaseSizeForPods(delta int, pods []*apiv1.Pod) error
+}
+
+// executeScaleUp increases the size of the node group for the pods. When the scale-up of the pods
+// is balanced between several node groups, each of them gets all the pods.
+func executeScaleUp(context *AutoscalingContext, info nodegroupset.ScaleUpInfo, pods []*apiv1.Pod) errors.AutoscalerError {
 	glog.V(0).Infof("Scale-up: setting group %s size to %d", info.Group.Id(), info.NewSize)
 	increase := info.NewSize - info.CurrentSize
-	if err := info.Group.IncreaseSize(increase); err != nil {
+	var err error
+	if increaser, ok := info.Group.(podCapacityIncreaser); ok {
+		err = increaser.IncreaseSizeForPods(increase, pods)
+	} else {
+		err = info.Group.IncreaseSize(increase)
+	}
+	if err != nil {
 		context.LogRecorder.Eventf(apiv1.EventTypeWarning, "FailedToScaleUpGroup", "Scale-up failed for group %s: %v", info.Group.Id(), err)
 		context.ClusterStateRegistry.RegisterFailedScaleUp(info.Group.Id(), metrics.APIError)
 		return errors.NewAutoscalerError(errors.CloudProviderError,
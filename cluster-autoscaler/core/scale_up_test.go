@@ -19,6 +19,7 @@ package core
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -40,6 +41,7 @@ import (
 	"k8s.io/client-go/kubernetes/fake"
 	core "k8s.io/client-go/testing"
 	kube_record "k8s.io/client-go/tools/record"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
 	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
 
 	"github.com/stretchr/testify/assert"
@@ -248,7 +250,9 @@ func simpleScaleUpTest(t *testing.T, config *scaleTestConfig) {
 		extraPods[i] = pod
 	}
 
-	result, err := ScaleUp(context, extraPods, nodes, []*extensionsv1.DaemonSet{})
+	nodeInfos, _ := GetNodeInfosForGroups(nodes, context.CloudProvider, context.ClientSet,
+		[]*extensionsv1.DaemonSet{}, context.PredicateChecker)
+	result, err := ScaleUp(context, extraPods, nodes, nodeInfos)
 	assert.NoError(t, err)
 	assert.True(t, result)
 
@@ -329,7 +333,9 @@ func TestScaleUpNodeComingNoScale(t *testing.T) {
 	}
 	p3 := BuildTestPod("p-new", 550, 0)
 
-	result, err := ScaleUp(context, []*apiv1.Pod{p3}, []*apiv1.Node{n1, n2}, []*extensionsv1.DaemonSet{})
+	nodeInfos, _ := GetNodeInfosForGroups([]*apiv1.Node{n1, n2}, context.CloudProvider, context.ClientSet,
+		[]*extensionsv1.DaemonSet{}, context.PredicateChecker)
+	result, err := ScaleUp(context, []*apiv1.Pod{p3}, []*apiv1.Node{n1, n2}, nodeInfos)
 	assert.NoError(t, err)
 	// A node is already coming - no need for scale up.
 	assert.False(t, result)
@@ -392,7 +398,9 @@ func TestScaleUpNodeComingHasScale(t *testing.T) {
 	}
 	p3 := BuildTestPod("p-new", 550, 0)
 
-	result, err := ScaleUp(context, []*apiv1.Pod{p3, p3}, []*apiv1.Node{n1, n2}, []*extensionsv1.DaemonSet{})
+	nodeInfos, _ := GetNodeInfosForGroups([]*apiv1.Node{n1, n2}, context.CloudProvider, context.ClientSet,
+		[]*extensionsv1.DaemonSet{}, context.PredicateChecker)
+	result, err := ScaleUp(context, []*apiv1.Pod{p3, p3}, []*apiv1.Node{n1, n2}, nodeInfos)
 	assert.NoError(t, err)
 	// Two nodes needed but one node is already coming, so it should increase by one.
 	assert.True(t, result)
@@ -452,7 +460,9 @@ func TestScaleUpUnhealthy(t *testing.T) {
 	}
 	p3 := BuildTestPod("p-new", 550, 0)
 
-	result, err := ScaleUp(context, []*apiv1.Pod{p3}, []*apiv1.Node{n1, n2}, []*extensionsv1.DaemonSet{})
+	nodeInfos, _ := GetNodeInfosForGroups([]*apiv1.Node{n1, n2}, context.CloudProvider, context.ClientSet,
+		[]*extensionsv1.DaemonSet{}, context.PredicateChecker)
+	result, err := ScaleUp(context, []*apiv1.Pod{p3}, []*apiv1.Node{n1, n2}, nodeInfos)
 	assert.NoError(t, err)
 	// Node group is unhealthy.
 	assert.False(t, result)
@@ -503,7 +513,9 @@ func TestScaleUpNoHelp(t *testing.T) {
 	}
 	p3 := BuildTestPod("p-new", 500, 0)
 
-	result, err := ScaleUp(context, []*apiv1.Pod{p3}, []*apiv1.Node{n1}, []*extensionsv1.DaemonSet{})
+	nodeInfos, _ := GetNodeInfosForGroups([]*apiv1.Node{n1}, context.CloudProvider, context.ClientSet,
+		[]*extensionsv1.DaemonSet{}, context.PredicateChecker)
+	result, err := ScaleUp(context, []*apiv1.Pod{p3}, []*apiv1.Node{n1}, nodeInfos)
 	assert.NoError(t, err)
 	assert.False(t, result)
 	var event string
@@ -515,6 +527,216 @@ func TestScaleUpNoHelp(t *testing.T) {
 	assert.Regexp(t, regexp.MustCompile("NotTriggerScaleUp"), event)
 }
 
+func TestScaleUpSeveralNodeGroups(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000*MB)
+	SetNodeReadyState(n1, true, time.Now())
+	n2 := BuildTestNode("n2", 4000, 100*MB)
+	SetNodeReadyState(n2, true, time.Now())
+	nodes := []*apiv1.Node{n1, n2}
+
+	// Each pod only fits one of the node groups.
+	pCpu := BuildTestPod("p-cpu", 3000, 50*MB)
+	pMem := BuildTestPod("p-mem", 500, 800*MB)
+
+	scaleUp := func(maxNodesTotal int) ([]string, []string) {
+		fakeClient := &fake.Clientset{}
+		fakeClient.Fake.AddReactor("list", "pods", func(action core.Action) (bool, runtime.Object, error) {
+			return true, &apiv1.PodList{Items: []apiv1.Pod{}}, nil
+		})
+		expandedGroups := make(chan string, 10)
+		provider := testprovider.NewTestCloudProvider(func(nodeGroup string, increase int) error {
+			expandedGroups <- fmt.Sprintf("%s-%d", nodeGroup, increase)
+			return nil
+		}, nil)
+		provider.AddNodeGroup("ng1", 1, 10, 1)
+		provider.AddNode("ng1", n1)
+		provider.AddNodeGroup("ng2", 1, 10, 1)
+		provider.AddNode("ng2", n2)
+
+		fakeRecorder := kube_record.NewFakeRecorder(5)
+		fakeLogRecorder, _ := utils.NewStatusMapRecorder(fakeClient, "kube-system", kube_record.NewFakeRecorder(5), false)
+		clusterState := clusterstate.NewClusterStateRegistry(provider, clusterstate.ClusterStateRegistryConfig{}, fakeLogRecorder)
+		clusterState.UpdateNodes(nodes, time.Now())
+		options := defaultOptions
+		options.MaxNodesTotal = maxNodesTotal
+		context := &AutoscalingContext{
+			AutoscalingOptions:   options,
+			PredicateChecker:     simulator.NewTestPredicateChecker(),
+			CloudProvider:        provider,
+			ClientSet:            fakeClient,
+			Recorder:             fakeRecorder,
+			ExpanderStrategy:     random.NewStrategy(),
+			ClusterStateRegistry: clusterState,
+			LogRecorder:          fakeLogRecorder,
+		}
+
+		nodeInfos, _ := GetNodeInfosForGroups(nodes, context.CloudProvider, context.ClientSet,
+			[]*extensionsv1.DaemonSet{}, context.PredicateChecker)
+		result, err := ScaleUp(context, []*apiv1.Pod{pCpu, pMem}, nodes, nodeInfos)
+		assert.NoError(t, err)
+		assert.True(t, result)
+
+		var expanded, events []string
+		for len(expandedGroups) > 0 {
+			expanded = append(expanded, <-expandedGroups)
+		}
+		for len(fakeRecorder.Events) > 0 {
+			events = append(events, <-fakeRecorder.Events)
+		}
+		sort.Strings(expanded)
+		return expanded, events
+	}
+
+	// Both node groups are grown in a single loop.
+	expanded, events := scaleUp(0)
+	assert.Equal(t, []string{"ng1-1", "ng2-1"}, expanded)
+	assert.Equal(t, 2, len(events))
+	for _, event := range events {
+		assert.Contains(t, event, "TriggeredScaleUp")
+	}
+
+	// The total number of new nodes is capped.
+	expanded, events = scaleUp(3)
+	assert.Equal(t, 1, len(expanded))
+	assert.Equal(t, 1, len(events))
+}
+
+func TestScaleUpSeveralNodeGroupsPartialFailure(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000*MB)
+	SetNodeReadyState(n1, true, time.Now())
+	n2 := BuildTestNode("n2", 4000, 100*MB)
+	SetNodeReadyState(n2, true, time.Now())
+	nodes := []*apiv1.Node{n1, n2}
+
+	// Each pod only fits one of the node groups.
+	pCpu := BuildTestPod("p-cpu", 3000, 50*MB)
+	pMem := BuildTestPod("p-mem", 500, 800*MB)
+
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("list", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, &apiv1.PodList{Items: []apiv1.Pod{}}, nil
+	})
+	expandedGroups := make(chan string, 10)
+	increases := 0
+	provider := testprovider.NewTestCloudProvider(func(nodeGroup string, increase int) error {
+		// The second step of the plan fails.
+		increases++
+		if increases == 2 {
+			return fmt.Errorf("quota exceeded")
+		}
+		expandedGroups <- nodeGroup
+		return nil
+	}, nil)
+	provider.AddNodeGroup("ng1", 1, 10, 1)
+	provider.AddNode("ng1", n1)
+	provider.AddNodeGroup("ng2", 1, 10, 1)
+	provider.AddNode("ng2", n2)
+
+	fakeRecorder := kube_record.NewFakeRecorder(5)
+	fakeLogRecorder, _ := utils.NewStatusMapRecorder(fakeClient, "kube-system", kube_record.NewFakeRecorder(5), false)
+	clusterState := clusterstate.NewClusterStateRegistry(provider, clusterstate.ClusterStateRegistryConfig{}, fakeLogRecorder)
+	clusterState.UpdateNodes(nodes, time.Now())
+	context := &AutoscalingContext{
+		AutoscalingOptions:   defaultOptions,
+		PredicateChecker:     simulator.NewTestPredicateChecker(),
+		CloudProvider:        provider,
+		ClientSet:            fakeClient,
+		Recorder:             fakeRecorder,
+		ExpanderStrategy:     random.NewStrategy(),
+		ClusterStateRegistry: clusterState,
+		LogRecorder:          fakeLogRecorder,
+	}
+
+	nodeInfos, _ := GetNodeInfosForGroups(nodes, context.CloudProvider, context.ClientSet,
+		[]*extensionsv1.DaemonSet{}, context.PredicateChecker)
+	result, err := ScaleUp(context, []*apiv1.Pod{pCpu, pMem}, nodes, nodeInfos)
+	assert.Error(t, err)
+	assert.True(t, result)
+	assert.Equal(t, 2, increases)
+
+	// The first step is kept, and only its pod triggered a scale-up.
+	expanded := getStringFromChan(expandedGroups)
+	assert.Equal(t, 0, len(expandedGroups))
+	assert.Equal(t, 1, len(fakeRecorder.Events))
+	event := <-fakeRecorder.Events
+	assert.Contains(t, event, "TriggeredScaleUp")
+	scaledUpPod := map[string]string{"ng1": "p-mem", "ng2": "p-cpu"}[expanded]
+	assert.Contains(t, event, scaledUpPod)
+	// The cluster state was recalculated after the scale-up.
+	assert.Equal(t, 1, clusterState.GetUpcomingNodes()[expanded])
+}
+
+func TestScaleUpZonal(t *testing.T) {
+	fakeClient := &fake.Clientset{}
+	n1 := BuildTestNode("n1", 1000, 1000)
+	n1.Labels[kubeletapis.LabelZoneFailureDomain] = "us-east-1a"
+	SetNodeReadyState(n1, true, time.Now())
+
+	p1 := BuildTestPod("p1", 800, 0)
+	p1.Spec.NodeName = "n1"
+
+	fakeClient.Fake.AddReactor("list", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		list := action.(core.ListAction)
+		fieldstring := list.GetListRestrictions().Fields.String()
+		if strings.Contains(fieldstring, "n1") {
+			return true, &apiv1.PodList{Items: []apiv1.Pod{*p1}}, nil
+		}
+		return true, nil, fmt.Errorf("Failed to list: %v", list)
+	})
+
+	expandedGroups := make(chan string, 10)
+	provider := testprovider.NewTestCloudProvider(func(nodeGroup string, increase int) error {
+		expandedGroups <- fmt.Sprintf("%s-%d", nodeGroup, increase)
+		return nil
+	}, nil)
+	provider.AddNodeGroup("ng1", 1, 10, 1)
+	provider.AddNode("ng1", n1)
+	// The next node is placed in us-east-1b, which has fewer nodes.
+	provider.SetNodeGroupZones("ng1", []string{"us-east-1a", "us-east-1b"}, []string{"us-east-1b"})
+
+	fakeRecorder := kube_record.NewFakeRecorder(5)
+	fakeLogRecorder, _ := utils.NewStatusMapRecorder(fakeClient, "kube-system", kube_record.NewFakeRecorder(5), false)
+	clusterState := clusterstate.NewClusterStateRegistry(provider, clusterstate.ClusterStateRegistryConfig{}, fakeLogRecorder)
+	clusterState.UpdateNodes([]*apiv1.Node{n1}, time.Now())
+	context := &AutoscalingContext{
+		AutoscalingOptions: AutoscalingOptions{
+			EstimatorName:  estimator.BinpackingEstimatorName,
+			MaxCoresTotal:  config.DefaultMaxClusterCores,
+			MaxMemoryTotal: config.DefaultMaxClusterMemory,
+		},
+		PredicateChecker:     simulator.NewTestPredicateChecker(),
+		CloudProvider:        provider,
+		ClientSet:            fakeClient,
+		Recorder:             fakeRecorder,
+		ExpanderStrategy:     random.NewStrategy(),
+		ClusterStateRegistry: clusterState,
+		LogRecorder:          fakeLogRecorder,
+	}
+	zonalPod := func(name string, zone string) *apiv1.Pod {
+		pod := BuildTestPod(name, 800, 0)
+		pod.Spec.NodeSelector = map[string]string{kubeletapis.LabelZoneFailureDomain: zone}
+		return pod
+	}
+
+	// A node added now can't be in us-east-1a.
+	nodeInfos, _ := GetNodeInfosForGroups([]*apiv1.Node{n1}, context.CloudProvider, context.ClientSet,
+		[]*extensionsv1.DaemonSet{}, context.PredicateChecker)
+	template := nodeInfos["ng1"]
+	result, err := ScaleUp(context, []*apiv1.Pod{zonalPod("pa", "us-east-1a")}, []*apiv1.Node{n1}, nodeInfos)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// Only the first new node is in us-east-1b.
+	result, err = ScaleUp(context, []*apiv1.Pod{zonalPod("pb1", "us-east-1b"), zonalPod("pb2", "us-east-1b")},
+		[]*apiv1.Node{n1}, nodeInfos)
+	assert.NoError(t, err)
+	assert.True(t, result)
+	assert.Equal(t, "ng1-1", getStringFromChan(expandedGroups))
+
+	// The templates shared with the rest of the loop aren't pinned to a zone.
+	assert.True(t, template == nodeInfos["ng1"])
+}
+
 func TestScaleUpBalanceGroups(t *testing.T) {
 	fakeClient := &fake.Clientset{}
 	provider := testprovider.NewTestCloudProvider(func(string, int) error {
@@ -588,7 +810,9 @@ func TestScaleUpBalanceGroups(t *testing.T) {
 		pods = append(pods, BuildTestPod(fmt.Sprintf("test-pod-%v", i), 80, 0))
 	}
 
-	result, typedErr := ScaleUp(context, pods, nodes, []*extensionsv1.DaemonSet{})
+	nodeInfos, _ := GetNodeInfosForGroups(nodes, context.CloudProvider, context.ClientSet,
+		[]*extensionsv1.DaemonSet{}, context.PredicateChecker)
+	result, typedErr := ScaleUp(context, pods, nodes, nodeInfos)
 	assert.NoError(t, typedErr)
 	assert.True(t, result)
 	groupMap := make(map[string]cloudprovider.NodeGroup, 3)
@@ -647,13 +871,65 @@ func TestScaleUpAutoprovisionedNodeGroup(t *testing.T) {
 		LogRecorder:          fakeLogRecorder,
 	}
 
-	result, err := ScaleUp(context, []*apiv1.Pod{p1}, []*apiv1.Node{}, []*extensionsv1.DaemonSet{})
+	nodeInfos, _ := GetNodeInfosForGroups([]*apiv1.Node{}, context.CloudProvider, context.ClientSet,
+		[]*extensionsv1.DaemonSet{}, context.PredicateChecker)
+	result, err := ScaleUp(context, []*apiv1.Pod{p1}, []*apiv1.Node{}, nodeInfos)
 	assert.NoError(t, err)
 	assert.True(t, result)
 	assert.Equal(t, "autoprovisioned-T1", getStringFromChan(createdGroups))
 	assert.Equal(t, "autoprovisioned-T1-1", getStringFromChan(expandedGroups))
 }
 
+func TestScaleUpAutoprovisionedNodeGroupCreateFailed(t *testing.T) {
+	expandedGroups := make(chan string, 10)
+
+	p1 := BuildTestPod("p1", 80, 0)
+
+	fakeClient := &fake.Clientset{}
+
+	t1 := BuildTestNode("t1", 4000, 1000000)
+	SetNodeReadyState(t1, true, time.Time{})
+	ti1 := schedulercache.NewNodeInfo()
+	ti1.SetNode(t1)
+
+	provider := testprovider.NewTestAutoprovisioningCloudProvider(
+		func(nodeGroup string, increase int) error {
+			expandedGroups <- fmt.Sprintf("%s-%d", nodeGroup, increase)
+			return nil
+		}, nil, func(nodeGroup string) error {
+			return fmt.Errorf("quota exceeded")
+		}, nil, []string{"T1"}, map[string]*schedulercache.NodeInfo{"T1": ti1})
+
+	fakeRecorder := kube_util.CreateEventRecorder(fakeClient)
+	fakeLogRecorder, _ := utils.NewStatusMapRecorder(fakeClient, "kube-system", fakeRecorder, false)
+	clusterState := clusterstate.NewClusterStateRegistry(provider, clusterstate.ClusterStateRegistryConfig{}, fakeLogRecorder)
+
+	context := &AutoscalingContext{
+		AutoscalingOptions: AutoscalingOptions{
+			EstimatorName:                    estimator.BinpackingEstimatorName,
+			MaxCoresTotal:                    5000 * 64,
+			MaxMemoryTotal:                   5000 * 64 * 20,
+			NodeAutoprovisioningEnabled:      true,
+			MaxAutoprovisionedNodeGroupCount: 10,
+		},
+		PredicateChecker:     simulator.NewTestPredicateChecker(),
+		CloudProvider:        provider,
+		ClientSet:            fakeClient,
+		Recorder:             fakeRecorder,
+		ExpanderStrategy:     random.NewStrategy(),
+		ClusterStateRegistry: clusterState,
+		LogRecorder:          fakeLogRecorder,
+	}
+
+	// The group is created when the plan is executed, a failure stops the scale-up.
+	nodeInfos, _ := GetNodeInfosForGroups([]*apiv1.Node{}, context.CloudProvider, context.ClientSet,
+		[]*extensionsv1.DaemonSet{}, context.PredicateChecker)
+	result, err := ScaleUp(context, []*apiv1.Pod{p1}, []*apiv1.Node{}, nodeInfos)
+	assert.Error(t, err)
+	assert.False(t, result)
+	assert.Equal(t, 0, len(expandedGroups))
+}
+
 func TestAddAutoprovisionedCandidatesOK(t *testing.T) {
 	t1 := BuildTestNode("t1", 4000, 1000000)
 	ti1 := schedulercache.NewNodeInfo()
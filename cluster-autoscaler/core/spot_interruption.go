@@ -0,0 +1,283 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"k8s.io/autoscaler/cluster-autoscaler/clusterstate"
+	"k8s.io/autoscaler/cluster-autoscaler/metrics"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/drain"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/errors"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// DefaultSpotInterruptionTaint is the taint key a termination handler sets on a node
+	// whose spot instance received an interruption notice.
+	DefaultSpotInterruptionTaint = "autoscalr.com/spot-interruption"
+	// DefaultSpotInterruptionCondition is the node condition a termination handler sets to true
+	// on a node whose spot instance received an interruption notice.
+	DefaultSpotInterruptionCondition = "SpotInterruption"
+)
+
+// SpotInterruptionSource tells which nodes are about to lose their spot instance.
+type SpotInterruptionSource interface {
+	// InterruptedNodes returns the nodes among the given ones that received an interruption notice.
+	InterruptedNodes(nodes []*apiv1.Node) ([]*apiv1.Node, error)
+}
+
+// nodeMarkerInterruptionSource reports nodes marked by a termination handler running on them,
+// either with a taint or with a node condition.
+type nodeMarkerInterruptionSource struct {
+	taintKey      string
+	conditionType apiv1.NodeConditionType
+}
+
+// NewNodeMarkerInterruptionSource returns a SpotInterruptionSource reporting nodes that have
+// a taint with the given key or the given condition set to true. Empty values are not checked.
+func NewNodeMarkerInterruptionSource(taintKey string, conditionType string) SpotInterruptionSource {
+	return &nodeMarkerInterruptionSource{
+		taintKey:      taintKey,
+		conditionType: apiv1.NodeConditionType(conditionType),
+	}
+}
+
+// InterruptedNodes implements SpotInterruptionSource.
+func (s *nodeMarkerInterruptionSource) InterruptedNodes(nodes []*apiv1.Node) ([]*apiv1.Node, error) {
+	result := make([]*apiv1.Node, 0)
+	for _, node := range nodes {
+		if s.isMarked(node) {
+			result = append(result, node)
+		}
+	}
+	return result, nil
+}
+
+func (s *nodeMarkerInterruptionSource) isMarked(node *apiv1.Node) bool {
+	if s.taintKey != "" {
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == s.taintKey {
+				return true
+			}
+		}
+	}
+	if s.conditionType != "" {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == s.conditionType && condition.Status == apiv1.ConditionTrue {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// spotInterruption is the handling state of an interrupted node.
+type spotInterruption struct {
+	noticed              time.Time
+	replacementRequested bool
+	deleteInProgress     bool
+	deleted              bool
+}
+
+// SpotInterruptionHandler replaces nodes that received a spot interruption notice before their
+// instance disappears: a replacement node is requested from the node group, then the interrupted
+// node is cordoned, drained and removed from the node group like in scale down.
+type SpotInterruptionHandler struct {
+	sync.Mutex
+	context       *AutoscalingContext
+	source        SpotInterruptionSource
+	interruptions map[string]*spotInterruption
+}
+
+// NewSpotInterruptionHandler builds a SpotInterruptionHandler reading notices from the given source.
+func NewSpotInterruptionHandler(context *AutoscalingContext, source SpotInterruptionSource) *SpotInterruptionHandler {
+	return &SpotInterruptionHandler{
+		context:       context,
+		source:        source,
+		interruptions: make(map[string]*spotInterruption),
+	}
+}
+
+// HandleInterruptions checks the given nodes for interruption notices and starts handling new
+// ones. Nodes are drained in the background; pods are the scheduled pods of the cluster. A node
+// whose replacement can't be requested is drained all the same, and the request is retried on
+// the next call.
+func (h *SpotInterruptionHandler) HandleInterruptions(allNodes []*apiv1.Node, pods []*apiv1.Pod,
+	currentTime time.Time) errors.AutoscalerError {
+	interrupted, err := h.source.InterruptedNodes(allNodes)
+	if err != nil {
+		return errors.ToAutoscalerError(errors.ApiCallError, err)
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	existing := make(map[string]bool, len(allNodes))
+	for _, node := range allNodes {
+		existing[node.Name] = true
+	}
+	for name, interruption := range h.interruptions {
+		if !existing[name] && !interruption.deleteInProgress {
+			delete(h.interruptions, name)
+		}
+	}
+
+	replacementErrs := make([]error, 0)
+	for _, node := range interrupted {
+		interruption, found := h.interruptions[node.Name]
+		if !found {
+			glog.V(0).Infof("Spot interruption notice for node %s", node.Name)
+			metrics.RegisterSpotInterruption()
+			h.context.LogRecorder.Eventf(apiv1.EventTypeNormal, "SpotInterruption",
+				"Spot interruption notice for node %s, replacing it", node.Name)
+			h.context.Recorder.Eventf(node, apiv1.EventTypeNormal, "SpotInterruption",
+				"spot interruption notice received, replacing the node")
+			interruption = &spotInterruption{noticed: currentTime}
+			h.interruptions[node.Name] = interruption
+		}
+		if !interruption.replacementRequested {
+			if err := h.requestReplacement(node, currentTime); err != nil {
+				glog.Errorf("Failed to request replacement of interrupted node %s: %v", node.Name, err)
+				replacementErrs = append(replacementErrs, err)
+			} else {
+				interruption.replacementRequested = true
+			}
+		}
+		if interruption.deleted || interruption.deleteInProgress {
+			continue
+		}
+		interruption.deleteInProgress = true
+		go h.deleteInterruptedNode(node, podsToEvict(node, pods))
+	}
+	if len(replacementErrs) > 0 {
+		return errors.NewAutoscalerError(errors.CloudProviderError,
+			"failed to request replacement of interrupted nodes, due to following errors: %v", replacementErrs)
+	}
+	return nil
+}
+
+// IsInterrupted returns true if the given node received an interruption notice and is
+// being replaced.
+func (h *SpotInterruptionHandler) IsInterrupted(nodeName string) bool {
+	h.Lock()
+	defer h.Unlock()
+	_, found := h.interruptions[nodeName]
+	return found
+}
+
+// IsDeleteInProgress returns true if an interrupted node is being drained or deleted.
+func (h *SpotInterruptionHandler) IsDeleteInProgress() bool {
+	h.Lock()
+	defer h.Unlock()
+	for _, interruption := range h.interruptions {
+		if interruption.deleteInProgress {
+			return true
+		}
+	}
+	return false
+}
+
+// requestReplacement increases the node group of the given node by one node. A node group at
+// its maximum size is left alone.
+func (h *SpotInterruptionHandler) requestReplacement(node *apiv1.Node, currentTime time.Time) errors.AutoscalerError {
+	nodeGroup, err := h.context.CloudProvider.NodeGroupForNode(node)
+	if err != nil {
+		return errors.NewAutoscalerError(
+			errors.CloudProviderError, "failed to find node group for %s: %v", node.Name, err)
+	}
+	if nodeGroup == nil || reflect.ValueOf(nodeGroup).IsNil() {
+		glog.Warningf("Interrupted node %s doesn't belong to a node group, not replacing it", node.Name)
+		return nil
+	}
+	size, err := nodeGroup.TargetSize()
+	if err != nil {
+		return errors.NewAutoscalerError(
+			errors.CloudProviderError, "failed to get target size of %s: %v", nodeGroup.Id(), err)
+	}
+	if size >= nodeGroup.MaxSize() {
+		glog.Warningf("Node group %s is at its max size, not replacing interrupted node %s", nodeGroup.Id(), node.Name)
+		return nil
+	}
+	if err := nodeGroup.IncreaseSize(1); err != nil {
+		return errors.NewAutoscalerError(
+			errors.CloudProviderError, "failed to increase %s: %v", nodeGroup.Id(), err)
+	}
+	h.context.LogRecorder.Eventf(apiv1.EventTypeNormal, "ScaledUpGroup",
+		"Scale-up: replacing interrupted node %s in group %s", node.Name, nodeGroup.Id())
+	h.context.ClusterStateRegistry.RegisterScaleUp(
+		&clusterstate.ScaleUpRequest{
+			NodeGroupName:   nodeGroup.Id(),
+			Increase:        1,
+			Time:            currentTime,
+			ExpectedAddTime: currentTime.Add(h.context.MaxNodeProvisionTime),
+		})
+	metrics.RegisterScaleUp(1)
+	return nil
+}
+
+// deleteInterruptedNode cordons, drains and deletes the given node. A failed attempt is
+// retried on the next HandleInterruptions call.
+func (h *SpotInterruptionHandler) deleteInterruptedNode(node *apiv1.Node, pods []*apiv1.Pod) {
+	err := deleteNode(h.context, node, pods)
+
+	h.Lock()
+	defer h.Unlock()
+	interruption, found := h.interruptions[node.Name]
+	if !found {
+		return
+	}
+	interruption.deleteInProgress = false
+	if err != nil {
+		glog.Errorf("Failed to delete interrupted node %s: %v", node.Name, err)
+		return
+	}
+	interruption.deleted = true
+	glog.V(0).Infof("Interrupted node %s removed %v after the notice", node.Name, time.Now().Sub(interruption.noticed))
+	metrics.RegisterScaleDown(1, metrics.SpotInterrupted)
+}
+
+// podsToEvict returns the pods running on the given node that need to be evicted before the
+// instance goes away. Mirror and DaemonSet pods are left alone, as they die with the node.
+func podsToEvict(node *apiv1.Node, pods []*apiv1.Pod) []*apiv1.Pod {
+	result := make([]*apiv1.Pod, 0)
+	for _, pod := range pods {
+		if pod.Spec.NodeName != node.Name || drain.IsMirrorPod(pod) {
+			continue
+		}
+		if controllerRef := drain.ControllerRef(pod); controllerRef != nil && controllerRef.Kind == "DaemonSet" {
+			continue
+		}
+		result = append(result, pod)
+	}
+	return result
+}
+
+func filterOutInterruptedNodes(nodes []*apiv1.Node, handler *SpotInterruptionHandler) []*apiv1.Node {
+	result := make([]*apiv1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if !handler.IsInterrupted(node.Name) {
+			result = append(result, node)
+		}
+	}
+	return result
+}
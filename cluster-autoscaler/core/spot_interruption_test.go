@@ -0,0 +1,274 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	testprovider "k8s.io/autoscaler/cluster-autoscaler/cloudprovider/test"
+	"k8s.io/autoscaler/cluster-autoscaler/clusterstate"
+	"k8s.io/autoscaler/cluster-autoscaler/clusterstate/utils"
+	kube_util "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
+	. "k8s.io/autoscaler/cluster-autoscaler/utils/test"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeInterruptionSource struct {
+	interrupted map[string]bool
+}
+
+func (s *fakeInterruptionSource) InterruptedNodes(nodes []*apiv1.Node) ([]*apiv1.Node, error) {
+	result := make([]*apiv1.Node, 0)
+	for _, node := range nodes {
+		if s.interrupted[node.Name] {
+			result = append(result, node)
+		}
+	}
+	return result, nil
+}
+
+func TestNodeMarkerInterruptionSource(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	n2 := BuildTestNode("n2", 1000, 1000)
+	n2.Spec.Taints = []apiv1.Taint{{Key: DefaultSpotInterruptionTaint, Effect: apiv1.TaintEffectNoSchedule}}
+	n3 := BuildTestNode("n3", 1000, 1000)
+	n3.Status.Conditions = append(n3.Status.Conditions, apiv1.NodeCondition{
+		Type:   DefaultSpotInterruptionCondition,
+		Status: apiv1.ConditionTrue,
+	})
+	n4 := BuildTestNode("n4", 1000, 1000)
+	n4.Status.Conditions = append(n4.Status.Conditions, apiv1.NodeCondition{
+		Type:   DefaultSpotInterruptionCondition,
+		Status: apiv1.ConditionFalse,
+	})
+	nodes := []*apiv1.Node{n1, n2, n3, n4}
+
+	source := NewNodeMarkerInterruptionSource(DefaultSpotInterruptionTaint, DefaultSpotInterruptionCondition)
+	interrupted, err := source.InterruptedNodes(nodes)
+	assert.NoError(t, err)
+	assert.Equal(t, []*apiv1.Node{n2, n3}, interrupted)
+
+	source = NewNodeMarkerInterruptionSource("", DefaultSpotInterruptionCondition)
+	interrupted, err = source.InterruptedNodes(nodes)
+	assert.NoError(t, err)
+	assert.Equal(t, []*apiv1.Node{n3}, interrupted)
+}
+
+func TestHandleSpotInterruptions(t *testing.T) {
+	scaledUpGroups := make(chan string, 10)
+	deletedNodes := make(chan string, 10)
+	evictedPods := make(chan string, 10)
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	n2 := BuildTestNode("n2", 1000, 1000)
+	SetNodeReadyState(n2, true, time.Time{})
+	p1 := BuildTestPod("p1", 100, 0)
+	p1.Spec.NodeName = "n1"
+	p2 := BuildTestPod("p2", 100, 0)
+	p2.Spec.NodeName = "n2"
+	ds := BuildTestPod("ds", 100, 0)
+	ds.Spec.NodeName = "n1"
+	ds.OwnerReferences = GenerateOwnerReferences("ds", "DaemonSet", "extensions/v1beta1", "")
+
+	provider := testprovider.NewTestCloudProvider(
+		func(nodeGroup string, increase int) error {
+			scaledUpGroups <- fmt.Sprintf("%s/%d", nodeGroup, increase)
+			return nil
+		},
+		func(nodeGroup string, node string) error {
+			deletedNodes <- node
+			return nil
+		})
+	provider.AddNodeGroup("ng1", 1, 10, 2)
+	provider.AddNode("ng1", n1)
+	provider.AddNode("ng1", n2)
+
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "nodes", func(action core.Action) (bool, runtime.Object, error) {
+		return true, n1, nil
+	})
+	fakeClient.Fake.AddReactor("update", "nodes", func(action core.Action) (bool, runtime.Object, error) {
+		return true, action.(core.UpdateAction).GetObject(), nil
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		eviction := action.(core.CreateAction).GetObject().(*policyv1.Eviction)
+		evictedPods <- eviction.Name
+		return true, nil, nil
+	})
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeRecorder := kube_util.CreateEventRecorder(fakeClient)
+	fakeLogRecorder, _ := utils.NewStatusMapRecorder(fakeClient, "kube-system", fakeRecorder, false)
+	context := &AutoscalingContext{
+		AutoscalingOptions: AutoscalingOptions{
+			MaxNodeProvisionTime: 15 * time.Minute,
+		},
+		ClientSet:            fakeClient,
+		Recorder:             fakeRecorder,
+		LogRecorder:          fakeLogRecorder,
+		CloudProvider:        provider,
+		ClusterStateRegistry: clusterstate.NewClusterStateRegistry(provider, clusterstate.ClusterStateRegistryConfig{}, fakeLogRecorder),
+	}
+
+	source := &fakeInterruptionSource{interrupted: map[string]bool{}}
+	handler := NewSpotInterruptionHandler(context, source)
+	nodes := []*apiv1.Node{n1, n2}
+	pods := []*apiv1.Pod{p1, p2, ds}
+	now := time.Now()
+
+	assert.NoError(t, handler.HandleInterruptions(nodes, pods, now))
+	assert.Equal(t, "Nothing returned", getStringFromChanImmediately(scaledUpGroups))
+	assert.False(t, handler.IsInterrupted("n1"))
+
+	source.interrupted["n1"] = true
+	assert.NoError(t, handler.HandleInterruptions(nodes, pods, now))
+	assert.True(t, handler.IsInterrupted("n1"))
+	assert.False(t, handler.IsInterrupted("n2"))
+	assert.Equal(t, "ng1/1", getStringFromChan(scaledUpGroups))
+	assert.Equal(t, "p1", getStringFromChan(evictedPods))
+	assert.Equal(t, "n1", getStringFromChan(deletedNodes))
+	assert.Equal(t, "Nothing returned", getStringFromChanImmediately(evictedPods))
+	assert.Equal(t, []*apiv1.Node{n2}, filterOutInterruptedNodes(nodes, handler))
+
+	// The notice is only handled once.
+	for start := time.Now(); time.Now().Sub(start) < 10*time.Second && !isDeleted(handler, "n1"); time.Sleep(100 * time.Millisecond) {
+	}
+	assert.True(t, isDeleted(handler, "n1"))
+	assert.False(t, handler.IsDeleteInProgress())
+	assert.NoError(t, handler.HandleInterruptions(nodes, pods, now.Add(time.Minute)))
+	assert.Equal(t, "Nothing returned", getStringFromChanImmediately(scaledUpGroups))
+	assert.Equal(t, "Nothing returned", getStringFromChanImmediately(deletedNodes))
+
+	// Nodes that are gone are forgotten.
+	assert.NoError(t, handler.HandleInterruptions([]*apiv1.Node{n2}, pods, now.Add(2*time.Minute)))
+	assert.False(t, handler.IsInterrupted("n1"))
+}
+
+func TestHandleSpotInterruptionsReplacementFailure(t *testing.T) {
+	scaledUpGroups := make(chan string, 10)
+	deletedNodes := make(chan string, 10)
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	n2 := BuildTestNode("n2", 1000, 1000)
+	SetNodeReadyState(n2, true, time.Time{})
+
+	failures := map[string]int{"ng1": 1}
+	provider := testprovider.NewTestCloudProvider(
+		func(nodeGroup string, increase int) error {
+			if failures[nodeGroup] > 0 {
+				failures[nodeGroup]--
+				return fmt.Errorf("throttled")
+			}
+			scaledUpGroups <- fmt.Sprintf("%s/%d", nodeGroup, increase)
+			return nil
+		},
+		func(nodeGroup string, node string) error {
+			deletedNodes <- node
+			return nil
+		})
+	provider.AddNodeGroup("ng1", 1, 10, 1)
+	provider.AddNode("ng1", n1)
+	provider.AddNodeGroup("ng2", 1, 10, 1)
+	provider.AddNode("ng2", n2)
+
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "nodes", func(action core.Action) (bool, runtime.Object, error) {
+		if action.(core.GetAction).GetName() == "n1" {
+			return true, n1, nil
+		}
+		return true, n2, nil
+	})
+	fakeClient.Fake.AddReactor("update", "nodes", func(action core.Action) (bool, runtime.Object, error) {
+		return true, action.(core.UpdateAction).GetObject(), nil
+	})
+	fakeRecorder := kube_util.CreateEventRecorder(fakeClient)
+	fakeLogRecorder, _ := utils.NewStatusMapRecorder(fakeClient, "kube-system", fakeRecorder, false)
+	context := &AutoscalingContext{
+		AutoscalingOptions: AutoscalingOptions{
+			MaxNodeProvisionTime: 15 * time.Minute,
+		},
+		ClientSet:            fakeClient,
+		Recorder:             fakeRecorder,
+		LogRecorder:          fakeLogRecorder,
+		CloudProvider:        provider,
+		ClusterStateRegistry: clusterstate.NewClusterStateRegistry(provider, clusterstate.ClusterStateRegistryConfig{}, fakeLogRecorder),
+	}
+
+	source := &fakeInterruptionSource{interrupted: map[string]bool{"n1": true, "n2": true}}
+	handler := NewSpotInterruptionHandler(context, source)
+	nodes := []*apiv1.Node{n1, n2}
+	now := time.Now()
+
+	// The failed replacement of n1 doesn't keep n2 from being replaced, nor either from being drained.
+	assert.Error(t, handler.HandleInterruptions(nodes, []*apiv1.Pod{}, now))
+	assert.Equal(t, "ng2/1", getStringFromChan(scaledUpGroups))
+	assert.Equal(t, "Nothing returned", getStringFromChanImmediately(scaledUpGroups))
+	deleted := []string{getStringFromChan(deletedNodes), getStringFromChan(deletedNodes)}
+	sort.Strings(deleted)
+	assert.Equal(t, []string{"n1", "n2"}, deleted)
+
+	// The replacement of n1 is requested again on the next call.
+	assert.NoError(t, handler.HandleInterruptions(nodes, []*apiv1.Pod{}, now.Add(10*time.Second)))
+	assert.Equal(t, "ng1/1", getStringFromChan(scaledUpGroups))
+	assert.Equal(t, "Nothing returned", getStringFromChanImmediately(scaledUpGroups))
+}
+
+func TestHandleSpotInterruptionsMaxSize(t *testing.T) {
+	scaledUpGroups := make(chan string, 10)
+	n1 := BuildTestNode("n1", 1000, 1000)
+	provider := testprovider.NewTestCloudProvider(
+		func(nodeGroup string, increase int) error {
+			scaledUpGroups <- fmt.Sprintf("%s/%d", nodeGroup, increase)
+			return nil
+		}, nil)
+	provider.AddNodeGroup("ng1", 1, 1, 1)
+	provider.AddNode("ng1", n1)
+
+	fakeClient := &fake.Clientset{}
+	fakeRecorder := kube_util.CreateEventRecorder(fakeClient)
+	fakeLogRecorder, _ := utils.NewStatusMapRecorder(fakeClient, "kube-system", fakeRecorder, false)
+	context := &AutoscalingContext{
+		ClientSet:            fakeClient,
+		Recorder:             fakeRecorder,
+		LogRecorder:          fakeLogRecorder,
+		CloudProvider:        provider,
+		ClusterStateRegistry: clusterstate.NewClusterStateRegistry(provider, clusterstate.ClusterStateRegistryConfig{}, fakeLogRecorder),
+	}
+	handler := NewSpotInterruptionHandler(context, &fakeInterruptionSource{})
+	assert.NoError(t, handler.requestReplacement(n1, time.Now()))
+	assert.Equal(t, "Nothing returned", getStringFromChanImmediately(scaledUpGroups))
+}
+
+func isDeleted(handler *SpotInterruptionHandler, nodeName string) bool {
+	handler.Lock()
+	defer handler.Unlock()
+	interruption, found := handler.interruptions[nodeName]
+	return found && interruption.deleted
+}
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/clusterstate/api"
 	"k8s.io/autoscaler/cluster-autoscaler/clusterstate/utils"
 	"k8s.io/autoscaler/cluster-autoscaler/metrics"
 	"k8s.io/autoscaler/cluster-autoscaler/simulator"
@@ -30,6 +31,7 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 	kube_client "k8s.io/client-go/kubernetes"
 	kube_record "k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
 
 	"github.com/golang/glog"
 )
@@ -55,6 +57,9 @@ type StaticAutoscaler struct {
 	lastScaleDownDeleteTime time.Time
 	lastScaleDownFailTime   time.Time
 	scaleDown               *ScaleDown
+	spotInterruptions       *SpotInterruptionHandler
+	headroomSpecs           []HeadroomSpec
+	headroomConditions      map[string]api.ClusterAutoscalerCondition
 }
 
 // NewStaticAutoscaler creates an instance of Autoscaler filled with provided parameters
@@ -72,7 +77,21 @@ func NewStaticAutoscaler(opts AutoscalingOptions, predicateChecker *simulator.Pr
 		return nil, errctx
 	}
 
+	var headroomSpecs []HeadroomSpec
+	for _, value := range opts.NodeGroupHeadroom {
+		spec, err := ParseHeadroomSpec(value)
+		if err != nil {
+			return nil, errors.ToAutoscalerError(errors.InternalError, err)
+		}
+		headroomSpecs = append(headroomSpecs, spec)
+	}
+
 	scaleDown := NewScaleDown(autoscalingContext)
+	var spotInterruptions *SpotInterruptionHandler
+	if opts.SpotInterruptionHandlingEnabled {
+		spotInterruptions = NewSpotInterruptionHandler(autoscalingContext,
+			NewNodeMarkerInterruptionSource(opts.SpotInterruptionTaint, opts.SpotInterruptionCondition))
+	}
 
 	return &StaticAutoscaler{
 		AutoscalingContext:      autoscalingContext,
@@ -82,6 +101,8 @@ func NewStaticAutoscaler(opts AutoscalingOptions, predicateChecker *simulator.Pr
 		lastScaleDownDeleteTime: time.Now(),
 		lastScaleDownFailTime:   time.Now(),
 		scaleDown:               scaleDown,
+		spotInterruptions:       spotInterruptions,
+		headroomSpecs:           headroomSpecs,
 	}, nil
 }
 
@@ -115,12 +136,8 @@ func (a *StaticAutoscaler) RunOnce(currentTime time.Time) errors.AutoscalerError
 
 	err := autoscalingContext.CloudProvider.Refresh()
 	if err != nil {
-		if err.Error() == "CollectClusterState.Completed" {
-			// Set to TransientError to stop rest of scaling processing this loop
-			return errors.ToAutoscalerError(errors.TransientError, err)
-		} else {
-			return errors.ToAutoscalerError(errors.CloudProviderError, err)
-		}
+		glog.Errorf("Failed to refresh cloud provider config: %v", err)
+		return errors.ToAutoscalerError(errors.CloudProviderError, err)
 	}
 
 	allNodes, err := allNodeLister.List()
@@ -179,6 +196,7 @@ func (a *StaticAutoscaler) RunOnce(currentTime time.Time) errors.AutoscalerError
 	defer func() {
 		if autoscalingContext.WriteStatusConfigMap {
 			status := a.ClusterStateRegistry.GetStatus(currentTime)
+			addHeadroomConditions(status, a.headroomConditions)
 			utils.WriteStatusConfigMap(autoscalingContext.ClientSet, autoscalingContext.ConfigNamespace,
 				status.GetReadableString(), a.AutoscalingContext.LogRecorder)
 		}
@@ -241,6 +259,16 @@ func (a *StaticAutoscaler) RunOnce(currentTime time.Time) errors.AutoscalerError
 		return errors.ToAutoscalerError(errors.ApiCallError, err)
 	}
 
+	if a.spotInterruptions != nil {
+		if typedErr := a.spotInterruptions.HandleInterruptions(allNodes, allScheduled, currentTime); typedErr != nil {
+			// The rest of the loop doesn't depend on it, the notices are handled again next loop.
+			glog.Errorf("Failed to handle spot interruptions: %v", typedErr)
+			metrics.RegisterError(typedErr)
+		}
+		// Interrupted nodes are going away, don't count on them to run pods.
+		readyNodes = filterOutInterruptedNodes(readyNodes, a.spotInterruptions)
+	}
+
 	ConfigurePredicateCheckerForLoop(allUnschedulablePods, allScheduled, a.PredicateChecker)
 
 	// We need to check whether pods marked as unschedulable are actually unschedulable.
@@ -277,9 +305,33 @@ func (a *StaticAutoscaler) RunOnce(currentTime time.Time) errors.AutoscalerError
 		glog.V(4).Info("No schedulable pods")
 	}
 
+	// Node infos are built at most once per loop, and shared by headroom and scale-up.
+	var nodeInfos map[string]*schedulercache.NodeInfo
+	var nodeInfosErr errors.AutoscalerError
+
+	// Virtual pods keep the headroom of node groups. Those fitting on the nodes of their node
+	// group are moved like other pods during scale down, the others trigger a scale-up.
+	headroom := &headroomResult{}
+	if len(a.headroomSpecs) > 0 {
+		nodeInfos, nodeInfosErr = a.buildNodeInfos(readyNodes)
+		if nodeInfosErr != nil {
+			glog.Errorf("Skipping node group headroom: %v", nodeInfosErr)
+		} else {
+			headroom = computeHeadroom(a.headroomSpecs, autoscalingContext.CloudProvider, a.PredicateChecker, readyNodes,
+				FilterOutExpendablePods(allScheduled, a.ExpendablePodsPriorityCutoff), nodeInfos)
+			a.headroomConditions = updateHeadroomConditions(a.headroomConditions, headroom.conditions, currentTime)
+			if len(headroom.unplaced) > 0 {
+				glog.V(1).Infof("%d virtual pods of node group headroom don't fit", len(headroom.unplaced))
+			}
+		}
+	}
+
 	// If all pending pods are new we may want to skip a real scale down (just like if the pods were handled).
 	allPendingPodsToHelpAreNew := false
 
+	// Virtual pods have no creation time, so missing headroom is restored without waiting for more pods.
+	unschedulablePodsToHelp = append(unschedulablePodsToHelp, headroom.unplaced...)
+
 	if len(unschedulablePodsToHelp) == 0 {
 		glog.V(1).Info("No unschedulable pods")
 	} else if a.MaxNodesTotal > 0 && len(readyNodes) >= a.MaxNodesTotal {
@@ -291,24 +343,28 @@ func (a *StaticAutoscaler) RunOnce(currentTime time.Time) errors.AutoscalerError
 		allPendingPodsToHelpAreNew = true
 		glog.V(1).Info("Unschedulable pods are very new, waiting one iteration for more")
 	} else {
-		daemonsets, err := a.ListerRegistry.DaemonSetLister().List()
-		if err != nil {
-			glog.Errorf("Failed to get daemonset list")
-			return errors.ToAutoscalerError(errors.ApiCallError, err)
+		if nodeInfos == nil && nodeInfosErr == nil {
+			nodeInfos, nodeInfosErr = a.buildNodeInfos(readyNodes)
+		}
+		if nodeInfosErr != nil {
+			glog.Errorf("Failed to scale up: %v", nodeInfosErr)
+			return nodeInfosErr
 		}
 
 		scaleUpStart := time.Now()
 		metrics.UpdateLastTime(metrics.ScaleUp, scaleUpStart)
 
-		scaledUp, typedErr := ScaleUp(autoscalingContext, unschedulablePodsToHelp, readyNodes, daemonsets)
+		scaledUp, typedErr := ScaleUp(autoscalingContext, unschedulablePodsToHelp, readyNodes, nodeInfos)
 
 		metrics.UpdateDurationFromStart(metrics.ScaleUp, scaleUpStart)
 
+		if scaledUp {
+			a.lastScaleUpTime = currentTime
+		}
 		if typedErr != nil {
 			glog.Errorf("Failed to scale up: %v", typedErr)
 			return typedErr
 		} else if scaledUp {
-			a.lastScaleUpTime = currentTime
 			// No scale down in this iteration.
 			return nil
 		}
@@ -327,8 +383,13 @@ func (a *StaticAutoscaler) RunOnce(currentTime time.Time) errors.AutoscalerError
 
 		scaleDown.CleanUp(currentTime)
 		potentiallyUnneeded := getPotentiallyUnneededNodes(autoscalingContext, allNodes)
+		if a.spotInterruptions != nil {
+			potentiallyUnneeded = filterOutInterruptedNodes(potentiallyUnneeded, a.spotInterruptions)
+		}
+		// Virtual pods keeping headroom are moved like other pods, a node isn't removed if they don't fit elsewhere.
+		scheduledWithHeadroom := append(append([]*apiv1.Pod{}, allScheduled...), headroom.placed...)
 
-		typedErr := scaleDown.UpdateUnneededNodes(allNodes, potentiallyUnneeded, append(allScheduled, unschedulableWaitingForLowerPriorityPreemption...), currentTime, pdbs)
+		typedErr := scaleDown.UpdateUnneededNodes(allNodes, potentiallyUnneeded, append(scheduledWithHeadroom, unschedulableWaitingForLowerPriorityPreemption...), currentTime, pdbs)
 		if typedErr != nil {
 			glog.Errorf("Failed to scale down: %v", typedErr)
 			return typedErr
@@ -369,7 +430,7 @@ func (a *StaticAutoscaler) RunOnce(currentTime time.Time) errors.AutoscalerError
 
 			scaleDownStart := time.Now()
 			metrics.UpdateLastTime(metrics.ScaleDown, scaleDownStart)
-			result, typedErr := scaleDown.TryToScaleDown(allNodes, allScheduled, pdbs, currentTime)
+			result, typedErr := scaleDown.TryToScaleDown(allNodes, scheduledWithHeadroom, pdbs, currentTime)
 			metrics.UpdateDurationFromStart(metrics.ScaleDown, scaleDownStart)
 
 			// TODO: revisit result handling
@@ -387,6 +448,29 @@ func (a *StaticAutoscaler) RunOnce(currentTime time.Time) errors.AutoscalerError
 	return nil
 }
 
+// buildNodeInfos returns the template node infos of the node groups, built from the ready nodes.
+func (a *StaticAutoscaler) buildNodeInfos(readyNodes []*apiv1.Node) (map[string]*schedulercache.NodeInfo, errors.AutoscalerError) {
+	daemonsets, err := a.ListerRegistry.DaemonSetLister().List()
+	if err != nil {
+		glog.Errorf("Failed to get daemonset list")
+		return nil, errors.ToAutoscalerError(errors.ApiCallError, err)
+	}
+	nodeInfos, typedErr := GetNodeInfosForGroups(readyNodes, a.CloudProvider, a.ClientSet, daemonsets, a.PredicateChecker)
+	if typedErr != nil {
+		return nil, typedErr.AddPrefix("failed to build node infos for node groups: ")
+	}
+	return nodeInfos, nil
+}
+
+// IsDeleteInProgress tells whether a scale down or the replacement of an interrupted spot node
+// is deleting a node.
+func (a *StaticAutoscaler) IsDeleteInProgress() bool {
+	if a.scaleDown.nodeDeleteStatus.IsDeleteInProgress() {
+		return true
+	}
+	return a.spotInterruptions != nil && a.spotInterruptions.IsDeleteInProgress()
+}
+
 // ExitCleanUp removes status configmap.
 func (a *StaticAutoscaler) ExitCleanUp() {
 	if !a.AutoscalingContext.WriteStatusConfigMap {
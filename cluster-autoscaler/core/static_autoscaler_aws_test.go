@@ -0,0 +1,177 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
+	"k8s.io/autoscaler/cluster-autoscaler/clusterstate"
+	"k8s.io/autoscaler/cluster-autoscaler/clusterstate/utils"
+	"k8s.io/autoscaler/cluster-autoscaler/estimator"
+	"k8s.io/autoscaler/cluster-autoscaler/expander/random"
+	"k8s.io/autoscaler/cluster-autoscaler/simulator"
+	kube_util "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
+	. "k8s.io/autoscaler/cluster-autoscaler/utils/test"
+
+	apiv1 "k8s.io/api/core/v1"
+	extensionsv1 "k8s.io/api/extensions/v1beta1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kube_client "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clientNodeLister lists the nodes registered in a kube client.
+type clientNodeLister struct {
+	client    kube_client.Interface
+	readyOnly bool
+}
+
+func (l *clientNodeLister) List() ([]*apiv1.Node, error) {
+	nodes, err := l.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*apiv1.Node, 0, len(nodes.Items))
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if l.readyOnly {
+			if ready, _, _ := kube_util.GetReadinessState(node); !ready || node.Spec.Unschedulable {
+				continue
+			}
+		}
+		result = append(result, node)
+	}
+	return result, nil
+}
+
+// staticPodLister lists a fixed set of pods, changed by the test between loops.
+type staticPodLister struct {
+	pods []*apiv1.Pod
+}
+
+func (l *staticPodLister) List() ([]*apiv1.Pod, error) {
+	return l.pods, nil
+}
+
+func TestStaticAutoscalerRunOnceWithFakeAws(t *testing.T) {
+	launchLatency := 2 * time.Minute
+	asgs := aws.NewFakeAutoScaling(launchLatency)
+	assert.NoError(t, asgs.AddGroup("ng1", "m4.large", "us-east-1a", 1, 5, 1, nil))
+	fakeClient := fake.NewSimpleClientset()
+	assert.NoError(t, asgs.RegisterNodes(fakeClient))
+
+	resourceLimiter := cloudprovider.NewResourceLimiter(
+		map[string]int64{cloudprovider.ResourceNameCores: 0, cloudprovider.ResourceNameMemory: 0},
+		map[string]int64{cloudprovider.ResourceNameCores: 100, cloudprovider.ResourceNameMemory: 1 << 40})
+	provider, err := aws.NewFakeAwsCloudProvider(asgs, cloudprovider.NodeGroupDiscoveryOptions{
+		NodeGroupSpecs: []string{"1:5:ng1"},
+	}, resourceLimiter)
+	assert.NoError(t, err)
+
+	fakeRecorder := kube_util.CreateEventRecorder(fakeClient)
+	fakeLogRecorder, _ := utils.NewStatusMapRecorder(fakeClient, "kube-system", fakeRecorder, false)
+	clusterState := clusterstate.NewClusterStateRegistry(provider, clusterstate.ClusterStateRegistryConfig{
+		OkTotalUnreadyCount:  1,
+		MaxNodeProvisionTime: 15 * time.Minute,
+	}, fakeLogRecorder)
+	context := &AutoscalingContext{
+		AutoscalingOptions: AutoscalingOptions{
+			EstimatorName:                 estimator.BinpackingEstimatorName,
+			ScaleDownEnabled:              true,
+			ScaleDownUtilizationThreshold: 0.5,
+			MaxNodesTotal:                 10,
+			MaxEmptyBulkDelete:            10,
+			ScaleDownUnreadyTime:          time.Minute,
+			ScaleDownUnneededTime:         time.Minute,
+		},
+		PredicateChecker:     simulator.NewTestPredicateChecker(),
+		CloudProvider:        provider,
+		ClientSet:            fakeClient,
+		Recorder:             fakeRecorder,
+		ExpanderStrategy:     random.NewStrategy(),
+		ClusterStateRegistry: clusterState,
+		LogRecorder:          fakeLogRecorder,
+	}
+
+	scheduledPods := &staticPodLister{}
+	unschedulablePods := &staticPodLister{}
+	pdbLister := &podDisruptionBudgetListerMock{}
+	pdbLister.On("List").Return([]*policyv1.PodDisruptionBudget{}, nil)
+	daemonSetLister := &daemonSetListerMock{}
+	daemonSetLister.On("List").Return([]*extensionsv1.DaemonSet{}, nil)
+	listerRegistry := kube_util.NewListerRegistry(&clientNodeLister{client: fakeClient},
+		&clientNodeLister{client: fakeClient, readyOnly: true}, scheduledPods, unschedulablePods, pdbLister, daemonSetLister, nil)
+
+	autoscaler := &StaticAutoscaler{AutoscalingContext: context,
+		ListerRegistry:        listerRegistry,
+		lastScaleUpTime:       asgs.Now(),
+		lastScaleDownFailTime: asgs.Now(),
+		scaleDown:             NewScaleDown(context)}
+
+	nodes := func() []*apiv1.Node {
+		nodes, err := listerRegistry.AllNodeLister().List()
+		assert.NoError(t, err)
+		return nodes
+	}
+	n1 := nodes()[0]
+
+	// A pod that doesn't fit on the node scales the group up.
+	p1 := BuildTestPod("p1", 1500, 1000)
+	p1.Spec.NodeName = n1.Name
+	p2 := BuildTestPod("p2", 1500, 1000)
+	scheduledPods.pods = []*apiv1.Pod{p1}
+	unschedulablePods.pods = []*apiv1.Pod{p2}
+
+	assert.NoError(t, autoscaler.RunOnce(asgs.Now()))
+	assert.Equal(t, int64(2), asgs.DesiredCapacity("ng1"))
+	assert.Equal(t, 1, len(nodes()))
+
+	// The node registers once the instance is launched, and gets the pod.
+	assert.NoError(t, asgs.Advance(launchLatency))
+	assert.Equal(t, 2, len(nodes()))
+	var n2 *apiv1.Node
+	for _, node := range nodes() {
+		if node.Name != n1.Name {
+			n2 = node
+		}
+	}
+	p2.Spec.NodeName = n2.Name
+	scheduledPods.pods = []*apiv1.Pod{p1, p2}
+	unschedulablePods.pods = []*apiv1.Pod{}
+
+	assert.NoError(t, autoscaler.RunOnce(asgs.Now()))
+	assert.Equal(t, int64(2), asgs.DesiredCapacity("ng1"))
+
+	// Once the pod is gone the node is unneeded, and removed after ScaleDownUnneededTime.
+	scheduledPods.pods = []*apiv1.Pod{p1}
+	assert.NoError(t, autoscaler.RunOnce(asgs.Now()))
+	assert.Equal(t, int64(2), asgs.DesiredCapacity("ng1"))
+
+	assert.NoError(t, asgs.Advance(2*time.Minute))
+	assert.NoError(t, autoscaler.RunOnce(asgs.Now()))
+	waitForDeleteToFinish(t, autoscaler.scaleDown)
+	assert.Equal(t, int64(1), asgs.DesiredCapacity("ng1"))
+	remaining := nodes()
+	assert.Equal(t, 1, len(remaining))
+	assert.Equal(t, n1.Name, remaining[0].Name)
+}
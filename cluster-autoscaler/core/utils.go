@@ -321,6 +321,75 @@ func sanitizeTemplateNode(node *apiv1.Node, nodeGroup string) (*apiv1.Node, erro
 	return newNode, nil
 }
 
+// zoneNodeInfos returns the node info of the node group in each of its zones, and the zones
+// the next node of the node group may be placed in. Nil is returned for node groups that
+// aren't spread across several zones.
+func zoneNodeInfos(nodeGroup cloudprovider.NodeGroup, nodeInfo *schedulercache.NodeInfo) (map[string]*schedulercache.NodeInfo, []string) {
+	zonal, ok := nodeGroup.(cloudprovider.ZonalNodeGroup)
+	if !ok {
+		return nil, nil
+	}
+	zones, err := zonal.Zones()
+	if err != nil {
+		glog.Warningf("Failed to get zones of %s: %v", nodeGroup.Id(), err)
+		return nil, nil
+	}
+	if len(zones) < 2 {
+		return nil, nil
+	}
+	scaleUpZones, err := zonal.ScaleUpZones()
+	if err != nil {
+		glog.Warningf("Failed to get scale-up zones of %s: %v", nodeGroup.Id(), err)
+		return nil, nil
+	}
+	result := make(map[string]*schedulercache.NodeInfo, len(zones))
+	for _, zone := range zones {
+		zoneNodeInfo, err := nodeInfoForZone(nodeInfo, zone)
+		if err != nil {
+			glog.Warningf("Failed to build node info of %s in %s: %v", nodeGroup.Id(), zone, err)
+			return nil, nil
+		}
+		result[zone] = zoneNodeInfo
+	}
+	known := make([]string, 0, len(scaleUpZones))
+	for _, zone := range scaleUpZones {
+		if _, found := result[zone]; found {
+			known = append(known, zone)
+		}
+	}
+	if len(known) == 0 {
+		glog.Warningf("No known scale-up zone of %s in %v", nodeGroup.Id(), scaleUpZones)
+		return nil, nil
+	}
+	return result, known
+}
+
+// podsFitAllZones checks whether each of the pods fits on the node infos of all zones.
+func podsFitAllZones(predicateChecker *simulator.PredicateChecker, pods []*apiv1.Pod, zoneNodeInfos map[string]*schedulercache.NodeInfo) bool {
+	for _, pod := range pods {
+		for _, nodeInfo := range zoneNodeInfos {
+			if err := predicateChecker.CheckPredicates(pod, nil, nodeInfo, simulator.ReturnVerboseError); err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// nodeInfoForZone returns a copy of the node info whose node is in the zone.
+func nodeInfoForZone(nodeInfo *schedulercache.NodeInfo, zone string) (*schedulercache.NodeInfo, error) {
+	node := nodeInfo.Node().DeepCopy()
+	if node.Labels == nil {
+		node.Labels = make(map[string]string)
+	}
+	node.Labels[kubeletapis.LabelZoneFailureDomain] = zone
+	result := schedulercache.NewNodeInfo(nodeInfo.Pods()...)
+	if err := result.SetNode(node); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // Removes unregistered nodes if needed. Returns true if anything was removed and error if such occurred.
 func removeOldUnregisteredNodes(unregisteredNodes []clusterstate.UnregisteredNode, context *AutoscalingContext,
 	currentTime time.Time, logRecorder *utils.LogEventRecorder) (bool, error) {
@@ -379,6 +448,7 @@ func fixNodeGroupSize(context *AutoscalingContext, currentTime time.Time) (bool,
 				if err := nodeGroup.DecreaseTargetSize(delta); err != nil {
 					return fixed, fmt.Errorf("Failed to decrease %s: %v", nodeGroup.Id(), err)
 				}
+				context.ClusterStateRegistry.RegisterTargetSizeDecrease(nodeGroup.Id(), delta, currentTime)
 				fixed = true
 			}
 		}
@@ -389,6 +459,7 @@ func fixNodeGroupSize(context *AutoscalingContext, currentTime time.Time) (bool,
 // getPotentiallyUnneededNodes returns nodes that are:
 // - managed by the cluster autoscaler
 // - in groups with size > min size
+// They are ordered by pay model if the scale down pay model policy asks for it.
 func getPotentiallyUnneededNodes(context *AutoscalingContext, nodes []*apiv1.Node) []*apiv1.Node {
 	result := make([]*apiv1.Node, 0, len(nodes))
 
@@ -415,6 +486,9 @@ func getPotentiallyUnneededNodes(context *AutoscalingContext, nodes []*apiv1.Nod
 		}
 		result = append(result, node)
 	}
+	if context.ScaleDownPayModelPolicy == OnDemandFirstPayModelPolicy {
+		return sortByPayModel(result)
+	}
 	return result
 }
 
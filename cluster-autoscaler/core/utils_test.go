@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
 	testprovider "k8s.io/autoscaler/cluster-autoscaler/cloudprovider/test"
 	"k8s.io/autoscaler/cluster-autoscaler/clusterstate"
 	"k8s.io/autoscaler/cluster-autoscaler/clusterstate/utils"
@@ -371,7 +372,7 @@ func TestSanitizeLabels(t *testing.T) {
 	oldNode := BuildTestNode("ng1-1", 1000, 1000)
 	oldNode.Labels = map[string]string{
 		kubeletapis.LabelHostname: "abc",
-		"x": "y",
+		"x":                       "y",
 	}
 	node, err := sanitizeTemplateNode(oldNode, "bzium")
 	assert.NoError(t, err)
@@ -406,6 +407,35 @@ func TestSanitizeTaints(t *testing.T) {
 	assert.Equal(t, node.Spec.Taints[0].Key, "test-taint")
 }
 
+func TestZoneNodeInfos(t *testing.T) {
+	provider := testprovider.NewTestCloudProvider(nil, nil)
+	provider.AddNodeGroup("ng1", 1, 10, 1)
+	provider.AddNodeGroup("ng2", 1, 10, 1)
+	provider.SetNodeGroupZones("ng1", []string{"us-east-1a", "us-east-1b", "us-east-1c"}, []string{"us-east-1b", "us-east-1c"})
+	provider.SetNodeGroupZones("ng2", []string{"us-east-1a"}, []string{"us-east-1a"})
+
+	node := BuildTestNode("n1", 1000, 1000)
+	node.Labels[kubeletapis.LabelZoneFailureDomain] = "us-east-1a"
+	pod := BuildTestPod("p1", 100, 0)
+	nodeInfo := schedulercache.NewNodeInfo(pod)
+	nodeInfo.SetNode(node)
+
+	zoneInfos, scaleUpZones := zoneNodeInfos(provider.GetNodeGroup("ng1"), nodeInfo)
+	assert.Equal(t, []string{"us-east-1b", "us-east-1c"}, scaleUpZones)
+	assert.Equal(t, 3, len(zoneInfos))
+	for _, zone := range []string{"us-east-1a", "us-east-1b", "us-east-1c"} {
+		assert.Equal(t, zone, zoneInfos[zone].Node().Labels[kubeletapis.LabelZoneFailureDomain])
+		assert.Equal(t, 1, len(zoneInfos[zone].Pods()))
+	}
+	// The node info of the node group isn't changed.
+	assert.Equal(t, "us-east-1a", nodeInfo.Node().Labels[kubeletapis.LabelZoneFailureDomain])
+
+	// Node groups in a single zone aren't zonal.
+	zoneInfos, scaleUpZones = zoneNodeInfos(provider.GetNodeGroup("ng2"), nodeInfo)
+	assert.Nil(t, zoneInfos)
+	assert.Nil(t, scaleUpZones)
+}
+
 func TestRemoveFixNodeTargetSize(t *testing.T) {
 	sizeChanges := make(chan string, 10)
 	now := time.Now()
@@ -447,6 +477,12 @@ func TestRemoveFixNodeTargetSize(t *testing.T) {
 	assert.True(t, removed)
 	change := getStringFromChan(sizeChanges)
 	assert.Equal(t, "ng1/-2", change)
+	assert.Nil(t, clusterState.GetIncorrectNodeGroupSize("ng1"))
+
+	// The decrease is recorded, so it is not repeated within the same loop.
+	removed, err = fixNodeGroupSize(context, now)
+	assert.NoError(t, err)
+	assert.False(t, removed)
 }
 
 func TestGetPotentiallyUnneededNodes(t *testing.T) {
@@ -470,6 +506,15 @@ func TestGetPotentiallyUnneededNodes(t *testing.T) {
 	ok1 := result[0].Name == "ng1-1" && result[1].Name == "ng1-2"
 	ok2 := result[1].Name == "ng1-1" && result[0].Name == "ng1-2"
 	assert.True(t, ok1 || ok2)
+
+	// On-demand nodes come first with the ondemand-first pay model policy.
+	ng1_1.Labels[aws.PayModelLabel] = aws.PayModelSpot
+	ng1_2.Labels[aws.PayModelLabel] = "ondemand"
+	context.ScaleDownPayModelPolicy = OnDemandFirstPayModelPolicy
+	result = getPotentiallyUnneededNodes(context, []*apiv1.Node{ng1_1, ng1_2, ng2_1, noNg})
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "ng1-2", result[0].Name)
+	assert.Equal(t, "ng1-1", result[1].Name)
 }
 
 func TestConfigurePredicateCheckerForLoop(t *testing.T) {
@@ -1,7 +1,2 @@
-<<<<<<< HEAD
-docker build -t autoscalr/k8s_autoscalr ..
-=======
-
-docker build -t autoscalr/k8s_autoscalr:v1.2.0 .
+docker build -t autoscalr/k8s_autoscalr:v1.2.0 ..
 docker push autoscalr/k8s_autoscalr:v1.2.0
->>>>>>> cluster-autoscaler-release-1.2
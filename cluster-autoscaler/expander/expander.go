@@ -24,7 +24,8 @@ import (
 
 var (
 	// AvailableExpanders is a list of available expander options
-	AvailableExpanders = []string{RandomExpanderName, MostPodsExpanderName, LeastWasteExpanderName, PriceBasedExpanderName}
+	AvailableExpanders = []string{RandomExpanderName, MostPodsExpanderName, LeastWasteExpanderName, PriceBasedExpanderName,
+		PriorityBasedExpanderName, ExternalExpanderName}
 	// RandomExpanderName selects a node group at random
 	RandomExpanderName = "random"
 	// MostPodsExpanderName selects a node group that fits the most pods
@@ -34,6 +35,10 @@ var (
 	// PriceBasedExpanderName selects a node group that is the most cost-effective and consistent with
 	// the preferred node size for the cluster
 	PriceBasedExpanderName = "price"
+	// PriorityBasedExpanderName selects a node group with the highest priority, configured in a configmap
+	PriorityBasedExpanderName = "priority"
+	// ExternalExpanderName selects a node group chosen by an external service
+	ExternalExpanderName = "external"
 )
 
 // Option describes an option to expand the cluster.
@@ -48,3 +53,9 @@ type Option struct {
 type Strategy interface {
 	BestOption(options []Option, nodeInfo map[string]*schedulercache.NodeInfo) *Option
 }
+
+// FilterStrategy describes an interface for narrowing down the options when scaling up. It returns
+// the options it finds equally good, which another strategy may choose from.
+type FilterStrategy interface {
+	BestOptions(options []Option, nodeInfo map[string]*schedulercache.NodeInfo) []Option
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package external
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/expander"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
+)
+
+// BestOptionsRequest is posted as json to the external expander for every scale-up.
+type BestOptionsRequest struct {
+	// Options are the possible scale-ups.
+	Options []Option `json:"options"`
+	// NodeTemplates are the template nodes of the node groups of the options, by node group id.
+	NodeTemplates map[string]NodeTemplate `json:"nodeTemplates"`
+}
+
+// Option is a possible scale-up of a node group.
+type Option struct {
+	NodeGroupId string       `json:"nodeGroupId"`
+	NodeCount   int          `json:"nodeCount"`
+	Pods        []PodSummary `json:"pods"`
+	Debug       string       `json:"debug,omitempty"`
+}
+
+// PodSummary describes a pending pod that a scale-up would schedule.
+type PodSummary struct {
+	Namespace string             `json:"namespace"`
+	Name      string             `json:"name"`
+	Labels    map[string]string  `json:"labels,omitempty"`
+	Requests  apiv1.ResourceList `json:"requests"`
+}
+
+// NodeTemplate describes a node the node group of an option would add.
+type NodeTemplate struct {
+	Labels      map[string]string  `json:"labels,omitempty"`
+	Taints      []apiv1.Taint      `json:"taints,omitempty"`
+	Capacity    apiv1.ResourceList `json:"capacity"`
+	Allocatable apiv1.ResourceList `json:"allocatable"`
+	// Requested are the resources requested by the pods started on every node, e.g. kube-proxy.
+	Requested apiv1.ResourceList `json:"requested"`
+}
+
+// BestOptionsResponse is the answer of the external expander.
+type BestOptionsResponse struct {
+	// NodeGroupIds are the node groups of the best options, which are equally good.
+	NodeGroupIds []string `json:"nodeGroupIds"`
+	// Reason is added to the debug output of the best options.
+	Reason string `json:"reason,omitempty"`
+}
+
+// newBestOptionsRequest summarizes the options and the template nodes of their node groups.
+func newBestOptionsRequest(options []expander.Option, nodeInfos map[string]*schedulercache.NodeInfo) *BestOptionsRequest {
+	request := &BestOptionsRequest{
+		Options:       make([]Option, 0, len(options)),
+		NodeTemplates: make(map[string]NodeTemplate),
+	}
+	for _, option := range options {
+		id := option.NodeGroup.Id()
+		summary := Option{
+			NodeGroupId: id,
+			NodeCount:   option.NodeCount,
+			Pods:        make([]PodSummary, 0, len(option.Pods)),
+			Debug:       option.Debug,
+		}
+		for _, pod := range option.Pods {
+			summary.Pods = append(summary.Pods, newPodSummary(pod))
+		}
+		request.Options = append(request.Options, summary)
+
+		if nodeInfo, found := nodeInfos[id]; found && nodeInfo.Node() != nil {
+			request.NodeTemplates[id] = newNodeTemplate(nodeInfo)
+		}
+	}
+	return request
+}
+
+func newPodSummary(pod *apiv1.Pod) PodSummary {
+	requests := apiv1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			sum := requests[name]
+			sum.Add(quantity)
+			requests[name] = sum
+		}
+	}
+	return PodSummary{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Labels:    pod.Labels,
+		Requests:  requests,
+	}
+}
+
+func newNodeTemplate(nodeInfo *schedulercache.NodeInfo) NodeTemplate {
+	node := nodeInfo.Node()
+	requested := nodeInfo.RequestedResource()
+	return NodeTemplate{
+		Labels:      node.Labels,
+		Taints:      node.Spec.Taints,
+		Capacity:    node.Status.Capacity,
+		Allocatable: node.Status.Allocatable,
+		Requested:   (&requested).ResourceList(),
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package external
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/autoscaler/cluster-autoscaler/expander"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
+)
+
+const (
+	// DefaultTimeout is the default timeout of a call to the external expander.
+	DefaultTimeout = time.Second
+
+	// maxResponseBytes caps the size of the responses read from the external expander.
+	maxResponseBytes = 1 << 20
+)
+
+type external struct {
+	url              string
+	httpClient       *http.Client
+	fallbackStrategy expander.Strategy
+}
+
+// NewStrategy returns a strategy that lets the service at url choose the node group to expand.
+// Ties between the node groups it chooses, and the whole choice if it fails or doesn't answer
+// within timeout, are left to fallbackStrategy.
+func NewStrategy(url string, timeout time.Duration, fallbackStrategy expander.Strategy) expander.Strategy {
+	return &external{
+		url:              url,
+		httpClient:       &http.Client{Timeout: timeout},
+		fallbackStrategy: fallbackStrategy,
+	}
+}
+
+// NewFilter returns a filter keeping the node groups chosen by the service at url. It keeps none
+// of them if the service fails or doesn't answer within timeout.
+func NewFilter(url string, timeout time.Duration) expander.FilterStrategy {
+	return &external{
+		url:        url,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// BestOption selects an option of the node groups chosen by the external expander.
+func (e *external) BestOption(expansionOptions []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) *expander.Option {
+	if len(expansionOptions) == 0 {
+		return nil
+	}
+	bestOptions, err := e.bestOptions(expansionOptions, nodeInfo)
+	if err != nil {
+		glog.Warningf("External expander failed, falling back to a local strategy: %v", err)
+		return e.fallbackStrategy.BestOption(expansionOptions, nodeInfo)
+	}
+	return e.fallbackStrategy.BestOption(bestOptions, nodeInfo)
+}
+
+// BestOptions selects the options of the node groups chosen by the external expander.
+func (e *external) BestOptions(expansionOptions []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) []expander.Option {
+	if len(expansionOptions) == 0 {
+		return nil
+	}
+	bestOptions, err := e.bestOptions(expansionOptions, nodeInfo)
+	if err != nil {
+		glog.Warningf("External expander failed: %v", err)
+		return nil
+	}
+	return bestOptions
+}
+
+// bestOptions asks the external expander for the best options. It fails unless at least one
+// of the options is chosen.
+func (e *external) bestOptions(expansionOptions []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) ([]expander.Option, error) {
+	response, err := e.post(newBestOptionsRequest(expansionOptions, nodeInfo))
+	if err != nil {
+		return nil, err
+	}
+
+	chosen := make(map[string]bool, len(response.NodeGroupIds))
+	for _, id := range response.NodeGroupIds {
+		chosen[id] = true
+	}
+	var bestOptions []expander.Option
+	for _, option := range expansionOptions {
+		id := option.NodeGroup.Id()
+		if !chosen[id] {
+			continue
+		}
+		delete(chosen, id)
+		option.Debug = fmt.Sprintf("%s | external-expander: %s", option.Debug, response.Reason)
+		bestOptions = append(bestOptions, option)
+	}
+	for id := range chosen {
+		glog.Warningf("External expander chose unknown node group %s", id)
+	}
+	if len(bestOptions) == 0 {
+		return nil, fmt.Errorf("none of the %d options was chosen", len(expansionOptions))
+	}
+	glog.V(2).Infof("External expander chose %d of %d options: %s", len(bestOptions), len(expansionOptions), response.Reason)
+	return bestOptions, nil
+}
+
+// post sends the request to the external expander and decodes its response.
+func (e *external) post(request *BestOptionsRequest) (*BestOptionsResponse, error) {
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(request); err != nil {
+		return nil, fmt.Errorf("failed to encode request: %v", err)
+	}
+	resp, err := e.httpClient.Post(e.url, "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned: %s", e.url, resp.Status)
+	}
+
+	response := new(BestOptionsResponse)
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return response, nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package external
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	testprovider "k8s.io/autoscaler/cluster-autoscaler/cloudprovider/test"
+	"k8s.io/autoscaler/cluster-autoscaler/expander"
+	. "k8s.io/autoscaler/cluster-autoscaler/utils/test"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
+)
+
+// lastOption picks the last of the options, so that fallbacks are predictable.
+type lastOption struct{}
+
+func (lastOption) BestOption(options []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) *expander.Option {
+	return &options[len(options)-1]
+}
+
+// buildOptions returns options of 4 node groups: ng1 and ng4 schedule both pods without waste,
+// ng2 schedules both on a bigger node, and ng3 schedules a single pod.
+func buildOptions() ([]expander.Option, map[string]*schedulercache.NodeInfo) {
+	p1 := BuildTestPod("p1", 1000, 1000)
+	p2 := BuildTestPod("p2", 1000, 1000)
+
+	provider := testprovider.NewTestCloudProvider(nil, nil)
+	nodeInfos := make(map[string]*schedulercache.NodeInfo)
+	var options []expander.Option
+	for _, group := range []struct {
+		id        string
+		cpu, mem  int64
+		pods      []*apiv1.Pod
+		kubeProxy bool
+	}{
+		{"ng1", 2000, 2000, []*apiv1.Pod{p1, p2}, false},
+		{"ng2", 4100, 4000, []*apiv1.Pod{p1, p2}, true},
+		{"ng3", 2000, 2000, []*apiv1.Pod{p1}, false},
+		{"ng4", 2000, 2000, []*apiv1.Pod{p1, p2}, false},
+	} {
+		provider.AddNodeGroup(group.id, 0, 10, 0)
+		node := BuildTestNode(group.id+"-template", group.cpu, group.mem)
+		node.Labels["group"] = group.id
+		nodeInfo := schedulercache.NewNodeInfo()
+		if group.kubeProxy {
+			nodeInfo = schedulercache.NewNodeInfo(BuildTestPod("kube-proxy", 100, 0))
+		}
+		nodeInfo.SetNode(node)
+		nodeInfos[group.id] = nodeInfo
+		options = append(options, expander.Option{
+			NodeGroup: provider.GetNodeGroup(group.id),
+			NodeCount: 1,
+			Pods:      group.pods,
+			Debug:     group.id,
+		})
+	}
+	return options, nodeInfos
+}
+
+func TestExternalReferenceServer(t *testing.T) {
+	server := httptest.NewServer(NewHandler(ReferencePolicy))
+	defer server.Close()
+	options, nodeInfos := buildOptions()
+
+	e := NewStrategy(server.URL, DefaultTimeout, lastOption{})
+	best := e.BestOption(options[:3], nodeInfos)
+	assert.Equal(t, "ng1", best.NodeGroup.Id())
+	assert.Equal(t, "ng1 | external-expander: 2 pods, 0.00% CPU and memory wasted", best.Debug)
+	assert.Equal(t, "ng1", options[0].Debug)
+
+	// Ties are broken by the fallback strategy.
+	best = e.BestOption(options, nodeInfos)
+	assert.Equal(t, "ng4", best.NodeGroup.Id())
+
+	bestOptions := NewFilter(server.URL, DefaultTimeout).BestOptions(options, nodeInfos)
+	assert.Equal(t, 2, len(bestOptions))
+	assert.Equal(t, "ng1", bestOptions[0].NodeGroup.Id())
+	assert.Equal(t, "ng4", bestOptions[1].NodeGroup.Id())
+
+	assert.Nil(t, e.BestOption([]expander.Option{}, nodeInfos))
+}
+
+func TestExternalRequest(t *testing.T) {
+	var request BestOptionsRequest
+	server := httptest.NewServer(NewHandler(func(r *BestOptionsRequest) (*BestOptionsResponse, error) {
+		request = *r
+		return &BestOptionsResponse{NodeGroupIds: []string{"ng2"}}, nil
+	}))
+	defer server.Close()
+	options, nodeInfos := buildOptions()
+	delete(nodeInfos, "ng3")
+
+	best := NewStrategy(server.URL, DefaultTimeout, lastOption{}).BestOption(options, nodeInfos)
+	assert.Equal(t, "ng2", best.NodeGroup.Id())
+
+	assert.Equal(t, 4, len(request.Options))
+	option := request.Options[1]
+	assert.Equal(t, "ng2", option.NodeGroupId)
+	assert.Equal(t, 1, option.NodeCount)
+	assert.Equal(t, "ng2", option.Debug)
+	assert.Equal(t, 2, len(option.Pods))
+	assert.Equal(t, "default", option.Pods[0].Namespace)
+	assert.Equal(t, "p1", option.Pods[0].Name)
+	cpu := option.Pods[0].Requests[apiv1.ResourceCPU]
+	assert.Equal(t, int64(1000), cpu.MilliValue())
+
+	// Node groups without template nodes are only sent as options.
+	assert.Equal(t, 3, len(request.NodeTemplates))
+	template := request.NodeTemplates["ng2"]
+	assert.Equal(t, "ng2", template.Labels["group"])
+	cpu = template.Allocatable[apiv1.ResourceCPU]
+	assert.Equal(t, int64(4100), cpu.MilliValue())
+	cpu = template.Requested[apiv1.ResourceCPU]
+	assert.Equal(t, int64(100), cpu.MilliValue())
+}
+
+func TestExternalFallback(t *testing.T) {
+	options, nodeInfos := buildOptions()
+	for name, handler := range map[string]http.HandlerFunc{
+		"timeout": func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(500 * time.Millisecond)
+			json.NewEncoder(w).Encode(&BestOptionsResponse{NodeGroupIds: []string{"ng1"}})
+		},
+		"server error": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "quota service unavailable", http.StatusServiceUnavailable)
+		},
+		"invalid response": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ng1"))
+		},
+		"unknown node groups": func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(&BestOptionsResponse{NodeGroupIds: []string{"ng5"}})
+		},
+		"no node groups": func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(&BestOptionsResponse{})
+		},
+	} {
+		server := httptest.NewServer(handler)
+		best := NewStrategy(server.URL, 100*time.Millisecond, lastOption{}).BestOption(options, nodeInfos)
+		assert.Equal(t, "ng4", best.NodeGroup.Id(), name)
+		assert.Equal(t, "ng4", best.Debug, name)
+		assert.Nil(t, NewFilter(server.URL, 100*time.Millisecond).BestOptions(options, nodeInfos), name)
+		server.Close()
+	}
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(NewHandler(ReferencePolicy))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, err = http.Post(server.URL, "application/json", strings.NewReader("{"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// The reference policy fails without node templates.
+	resp, err = http.Post(server.URL, "application/json", strings.NewReader(`{"options": [{"nodeGroupId": "ng1"}]}`))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
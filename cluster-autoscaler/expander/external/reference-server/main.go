@@ -0,0 +1,43 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// reference-server serves the external expander API with external.ReferencePolicy. It is meant
+// as a starting point for external expanders, and for trying out --expander=external:
+//
+//	go run expander/external/reference-server/main.go --address=:8085
+//	cluster-autoscaler --expander=external --external-expander-url=http://localhost:8085/best-options
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/golang/glog"
+	"k8s.io/autoscaler/cluster-autoscaler/expander/external"
+)
+
+var (
+	address = flag.String("address", ":8085", "The address to serve the external expander API on")
+	path    = flag.String("path", "/best-options", "The path to serve the external expander API on")
+)
+
+func main() {
+	flag.Parse()
+	mux := http.NewServeMux()
+	mux.Handle(*path, external.NewHandler(external.ReferencePolicy))
+	glog.Infof("Serving the external expander API on %s%s", *address, *path)
+	glog.Fatal(http.ListenAndServe(*address, mux))
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package external
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// maxRequestBytes caps the size of the requests read by the handler.
+const maxRequestBytes = 16 << 20
+
+// Policy chooses the best options of a request.
+type Policy func(request *BestOptionsRequest) (*BestOptionsResponse, error)
+
+// NewHandler returns an http.Handler serving the external expander API with policy. It can be
+// used as a base for external expanders written in Go.
+func NewHandler(policy Policy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		request := new(BestOptionsRequest)
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBytes)).Decode(request); err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+			return
+		}
+		response, err := policy(request)
+		if err != nil {
+			glog.Errorf("Failed to choose among %d options: %v", len(request.Options), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			glog.Errorf("Failed to encode response: %v", err)
+		}
+	})
+}
+
+// ReferencePolicy chooses the options scheduling the most pods and, among them, the ones wasting
+// the least fraction of the CPU and memory they add. Options without a node template are ignored.
+func ReferencePolicy(request *BestOptionsRequest) (*BestOptionsResponse, error) {
+	var bestIds []string
+	var bestPods int
+	var bestWaste float64
+	for _, option := range request.Options {
+		template, found := request.NodeTemplates[option.NodeGroupId]
+		if !found {
+			continue
+		}
+		waste, err := wasteOf(option, template)
+		if err != nil {
+			return nil, fmt.Errorf("node group %s: %v", option.NodeGroupId, err)
+		}
+		pods := len(option.Pods)
+		switch {
+		case bestIds == nil || pods > bestPods || (pods == bestPods && waste < bestWaste):
+			bestIds = []string{option.NodeGroupId}
+			bestPods = pods
+			bestWaste = waste
+		case pods == bestPods && waste == bestWaste:
+			bestIds = append(bestIds, option.NodeGroupId)
+		}
+	}
+	if bestIds == nil {
+		return nil, fmt.Errorf("no option with a node template")
+	}
+	return &BestOptionsResponse{
+		NodeGroupIds: bestIds,
+		Reason:       fmt.Sprintf("%d pods, %0.2f%% CPU and memory wasted", bestPods, bestWaste*50.0),
+	}, nil
+}
+
+// wasteOf returns the sum of the fractions of the CPU and memory added by the option that its
+// pods leave unused.
+func wasteOf(option Option, template NodeTemplate) (float64, error) {
+	var waste float64
+	for _, name := range []apiv1.ResourceName{apiv1.ResourceCPU, apiv1.ResourceMemory} {
+		allocatable := template.Allocatable[name]
+		requested := template.Requested[name]
+		free := float64(allocatable.MilliValue()-requested.MilliValue()) * float64(option.NodeCount)
+		if free <= 0 {
+			return 0, fmt.Errorf("no free %s", name)
+		}
+		var used float64
+		for _, pod := range option.Pods {
+			request := pod.Requests[name]
+			used += float64(request.MilliValue())
+		}
+		waste += (free - used) / free
+	}
+	return waste, nil
+}
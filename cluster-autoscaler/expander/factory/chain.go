@@ -0,0 +1,91 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package factory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"k8s.io/autoscaler/cluster-autoscaler/expander"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
+)
+
+// chainFilter is a named stage of a chain.
+type chainFilter struct {
+	name   string
+	filter expander.FilterStrategy
+}
+
+// chain narrows the options with each of its filters in turn, and lets its last strategy pick
+// one of the remaining options.
+type chain struct {
+	filters   []chainFilter
+	lastName  string
+	lastStage expander.Strategy
+}
+
+// newChain returns a strategy running the filters, then last.
+func newChain(filters []chainFilter, lastName string, last expander.Strategy) expander.Strategy {
+	return &chain{
+		filters:   filters,
+		lastName:  lastName,
+		lastStage: last,
+	}
+}
+
+// BestOption selects an option with the filters and the last strategy of the chain. A filter
+// keeping none of the options is ignored, so that the following stages act as its fallback.
+func (c *chain) BestOption(expansionOptions []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) *expander.Option {
+	if len(expansionOptions) == 0 {
+		return nil
+	}
+
+	decisions := make([]string, 0, len(c.filters)+1)
+	options := expansionOptions
+	for _, stage := range c.filters {
+		if len(options) == 1 {
+			break
+		}
+		kept := stage.filter.BestOptions(options, nodeInfo)
+		if len(kept) == 0 {
+			glog.Warningf("Expander %s kept none of %d options, ignoring it", stage.name, len(options))
+			decisions = append(decisions, fmt.Sprintf("%s kept none, ignored", stage.name))
+			continue
+		}
+		decisions = append(decisions, fmt.Sprintf("%s kept %d of %d [%s]", stage.name, len(kept), len(options), optionIds(kept)))
+		options = kept
+	}
+
+	best := c.lastStage.BestOption(options, nodeInfo)
+	if best == nil {
+		return nil
+	}
+	decisions = append(decisions, fmt.Sprintf("%s chose %s", c.lastName, best.NodeGroup.Id()))
+
+	result := *best
+	result.Debug = fmt.Sprintf("%s | expander chain: %s", best.Debug, strings.Join(decisions, "; "))
+	return &result
+}
+
+func optionIds(options []expander.Option) string {
+	ids := make([]string, 0, len(options))
+	for _, option := range options {
+		ids = append(ids, option.NodeGroup.Id())
+	}
+	return strings.Join(ids, ",")
+}
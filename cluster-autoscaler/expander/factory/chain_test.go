@@ -0,0 +1,109 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package factory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	testprovider "k8s.io/autoscaler/cluster-autoscaler/cloudprovider/test"
+	"k8s.io/autoscaler/cluster-autoscaler/expander"
+	"k8s.io/autoscaler/cluster-autoscaler/expander/external"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
+)
+
+// keepIds is a filter keeping the options of the given node groups.
+type keepIds map[string]bool
+
+func (k keepIds) BestOptions(options []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) []expander.Option {
+	var result []expander.Option
+	for _, option := range options {
+		if k[option.NodeGroup.Id()] {
+			result = append(result, option)
+		}
+	}
+	return result
+}
+
+// lastOption picks the last of the options.
+type lastOption struct{}
+
+func (lastOption) BestOption(options []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) *expander.Option {
+	return &options[len(options)-1]
+}
+
+func TestChain(t *testing.T) {
+	provider := testprovider.NewTestCloudProvider(nil, nil)
+	var options []expander.Option
+	for _, id := range []string{"ng1", "ng2", "ng3"} {
+		provider.AddNodeGroup(id, 0, 10, 0)
+		options = append(options, expander.Option{NodeGroup: provider.GetNodeGroup(id), NodeCount: 1, Debug: id})
+	}
+
+	e := newChain([]chainFilter{
+		{name: "first", filter: keepIds{"ng1": true, "ng2": true}},
+		{name: "second", filter: keepIds{}},
+		{name: "third", filter: keepIds{"ng1": true, "ng2": true, "ng3": true}},
+	}, "last", lastOption{})
+	best := e.BestOption(options, nil)
+	assert.Equal(t, "ng2", best.NodeGroup.Id())
+	assert.Equal(t, "ng2 | expander chain: first kept 2 of 3 [ng1,ng2]; second kept none, ignored; "+
+		"third kept 2 of 2 [ng1,ng2]; last chose ng2", best.Debug)
+	// The options themselves are left untouched.
+	assert.Equal(t, "ng2", options[1].Debug)
+
+	// Filters stop once a single option is left.
+	e = newChain([]chainFilter{
+		{name: "first", filter: keepIds{"ng3": true}},
+		{name: "second", filter: keepIds{}},
+	}, "last", lastOption{})
+	best = e.BestOption(options, nil)
+	assert.Equal(t, "ng3 | expander chain: first kept 1 of 3 [ng3]; last chose ng3", best.Debug)
+
+	assert.Nil(t, e.BestOption([]expander.Option{}, nil))
+}
+
+func TestExpanderStrategyFromString(t *testing.T) {
+	provider := testprovider.NewTestCloudProvider(nil, nil)
+	provider.AddNodeGroup("ng1", 0, 10, 0)
+	provider.AddNodeGroup("ng2", 0, 10, 0)
+	options := []expander.Option{
+		{NodeGroup: provider.GetNodeGroup("ng1"), NodeCount: 1, Pods: []*apiv1.Pod{nil}},
+		{NodeGroup: provider.GetNodeGroup("ng2"), NodeCount: 1, Pods: []*apiv1.Pod{nil, nil}},
+	}
+
+	e, err := ExpanderStrategyFromString(expander.MostPodsExpanderName, provider, nil, "kube-system", nil, nil, "", external.DefaultTimeout)
+	assert.NoError(t, err)
+	assert.Equal(t, "ng2", e.BestOption(options, nil).NodeGroup.Id())
+
+	// The test provider has no pricing, so price is skipped.
+	e, err = ExpanderStrategyFromString("price, most-pods,random", provider, nil, "kube-system", nil, nil, "", external.DefaultTimeout)
+	assert.NoError(t, err)
+	best := e.BestOption(options, nil)
+	assert.Equal(t, "ng2", best.NodeGroup.Id())
+	assert.Contains(t, best.Debug, "expander chain: most-pods kept 1 of 2 [ng2]; random chose ng2")
+
+	_, err = ExpanderStrategyFromString(expander.PriceBasedExpanderName, provider, nil, "kube-system", nil, nil, "", external.DefaultTimeout)
+	assert.Error(t, err)
+	_, err = ExpanderStrategyFromString("random,most-pods", provider, nil, "kube-system", nil, nil, "", external.DefaultTimeout)
+	assert.Error(t, err)
+	_, err = ExpanderStrategyFromString("most-pods,unknown", provider, nil, "kube-system", nil, nil, "", external.DefaultTimeout)
+	assert.Error(t, err)
+	_, err = ExpanderStrategyFromString(expander.ExternalExpanderName, provider, nil, "kube-system", nil, nil, "", external.DefaultTimeout)
+	assert.Error(t, err)
+}
@@ -17,21 +17,96 @@ limitations under the License.
 package factory
 
 import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
 	"k8s.io/autoscaler/cluster-autoscaler/expander"
+	"k8s.io/autoscaler/cluster-autoscaler/expander/external"
 	"k8s.io/autoscaler/cluster-autoscaler/expander/mostpods"
 	"k8s.io/autoscaler/cluster-autoscaler/expander/price"
+	"k8s.io/autoscaler/cluster-autoscaler/expander/priority"
 	"k8s.io/autoscaler/cluster-autoscaler/expander/random"
 	"k8s.io/autoscaler/cluster-autoscaler/expander/waste"
 	"k8s.io/autoscaler/cluster-autoscaler/utils/errors"
 
 	kube_util "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
+	kube_client "k8s.io/client-go/kubernetes"
+	v1lister "k8s.io/client-go/listers/core/v1"
+	kube_record "k8s.io/client-go/tools/record"
+)
+
+type configMapListerKey struct {
+	kubeClient kube_client.Interface
+	namespace  string
+}
+
+var (
+	configMapListersMutex sync.Mutex
+	// configMapListers are shared by the expanders built for a namespace, so that the configmaps
+	// are watched once for as long as the autoscaler runs rather than once per built expander.
+	configMapListers = make(map[configMapListerKey]v1lister.ConfigMapNamespaceLister)
 )
 
-// ExpanderStrategyFromString creates an expander.Strategy according to its name
+// configMapLister returns the configmap lister of the namespace, building it on first use.
+func configMapLister(kubeClient kube_client.Interface, namespace string) v1lister.ConfigMapNamespaceLister {
+	configMapListersMutex.Lock()
+	defer configMapListersMutex.Unlock()
+	key := configMapListerKey{kubeClient: kubeClient, namespace: namespace}
+	lister, found := configMapListers[key]
+	if !found {
+		lister = kube_util.NewConfigMapLister(kubeClient, namespace, make(chan struct{}))
+		configMapListers[key] = lister
+	}
+	return lister
+}
+
+// ExpanderStrategyFromString creates an expander.Strategy according to its name, or a chain of
+// them from a comma-separated list of names, e.g. "price,least-waste,random". Every stage of a
+// chain but the last narrows down the options, and the last one chooses among the remaining ones.
 func ExpanderStrategyFromString(expanderFlag string, cloudProvider cloudprovider.CloudProvider,
-	nodeLister kube_util.NodeLister) (expander.Strategy, errors.AutoscalerError) {
-	switch expanderFlag {
+	kubeClient kube_client.Interface, configNamespace string, kubeEventRecorder kube_record.EventRecorder,
+	nodeLister kube_util.NodeLister, externalUrl string, externalTimeout time.Duration) (expander.Strategy, errors.AutoscalerError) {
+	names := strings.Split(expanderFlag, ",")
+	if len(names) == 1 {
+		return strategyFromName(expanderFlag, cloudProvider, kubeClient, configNamespace, kubeEventRecorder, nodeLister, externalUrl, externalTimeout)
+	}
+
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		if !isAvailable(names[i]) {
+			return nil, errors.NewAutoscalerError(errors.InternalError, "Expander %s not supported", names[i])
+		}
+		if names[i] == expander.RandomExpanderName && i != len(names)-1 {
+			return nil, errors.NewAutoscalerError(errors.InternalError, "Expander %s can only be the last of %s", names[i], expanderFlag)
+		}
+	}
+
+	// Filters that can't be created, e.g. price without a pricing model, are skipped so that the
+	// following stages act as their fallback.
+	var filters []chainFilter
+	for _, name := range names[:len(names)-1] {
+		filter, err := filterFromName(name, cloudProvider, kubeClient, configNamespace, kubeEventRecorder, nodeLister, externalUrl, externalTimeout)
+		if err != nil {
+			glog.Warningf("Skipping expander %s of %s: %v", name, expanderFlag, err)
+			continue
+		}
+		filters = append(filters, chainFilter{name: name, filter: filter})
+	}
+	lastName := names[len(names)-1]
+	last, err := strategyFromName(lastName, cloudProvider, kubeClient, configNamespace, kubeEventRecorder, nodeLister, externalUrl, externalTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return newChain(filters, lastName, last), nil
+}
+
+func strategyFromName(name string, cloudProvider cloudprovider.CloudProvider,
+	kubeClient kube_client.Interface, configNamespace string, kubeEventRecorder kube_record.EventRecorder,
+	nodeLister kube_util.NodeLister, externalUrl string, externalTimeout time.Duration) (expander.Strategy, errors.AutoscalerError) {
+	switch name {
 	case expander.RandomExpanderName:
 		return random.NewStrategy(), nil
 	case expander.MostPodsExpanderName:
@@ -46,6 +121,49 @@ func ExpanderStrategyFromString(expanderFlag string, cloudProvider cloudprovider
 		return price.NewStrategy(pricing,
 			price.NewSimplePreferredNodeProvider(nodeLister),
 			price.SimpleNodeUnfitness), nil
+	case expander.PriorityBasedExpanderName:
+		return priority.NewStrategy(random.NewStrategy(), configMapLister(kubeClient, configNamespace), kubeEventRecorder), nil
+	case expander.ExternalExpanderName:
+		if externalUrl == "" {
+			return nil, errors.NewAutoscalerError(errors.InternalError, "Expander %s requires an url", name)
+		}
+		return external.NewStrategy(externalUrl, externalTimeout, random.NewStrategy()), nil
+	}
+	return nil, errors.NewAutoscalerError(errors.InternalError, "Expander %s not supported", name)
+}
+
+func filterFromName(name string, cloudProvider cloudprovider.CloudProvider,
+	kubeClient kube_client.Interface, configNamespace string, kubeEventRecorder kube_record.EventRecorder,
+	nodeLister kube_util.NodeLister, externalUrl string, externalTimeout time.Duration) (expander.FilterStrategy, errors.AutoscalerError) {
+	switch name {
+	case expander.MostPodsExpanderName:
+		return mostpods.NewFilter(), nil
+	case expander.LeastWasteExpanderName:
+		return waste.NewFilter(), nil
+	case expander.PriceBasedExpanderName:
+		pricing, err := cloudProvider.Pricing()
+		if err != nil {
+			return nil, err
+		}
+		return price.NewFilter(pricing,
+			price.NewSimplePreferredNodeProvider(nodeLister),
+			price.SimpleNodeUnfitness), nil
+	case expander.PriorityBasedExpanderName:
+		return priority.NewFilter(configMapLister(kubeClient, configNamespace), kubeEventRecorder), nil
+	case expander.ExternalExpanderName:
+		if externalUrl == "" {
+			return nil, errors.NewAutoscalerError(errors.InternalError, "Expander %s requires an url", name)
+		}
+		return external.NewFilter(externalUrl, externalTimeout), nil
+	}
+	return nil, errors.NewAutoscalerError(errors.InternalError, "Expander %s not supported", name)
+}
+
+func isAvailable(name string) bool {
+	for _, available := range expander.AvailableExpanders {
+		if name == available {
+			return true
+		}
 	}
-	return nil, errors.NewAutoscalerError(errors.InternalError, "Expander %s not supported", expanderFlag)
+	return false
 }
@@ -305,3 +305,67 @@ func TestPriceExpander(t *testing.T) {
 		SimpleNodeUnfitness,
 	).BestOption(options3, nodeInfosForGroups).Debug, "ng3")
 }
+
+func TestPriceFilter(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	n2 := BuildTestNode("n2", 4000, 1000)
+	n3 := BuildTestNode("n3", 4000, 1000)
+
+	p1 := BuildTestPod("p1", 1000, 0)
+
+	provider := testprovider.NewTestCloudProvider(nil, nil)
+	for i, node := range []*apiv1.Node{n1, n2, n3} {
+		provider.AddNodeGroup(fmt.Sprintf("ng%d", i+1), 1, 10, 1)
+		provider.AddNode(fmt.Sprintf("ng%d", i+1), node)
+	}
+	nodeInfosForGroups := map[string]*schedulercache.NodeInfo{}
+	var options []expander.Option
+	for i, node := range []*apiv1.Node{n1, n2, n3} {
+		id := fmt.Sprintf("ng%d", i+1)
+		nodeInfo := schedulercache.NewNodeInfo()
+		nodeInfo.SetNode(node)
+		nodeInfosForGroups[id] = nodeInfo
+		options = append(options, expander.Option{
+			NodeGroup: provider.GetNodeGroup(id),
+			NodeCount: 1,
+			Pods:      []*apiv1.Pod{p1},
+			Debug:     id,
+		})
+	}
+
+	// The second and third node groups are equally cheap.
+	filter := NewFilter(
+		&testPricingModel{
+			podPrice: map[string]float64{
+				"p1":        20.0,
+				"stabilize": 10,
+			},
+			nodePrice: map[string]float64{
+				"n1": 200.0,
+				"n2": 40.0,
+				"n3": 40.0,
+			},
+		},
+		&testPreferredNodeProvider{
+			preferred: buildNode(2000, 1024*1024*1024),
+		},
+		SimpleNodeUnfitness,
+	)
+	best := filter.BestOptions(options, nodeInfosForGroups)
+	assert.Equal(t, 2, len(best))
+	assert.Equal(t, "ng2", best[0].NodeGroup.Id())
+	assert.Contains(t, best[0].Debug, "price-expander")
+	assert.Equal(t, "ng3", best[1].NodeGroup.Id())
+
+	// Options that can't be priced are left out.
+	assert.Empty(t, NewFilter(
+		&testPricingModel{
+			podPrice:  map[string]float64{},
+			nodePrice: map[string]float64{},
+		},
+		&testPreferredNodeProvider{
+			preferred: buildNode(2000, 1024*1024*1024),
+		},
+		SimpleNodeUnfitness,
+	).BestOptions(options, nodeInfosForGroups))
+}
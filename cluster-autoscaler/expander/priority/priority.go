@@ -0,0 +1,181 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priority
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/golang/glog"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/autoscaler/cluster-autoscaler/expander"
+	v1lister "k8s.io/client-go/listers/core/v1"
+	kube_record "k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
+)
+
+const (
+	// PriorityConfigMapName is the name of the configmap holding the priorities of node groups.
+	PriorityConfigMapName = "cluster-autoscaler-priority-expander"
+	// ConfigMapKey is the key of the priorities in the configmap.
+	ConfigMapKey = "priorities"
+)
+
+// priorities maps priorities to the regexps of the node group ids they apply to.
+type priorities map[int][]*regexp.Regexp
+
+type priority struct {
+	fallbackStrategy expander.Strategy
+	configMapLister  v1lister.ConfigMapNamespaceLister
+	recorder         kube_record.EventRecorder
+	// priorities parsed from the configmap at resourceVersion.
+	priorities      priorities
+	resourceVersion string
+}
+
+// NewStrategy returns a strategy that selects the node group with the highest priority. Priorities
+// are read from the configmap every time they change, and ties are broken by fallbackStrategy.
+func NewStrategy(fallbackStrategy expander.Strategy, configMapLister v1lister.ConfigMapNamespaceLister,
+	recorder kube_record.EventRecorder) expander.Strategy {
+	return &priority{
+		fallbackStrategy: fallbackStrategy,
+		configMapLister:  configMapLister,
+		recorder:         recorder,
+	}
+}
+
+// NewFilter returns a filter keeping the node groups with the highest priority, read from the
+// configmap every time they change.
+func NewFilter(configMapLister v1lister.ConfigMapNamespaceLister, recorder kube_record.EventRecorder) expander.FilterStrategy {
+	return &priority{
+		configMapLister: configMapLister,
+		recorder:        recorder,
+	}
+}
+
+// BestOption selects an option of the node groups with the highest priority.
+func (p *priority) BestOption(expansionOptions []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) *expander.Option {
+	bestOptions := p.BestOptions(expansionOptions, nodeInfo)
+	if len(bestOptions) == 0 {
+		return nil
+	}
+	return p.fallbackStrategy.BestOption(bestOptions, nodeInfo)
+}
+
+// BestOptions selects the options of the node groups with the highest priority. Node groups matching
+// no pattern are only kept if no node group matches, as are all node groups without priorities.
+func (p *priority) BestOptions(expansionOptions []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) []expander.Option {
+	if len(expansionOptions) == 0 {
+		return nil
+	}
+	if err := p.reloadPriorities(); err != nil {
+		glog.Warningf("Failed to reload node group priorities: %v", err)
+	}
+
+	var bestPriority int
+	var bestOptions []expander.Option
+	for _, option := range expansionOptions {
+		id := option.NodeGroup.Id()
+		optionPriority, found := p.priorities.of(id)
+		if !found {
+			glog.V(4).Infof("No priority for node group %s", id)
+			continue
+		}
+		if bestOptions == nil || optionPriority > bestPriority {
+			bestPriority = optionPriority
+			bestOptions = []expander.Option{option}
+		} else if optionPriority == bestPriority {
+			bestOptions = append(bestOptions, option)
+		}
+	}
+
+	if len(bestOptions) == 0 {
+		glog.V(2).Infof("No node group with a priority, keeping all of them")
+		return expansionOptions
+	}
+	glog.V(2).Infof("%d node groups of priority %d", len(bestOptions), bestPriority)
+	return bestOptions
+}
+
+// reloadPriorities parses the priorities of the configmap if it changed. The last valid
+// priorities are kept if it is missing or invalid.
+func (p *priority) reloadPriorities() error {
+	cm, err := p.configMapLister.Get(PriorityConfigMapName)
+	if err != nil {
+		return fmt.Errorf("failed to get configmap %s: %v", PriorityConfigMapName, err)
+	}
+	if cm.ResourceVersion != "" && cm.ResourceVersion == p.resourceVersion {
+		return nil
+	}
+
+	parsed, err := parsePriorities(cm.Data[ConfigMapKey])
+	if err != nil {
+		p.recorder.Eventf(cm, apiv1.EventTypeWarning, "PriorityConfigMapInvalid",
+			"cluster-autoscaler failed to load node group priorities: %v", err)
+		return fmt.Errorf("invalid configmap %s: %v", PriorityConfigMapName, err)
+	}
+	glog.V(1).Infof("Loaded node group priorities of configmap %s version %s", PriorityConfigMapName, cm.ResourceVersion)
+	p.priorities = parsed
+	p.resourceVersion = cm.ResourceVersion
+	return nil
+}
+
+// parsePriorities parses a yaml map of integer priorities to lists of regexps, e.g.
+//
+//	10:
+//	  - .*-on-demand-.*
+//	50:
+//	  - .*-spot-.*
+//
+// Regexps must match the whole node group id.
+func parsePriorities(value string) (priorities, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, fmt.Errorf("no %s", ConfigMapKey)
+	}
+	var patterns map[int][]string
+	if err := yaml.NewYAMLOrJSONDecoder(strings.NewReader(value), 4096).Decode(&patterns); err != nil {
+		return nil, err
+	}
+	result := make(priorities, len(patterns))
+	for priority, expressions := range patterns {
+		for _, expression := range expressions {
+			re, err := regexp.Compile("^(?:" + expression + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("priority %d: %v", priority, err)
+			}
+			result[priority] = append(result[priority], re)
+		}
+	}
+	return result, nil
+}
+
+// of returns the highest priority whose regexps match the node group id.
+func (ps priorities) of(id string) (int, bool) {
+	var result int
+	found := false
+	for priority, regexps := range ps {
+		for _, re := range regexps {
+			if re.MatchString(id) && (!found || priority > result) {
+				result = priority
+				found = true
+			}
+		}
+	}
+	return result, found
+}
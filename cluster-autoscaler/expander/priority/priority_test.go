@@ -0,0 +1,111 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priority
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testprovider "k8s.io/autoscaler/cluster-autoscaler/cloudprovider/test"
+	"k8s.io/autoscaler/cluster-autoscaler/expander"
+	v1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	kube_record "k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/scheduler/schedulercache"
+)
+
+// firstOption picks the first of the options, so that ties are broken predictably.
+type firstOption struct{}
+
+func (firstOption) BestOption(options []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) *expander.Option {
+	return &options[0]
+}
+
+func buildConfigMap(version string, priorities string) *apiv1.ConfigMap {
+	return &apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            PriorityConfigMapName,
+			Namespace:       "kube-system",
+			ResourceVersion: version,
+		},
+		Data: map[string]string{ConfigMapKey: priorities},
+	}
+}
+
+func TestPriority(t *testing.T) {
+	provider := testprovider.NewTestCloudProvider(nil, nil)
+	for _, id := range []string{"ondemand-1", "spot-1", "spot-2", "reserved-1"} {
+		provider.AddNodeGroup(id, 0, 10, 0)
+	}
+	option := func(id string) expander.Option {
+		return expander.Option{NodeGroup: provider.GetNodeGroup(id), NodeCount: 1}
+	}
+	ondemand, spot1, spot2, reserved := option("ondemand-1"), option("spot-1"), option("spot-2"), option("reserved-1")
+
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	recorder := kube_record.NewFakeRecorder(5)
+	e := NewStrategy(firstOption{}, v1lister.NewConfigMapLister(store).ConfigMaps("kube-system"), recorder)
+
+	// Without priorities every option is considered.
+	assert.Equal(t, ondemand, *e.BestOption([]expander.Option{ondemand, spot1}, nil))
+	assert.Nil(t, e.BestOption([]expander.Option{}, nil))
+
+	assert.NoError(t, store.Add(buildConfigMap("1", `
+10:
+  - ondemand-.*
+50:
+  - spot-.*
+  - reserved-.*
+`)))
+	assert.Equal(t, spot1, *e.BestOption([]expander.Option{ondemand, spot1, spot2}, nil))
+	assert.Equal(t, reserved, *e.BestOption([]expander.Option{ondemand, reserved, spot1}, nil))
+	assert.Equal(t, ondemand, *e.BestOption([]expander.Option{ondemand}, nil))
+
+	// Changes are picked up without a restart, and patterns match whole ids.
+	assert.NoError(t, store.Update(buildConfigMap("2", `
+100:
+  - reserved-.*
+50:
+  - spot-2
+`)))
+	assert.Equal(t, reserved, *e.BestOption([]expander.Option{spot1, spot2, reserved}, nil))
+	assert.Equal(t, spot2, *e.BestOption([]expander.Option{spot1, spot2}, nil))
+	assert.Equal(t, spot1, *e.BestOption([]expander.Option{spot1, ondemand}, nil))
+
+	// Invalid priorities are reported, and the last valid ones kept.
+	assert.NoError(t, store.Update(buildConfigMap("3", "100: [\"reserved-(\"]")))
+	assert.Equal(t, reserved, *e.BestOption([]expander.Option{spot1, reserved}, nil))
+	assert.Contains(t, <-recorder.Events, "PriorityConfigMapInvalid")
+}
+
+func TestParsePriorities(t *testing.T) {
+	parsed, err := parsePriorities(`{"10": ["a.*"], "20": ["b", "c"]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(parsed))
+	priority, found := parsed.of("c")
+	assert.True(t, found)
+	assert.Equal(t, 20, priority)
+	_, found = parsed.of("xa")
+	assert.False(t, found)
+
+	_, err = parsePriorities("")
+	assert.Error(t, err)
+	_, err = parsePriorities("high: [a]")
+	assert.Error(t, err)
+}
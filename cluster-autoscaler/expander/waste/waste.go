@@ -34,8 +34,23 @@ func NewStrategy() expander.Strategy {
 	return &leastwaste{random.NewStrategy()}
 }
 
+// NewFilter returns a filter keeping the node groups that waste the least fraction of CPU and Memory
+func NewFilter() expander.FilterStrategy {
+	return &leastwaste{}
+}
+
 // BestOption Finds the option that wastes the least fraction of CPU and Memory
 func (l *leastwaste) BestOption(expansionOptions []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) *expander.Option {
+	leastWastedOptions := l.BestOptions(expansionOptions, nodeInfo)
+	if len(leastWastedOptions) == 0 {
+		return nil
+	}
+
+	return l.fallbackStrategy.BestOption(leastWastedOptions, nodeInfo)
+}
+
+// BestOptions Finds the options that waste the least fraction of CPU and Memory
+func (l *leastwaste) BestOptions(expansionOptions []expander.Option, nodeInfo map[string]*schedulercache.NodeInfo) []expander.Option {
 	var leastWastedScore float64
 	var leastWastedOptions []expander.Option
 
@@ -66,11 +81,7 @@ func (l *leastwaste) BestOption(expansionOptions []expander.Option, nodeInfo map
 		}
 	}
 
-	if len(leastWastedOptions) == 0 {
-		return nil
-	}
-
-	return l.fallbackStrategy.BestOption(leastWastedOptions, nodeInfo)
+	return leastWastedOptions
 }
 
 func resourcesForPods(pods []*apiv1.Pod) (cpu resource.Quantity, memory resource.Quantity) {
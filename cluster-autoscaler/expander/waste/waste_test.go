@@ -116,3 +116,39 @@ func TestLeastWaste(t *testing.T) {
 	ret = e.BestOption([]expander.Option{balancedOption, highmemOption, lowcpuOption}, nodeMap)
 	assert.Equal(t, *ret, lowcpuOption)
 }
+
+func TestLeastWasteFilter(t *testing.T) {
+	cpuPerPod := int64(500)
+	memoryPerPod := int64(1000 * 1024 * 1024)
+	f := NewFilter()
+	nodeMap := map[string]*schedulercache.NodeInfo{
+		"balanced":   makeNodeInfo(16*cpuPerPod, 16*memoryPerPod, 100),
+		"balanced-2": makeNodeInfo(16*cpuPerPod, 16*memoryPerPod, 100),
+		"highmem":    makeNodeInfo(16*cpuPerPod, 32*memoryPerPod, 100),
+	}
+	pod := &apiv1.Pod{
+		Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{
+				{
+					Resources: apiv1.ResourceRequirements{
+						Requests: apiv1.ResourceList{
+							apiv1.ResourceCPU:    *resource.NewMilliQuantity(cpuPerPod, resource.DecimalSI),
+							apiv1.ResourceMemory: *resource.NewQuantity(memoryPerPod, resource.DecimalSI),
+						},
+					},
+				},
+			},
+		},
+	}
+	pods := []*apiv1.Pod{pod}
+	balancedOption := expander.Option{NodeGroup: &FakeNodeGroup{"balanced"}, NodeCount: 1, Pods: pods}
+	balanced2Option := expander.Option{NodeGroup: &FakeNodeGroup{"balanced-2"}, NodeCount: 1, Pods: pods}
+	highmemOption := expander.Option{NodeGroup: &FakeNodeGroup{"highmem"}, NodeCount: 1, Pods: pods}
+	unknownOption := expander.Option{NodeGroup: &FakeNodeGroup{"unknown"}, NodeCount: 1, Pods: pods}
+
+	// Equally wasteful node groups are all kept, and ones without node info left out.
+	ret := f.BestOptions([]expander.Option{highmemOption, balancedOption, unknownOption, balanced2Option}, nodeMap)
+	assert.Equal(t, []expander.Option{balancedOption, balanced2Option}, ret)
+
+	assert.Empty(t, f.BestOptions([]expander.Option{unknownOption}, nodeMap))
+}
@@ -30,12 +30,14 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kube_flag "k8s.io/apiserver/pkg/util/flag"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/aws"
 	cloudBuilder "k8s.io/autoscaler/cluster-autoscaler/cloudprovider/builder"
 	"k8s.io/autoscaler/cluster-autoscaler/config"
 	"k8s.io/autoscaler/cluster-autoscaler/config/dynamic"
 	"k8s.io/autoscaler/cluster-autoscaler/core"
 	"k8s.io/autoscaler/cluster-autoscaler/estimator"
 	"k8s.io/autoscaler/cluster-autoscaler/expander"
+	"k8s.io/autoscaler/cluster-autoscaler/expander/external"
 	"k8s.io/autoscaler/cluster-autoscaler/metrics"
 	"k8s.io/autoscaler/cluster-autoscaler/simulator"
 	"k8s.io/autoscaler/cluster-autoscaler/utils/errors"
@@ -68,6 +70,7 @@ func (flag *MultiStringFlag) Set(value string) error {
 var (
 	nodeGroupsFlag             MultiStringFlag
 	nodeGroupAutoDiscoveryFlag MultiStringFlag
+	nodeGroupHeadroomFlag      MultiStringFlag
 
 	clusterName            = flag.String("cluster-name", "", "Autoscaled cluster name, if available")
 	address                = flag.String("address", ":8085", "The address to expose prometheus metrics.")
@@ -121,17 +124,29 @@ var (
 		"Type of resource estimator to be used in scale up. Available values: ["+strings.Join(estimator.AvailableEstimators, ",")+"]")
 
 	expanderFlag = flag.String("expander", expander.RandomExpanderName,
-		"Type of node group expander to be used in scale up. Available values: ["+strings.Join(expander.AvailableExpanders, ",")+"]")
+		"Type of node group expander to be used in scale up, or a comma-separated chain of them, e.g. price,least-waste,random. Available values: ["+strings.Join(expander.AvailableExpanders, ",")+"]")
+	externalExpanderUrl     = flag.String("external-expander-url", "", "Url the external expander posts scale-up options to")
+	externalExpanderTimeout = flag.Duration("external-expander-timeout", external.DefaultTimeout, "Timeout of a call to the external expander, after which it falls back to a local strategy")
 
 	writeStatusConfigMapFlag         = flag.Bool("write-status-configmap", true, "Should CA write status information to a configmap")
 	maxInactivityTimeFlag            = flag.Duration("max-inactivity", 10*time.Minute, "Maximum time from last recorded autoscaler activity before automatic restart")
 	maxFailingTimeFlag               = flag.Duration("max-failing-time", 15*time.Minute, "Maximum time from last recorded successful autoscaler run before automatic restart")
+	maxRunTimeFlag                   = flag.Duration("max-run-time", 0, "Maximum time CA runs before restarting gracefully, once no node is being deleted. 0 to never restart")
 	balanceSimilarNodeGroupsFlag     = flag.Bool("balance-similar-node-groups", false, "Detect similar node groups and balance the number of nodes between them")
 	nodeAutoprovisioningEnabled      = flag.Bool("node-autoprovisioning-enabled", false, "Should CA autoprovision node groups when needed")
 	maxAutoprovisionedNodeGroupCount = flag.Int("max-autoprovisioned-node-group-count", 15, "The maximum number of autoprovisioned groups in the cluster.")
 
 	expendablePodsPriorityCutoff = flag.Int("expendable-pods-priority-cutoff", 0, "Pods with priority below cutoff will be expendable. They can be killed without any consideration during scale down and they don't cause scale up. Pods with null priority (PodPriority disabled) are non expendable.")
 	regional                     = flag.Bool("regional", false, "Cluster is regional.")
+
+	spotInterruptionHandlingEnabled = flag.Bool("spot-interruption-handling", false, "Should CA replace and drain nodes that received a spot interruption notice")
+	spotInterruptionTaint           = flag.String("spot-interruption-taint", core.DefaultSpotInterruptionTaint, "Taint key set by a termination handler on nodes that received a spot interruption notice. Empty to ignore taints.")
+	spotInterruptionCondition       = flag.String("spot-interruption-condition", core.DefaultSpotInterruptionCondition, "Node condition set to true by a termination handler on nodes that received a spot interruption notice. Empty to ignore conditions.")
+
+	scaleDownPayModelPolicy = flag.String("scale-down-pay-model-policy", core.NoPayModelPolicy,
+		"How scale down picks between spot and on-demand nodes, based on the "+aws.PayModelLabel+" label. Empty to ignore pay models. Available values: ["+strings.Join(core.AvailablePayModelPolicies, ",")+"]")
+	maxSpotPercentTotal     = flag.Float64("max-spot-percent-total", -1, "Scale down won't raise the percentage of the cores of a node group on spot nodes above this value. Negative to use the limits of the cloud provider (AutoScalr), or 100 if it has none")
+	maxSpotPercentOneMarket = flag.Float64("max-spot-percent-one-market", -1, "Scale down won't raise the percentage of the cores of a node group in a single spot market above this value. Negative to use the limits of the cloud provider (AutoScalr), or 100 if it has none")
 )
 
 func createAutoscalerOptions() core.AutoscalerOptions {
@@ -155,6 +170,8 @@ func createAutoscalerOptions() core.AutoscalerOptions {
 		OkTotalUnreadyCount:              *okTotalUnreadyCount,
 		EstimatorName:                    *estimatorFlag,
 		ExpanderName:                     *expanderFlag,
+		ExternalExpanderUrl:              *externalExpanderUrl,
+		ExternalExpanderTimeout:          *externalExpanderTimeout,
 		MaxEmptyBulkDelete:               *maxEmptyBulkDeleteFlag,
 		MaxGracefulTerminationSec:        *maxGracefulTerminationFlag,
 		MaxNodeProvisionTime:             *maxNodeProvisionTime,
@@ -182,6 +199,13 @@ func createAutoscalerOptions() core.AutoscalerOptions {
 		MaxAutoprovisionedNodeGroupCount: *maxAutoprovisionedNodeGroupCount,
 		ExpendablePodsPriorityCutoff:     *expendablePodsPriorityCutoff,
 		Regional:                         *regional,
+		SpotInterruptionHandlingEnabled:  *spotInterruptionHandlingEnabled,
+		SpotInterruptionTaint:            *spotInterruptionTaint,
+		SpotInterruptionCondition:        *spotInterruptionCondition,
+		ScaleDownPayModelPolicy:          *scaleDownPayModelPolicy,
+		MaxSpotPercentTotal:              *maxSpotPercentTotal,
+		MaxSpotPercentOneMarket:          *maxSpotPercentOneMarket,
+		NodeGroupHeadroom:                nodeGroupHeadroomFlag,
 	}
 
 	configFetcherOpts := dynamic.ConfigFetcherOptions{
@@ -237,6 +261,20 @@ func registerSignalHandlers(autoscaler core.Autoscaler) {
 	}()
 }
 
+// restartWhenIdle exits so that CA is restarted, unless nodes are being deleted. In that case
+// it is called again after the next loop.
+func restartWhenIdle(autoscaler core.Autoscaler) {
+	metrics.UpdateGracefulRestartPending(true)
+	if autoscaler.IsDeleteInProgress() {
+		glog.V(1).Infof("Max run time of %v reached, waiting for node deletions to finish before restarting", *maxRunTimeFlag)
+		return
+	}
+	glog.V(0).Infof("Max run time of %v reached, restarting", *maxRunTimeFlag)
+	autoscaler.ExitCleanUp()
+	glog.Flush()
+	os.Exit(0)
+}
+
 func run(healthCheck *metrics.HealthCheck) {
 	metrics.RegisterAll()
 	kubeClient := createKubeClient()
@@ -257,6 +295,7 @@ func run(healthCheck *metrics.HealthCheck) {
 	autoscaler.CleanUp()
 	registerSignalHandlers(autoscaler)
 	healthCheck.StartMonitoring()
+	runStart := time.Now()
 
 	for {
 		select {
@@ -274,6 +313,10 @@ func run(healthCheck *metrics.HealthCheck) {
 				}
 
 				metrics.UpdateDurationFromStart(metrics.Main, loopStart)
+
+				if *maxRunTimeFlag > 0 && time.Now().Sub(runStart) > *maxRunTimeFlag {
+					restartWhenIdle(autoscaler)
+				}
 			}
 		}
 	}
@@ -288,9 +331,12 @@ func main() {
 		"Can be used multiple times. Format: <min>:<max>:<other...>")
 	flag.Var(&nodeGroupAutoDiscoveryFlag, "node-group-auto-discovery", "One or more definition(s) of node group auto-discovery. "+
 		"A definition is expressed `<name of discoverer>:[<key>[=<value>]]`. "+
-		"The `aws` and `gce` cloud providers are currently supported. AWS matches by ASG tags, e.g. `asg:tag=tagKey,anotherTagKey`. "+
+		"The `aws` and `gce` cloud providers are currently supported. AWS matches by ASG tags, e.g. `asg:tag=tagKey,anotherTagKey=value,!excludedTagKey`. "+
 		"GCE matches by IG name prefix, and requires you to specify min and max nodes per IG, e.g. `mig:namePrefix=pfx,min=0,max=10` "+
 		"Can be used multiple times.")
+	flag.Var(&nodeGroupHeadroomFlag, "node-group-headroom", "Spare capacity kept in a node group by scaling it up ahead of pending pods. "+
+		"Format: `<node group>:cpu=<quantity>,memory=<quantity>` for spare capacity, or `<node group>:pods=<count>,cpu=<quantity>,memory=<quantity>` "+
+		"for spare pods of the given shape. Can be used multiple times.")
 	kube_flag.InitFlags()
 
 	healthCheck := metrics.NewHealthCheck(*maxInactivityTimeFlag, *maxFailingTimeFlag)
@@ -38,6 +38,9 @@ type FunctionLabel string
 // NodeGroupType describes node group relation to CA
 type NodeGroupType string
 
+// AwsRequestResult describes the outcome of an AWS API request
+type AwsRequestResult string
+
 const (
 	caNamespace           = "cluster_autoscaler"
 	readyLabel            = "ready"
@@ -52,12 +55,21 @@ const (
 	Empty NodeScaleDownReason = "empty"
 	// Unready node was removed
 	Unready NodeScaleDownReason = "unready"
+	// SpotInterrupted node was removed ahead of a spot interruption
+	SpotInterrupted NodeScaleDownReason = "spot_interrupted"
 
 	// APIError caused scale-up to fail
 	APIError FailedScaleUpReason = "apiCallError"
 	// Timeout was encountered when trying to scale-up
 	Timeout FailedScaleUpReason = "timeout"
 
+	// AwsRequestSuccess is a successful AWS API request
+	AwsRequestSuccess AwsRequestResult = "success"
+	// AwsRequestThrottled is an AWS API request rejected by request throttling
+	AwsRequestThrottled AwsRequestResult = "throttled"
+	// AwsRequestError is an AWS API request failed for any other reason
+	AwsRequestError AwsRequestResult = "error"
+
 	// autoscaledGroup is managed by CA
 	autoscaledGroup NodeGroupType = "autoscaled"
 	// autoprovisionedGroup have been created by CA (Node Autoprovisioning),
@@ -212,6 +224,40 @@ var (
 			Help:      "Number of node groups deleted by Node Autoprovisioning.",
 		},
 	)
+
+	gracefulRestartPending = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: caNamespace,
+			Name:      "graceful_restart_pending",
+			Help:      "Whether CA reached its max run time and restarts once no node is being deleted. 1 if it does, 0 otherwise.",
+		},
+	)
+
+	spotInterruptionsCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: caNamespace,
+			Name:      "spot_interruptions_total",
+			Help:      "Number of spot interruption notices handled by CA.",
+		},
+	)
+
+	/**** Metrics related to cloud provider APIs ****/
+	awsRequestsCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: caNamespace,
+			Name:      "aws_requests_total",
+			Help:      "Number of AWS API requests by operation and result, retries counted as requests.",
+		}, []string{"operation", "result"},
+	)
+
+	awsRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: caNamespace,
+			Name:      "aws_request_duration_seconds",
+			Help:      "Time taken by AWS API requests.",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0},
+		}, []string{"operation"},
+	)
 )
 
 // RegisterAll registers all metrics.
@@ -231,6 +277,10 @@ func RegisterAll() {
 	prometheus.MustRegister(napEnabled)
 	prometheus.MustRegister(nodeGroupCreationCount)
 	prometheus.MustRegister(nodeGroupDeletionCount)
+	prometheus.MustRegister(spotInterruptionsCount)
+	prometheus.MustRegister(gracefulRestartPending)
+	prometheus.MustRegister(awsRequestsCount)
+	prometheus.MustRegister(awsRequestDuration)
 }
 
 // UpdateDurationFromStart records the duration of the step identified by the
@@ -324,6 +374,15 @@ func UpdateNapEnabled(enabled bool) {
 	}
 }
 
+// UpdateGracefulRestartPending records if CA is waiting to restart
+func UpdateGracefulRestartPending(pending bool) {
+	if pending {
+		gracefulRestartPending.Set(1)
+	} else {
+		gracefulRestartPending.Set(0)
+	}
+}
+
 // RegisterNodeGroupCreation registers node group creation
 func RegisterNodeGroupCreation() {
 	nodeGroupCreationCount.Add(1.0)
@@ -333,3 +392,14 @@ func RegisterNodeGroupCreation() {
 func RegisterNodeGroupDeletion() {
 	nodeGroupDeletionCount.Add(1.0)
 }
+
+// RegisterSpotInterruption registers a spot interruption notice
+func RegisterSpotInterruption() {
+	spotInterruptionsCount.Add(1.0)
+}
+
+// RegisterAwsRequest records an AWS API request and its duration
+func RegisterAwsRequest(operation string, result AwsRequestResult, duration time.Duration) {
+	awsRequestsCount.WithLabelValues(operation, string(result)).Inc()
+	awsRequestDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
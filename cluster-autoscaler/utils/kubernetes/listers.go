@@ -17,21 +17,31 @@ limitations under the License.
 package kubernetes
 
 import (
+	"sync"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
 	extensionsv1 "k8s.io/api/extensions/v1beta1"
 	policyv1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
 	client "k8s.io/client-go/kubernetes"
+	v1appslister "k8s.io/client-go/listers/apps/v1"
 	v1lister "k8s.io/client-go/listers/core/v1"
 	v1extensionslister "k8s.io/client-go/listers/extensions/v1beta1"
 	v1policylister "k8s.io/client-go/listers/policy/v1beta1"
 	"k8s.io/client-go/tools/cache"
 	podv1 "k8s.io/kubernetes/pkg/api/v1/pod"
+
+	"github.com/golang/glog"
 )
 
+// lazyListerSyncTimeout is how long the first List of a lazily started lister waits for the
+// objects to be listed.
+const lazyListerSyncTimeout = 30 * time.Second
+
 // ListerRegistry is a registry providing various listers to list pods or nodes matching conditions
 type ListerRegistry interface {
 	AllNodeLister() NodeLister
@@ -40,6 +50,7 @@ type ListerRegistry interface {
 	UnschedulablePodLister() PodLister
 	PodDisruptionBudgetLister() PodDisruptionBudgetLister
 	DaemonSetLister() DaemonSetLister
+	DeploymentLister() DeploymentLister
 }
 
 type listerRegistryImpl struct {
@@ -49,12 +60,13 @@ type listerRegistryImpl struct {
 	unschedulablePodLister    PodLister
 	podDisruptionBudgetLister PodDisruptionBudgetLister
 	daemonSetLister           DaemonSetLister
+	deploymentLister          DeploymentLister
 }
 
 // NewListerRegistry returns a registry providing various listers to list pods or nodes matching conditions
 func NewListerRegistry(allNode NodeLister, readyNode NodeLister, scheduledPod PodLister,
 	unschedulablePod PodLister, podDisruptionBudgetLister PodDisruptionBudgetLister,
-	daemonSetLister DaemonSetLister) ListerRegistry {
+	daemonSetLister DaemonSetLister, deploymentLister DeploymentLister) ListerRegistry {
 	return listerRegistryImpl{
 		allNodeLister:             allNode,
 		readyNodeLister:           readyNode,
@@ -62,6 +74,7 @@ func NewListerRegistry(allNode NodeLister, readyNode NodeLister, scheduledPod Po
 		unschedulablePodLister:    unschedulablePod,
 		podDisruptionBudgetLister: podDisruptionBudgetLister,
 		daemonSetLister:           daemonSetLister,
+		deploymentLister:          deploymentLister,
 	}
 }
 
@@ -73,8 +86,9 @@ func NewListerRegistryWithDefaultListers(kubeClient client.Interface, stopChanne
 	allNodeLister := NewAllNodeLister(kubeClient, stopChannel)
 	podDisruptionBudgetLister := NewPodDisruptionBudgetLister(kubeClient, stopChannel)
 	daemonSetLister := NewDaemonSetLister(kubeClient, stopChannel)
+	deploymentLister := newLazyDeploymentLister(kubeClient, stopChannel)
 	return NewListerRegistry(allNodeLister, readyNodeLister, scheduledPodLister,
-		unschedulablePodLister, podDisruptionBudgetLister, daemonSetLister)
+		unschedulablePodLister, podDisruptionBudgetLister, daemonSetLister, deploymentLister)
 }
 
 // AllNodeLister returns the AllNodeLister registered to this registry
@@ -107,6 +121,11 @@ func (r listerRegistryImpl) DaemonSetLister() DaemonSetLister {
 	return r.daemonSetLister
 }
 
+// DeploymentLister returns the deploymentLister registered to this registry
+func (r listerRegistryImpl) DeploymentLister() DeploymentLister {
+	return r.deploymentLister
+}
+
 // PodLister lists pods.
 type PodLister interface {
 	List() ([]*apiv1.Pod, error)
@@ -296,3 +315,76 @@ func NewDaemonSetLister(kubeClient client.Interface, stopchannel <-chan struct{}
 		daemonSetLister: lister,
 	}
 }
+
+// DeploymentLister lists deployments.
+type DeploymentLister interface {
+	List() ([]*appsv1.Deployment, error)
+}
+
+// DeploymentListerImpl lists all deployments.
+type DeploymentListerImpl struct {
+	deploymentLister v1appslister.DeploymentLister
+}
+
+// List returns all deployments
+func (lister *DeploymentListerImpl) List() ([]*appsv1.Deployment, error) {
+	return lister.deploymentLister.List(labels.Everything())
+}
+
+// NewDeploymentLister builds a deployment lister.
+func NewDeploymentLister(kubeClient client.Interface, stopchannel <-chan struct{}) DeploymentLister {
+	lister, _ := newDeploymentLister(kubeClient, stopchannel)
+	return lister
+}
+
+func newDeploymentLister(kubeClient client.Interface, stopchannel <-chan struct{}) (*DeploymentListerImpl, *cache.Reflector) {
+	listWatcher := cache.NewListWatchFromClient(kubeClient.AppsV1().RESTClient(), "deployments", apiv1.NamespaceAll, fields.Everything())
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	lister := v1appslister.NewDeploymentLister(store)
+	reflector := cache.NewReflector(listWatcher, &appsv1.Deployment{}, store, time.Hour)
+	go reflector.Run(stopchannel)
+	return &DeploymentListerImpl{
+		deploymentLister: lister,
+	}, reflector
+}
+
+// lazyDeploymentLister only starts watching deployments when first listed, so that clusters
+// whose cloud provider doesn't need deployments neither grant access to them nor cache them.
+type lazyDeploymentLister struct {
+	once        sync.Once
+	kubeClient  client.Interface
+	stopChannel <-chan struct{}
+	lister      DeploymentLister
+}
+
+func newLazyDeploymentLister(kubeClient client.Interface, stopChannel <-chan struct{}) DeploymentLister {
+	return &lazyDeploymentLister{
+		kubeClient:  kubeClient,
+		stopChannel: stopChannel,
+	}
+}
+
+// List returns all deployments. The first call waits for the deployments to be listed once.
+func (lister *lazyDeploymentLister) List() ([]*appsv1.Deployment, error) {
+	lister.once.Do(func() {
+		deploymentLister, reflector := newDeploymentLister(lister.kubeClient, lister.stopChannel)
+		err := wait.PollImmediate(100*time.Millisecond, lazyListerSyncTimeout, func() (bool, error) {
+			return reflector.LastSyncResourceVersion() != "", nil
+		})
+		if err != nil {
+			glog.Warningf("Deployments not listed after %v, they may be missing until they are", lazyListerSyncTimeout)
+		}
+		lister.lister = deploymentLister
+	})
+	return lister.lister.List()
+}
+
+// NewConfigMapLister builds a configmap lister of the namespace.
+func NewConfigMapLister(kubeClient client.Interface, namespace string, stopchannel <-chan struct{}) v1lister.ConfigMapNamespaceLister {
+	listWatcher := cache.NewListWatchFromClient(kubeClient.CoreV1().RESTClient(), "configmaps", namespace, fields.Everything())
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	lister := v1lister.NewConfigMapLister(store)
+	reflector := cache.NewReflector(listWatcher, &apiv1.ConfigMap{}, store, time.Hour)
+	go reflector.Run(stopchannel)
+	return lister.ConfigMaps(namespace)
+}